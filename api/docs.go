@@ -1,30 +1,107 @@
-// Package docs Code generated by swaggo/swag. DO NOT EDIT
+// Package docs holds the OpenAPI document of the API, it is written by hand and is the source of truth for the routes
 package docs
 
 import (
-	"os"
-	"path"
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"strings"
 
+	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/swaggo/swag"
 )
 
+//go:generate go run ./internal/fetchredoc
+
+// openAPIYAML is the OpenAPI document, go:embed fails the build when it is missing
+//
+//go:embed openapi.yaml
+var openAPIYAML string
+
+//go:embed redoc
+var redoc embed.FS
+
+const (
+	// ReDocBundle is the file ReDoc is loaded from, it is embedded once fetched with go generate
+	ReDocBundle = "redoc.standalone.js"
+	// ReDocChecksumFile pins the SHA-256 of the bundle, in the format of sha256sum
+	ReDocChecksumFile = "redoc.standalone.js.sha256"
+)
+
 func init() {
-	dir, _ := os.Getwd()
-	docPath := path.Join(dir, "api", "swagger.yaml")
-	docContent, _ := os.ReadFile(docPath)
-
-	// SwaggerInfo holds exported Swagger Info so clients can modify it
-	var SwaggerInfo = &swag.Spec{
-		Version:          "1.0",
-		Host:             "localhost:8080",
-		BasePath:         "/v1/meme-coin/",
-		Schemes:          []string{},
-		Title:            "MemeCoin API",
-		Description:      "This is a simple API for MemeCoin",
-		InfoInstanceName: "swagger",
-		SwaggerTemplate:  string(docContent),
+	if strings.TrimSpace(openAPIYAML) == "" {
+		panic("api/openapi.yaml is empty")
+	}
+
+	// Swagger UI reads the document from the swag registry, it renders OpenAPI 3 as is
+	swag.Register(swag.Name, &swag.Spec{
+		InfoInstanceName: swag.Name,
+		SwaggerTemplate:  openAPIYAML,
 		LeftDelim:        "{{",
 		RightDelim:       "}}",
+	})
+}
+
+// Spec returns the OpenAPI document as YAML
+func Spec() []byte {
+	return []byte(openAPIYAML)
+}
+
+// OpenAPI parses the OpenAPI document and checks that it is valid
+func OpenAPI() (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	spec, err := loader.LoadFromData(Spec())
+	if err != nil {
+		return nil, err
 	}
-	swag.Register(SwaggerInfo.InstanceName(), SwaggerInfo)
+
+	err = spec.Validate(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
+
+// ReDoc holds the ReDoc page and, once fetched, its bundle
+func ReDoc() fs.FS {
+	sub, err := fs.Sub(redoc, "redoc")
+	if err != nil {
+		// The directory is checked by go:embed at build time
+		panic(err)
+	}
+
+	return sub
+}
+
+// CheckReDoc fails when the bundle wasn't fetched before the build or isn't the pinned one, the server refuses to start then
+func CheckReDoc() error {
+	bundle, err := fs.ReadFile(redoc, "redoc/"+ReDocBundle)
+	if err != nil {
+		return fmt.Errorf("%s is not embedded, run go generate ./api before building: %w", ReDocBundle, err)
+	}
+
+	return VerifyReDoc(bundle)
+}
+
+// VerifyReDoc compares the bundle with the checksum committed in api/redoc
+func VerifyReDoc(bundle []byte) error {
+	line, err := fs.ReadFile(redoc, "redoc/"+ReDocChecksumFile)
+	if err != nil {
+		return fmt.Errorf("%s has no pinned checksum, review the bundle and record it with go run ./api/internal/fetchredoc -record -dir api/redoc: %w", ReDocBundle, err)
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return fmt.Errorf("%s is empty", ReDocChecksumFile)
+	}
+
+	sum := sha256.Sum256(bundle)
+	if checksum := hex.EncodeToString(sum[:]); checksum != fields[0] {
+		return fmt.Errorf("%s has checksum %s, %s pins %s", ReDocBundle, checksum, ReDocChecksumFile, fields[0])
+	}
+
+	return nil
 }
@@ -0,0 +1,63 @@
+// Command fetchredoc downloads the pinned ReDoc bundle into api/redoc, run by go generate ./api.
+//
+// The bundle is only written when its SHA-256 matches the checksum committed next to it.
+// After bumping the version, review the new bundle and record its checksum with -record.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	docs "portto-assignment/api"
+	"time"
+)
+
+// bundleURL is the pinned ReDoc release
+const bundleURL = "https://cdn.redoc.ly/redoc/v2.1.5/bundles/redoc.standalone.js"
+
+func main() {
+	record := flag.Bool("record", false, "write the checksum of the downloaded bundle instead of checking it")
+	dir := flag.String("dir", "redoc", "directory the bundle is written to")
+	flag.Parse()
+
+	err := fetch(*dir, *record)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fetchredoc:", err)
+		os.Exit(1)
+	}
+}
+
+func fetch(dir string, record bool) error {
+	client := &http.Client{Timeout: time.Minute}
+	response, err := client.Get(bundleURL)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", bundleURL, response.Status)
+	}
+	bundle, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(bundle)
+	checksum := hex.EncodeToString(sum[:])
+	if record {
+		line := fmt.Sprintf("%s  %s\n", checksum, docs.ReDocBundle)
+		return os.WriteFile(filepath.Join(dir, docs.ReDocChecksumFile), []byte(line), 0o644)
+	}
+
+	err = docs.VerifyReDoc(bundle)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, docs.ReDocBundle), bundle, 0o644)
+}
@@ -0,0 +1,34 @@
+// Package assets embeds the files the binaries read at run time, so they don't depend on the working directory
+package assets
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed sql/migrations/*.sql
+var migrations embed.FS
+
+//go:embed sql/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// Migrations holds the SQL migration files, named <version>.sql
+func Migrations() fs.FS {
+	sub, err := fs.Sub(migrations, "sql/migrations")
+	if err != nil {
+		// The directory is checked by go:embed at build time
+		panic(err)
+	}
+
+	return sub
+}
+
+// SQLiteMigrations holds the migration files of the SQLite storage driver, named <version>.sql
+func SQLiteMigrations() fs.FS {
+	sub, err := fs.Sub(sqliteMigrations, "sql/sqlite")
+	if err != nil {
+		panic(err)
+	}
+
+	return sub
+}
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+  id SERIAL PRIMARY KEY,
+  name text NOT NULL,
+  prefix text NOT NULL,
+  key_hash text NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+  revoked_at TIMESTAMPTZ
+);
+-- Keys are looked up by their hash on every authenticated request
+CREATE UNIQUE INDEX IF NOT EXISTS api_key_key_hash_idx ON api_keys USING btree (key_hash);
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS categories (
+  id SERIAL PRIMARY KEY,
+  name text NOT NULL,
+  description text NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS category_name_idx ON categories USING btree (name);
+
+-- Tags are created the first time they are put on a coin, an admin may file them under a category
+CREATE TABLE IF NOT EXISTS tags (
+  id SERIAL PRIMARY KEY,
+  name text NOT NULL,
+  category_id INT REFERENCES categories (id) ON DELETE SET NULL,
+  created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS tag_name_idx ON tags USING btree (name);
+
+CREATE TABLE IF NOT EXISTS meme_coin_tags (
+  meme_coin_id INT NOT NULL REFERENCES meme_coins (id) ON DELETE CASCADE,
+  tag_id INT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+  PRIMARY KEY (meme_coin_id, tag_id)
+);
+-- Coins are listed by tag in id order
+CREATE INDEX IF NOT EXISTS meme_coin_tag_tag_id_idx ON meme_coin_tags USING btree (tag_id, meme_coin_id);
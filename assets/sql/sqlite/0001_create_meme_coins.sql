@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS meme_coins (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  popularity_score INTEGER NOT NULL DEFAULT 0
+);
+-- Set up unique index and constraint for "name" column
+CREATE UNIQUE INDEX IF NOT EXISTS meme_coin_name_idx ON meme_coins (name);
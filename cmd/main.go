@@ -1,54 +1,194 @@
 package main
 
 import (
-	_ "portto-assignment/api"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	docs "portto-assignment/api"
 	"portto-assignment/config"
-	"portto-assignment/database/seeds"
 	"portto-assignment/internal/handlers"
+	"portto-assignment/internal/logging"
+	"portto-assignment/internal/middlewares"
 	"portto-assignment/internal/repositories"
 	"portto-assignment/internal/routes"
 	"portto-assignment/internal/services"
+	"portto-assignment/internal/tracing"
+	"syscall"
 )
 
-// @title			MemeCoin API
-// @version		1.0
-// @description	This is a simple API for MemeCoin
-
-// @host		localhost:8080
-// @BasePath	/v1/meme-coin/
 func main() {
-	// Get database connection pool
-	connectionPool, err := config.NewDatabaseConnectionPool()
+	// Settings come from the flags, the environment and the env file, in that order of precedence
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	config.RegisterFlags(flags)
+	flags.Parse(os.Args[1:])
+	settings, err := config.Load(config.LoadOptions{Flags: flags})
+	if err != nil {
+		panic(err)
+	}
+
+	// Logs are JSON lines unless LOG_FORMAT is text, the level can be changed at runtime through /admin/log-level
+	logLevel := new(slog.LevelVar)
+	level, err := logging.ParseLevel(settings.Log.Level)
 	if err != nil {
 		panic(err)
 	}
-	defer connectionPool.Close()
+	logLevel.Set(level)
+	logFormat, err := logging.ParseFormat(settings.Log.Format)
+	if err != nil {
+		panic(err)
+	}
+	logger := logging.NewLogger(logging.LoggerConfig{
+		Level:  logLevel,
+		Format: logFormat,
+	})
+	slog.SetDefault(logger)
+	logger.Info("Loaded configuration", "service_env", settings.Environment)
 
-	// Get redis connection
-	redisClient, err := config.NewRedisClient()
+	// Spans are only recorded with TRACING_EXPORTER, the incoming trace context is passed on regardless
+	exporter, err := tracing.ParseExporter(settings.Tracing.Exporter)
+	if err != nil {
+		panic(err)
+	}
+	serviceName := settings.Tracing.ServiceName
+	if serviceName == "" {
+		serviceName = tracing.DefaultServiceName
+	}
+	tracerProvider, err := tracing.NewTracerProvider(context.Background(), tracing.TracerConfig{
+		Exporter:    exporter,
+		FilePath:    settings.Tracing.File,
+		ServiceName: serviceName,
+		SampleRatio: settings.Tracing.SampleRatio,
+	})
+	if err != nil {
+		panic(err)
+	}
+	// Deferred first so that the spans of the other deferred closes are flushed too
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), settings.HTTP.ShutdownTimeout)
+		defer cancel()
+		if err := tracerProvider.Shutdown(ctx); err != nil {
+			logger.Error("Error flushing spans", "error", err)
+		}
+	}()
+
+	// Redis keys are prefixed with the namespace, and the environment when several share one Redis
+	keys, err := repositories.NewKeyBuilder(repositories.KeyBuilderConfig{
+		Namespace:   settings.Redis.KeyNamespace,
+		Environment: settings.Redis.KeyEnvironment,
+		HashTags:    settings.Redis.KeyHashTags,
+	})
 	if err != nil {
 		panic(err)
 	}
-	defer redisClient.Close()
 
-	// Seed database
-	seeds.Seeds(connectionPool)
+	// PostgreSQL and Redis unless STORAGE_DRIVER is sqlite
+	store, err := openStorage(settings, keys, logger)
+	if err != nil {
+		panic(err)
+	}
+	defer store.close()
 
-	// Inject database connection pools
-	memeCoinRepository := repositories.NewMemeCoinRepository(connectionPool)
-	redisRepository := repositories.NewRedisCachedRepository(connectionPool, redisClient, repositories.RepositoryConfig{
-		SyncBatchSize: repositories.DefaultSyncBatchSize,
-		SyncInterval:  repositories.DefaultSyncInterval,
-		NeedToSync:    true,
+	// Coin lookups are cached in memory, the fallback keeps reading the database directly
+	cachedMemeCoinRepository := repositories.NewCachedMemeCoinRepository(store.memeCoins, store.redisClient, repositories.CacheConfig{
+		Size:   settings.Cache.Size,
+		TTL:    settings.Cache.TTL,
+		Keys:   keys,
+		Logger: logger,
 	})
 
+	// Pokes are combined in memory before reaching Redis when a flush interval is set
+	scoreRepository := store.scores
+	if settings.Poke.FlushInterval > 0 {
+		bufferedRedisRepository := repositories.NewBufferedRedisRepository(store.scores, repositories.BufferConfig{
+			FlushInterval:  settings.Poke.FlushInterval,
+			MaxPendingKeys: settings.Poke.MaxPendingKeys,
+			ExistenceTTL:   settings.Poke.ExistenceTTL,
+			Logger:         logger,
+		})
+		defer bufferedRedisRepository.Close()
+		scoreRepository = bufferedRedisRepository
+	}
+
 	// Inject repositories
-	memeCoinService := services.NewMemeCoinService(memeCoinRepository, redisRepository)
+	memeCoinService := services.NewMemeCoinService(cachedMemeCoinRepository, scoreRepository, keys, logger)
+	healthService := services.NewHealthService(store.database, store.redis, logger)
 
 	// Inject services
-	memeCoinHandler := handlers.NewMemeCoinHandler(memeCoinService)
+	memeCoinHandler := handlers.NewMemeCoinHandler(memeCoinService, logger)
+	healthHandler := handlers.NewHealthHandler(healthService)
+
+	// Inject middlewares
+	idempotencyMiddleware := middlewares.NewIdempotencyMiddleware(store.idempotency, middlewares.IdempotencyConfig{
+		TTL:     middlewares.DefaultIdempotencyTTL,
+		LockTTL: middlewares.DefaultIdempotencyLockTTL,
+		Logger:  logger,
+	})
+
+	routerConfig := routes.RouterConfig{
+		Tracing:   middlewares.NewTracingMiddleware(serviceName),
+		RequestID: middlewares.NewRequestIDMiddleware(),
+		AccessLog: middlewares.NewAccessLogMiddleware(logger, middlewares.AccessLogConfig{
+			SampleRate: settings.Log.AccessSampleRate,
+		}),
+		Idempotency:    idempotencyMiddleware,
+		ReadYourWrites: middlewares.NewReadYourWritesMiddleware(),
+		Health:         healthHandler,
+		LogLevel:       handlers.NewLogLevelHandler(logLevel),
+	}
+	// API keys are managed with memecoinctl and only enforced when enabled, which the sqlite driver doesn't allow
+	if settings.Features.APIKeyAuth {
+		routerConfig.Authentication = middlewares.NewAPIKeyMiddleware(services.NewAPIKeyService(store.apiKeys), logger)
+	} else {
+		logger.Warn("Admin routes are not served, they need API_KEY_AUTH_ENABLED")
+	}
+	// Tags are stored in PostgreSQL, the sqlite driver leaves their routes out
+	if store.tags != nil {
+		tagService := services.NewTagService(store.tags, cachedMemeCoinRepository, scoreRepository, store.tagLeaderboards, keys, logger)
+		routerConfig.Tags = handlers.NewTagHandler(tagService, logger)
+	}
+	// The ReDoc page is served from the binary, a build without the pinned bundle is refused
+	if err := docs.CheckReDoc(); err != nil {
+		panic(err)
+	}
+
+	// Requests are checked against the same document served at /v1/openapi.yaml
+	if settings.Features.OpenAPIValidation {
+		spec, err := docs.OpenAPI()
+		if err != nil {
+			panic(err)
+		}
+		routerConfig.OpenAPIValidation, err = middlewares.NewOpenAPIValidationMiddleware(spec)
+		if err != nil {
+			panic(err)
+		}
+	}
 
 	// Setup routes
-	router := routes.NewRouter(memeCoinHandler)
-	router.Run(":8080")
+	router := routes.NewRouter(memeCoinHandler, routerConfig)
+	server := &http.Server{
+		Addr:    settings.HTTP.Addr,
+		Handler: router,
+	}
+
+	// Stop accepting requests on SIGINT or SIGTERM, the deferred closes then flush what is buffered
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Server stopped", "error", err)
+			stop()
+		}
+	}()
+	<-ctx.Done()
+
+	logger.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), settings.HTTP.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Error shutting down the server", "error", err)
+	}
 }
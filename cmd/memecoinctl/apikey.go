@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"portto-assignment/config"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/internal/services"
+	"strconv"
+)
+
+const apiKeyUsage = "usage: memecoinctl apikey create -name <name> | list | revoke <id>"
+
+func runAPIKey(settings *config.Config, args []string) error {
+	if len(args) < 1 {
+		return errors.New(apiKeyUsage)
+	}
+
+	connectionPool, err := connectDatabase(settings)
+	if err != nil {
+		return err
+	}
+	defer connectionPool.Close()
+
+	apiKeyService := services.NewAPIKeyService(repositories.NewAPIKeyRepository(connectionPool))
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("apikey create", flag.ExitOnError)
+		name := flags.String("name", "", "who or what the key is for")
+		flags.Parse(args[1:])
+
+		createdAPIKey, err := apiKeyService.CreateAPIKey(*name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Store the key now, it cannot be shown again")
+		return printJSON(createdAPIKey)
+
+	case "list":
+		apiKeys, err := apiKeyService.ListAPIKeys()
+		if err != nil {
+			return err
+		}
+		return printJSON(apiKeys)
+
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New(apiKeyUsage)
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid api key id %q", args[1])
+		}
+
+		revokedAPIKey, err := apiKeyService.RevokeAPIKey(id)
+		if err != nil {
+			return err
+		}
+		if revokedAPIKey == nil {
+			return fmt.Errorf("api key %d does not exist or is already revoked", id)
+		}
+		return printJSON(revokedAPIKey)
+
+	default:
+		return errors.New(apiKeyUsage)
+	}
+}
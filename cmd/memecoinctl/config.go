@@ -0,0 +1,20 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"portto-assignment/config"
+)
+
+func runConfig(settings *config.Config, args []string) error {
+	flags := flag.NewFlagSet("config", flag.ExitOnError)
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || flags.Arg(0) != "print" {
+		return errors.New("usage: memecoinctl config print")
+	}
+
+	// The output is an env file, usable as the -config of another run
+	return settings.Print(os.Stdout)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"portto-assignment/config"
+	"portto-assignment/internal/logging"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/internal/services"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+// command is a memecoinctl subcommand, args are the arguments after its name
+type command struct {
+	name        string
+	description string
+	run         func(settings *config.Config, args []string) error
+}
+
+var commands = []command{
+	{name: "migrate", description: "Apply pending database migrations, or list them with -status", run: runMigrate},
+	{name: "sync", description: "Write every popularity score in Redis to Postgres", run: runSync},
+	{name: "warm", description: "Load popularity scores missing from Redis from Postgres", run: runWarm},
+	{name: "layout", description: "Move popularity scores in Redis to the configured storage layout", run: runLayout},
+	{name: "reconcile", description: "Report and optionally repair drift between Redis and Postgres", run: runReconcile},
+	{name: "score", description: "Inspect or adjust a coin's popularity score (get, set, incr)", run: runScore},
+	{name: "apikey", description: "Manage API keys (create, list, revoke)", run: runAPIKey},
+	{name: "export", description: "Export the coin catalogue as CSV or NDJSON", run: runExport},
+	{name: "import", description: "Import a coin catalogue from CSV or NDJSON", run: runImport},
+	{name: "config", description: "Print the loaded settings with the secrets redacted (print)", run: runConfig},
+}
+
+func main() {
+	// The settings flags come before the command, e.g. memecoinctl -redis-url redis://... sync
+	flags := flag.NewFlagSet("memecoinctl", flag.ExitOnError)
+	config.RegisterFlags(flags)
+	flags.Usage = printUsage
+	flags.Parse(os.Args[1:])
+	if flags.NArg() < 1 {
+		printUsage()
+		os.Exit(2)
+	}
+
+	for _, command := range commands {
+		if command.name != flags.Arg(0) {
+			continue
+		}
+
+		settings, err := config.Load(config.LoadOptions{Flags: flags})
+		if err == nil {
+			err = setupLogger(settings)
+		}
+		if err == nil {
+			err = command.run(settings, flags.Args()[1:])
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "memecoinctl %s: %v\n", command.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	printUsage()
+	os.Exit(2)
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: memecoinctl [settings flags] <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, command := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", command.name, command.description)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Settings flags override the environment and the env file, e.g. -database-url for DATABASE_URL")
+}
+
+// setupLogger logs to stderr like the API does, so that command output stays pipeable
+func setupLogger(settings *config.Config) error {
+	level, err := logging.ParseLevel(settings.Log.Level)
+	if err != nil {
+		return err
+	}
+	format, err := logging.ParseFormat(settings.Log.Format)
+	if err != nil {
+		return err
+	}
+
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(level)
+	slog.SetDefault(logging.NewLogger(logging.LoggerConfig{
+		Level:  logLevel,
+		Format: format,
+	}))
+
+	return nil
+}
+
+// connectDatabase opens the same database connection pool as the API
+func connectDatabase(settings *config.Config) (*pgxpool.Pool, error) {
+	return config.NewDatabaseConnectionPool(settings.Database)
+}
+
+// connect opens the same database and Redis connections as the API, without starting the sync worker
+func connect(settings *config.Config) (*pgxpool.Pool, redis.UniversalClient, error) {
+	connectionPool, err := connectDatabase(settings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	redisClient, err := config.NewRedisClient(settings.Redis)
+	if err != nil {
+		connectionPool.Close()
+		return nil, nil, err
+	}
+
+	return connectionPool, redisClient, nil
+}
+
+func newRedisCachedRepository(settings *config.Config, connectionPool *pgxpool.Pool, redisClient redis.UniversalClient) (*repositories.RedisCachedRepository, error) {
+	repositoryConfig, err := newRepositoryConfig(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return repositories.NewRedisCachedRepository(connectionPool, redisClient, repositoryConfig), nil
+}
+
+// newRepositoryConfig reads the Redis score layout the API uses, without starting the sync worker
+func newRepositoryConfig(settings *config.Config) (repositories.RepositoryConfig, error) {
+	scoreLayout, err := repositories.ParseScoreLayout(settings.Redis.ScoreLayout)
+	if err != nil {
+		return repositories.RepositoryConfig{}, err
+	}
+
+	keys, err := newKeyBuilder(settings)
+	if err != nil {
+		return repositories.RepositoryConfig{}, err
+	}
+
+	return repositories.RepositoryConfig{
+		NeedToSync:      false,
+		ScoreLayout:     scoreLayout,
+		ScoreBucketSize: settings.Redis.ScoreBucketSize,
+		Keys:            keys,
+	}, nil
+}
+
+// newKeyBuilder reads the Redis key namespace the API uses
+func newKeyBuilder(settings *config.Config) (*repositories.KeyBuilder, error) {
+	return repositories.NewKeyBuilder(repositories.KeyBuilderConfig{
+		Namespace:   settings.Redis.KeyNamespace,
+		Environment: settings.Redis.KeyEnvironment,
+		HashTags:    settings.Redis.KeyHashTags,
+	})
+}
+
+func newMemeCoinService(settings *config.Config, connectionPool *pgxpool.Pool, redisClient redis.UniversalClient) (*services.MemeCoinService, error) {
+	memeCoinRepository := repositories.NewMemeCoinRepository(connectionPool)
+	repositoryConfig, err := newRepositoryConfig(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// Scores set from the command line are reflected on the tag leaderboards of the API
+	repositoryConfig.TagLeaderboards = repositories.NewTagLeaderboardRepository(redisClient, repositories.TagLeaderboardConfig{
+		Keys: repositoryConfig.Keys,
+	})
+	redisRepository := repositories.NewRedisCachedRepository(connectionPool, redisClient, repositoryConfig)
+
+	return services.NewMemeCoinService(memeCoinRepository, redisRepository, repositoryConfig.Keys, nil), nil
+}
+
+// printJSON writes results to stdout so they can be piped into other tools
+func printJSON(value any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"portto-assignment/config"
+	"portto-assignment/database/migrations"
+	"time"
+)
+
+func runMigrate(settings *config.Config, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	status := flags.Bool("status", false, "list migrations instead of applying them")
+	flags.Parse(args)
+
+	connectionPool, err := connectDatabase(settings)
+	if err != nil {
+		return err
+	}
+	defer connectionPool.Close()
+
+	if *status {
+		migrationList, err := migrations.Status(connectionPool)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrationList {
+			appliedAt := "pending"
+			if migration.AppliedAt != nil {
+				appliedAt = migration.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%-40s %s\n", migration.Version, appliedAt)
+		}
+		return nil
+	}
+
+	appliedVersions, err := migrations.Migrate(connectionPool)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Applied %d migration(s)\n", len(appliedVersions))
+
+	return nil
+}
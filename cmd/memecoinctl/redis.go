@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"portto-assignment/config"
+	"portto-assignment/internal/repositories"
+)
+
+func runSync(settings *config.Config, args []string) error {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	flags.Parse(args)
+
+	connectionPool, redisClient, err := connect(settings)
+	if err != nil {
+		return err
+	}
+	defer connectionPool.Close()
+	defer redisClient.Close()
+
+	redisCachedRepository, err := newRedisCachedRepository(settings, connectionPool, redisClient)
+	if err != nil {
+		return err
+	}
+	synced, err := redisCachedRepository.SyncAll()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Synced %d popularity score(s) to Postgres\n", synced)
+
+	return nil
+}
+
+func runWarm(settings *config.Config, args []string) error {
+	flags := flag.NewFlagSet("warm", flag.ExitOnError)
+	pageSize := flags.Int("page-size", repositories.DefaultWarmUpPageSize, "rows read from Postgres per page")
+	workers := flags.Int("workers", repositories.DefaultWarmUpWorkers, "pages written to Redis concurrently")
+	flags.Parse(args)
+
+	connectionPool, redisClient, err := connect(settings)
+	if err != nil {
+		return err
+	}
+	defer connectionPool.Close()
+	defer redisClient.Close()
+
+	repositoryConfig, err := newRepositoryConfig(settings)
+	if err != nil {
+		return err
+	}
+	repositoryConfig.WarmUpPageSize = *pageSize
+	repositoryConfig.WarmUpWorkers = *workers
+	report, err := repositories.NewRedisCachedRepository(connectionPool, redisClient, repositoryConfig).WarmUp()
+	if report != nil {
+		if printErr := printJSON(report); printErr != nil {
+			return printErr
+		}
+	}
+
+	return err
+}
+
+func runReconcile(settings *config.Config, args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	repair := flags.String("repair", string(repositories.ReconcilePolicyReport), "repair policy: report, redis or database")
+	flags.Parse(args)
+
+	policy, err := repositories.ParseReconcilePolicy(*repair)
+	if err != nil {
+		return err
+	}
+
+	connectionPool, redisClient, err := connect(settings)
+	if err != nil {
+		return err
+	}
+	defer connectionPool.Close()
+	defer redisClient.Close()
+
+	redisCachedRepository, err := newRedisCachedRepository(settings, connectionPool, redisClient)
+	if err != nil {
+		return err
+	}
+	report, err := redisCachedRepository.Reconcile(policy)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(report)
+}
+
+func runLayout(settings *config.Config, args []string) error {
+	flags := flag.NewFlagSet("layout", flag.ExitOnError)
+	from := flags.String("from", string(repositories.ScoreLayoutString), "layout the scores are stored with now: string or hash")
+	flags.Parse(args)
+
+	connectionPool, redisClient, err := connect(settings)
+	if err != nil {
+		return err
+	}
+	defer connectionPool.Close()
+	defer redisClient.Close()
+
+	// The scores are moved to REDIS_SCORE_LAYOUT, the API must be stopped meanwhile
+	redisCachedRepository, err := newRedisCachedRepository(settings, connectionPool, redisClient)
+	if err != nil {
+		return err
+	}
+	report, err := redisCachedRepository.MigrateScoreLayout(repositories.ScoreLayout(*from))
+	if report != nil {
+		if printErr := printJSON(report); printErr != nil {
+			return printErr
+		}
+	}
+
+	return err
+}
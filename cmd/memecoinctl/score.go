@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"portto-assignment/config"
+	"portto-assignment/internal/services"
+	"strconv"
+)
+
+const scoreUsage = "usage: memecoinctl score get <id> | set <id> <score> | incr <id> <delta>"
+
+func runScore(settings *config.Config, args []string) error {
+	if len(args) < 2 {
+		return errors.New(scoreUsage)
+	}
+	id, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid meme coin id %q", args[1])
+	}
+
+	var value int
+	if args[0] == "set" || args[0] == "incr" {
+		if len(args) != 3 {
+			return errors.New(scoreUsage)
+		}
+		value, err = strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Errorf("invalid value %q", args[2])
+		}
+	}
+
+	connectionPool, redisClient, err := connect(settings)
+	if err != nil {
+		return err
+	}
+	defer connectionPool.Close()
+	defer redisClient.Close()
+
+	memeCoinService, err := newMemeCoinService(settings, connectionPool, redisClient)
+	if err != nil {
+		return err
+	}
+	var popularityScore *services.PopularityScore
+	switch args[0] {
+	case "get":
+		popularityScore, err = memeCoinService.GetPopularityScore(context.Background(), id)
+	case "set":
+		popularityScore, err = memeCoinService.SetPopularityScore(context.Background(), id, value)
+	case "incr":
+		popularityScore, err = memeCoinService.AdjustPopularityScore(context.Background(), id, value)
+	default:
+		return errors.New(scoreUsage)
+	}
+	if err != nil {
+		return err
+	}
+	if popularityScore == nil {
+		return fmt.Errorf("meme coin %d does not exist", id)
+	}
+
+	return printJSON(popularityScore)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"portto-assignment/config"
+	"portto-assignment/internal/services"
+)
+
+func runExport(settings *config.Config, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", services.FormatCSV, "export format, csv or ndjson")
+	output := flags.String("output", "-", "file to write, - for stdout")
+	flags.Parse(args)
+
+	connectionPool, redisClient, err := connect(settings)
+	if err != nil {
+		return err
+	}
+	defer connectionPool.Close()
+	defer redisClient.Close()
+
+	var writer io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	memeCoinService, err := newMemeCoinService(settings, connectionPool, redisClient)
+	if err != nil {
+		return err
+	}
+
+	return memeCoinService.ExportMemeCoins(context.Background(), writer, *format)
+}
+
+func runImport(settings *config.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", services.FormatCSV, "import format, csv or ndjson")
+	input := flags.String("input", "-", "file to read, - for stdin")
+	flags.Parse(args)
+
+	connectionPool, redisClient, err := connect(settings)
+	if err != nil {
+		return err
+	}
+	defer connectionPool.Close()
+	defer redisClient.Close()
+
+	var reader io.Reader = os.Stdin
+	if *input != "-" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	memeCoinService, err := newMemeCoinService(settings, connectionPool, redisClient)
+	if err != nil {
+		return err
+	}
+
+	// The rows before a malformed line are committed, so their report is printed along with the error
+	report, err := memeCoinService.ImportMemeCoins(context.Background(), reader, *format)
+	if err != nil && report != nil {
+		printJSON(report)
+	}
+	if err != nil {
+		return err
+	}
+
+	return printJSON(report)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"portto-assignment/config"
+	"portto-assignment/database/migrations"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/internal/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// storage holds the repositories of the storage driver picked by STORAGE_DRIVER
+type storage struct {
+	memeCoins   repositories.MemeCoinRepositoryInterface
+	scores      repositories.RedisRepositoryInterface
+	idempotency repositories.IdempotencyRepositoryInterface
+	// apiKeys is nil with the sqlite driver, which doesn't support API key authentication
+	apiKeys repositories.APIKeyRepositoryInterface
+	// tags and tagLeaderboards are nil with the sqlite driver, which doesn't support tags
+	tags            repositories.TagRepositoryInterface
+	tagLeaderboards repositories.TagLeaderboardRepositoryInterface
+	database        services.DatabaseHealthInterface
+	redis           repositories.RedisStatusInterface
+	// redisClient broadcasts the cache invalidations to the other instances, nil when the process is alone
+	redisClient redis.UniversalClient
+	closers     []func()
+}
+
+func openStorage(settings *config.Config, keys *repositories.KeyBuilder, logger *slog.Logger) (*storage, error) {
+	if settings.Storage.Driver == config.StorageDriverSQLite {
+		return openSQLiteStorage(settings, keys, logger)
+	}
+
+	return openPostgresStorage(settings, keys, logger)
+}
+
+// close releases the connections in the reverse order they were opened
+func (s *storage) close() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i]()
+	}
+}
+
+func openPostgresStorage(settings *config.Config, keys *repositories.KeyBuilder, logger *slog.Logger) (*storage, error) {
+	s := &storage{}
+
+	// Get database connection pool
+	connectionPool, err := config.NewDatabaseConnectionPool(settings.Database)
+	if err != nil {
+		return nil, err
+	}
+	s.closers = append(s.closers, connectionPool.Close)
+
+	// Read replicas are optional, reads stay on the primary when none are configured
+	replicaPools, err := config.NewDatabaseReplicaPools(settings.Database)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	replicas := []config.DatabaseConnectionPoolInterface{}
+	for _, replicaPool := range replicaPools {
+		s.closers = append(s.closers, replicaPool.Close)
+		replicas = append(replicas, replicaPool)
+	}
+
+	// Get redis connection, the API starts degraded when Redis is unreachable
+	redisClient, err := config.OpenRedisClient(settings.Redis)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	s.closers = append(s.closers, func() { redisClient.Close() })
+
+	// Migrate database
+	_, err = migrations.Migrate(connectionPool)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+
+	// Reconciliation between Redis and the database is disabled unless an interval is set
+	reconcilePolicy, err := repositories.ParseReconcilePolicy(settings.Sync.ReconcilePolicy)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+
+	// Popularity scores are stored as one key per coin unless the hash layout is chosen
+	scoreLayout, err := repositories.ParseScoreLayout(settings.Redis.ScoreLayout)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+
+	// Inject database connection pools
+	databaseRouter := repositories.NewDatabaseRouter(connectionPool, replicas, repositories.DatabaseRouterConfig{
+		HealthCheckInterval: repositories.DefaultReplicaHealthCheckInterval,
+		HealthCheckTimeout:  repositories.DefaultReplicaHealthCheckTimeout,
+		Logger:              logger,
+	})
+	memeCoinRepository := repositories.NewRoutedMemeCoinRepository(databaseRouter)
+	// The tag leaderboards are written along with the scores, behind the same buffer and circuit breaker
+	tagLeaderboardRepository := repositories.NewTagLeaderboardRepository(redisClient, repositories.TagLeaderboardConfig{
+		Keys:   keys,
+		Logger: logger,
+	})
+	redisRepository := repositories.NewRedisCachedRepository(connectionPool, redisClient, repositories.RepositoryConfig{
+		SyncBatchSize:     settings.Sync.BatchSize,
+		SyncInterval:      settings.Sync.Interval,
+		NeedToSync:        true,
+		ReconcileInterval: settings.Sync.ReconcileInterval,
+		ReconcilePolicy:   reconcilePolicy,
+		ScoreLayout:       scoreLayout,
+		ScoreBucketSize:   settings.Redis.ScoreBucketSize,
+		Keys:              keys,
+		TagLeaderboards:   tagLeaderboardRepository,
+		Logger:            logger,
+	})
+	// The pending syncs are written before the connections close, whatever fails stays marked in Redis for the next start
+	s.closers = append(s.closers, func() {
+		if err := redisRepository.Close(); err != nil {
+			logger.Error("Error syncing popularity scores on shutdown", "error", err)
+		}
+	})
+
+	tagRepository := repositories.NewRoutedTagRepository(databaseRouter)
+	fallbackRedisRepository := repositories.NewFallbackRedisRepository(redisRepository, memeCoinRepository, repositories.FallbackConfig{
+		FailureThreshold: repositories.DefaultFailureThreshold,
+		RecoveryInterval: repositories.DefaultRecoveryInterval,
+		Tags:             tagRepository,
+		Logger:           logger,
+	})
+
+	// The tag leaderboards are rebuilt from the database and the live scores, a failure leaves them as they were in Redis.
+	// When Redis is down they are rebuilt once the fallback recovers instead
+	if fallbackRedisRepository.Status() == repositories.RedisStatusUp {
+		if _, err := tagLeaderboardRepository.WarmUp(context.Background(), tagRepository, redisRepository); err != nil {
+			logger.Error("Error warming up tag leaderboards", "error", err)
+		}
+	}
+
+	s.memeCoins = memeCoinRepository
+	s.scores = fallbackRedisRepository
+	s.idempotency = repositories.NewIdempotencyRepository(redisClient, keys)
+	s.apiKeys = repositories.NewAPIKeyRepository(connectionPool)
+	s.tags = tagRepository
+	s.tagLeaderboards = tagLeaderboardRepository
+	s.database = connectionPool
+	s.redis = fallbackRedisRepository
+	s.redisClient = redisClient
+
+	return s, nil
+}
+
+// openSQLiteStorage keeps the meme coins in a file and everything else in memory, for demos and offline development
+func openSQLiteStorage(settings *config.Config, keys *repositories.KeyBuilder, logger *slog.Logger) (*storage, error) {
+	db, err := config.OpenSQLiteDatabase(settings.Storage)
+	if err != nil {
+		return nil, err
+	}
+	s := &storage{
+		closers: []func(){func() { db.Close() }},
+	}
+
+	_, err = migrations.MigrateSQLite(db)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+
+	memeCoinRepository := repositories.NewSQLiteMemeCoinRepository(db, repositories.SQLiteConfig{})
+	// Scores are written through to SQLite, so they survive restarts without a sync worker
+	memoryRedisRepository := repositories.NewMemoryRedisRepository(repositories.MemoryRedisConfig{
+		Repo: memeCoinRepository,
+		Keys: keys,
+	})
+	loaded, err := memoryRedisRepository.WarmUp(context.Background())
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	logger.Info("Opened SQLite database", "path", settings.Storage.SQLitePath, "meme_coins", loaded)
+
+	s.memeCoins = memeCoinRepository
+	s.scores = memoryRedisRepository
+	s.idempotency = repositories.NewMemoryIdempotencyRepository(nil)
+	s.database = memeCoinRepository
+	s.redis = memoryRedisRepository
+
+	return s, nil
+}
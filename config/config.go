@@ -1,38 +1,361 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
-func init() {
-	dir, _ := os.Getwd()
+// settings lists every key in the order they are printed
+var settings = []setting{
+	{key: "SERVICE_ENV", usage: "environment, picks the default config file", field: func(c *Config) any { return &c.Environment }},
+	{key: "HTTP_ADDR", usage: "address the API listens on", field: func(c *Config) any { return &c.HTTP.Addr }},
+	{key: "HTTP_SHUTDOWN_TIMEOUT", usage: "time in-flight requests get on shutdown", field: func(c *Config) any { return &c.HTTP.ShutdownTimeout }},
+	{key: "STORAGE_DRIVER", usage: "postgres, or sqlite to run as a single process", field: func(c *Config) any { return &c.Storage.Driver }},
+	{key: "SQLITE_PATH", usage: "database file of the sqlite driver", field: func(c *Config) any { return &c.Storage.SQLitePath }},
+	{key: "DATABASE_URL", usage: "PostgreSQL connection string", secret: true, field: func(c *Config) any { return &c.Database.URL }},
+	{key: "DATABASE_REPLICA_URLS", usage: "comma separated read replica connection strings", secret: true, field: func(c *Config) any { return &c.Database.ReplicaURLs }},
+	{key: "DATABASE_MAX_CONNS", usage: "largest number of open connections per pool", field: func(c *Config) any { return &c.Database.MaxConns }},
+	{key: "DATABASE_MIN_CONNS", usage: "connections kept open when idle", field: func(c *Config) any { return &c.Database.MinConns }},
+	{key: "DATABASE_MAX_CONN_LIFETIME", usage: "time a connection is used before it is replaced", field: func(c *Config) any { return &c.Database.MaxConnLifetime }},
+	{key: "DATABASE_MAX_CONN_IDLE_TIME", usage: "time an idle connection is kept", field: func(c *Config) any { return &c.Database.MaxConnIdleTime }},
+	{key: "DATABASE_HEALTH_CHECK_PERIOD", usage: "interval of the idle connection checks", field: func(c *Config) any { return &c.Database.HealthCheckPeriod }},
+	{key: "DATABASE_STATEMENT_CACHE_CAPACITY", usage: "prepared statements cached per connection", field: func(c *Config) any { return &c.Database.StatementCacheCapacity }},
+	{key: "REDIS_URL", usage: "Redis connection string", secret: true, field: func(c *Config) any { return &c.Redis.URL }},
+	{key: "REDIS_MODE", usage: "standalone, sentinel or cluster", field: func(c *Config) any { return &c.Redis.Mode }},
+	{key: "REDIS_SENTINEL_MASTER", usage: "master name in sentinel mode", field: func(c *Config) any { return &c.Redis.SentinelMaster }},
+	{key: "REDIS_SENTINEL_ADDRS", usage: "comma separated sentinel addresses", field: func(c *Config) any { return &c.Redis.SentinelAddrs }},
+	{key: "REDIS_SENTINEL_PASSWORD", usage: "password of the sentinels", secret: true, field: func(c *Config) any { return &c.Redis.SentinelPassword }},
+	{key: "REDIS_CLUSTER_ADDRS", usage: "comma separated cluster node addresses", field: func(c *Config) any { return &c.Redis.ClusterAddrs }},
+	{key: "REDIS_SCORE_LAYOUT", usage: "string or hash storage of the popularity scores", field: func(c *Config) any { return &c.Redis.ScoreLayout }},
+	{key: "REDIS_SCORE_BUCKET_SIZE", usage: "coins per hash in the hash layout", field: func(c *Config) any { return &c.Redis.ScoreBucketSize }},
+	{key: "REDIS_KEY_NAMESPACE", usage: "prefix of every Redis key", field: func(c *Config) any { return &c.Redis.KeyNamespace }},
+	{key: "REDIS_KEY_ENVIRONMENT", usage: "environment prefixed to the namespace", field: func(c *Config) any { return &c.Redis.KeyEnvironment }},
+	{key: "REDIS_KEY_HASH_TAGS", usage: "wrap the key prefix in a cluster hash tag", field: func(c *Config) any { return &c.Redis.KeyHashTags }},
+	{key: "SYNC_BATCH_SIZE", usage: "dirty popularity scores written to the database per batch", field: func(c *Config) any { return &c.Sync.BatchSize }},
+	{key: "SYNC_INTERVAL", usage: "longest time a dirty popularity score waits for its batch", field: func(c *Config) any { return &c.Sync.Interval }},
+	{key: "RECONCILE_INTERVAL", usage: "interval of the Redis and database comparison, disabled when unset", field: func(c *Config) any { return &c.Sync.ReconcileInterval }},
+	{key: "RECONCILE_POLICY", usage: "report, redis or database", field: func(c *Config) any { return &c.Sync.ReconcilePolicy }},
+	{key: "MEME_COIN_CACHE_SIZE", usage: "meme coins cached in memory", field: func(c *Config) any { return &c.Cache.Size }},
+	{key: "MEME_COIN_CACHE_TTL", usage: "time a meme coin stays cached", field: func(c *Config) any { return &c.Cache.TTL }},
+	{key: "POKE_FLUSH_INTERVAL", usage: "interval pokes are combined for, disabled when unset", field: func(c *Config) any { return &c.Poke.FlushInterval }},
+	{key: "POKE_MAX_PENDING_KEYS", usage: "combined coins that trigger an early flush", field: func(c *Config) any { return &c.Poke.MaxPendingKeys }},
+	{key: "POKE_EXISTENCE_TTL", usage: "time a coin existence check is remembered", field: func(c *Config) any { return &c.Poke.ExistenceTTL }},
+	{key: "LOG_LEVEL", usage: "debug, info, warn or error", field: func(c *Config) any { return &c.Log.Level }},
+	{key: "LOG_FORMAT", usage: "json or text", field: func(c *Config) any { return &c.Log.Format }},
+	{key: "LOG_ACCESS_SAMPLE_RATE", usage: "share of the requests in the access log", field: func(c *Config) any { return &c.Log.AccessSampleRate }},
+	{key: "TRACING_EXPORTER", usage: "none, stdout, file or otlp", field: func(c *Config) any { return &c.Tracing.Exporter }},
+	{key: "TRACING_FILE", usage: "file the file exporter writes to", field: func(c *Config) any { return &c.Tracing.File }},
+	{key: "TRACING_SAMPLE_RATIO", usage: "share of the traces started here that are recorded", field: func(c *Config) any { return &c.Tracing.SampleRatio }},
+	{key: "OTEL_SERVICE_NAME", usage: "service name of the spans", field: func(c *Config) any { return &c.Tracing.ServiceName }},
+	{key: "API_KEY_AUTH_ENABLED", usage: "require an API key on the write routes", field: func(c *Config) any { return &c.Features.APIKeyAuth }},
+	{key: "OPENAPI_VALIDATION_ENABLED", usage: "reject the requests that don't match the OpenAPI document", field: func(c *Config) any { return &c.Features.OpenAPIValidation }},
+}
+
+// DefaultConfig returns the settings used when nothing overrides them
+func DefaultConfig() Config {
+	return Config{
+		Environment: DefaultEnvironment,
+		HTTP: HTTPConfig{
+			Addr:            DefaultHTTPAddr,
+			ShutdownTimeout: DefaultShutdownTimeout,
+		},
+		Storage: StorageConfig{
+			Driver:     StorageDriverPostgres,
+			SQLitePath: DefaultSQLitePath,
+		},
+		Database: DatabaseConfig{
+			MaxConns:               DefaultDatabaseMaxConns,
+			MinConns:               DefaultDatabaseMinConns,
+			MaxConnLifetime:        DefaultDatabaseMaxConnLifetime,
+			MaxConnIdleTime:        DefaultDatabaseMaxConnIdleTime,
+			HealthCheckPeriod:      DefaultDatabaseHealthCheckPeriod,
+			StatementCacheCapacity: DefaultDatabaseStatementCacheCapacity,
+		},
+		Redis: RedisConfig{
+			Mode: RedisModeStandalone,
+		},
+		Log: LogConfig{
+			Level:            "info",
+			Format:           "json",
+			AccessSampleRate: 1,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+		},
+		Features: FeatureConfig{
+			OpenAPIValidation: true,
+		},
+	}
+}
+
+// RegisterFlags adds a flag per setting, e.g. -database-url for DATABASE_URL, and the -config flag naming the env file
+func RegisterFlags(flags *flag.FlagSet) {
+	flags.String(ConfigFileFlag, "", "env file to read the settings from")
+	for _, setting := range settings {
+		flags.String(flagName(setting.key), "", setting.usage+" ("+setting.key+")")
+	}
+}
+
+// Load reads the settings from, lowest precedence first, the defaults, the env file, the environment variables and the flags.
+// Every invalid setting is reported in the returned error.
+func Load(options LoadOptions) (*Config, error) {
+	flagValues := map[string]string{}
+	if options.Flags != nil {
+		options.Flags.Visit(func(f *flag.Flag) {
+			flagValues[f.Name] = f.Value.String()
+		})
+	}
+	// An empty value is treated as unset, so it doesn't override the lower layers
+	lookup := func(key string) (string, bool) {
+		if value := strings.TrimSpace(flagValues[flagName(key)]); value != "" {
+			return value, true
+		}
+		value := strings.TrimSpace(os.Getenv(key))
+		return value, value != ""
+	}
+
+	file := options.File
+	if file == "" {
+		file = flagValues[ConfigFileFlag]
+	}
+	fileValues, err := readConfigFile(file, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	config := DefaultConfig()
+	errs := []error{}
+	for _, setting := range settings {
+		value, found := lookup(setting.key)
+		if !found {
+			value = strings.TrimSpace(fileValues[setting.key])
+			found = value != ""
+		}
+		if !found {
+			continue
+		}
+		if err := setting.parse(&config, value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// Validate reports every setting out of its range, the values parsed by other packages are checked when they are used
+func (c *Config) Validate() error {
+	errs := []error{}
+	check := func(valid bool, key string, format string, args ...any) {
+		if !valid {
+			errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	check(c.HTTP.Addr != "", "HTTP_ADDR", "is required")
+	check(c.HTTP.ShutdownTimeout > 0, "HTTP_SHUTDOWN_TIMEOUT", "must be positive")
+	postgres := c.Storage.Driver == StorageDriverPostgres
+	switch c.Storage.Driver {
+	case StorageDriverPostgres:
+	case StorageDriverSQLite:
+		check(c.Storage.SQLitePath != "", "SQLITE_PATH", "is required with the %s driver", c.Storage.Driver)
+		check(!c.Features.APIKeyAuth, "API_KEY_AUTH_ENABLED", "needs the %s driver", StorageDriverPostgres)
+	default:
+		check(false, "STORAGE_DRIVER", "unknown driver %q", c.Storage.Driver)
+	}
+
+	check(!postgres || c.Database.URL != "", "DATABASE_URL", "is required")
+	check(c.Database.MaxConns > 0, "DATABASE_MAX_CONNS", "must be positive")
+	check(c.Database.MinConns >= 0 && c.Database.MinConns <= c.Database.MaxConns, "DATABASE_MIN_CONNS", "must be between 0 and DATABASE_MAX_CONNS (%d)", c.Database.MaxConns)
+	check(c.Database.MaxConnLifetime > 0, "DATABASE_MAX_CONN_LIFETIME", "must be positive")
+	check(c.Database.MaxConnIdleTime > 0, "DATABASE_MAX_CONN_IDLE_TIME", "must be positive")
+	check(c.Database.HealthCheckPeriod > 0, "DATABASE_HEALTH_CHECK_PERIOD", "must be positive")
+	check(c.Database.StatementCacheCapacity > 0, "DATABASE_STATEMENT_CACHE_CAPACITY", "must be positive")
+
+	switch c.Redis.Mode {
+	case "", RedisModeStandalone:
+		check(!postgres || c.Redis.URL != "", "REDIS_URL", "is required in %s mode", c.Redis.Mode)
+	case RedisModeSentinel:
+		check(!postgres || c.Redis.SentinelMaster != "", "REDIS_SENTINEL_MASTER", "is required in %s mode", c.Redis.Mode)
+		check(!postgres || len(c.Redis.SentinelAddrs) > 0, "REDIS_SENTINEL_ADDRS", "is required in %s mode", c.Redis.Mode)
+	case RedisModeCluster:
+		check(!postgres || c.Redis.URL != "" || len(c.Redis.ClusterAddrs) > 0, "REDIS_CLUSTER_ADDRS", "or REDIS_URL is required in %s mode", c.Redis.Mode)
+	default:
+		check(false, "REDIS_MODE", "unknown mode %q", c.Redis.Mode)
+	}
+	check(c.Redis.ScoreBucketSize >= 0, "REDIS_SCORE_BUCKET_SIZE", "must not be negative")
+
+	check(c.Sync.BatchSize >= 0, "SYNC_BATCH_SIZE", "must not be negative")
+	check(c.Sync.Interval >= 0, "SYNC_INTERVAL", "must not be negative")
+	check(c.Sync.ReconcileInterval >= 0, "RECONCILE_INTERVAL", "must not be negative")
+	check(c.Cache.Size >= 0, "MEME_COIN_CACHE_SIZE", "must not be negative")
+	check(c.Cache.TTL >= 0, "MEME_COIN_CACHE_TTL", "must not be negative")
+	check(c.Poke.FlushInterval >= 0, "POKE_FLUSH_INTERVAL", "must not be negative")
+	check(c.Poke.MaxPendingKeys >= 0, "POKE_MAX_PENDING_KEYS", "must not be negative")
+	check(c.Poke.ExistenceTTL >= 0, "POKE_EXISTENCE_TTL", "must not be negative")
+	check(c.Log.AccessSampleRate > 0 && c.Log.AccessSampleRate <= 1, "LOG_ACCESS_SAMPLE_RATE", "must be above 0 and at most 1")
+	check(c.Tracing.SampleRatio > 0 && c.Tracing.SampleRatio <= 1, "TRACING_SAMPLE_RATIO", "must be above 0 and at most 1")
 
-	// Automatically bind environment variables
-	viper.AutomaticEnv()
+	return errors.Join(errs...)
+}
 
-	// Set the configuration type and path
-	viper.SetConfigType("env")
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	viper.AddConfigPath(path.Join(dir, "config"))
-	viper.ReadInConfig()
+// Print writes the settings as an env file, with the secrets redacted
+func (c *Config) Print(writer io.Writer) error {
+	for _, setting := range settings {
+		value := formatValue(setting.field(c))
+		if setting.secret {
+			value = redact(value)
+		}
+		if _, err := fmt.Fprintf(writer, "%s=%s\n", setting.key, strconv.Quote(value)); err != nil {
+			return err
+		}
+	}
 
-	env := viper.GetString("SERVICE_ENV")
-	if env == "" {
-		env = "local"
+	return nil
+}
+
+func (s setting) parse(config *Config, value string) error {
+	var err error
+	switch field := s.field(config).(type) {
+	case *string:
+		*field = value
+	case *[]string:
+		*field = splitList(value)
+	case *bool:
+		*field, err = strconv.ParseBool(value)
+	case *int:
+		*field, err = strconv.Atoi(value)
+	case *float64:
+		*field, err = strconv.ParseFloat(value, 64)
+	case *time.Duration:
+		*field, err = time.ParseDuration(value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: invalid value %q", s.key, value)
 	}
-	log.Println("SERVICE_ENV: ", env)
 
-	// Load local environment variables via config.env.local
-	if env == "local" {
-		viper.SetConfigName("config.env.local")
-		if err := viper.MergeInConfig(); err != nil {
-			panic(err)
+	return nil
+}
+
+// readConfigFile reads the env file, a missing default file is fine since environment variables are enough
+func readConfigFile(file string, lookup func(key string) (string, bool)) (map[string]string, error) {
+	required := file != ""
+	if !required {
+		environment, found := lookup("SERVICE_ENV")
+		if !found {
+			environment = DefaultEnvironment
 		}
+		dir, _ := os.Getwd()
+		file = path.Join(dir, "config", "config.env."+environment)
 	}
 
+	reader := viper.New()
+	reader.SetConfigType("env")
+	reader.SetConfigFile(file)
+	if err := reader.ReadInConfig(); err != nil {
+		if !required && errors.Is(err, os.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("reading config file %s: %w", file, err)
+	}
+
+	// viper lowercases the keys
+	values := map[string]string{}
+	for _, key := range reader.AllKeys() {
+		values[strings.ToUpper(key)] = reader.GetString(key)
+	}
+
+	return values, nil
+}
+
+func formatValue(field any) string {
+	switch field := field.(type) {
+	case *string:
+		return *field
+	case *[]string:
+		return strings.Join(*field, ",")
+	case *bool:
+		return strconv.FormatBool(*field)
+	case *int:
+		return strconv.Itoa(*field)
+	case *float64:
+		return strconv.FormatFloat(*field, 'g', -1, 64)
+	case *time.Duration:
+		return field.String()
+	}
+
+	return ""
+}
+
+// passwordPattern finds the password of a keyword/value connection string such as "host=db password=secret"
+var passwordPattern = regexp.MustCompile(`(password=)\S+`)
+
+// redact hides the credentials of connection strings and the whole of other secrets
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	redactedValues := []string{}
+	for _, item := range strings.Split(value, ",") {
+		parsed, err := url.Parse(item)
+		switch {
+		case err == nil && parsed.Scheme != "" && parsed.Host != "":
+			if password, hasPassword := parsed.User.Password(); hasPassword && password != "" {
+				parsed.User = url.UserPassword(parsed.User.Username(), redacted)
+			} else if parsed.User != nil {
+				// Redis URLs may carry the password in the user part
+				parsed.User = url.User(redacted)
+			}
+			query := parsed.Query()
+			if query.Has("password") {
+				query.Set("password", redacted)
+				parsed.RawQuery = query.Encode()
+			}
+			item = parsed.String()
+		case strings.Contains(item, "="):
+			item = passwordPattern.ReplaceAllString(item, "${1}"+redacted)
+		default:
+			item = redacted
+		}
+		redactedValues = append(redactedValues, item)
+	}
+
+	return strings.Join(redactedValues, ",")
+}
+
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// flagName turns DATABASE_URL into database-url
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
 }
@@ -1,33 +1,81 @@
 package config
 
 import (
-	"database/sql"
-	"log"
-	"time"
+	"context"
+	"log/slog"
+	"portto-assignment/internal/tracing"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
-	"github.com/spf13/viper"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func NewDatabaseConnectionPool() (*sql.DB, error) {
-	connectionString := viper.GetString("DATABASE_URL")
+// NewDatabaseConnectionPool opens a pgx pool, statements are prepared on first use and cached per connection
+func NewDatabaseConnectionPool(config DatabaseConfig) (*pgxpool.Pool, error) {
+	poolConfig, err := newDatabasePoolConfig(config.URL, config)
+	if err != nil {
+		slog.Error("Error parsing database url", "error", err)
+		return nil, err
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		slog.Error("Error connecting to database", "error", err)
+		return nil, err
+	}
 
-	db, err := sql.Open("pgx", connectionString)
+	err = pool.Ping(context.Background())
 	if err != nil {
-		log.Printf("error connecting to database: %v", err)
+		slog.Error("Error pinging database", "error", err)
+		pool.Close()
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
-	db.SetConnMaxIdleTime(5 * time.Minute)
+	return pool, nil
+}
+
+// NewDatabaseReplicaPools opens a pool per replica URL.
+// Replicas aren't pinged, an unreachable one is skipped by the read routing until it answers.
+func NewDatabaseReplicaPools(config DatabaseConfig) ([]*pgxpool.Pool, error) {
+	pools := []*pgxpool.Pool{}
+	for _, replicaUrl := range config.ReplicaURLs {
+		poolConfig, err := newDatabasePoolConfig(replicaUrl, config)
+		if err != nil {
+			slog.Error("Error parsing database replica url", "error", err)
+			closeDatabasePools(pools)
+			return nil, err
+		}
+		pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+		if err != nil {
+			slog.Error("Error connecting to database replica", "error", err)
+			closeDatabasePools(pools)
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+
+	return pools, nil
+}
 
-	err = db.Ping()
+func newDatabasePoolConfig(connectionString string, config DatabaseConfig) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(connectionString)
 	if err != nil {
-		log.Printf("error pinging database: %v", err)
 		return nil, err
 	}
 
-	return db, nil
+	poolConfig.MaxConns = int32(config.MaxConns)
+	poolConfig.MinConns = int32(config.MinConns)
+	poolConfig.MaxConnLifetime = config.MaxConnLifetime
+	poolConfig.MaxConnIdleTime = config.MaxConnIdleTime
+	poolConfig.HealthCheckPeriod = config.HealthCheckPeriod
+	poolConfig.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	poolConfig.ConnConfig.StatementCacheCapacity = config.StatementCacheCapacity
+	poolConfig.ConnConfig.Tracer = tracing.NewQueryTracer()
+
+	return poolConfig, nil
+}
+
+func closeDatabasePools(pools []*pgxpool.Pool) {
+	for _, pool := range pools {
+		pool.Close()
+	}
 }
@@ -2,24 +2,110 @@ package config
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
-	"github.com/spf13/viper"
 )
 
-func NewRedisClient() (*redis.Client, error) {
-	redisUrl := viper.GetString("REDIS_URL")
-	opts, err := redis.ParseURL(redisUrl)
+func NewRedisClient(config RedisConfig) (redis.UniversalClient, error) {
+	client, err := OpenRedisClient(config)
 	if err != nil {
 		return nil, err
 	}
 
-	client := redis.NewClient(opts)
-
 	_, err = client.Ping(context.Background()).Result()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// OpenRedisClient creates a client for the Redis mode without checking that Redis is reachable, so the API can start degraded.
+// Every command is traced.
+func OpenRedisClient(config RedisConfig) (redis.UniversalClient, error) {
+	client, err := openRedisClient(config)
 	if err != nil {
 		return nil, err
 	}
 
+	err = redisotel.InstrumentTracing(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
 	return client, nil
 }
+
+func openRedisClient(config RedisConfig) (redis.UniversalClient, error) {
+	switch config.Mode {
+	case "", RedisModeStandalone:
+		opts, err := redis.ParseURL(config.URL)
+		if err != nil {
+			return nil, err
+		}
+		return redis.NewClient(opts), nil
+	case RedisModeSentinel:
+		opts, err := newRedisFailoverOptions(config)
+		if err != nil {
+			return nil, err
+		}
+		return redis.NewFailoverClient(opts), nil
+	case RedisModeCluster:
+		opts, err := newRedisClusterOptions(config)
+		if err != nil {
+			return nil, err
+		}
+		return redis.NewClusterClient(opts), nil
+	default:
+		return nil, fmt.Errorf("unknown Redis mode %q", config.Mode)
+	}
+}
+
+// newRedisFailoverOptions asks the sentinels for the master, REDIS_URL only provides the credentials and database when set
+func newRedisFailoverOptions(config RedisConfig) (*redis.FailoverOptions, error) {
+	opts := &redis.FailoverOptions{
+		MasterName:       config.SentinelMaster,
+		SentinelAddrs:    config.SentinelAddrs,
+		SentinelPassword: config.SentinelPassword,
+	}
+	if opts.MasterName == "" || len(opts.SentinelAddrs) == 0 {
+		return nil, errors.New("REDIS_SENTINEL_MASTER and REDIS_SENTINEL_ADDRS are required in sentinel mode")
+	}
+
+	if config.URL != "" {
+		urlOpts, err := redis.ParseURL(config.URL)
+		if err != nil {
+			return nil, err
+		}
+		opts.Username = urlOpts.Username
+		opts.Password = urlOpts.Password
+		opts.DB = urlOpts.DB
+		opts.Protocol = urlOpts.Protocol
+		opts.TLSConfig = urlOpts.TLSConfig
+	}
+
+	return opts, nil
+}
+
+// newRedisClusterOptions discovers the cluster from the REDIS_URL node and the REDIS_CLUSTER_ADDRS nodes
+func newRedisClusterOptions(config RedisConfig) (*redis.ClusterOptions, error) {
+	opts := &redis.ClusterOptions{}
+	if config.URL != "" {
+		urlOpts, err := redis.ParseClusterURL(config.URL)
+		if err != nil {
+			return nil, err
+		}
+		opts = urlOpts
+	}
+	opts.Addrs = append(opts.Addrs, config.ClusterAddrs...)
+	if len(opts.Addrs) == 0 {
+		return nil, errors.New("REDIS_URL or REDIS_CLUSTER_ADDRS is required in cluster mode")
+	}
+
+	return opts, nil
+}
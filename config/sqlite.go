@@ -0,0 +1,34 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/url"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// OpenSQLiteDatabase opens the database file of the sqlite driver, it is created when missing
+func OpenSQLiteDatabase(config StorageConfig) (*sql.DB, error) {
+	// Writers wait for the lock instead of failing, and readers don't block the writer
+	query := url.Values{}
+	query.Set("_busy_timeout", "5000")
+	query.Set("_journal_mode", "WAL")
+	db, err := sql.Open("sqlite3", "file:"+config.SQLitePath+"?"+query.Encode())
+	if err != nil {
+		slog.Error("Error opening SQLite database", "error", err)
+		return nil, err
+	}
+	// SQLite allows one writer at a time, a single connection queues them in the pool instead
+	db.SetMaxOpenConns(1)
+
+	err = db.PingContext(context.Background())
+	if err != nil {
+		slog.Error("Error opening SQLite database", "error", err)
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
@@ -2,9 +2,12 @@ package config
 
 import (
 	"context"
+	"flag"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type DatabaseConnectionPoolInterface interface {
@@ -13,4 +16,176 @@ type DatabaseConnectionPoolInterface interface {
 	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 	Begin(ctx context.Context) (pgx.Tx, error)
+	Ping(ctx context.Context) error
+	Stat() *pgxpool.Stat
 }
+
+const (
+	// DefaultDatabaseMaxConns is the largest number of open connections in the pool
+	DefaultDatabaseMaxConns = 25
+
+	// DefaultDatabaseMinConns is the number of connections kept open when idle
+	DefaultDatabaseMinConns = 5
+
+	// DefaultDatabaseMaxConnLifetime is how long a connection is used before it is replaced
+	DefaultDatabaseMaxConnLifetime = 5 * time.Minute
+
+	// DefaultDatabaseMaxConnIdleTime is how long an idle connection is kept above the minimum
+	DefaultDatabaseMaxConnIdleTime = 5 * time.Minute
+
+	// DefaultDatabaseHealthCheckPeriod is how often idle connections are checked
+	DefaultDatabaseHealthCheckPeriod = time.Minute
+
+	// DefaultDatabaseStatementCacheCapacity is the number of prepared statements cached per connection
+	DefaultDatabaseStatementCacheCapacity = 512
+)
+
+const (
+	// StorageDriverPostgres keeps the meme coins in PostgreSQL and the popularity scores in Redis
+	StorageDriverPostgres = "postgres"
+
+	// StorageDriverSQLite keeps everything in one process, the meme coins in the SQLite file of SQLITE_PATH
+	StorageDriverSQLite = "sqlite"
+
+	// DefaultSQLitePath is the database file of the sqlite driver
+	DefaultSQLitePath = "memecoin.db"
+)
+
+const (
+	// RedisModeStandalone connects to the single node of REDIS_URL
+	RedisModeStandalone = "standalone"
+
+	// RedisModeSentinel connects to the master that the sentinels of REDIS_SENTINEL_ADDRS elect
+	RedisModeSentinel = "sentinel"
+
+	// RedisModeCluster connects to a Redis Cluster, keys are spread across the masters by hash slot
+	RedisModeCluster = "cluster"
+)
+
+// Config holds every setting of the API and memecoinctl, zero values of the tuning settings use the defaults of the package they configure
+type Config struct {
+	// Environment picks the config file read by default, config/config.env.local for "local"
+	Environment string
+	HTTP        HTTPConfig
+	Storage     StorageConfig
+	Database    DatabaseConfig
+	Redis       RedisConfig
+	Sync        SyncConfig
+	Cache       CacheConfig
+	Poke        PokeConfig
+	Log         LogConfig
+	Tracing     TracingConfig
+	Features    FeatureConfig
+}
+
+type HTTPConfig struct {
+	Addr string
+	// ShutdownTimeout is how long in-flight requests get to complete on shutdown
+	ShutdownTimeout time.Duration
+}
+
+type StorageConfig struct {
+	// Driver is postgres or sqlite, DATABASE_* and REDIS_* settings are only read by postgres
+	Driver     string
+	SQLitePath string
+}
+
+type DatabaseConfig struct {
+	URL         string
+	ReplicaURLs []string
+	MaxConns    int
+	MinConns    int
+	// MaxConnLifetime is how long a connection is used before it is replaced
+	MaxConnLifetime        time.Duration
+	MaxConnIdleTime        time.Duration
+	HealthCheckPeriod      time.Duration
+	StatementCacheCapacity int
+}
+
+type RedisConfig struct {
+	URL string
+	// Mode is standalone, sentinel or cluster
+	Mode             string
+	SentinelMaster   string
+	SentinelAddrs    []string
+	SentinelPassword string
+	ClusterAddrs     []string
+	ScoreLayout      string
+	ScoreBucketSize  int
+	KeyNamespace     string
+	KeyEnvironment   string
+	KeyHashTags      bool
+}
+
+type SyncConfig struct {
+	BatchSize int
+	Interval  time.Duration
+	// ReconcileInterval disables the reconciliation when zero
+	ReconcileInterval time.Duration
+	ReconcilePolicy   string
+}
+
+type CacheConfig struct {
+	Size int
+	TTL  time.Duration
+}
+
+type PokeConfig struct {
+	// FlushInterval disables the buffering of pokes when zero
+	FlushInterval  time.Duration
+	MaxPendingKeys int
+	ExistenceTTL   time.Duration
+}
+
+type LogConfig struct {
+	Level            string
+	Format           string
+	AccessSampleRate float64
+}
+
+type TracingConfig struct {
+	Exporter    string
+	File        string
+	SampleRatio float64
+	ServiceName string
+}
+
+type FeatureConfig struct {
+	// APIKeyAuth requires an API key on the routes changing or dumping the catalogue
+	APIKeyAuth bool
+	// OpenAPIValidation rejects the requests that don't match api/openapi.yaml
+	OpenAPIValidation bool
+}
+
+type LoadOptions struct {
+	// File is the env file to read, config/config.env.<SERVICE_ENV> may be missing when it is empty
+	File string
+	// Flags holds the settings given on the command line, it must be registered with RegisterFlags and parsed
+	Flags *flag.FlagSet
+}
+
+// setting is a configuration key, field returns the pointer to the Config field it is loaded into
+type setting struct {
+	key   string
+	usage string
+	// secret values are redacted when the configuration is printed
+	secret bool
+	field  func(config *Config) any
+}
+
+const (
+	// ConfigFileFlag is the flag naming the env file to read
+	ConfigFileFlag = "config"
+
+	// DefaultEnvironment is the environment when SERVICE_ENV isn't set
+	DefaultEnvironment = "local"
+
+	// DefaultHTTPAddr is the address the API listens on
+	DefaultHTTPAddr = ":8080"
+
+	// DefaultShutdownTimeout is how long in-flight requests get to complete on shutdown
+	DefaultShutdownTimeout = 10 * time.Second
+
+	// redacted replaces the secrets when the configuration is printed
+	redacted = "xxxxx"
+)
@@ -0,0 +1,174 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"portto-assignment/assets"
+	"portto-assignment/config"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type Migration struct {
+	Version   string
+	AppliedAt *time.Time
+}
+
+// migrationLockKey is the advisory lock held while migrating, instances starting together apply the migrations one after the other
+const migrationLockKey int64 = 0x6d656d65636f696e
+
+// Migrate applies every migration embedded from assets/sql/migrations that has not been applied yet, in file name order
+func Migrate(db config.DatabaseConnectionPoolInterface) ([]string, error) {
+	ctx := context.Background()
+
+	// The migrations are committed together with their bookkeeping rows, or not at all
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The lock is taken before checking what is applied, so the instances waiting on it find the migrations of the first one
+	_, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockKey)
+	if err != nil {
+		return nil, err
+	}
+	err = createMigrationsTable(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := status(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	appliedVersions := []string{}
+	for _, migration := range migrations {
+		if migration.AppliedAt != nil {
+			continue
+		}
+
+		err := applyMigration(ctx, tx, migration.Version)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", migration.Version, err)
+		}
+		appliedVersions = append(appliedVersions, migration.Version)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range appliedVersions {
+		slog.Info("Applied migration", "version", version)
+	}
+
+	return appliedVersions, nil
+}
+
+// Status lists every migration file together with the time it was applied, if it was
+func Status(db config.DatabaseConnectionPoolInterface) ([]Migration, error) {
+	ctx := context.Background()
+	err := createMigrationsTable(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return status(ctx, db)
+}
+
+// executor is satisfied by both the connection pool and a transaction
+type executor interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func status(ctx context.Context, db executor) ([]Migration, error) {
+	versions, err := listMigrationFiles(assets.Migrations())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := map[string]time.Time{}
+	for rows.Next() {
+		var version string
+		var at time.Time
+		err := rows.Scan(&version, &at)
+		if err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, len(versions))
+	for i, version := range versions {
+		migrations[i].Version = version
+		if at, ok := appliedAt[version]; ok {
+			migrations[i].AppliedAt = &at
+		}
+	}
+
+	return migrations, nil
+}
+
+func createMigrationsTable(ctx context.Context, db executor) error {
+	_, err := db.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version text PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+
+	return err
+}
+
+func applyMigration(ctx context.Context, tx pgx.Tx, version string) error {
+	sqlBinary, err := fs.ReadFile(assets.Migrations(), version+".sql")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, string(sqlBinary))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version)
+
+	return err
+}
+
+func listMigrationFiles(files fs.FS) ([]string, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		versions = append(versions, strings.TrimSuffix(entry.Name(), ".sql"))
+	}
+	if len(versions) == 0 {
+		return nil, errors.New("no migrations embedded")
+	}
+	sort.Strings(versions)
+
+	return versions, nil
+}
@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"portto-assignment/assets"
+)
+
+// MigrateSQLite applies the migrations embedded from assets/sql/sqlite that have not been applied yet, in file name order
+func MigrateSQLite(db *sql.DB) ([]string, error) {
+	ctx := context.Background()
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := listMigrationFiles(assets.SQLiteMigrations())
+	if err != nil {
+		return nil, err
+	}
+
+	appliedVersions := []string{}
+	for _, version := range versions {
+		var applied bool
+		err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = ?)", version).Scan(&applied)
+		if err != nil {
+			return appliedVersions, err
+		}
+		if applied {
+			continue
+		}
+
+		err = applySQLiteMigration(ctx, db, version)
+		if err != nil {
+			return appliedVersions, fmt.Errorf("migration %s: %w", version, err)
+		}
+		slog.Info("Applied migration", "version", version)
+		appliedVersions = append(appliedVersions, version)
+	}
+
+	return appliedVersions, nil
+}
+
+func applySQLiteMigration(ctx context.Context, db *sql.DB, version string) error {
+	sqlBinary, err := fs.ReadFile(assets.SQLiteMigrations(), version+".sql")
+	if err != nil {
+		return err
+	}
+
+	// The migration and its bookkeeping row are committed together
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, string(sqlBinary))
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES (?)", version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
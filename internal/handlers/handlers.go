@@ -1,33 +1,31 @@
 package handlers
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"portto-assignment/internal/services"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func NewMemeCoinHandler(service services.MemeCoinServiceInterface) *MemeCoinHandler {
+func NewMemeCoinHandler(service services.MemeCoinServiceInterface, logger *slog.Logger) *MemeCoinHandler {
+	// Apply defaults if values aren't specified
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	memeCoinHandler := MemeCoinHandler{
 		service: service,
+		logger:  logger,
 	}
 	return &memeCoinHandler
 }
 
-// CreateMemeCoin godoc
-//
-//	@Summary	Create a MemeCoin
-//	@Tags		MemeCoin
-//	@Accept		json
-//	@Produce	json
-//	@Param		body   body handlers.CreateMemeCoinRequestBody true "Request body"
-//	@Success	200			{object}	repositories.MemeCoin
-//	@Failure	400			{object}	handlers.HttpError
-//	@Failure	404			{object}	handlers.HttpError
-//	@Failure	409			{object}	handlers.HttpError
-//	@Failure	500			{object}	handlers.HttpError
-//	@Router		/create [post]
+// CreateMemeCoin answers 409 when the name is taken
 func (handler *MemeCoinHandler) CreateMemeCoin(context *gin.Context) {
 	// Get request body
 	var reqBody *CreateMemeCoinRequestBody
@@ -43,12 +41,12 @@ func (handler *MemeCoinHandler) CreateMemeCoin(context *gin.Context) {
 	// Call service
 	name := reqBody.Name
 	description := reqBody.Description
-	newMemeCoin, err := handler.service.CreateMemeCoin(services.CreateMemeCoinInput{
+	newMemeCoin, err := handler.service.CreateMemeCoin(context.Request.Context(), services.CreateMemeCoinInput{
 		Name:        name,
 		Description: description,
 	})
 	if err != nil {
-		log.Printf("Failed to create meme coin: %v", err)
+		handler.logger.ErrorContext(context.Request.Context(), "Failed to create meme coin", "error", err)
 		context.JSON(http.StatusInternalServerError, HttpError{
 			Message: "Database Error",
 			Error:   err.Error(),
@@ -67,18 +65,7 @@ func (handler *MemeCoinHandler) CreateMemeCoin(context *gin.Context) {
 	context.JSON(http.StatusOK, newMemeCoin)
 }
 
-// GetMemeCoin   godoc
-//
-//	@Summary	Get a MemeCoin
-//	@Tags		MemeCoin
-//	@Accept		json
-//	@Produce	json
-//	@Param		id	path		int	true	"MemeCoin ID"
-//	@Success	200	{object}	repositories.MemeCoin
-//	@Failure	400	{object}	handlers.HttpError
-//	@Failure	404	{object}	handlers.HttpError
-//	@Failure	500	{object}	handlers.HttpError
-//	@Router		/{id} [get]
+// GetMemeCoin returns the MemeCoin with its popularity score
 func (handler *MemeCoinHandler) GetMemeCoin(context *gin.Context) {
 	var urlParams *struct {
 		Id int `uri:"id" binding:"required"`
@@ -93,7 +80,7 @@ func (handler *MemeCoinHandler) GetMemeCoin(context *gin.Context) {
 	}
 
 	id := urlParams.Id
-	memeCoin, err := handler.service.GetMemeCoin(id)
+	memeCoin, err := handler.service.GetMemeCoin(context.Request.Context(), id)
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, HttpError{
 			Message: "Database Error",
@@ -113,19 +100,7 @@ func (handler *MemeCoinHandler) GetMemeCoin(context *gin.Context) {
 	context.JSON(http.StatusOK, memeCoin)
 }
 
-// UpdateMemeCoin  godoc
-//
-//	@Summary	Update a MemeCoin
-//	@Tags		MemeCoin
-//	@Accept	json
-//	@Produce json
-//	@Param id path int true	"MemeCoin ID"
-//	@Param body body handlers.UpdateMemeCoinRequestBody true "Request body"
-//	@Success	200			{object}	repositories.MemeCoin
-//	@Failure	400			{object}	handlers.HttpError
-//	@Failure	404			{object}	handlers.HttpError
-//	@Failure	500			{object}	handlers.HttpError
-//	@Router		/{id} [patch]
+// UpdateMemeCoin changes the description, the only field that can be updated
 func (handler *MemeCoinHandler) UpdateMemeCoin(context *gin.Context) {
 	var urlParams *struct {
 		Id int `uri:"id" binding:"required"`
@@ -151,7 +126,7 @@ func (handler *MemeCoinHandler) UpdateMemeCoin(context *gin.Context) {
 		return
 	}
 
-	updatedMemeCoin, err := handler.service.UpdateMemeCoin(urlParams.Id, reqBody.Description)
+	updatedMemeCoin, err := handler.service.UpdateMemeCoin(context.Request.Context(), urlParams.Id, reqBody.Description)
 	if updatedMemeCoin == nil {
 		context.JSON(http.StatusNotFound, HttpError{
 			Message: "MemeCoin not found",
@@ -170,18 +145,7 @@ func (handler *MemeCoinHandler) UpdateMemeCoin(context *gin.Context) {
 	context.JSON(http.StatusOK, updatedMemeCoin)
 }
 
-// DeleteMemeCoin   godoc
-//
-//	@Summary	Delete a MemeCoin
-//	@Tags		MemeCoin
-//	@Accept		json
-//	@Produce	json
-//	@Param		id	path		int	true	"MemeCoin ID"
-//	@Success	200	{object}	repositories.MemeCoin
-//	@Failure	400	{object}	handlers.HttpError
-//	@Failure	404	{object}	handlers.HttpError
-//	@Failure	500	{object}	handlers.HttpError
-//	@Router		/{id} [delete]
+// DeleteMemeCoin returns the MemeCoin as it was before the delete
 func (handler *MemeCoinHandler) DeleteMemeCoin(context *gin.Context) {
 	var urlParams *struct {
 		Id int `uri:"id" binding:"required"`
@@ -196,7 +160,7 @@ func (handler *MemeCoinHandler) DeleteMemeCoin(context *gin.Context) {
 	}
 
 	id := urlParams.Id
-	deletedMemeCoin, err := handler.service.DeleteMemeCoin(id)
+	deletedMemeCoin, err := handler.service.DeleteMemeCoin(context.Request.Context(), id)
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, HttpError{
 			Message: "Database Error",
@@ -216,18 +180,7 @@ func (handler *MemeCoinHandler) DeleteMemeCoin(context *gin.Context) {
 	context.JSON(http.StatusOK, deletedMemeCoin)
 }
 
-// PokeMemeCoin  godoc
-//
-//	@Summary	Poke a MemeCoin
-//	@Tags		MemeCoin
-//	@Accept		json
-//	@Produce	json
-//	@Param		id			path		int		true	"MemeCoin ID"
-//	@Success	204
-//	@Failure	400			{object}	handlers.HttpError
-//	@Failure	404			{object}	handlers.HttpError
-//	@Failure	500			{object}	handlers.HttpError
-//	@Router		/{id}/poke [post]
+// PokeMemeCoin adds one to the popularity score
 func (handler *MemeCoinHandler) PokeMemeCoin(context *gin.Context) {
 	var reqBody *struct {
 		Id int `uri:"id" binding:"required"`
@@ -242,7 +195,7 @@ func (handler *MemeCoinHandler) PokeMemeCoin(context *gin.Context) {
 	}
 
 	id := reqBody.Id
-	err = handler.service.PokeMemeCoin(id)
+	err = handler.service.PokeMemeCoin(context.Request.Context(), id)
 	if err != nil && err.Error() == "no such meme coin" {
 		context.JSON(http.StatusNotFound, HttpError{
 			Message: "MemeCoin not found",
@@ -260,3 +213,229 @@ func (handler *MemeCoinHandler) PokeMemeCoin(context *gin.Context) {
 
 	context.JSON(http.StatusNoContent, nil)
 }
+
+// BatchCreateMemeCoins reports a result per item, names already taken are conflicts
+func (handler *MemeCoinHandler) BatchCreateMemeCoins(context *gin.Context) {
+	var reqBody *BatchCreateMemeCoinsRequestBody
+	err := context.ShouldBindJSON(&reqBody)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Invalid request body",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if len(reqBody.Items) > services.MaxBatchCreateSize {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Too many MemeCoins",
+			Error:   fmt.Sprintf("At most %d MemeCoins can be created in one batch", services.MaxBatchCreateSize),
+		})
+		return
+	}
+
+	inputs := make([]services.CreateMemeCoinInput, len(reqBody.Items))
+	for i, item := range reqBody.Items {
+		inputs[i] = services.CreateMemeCoinInput{
+			Name:        item.Name,
+			Description: item.Description,
+		}
+	}
+	results, err := handler.service.CreateMemeCoins(context.Request.Context(), inputs)
+	if err != nil {
+		handler.logger.ErrorContext(context.Request.Context(), "Failed to create meme coins", "error", err)
+		context.JSON(http.StatusInternalServerError, HttpError{
+			Message: "Database Error",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	response := BatchCreateMemeCoinsResponse{
+		Results: results,
+	}
+	for _, result := range results {
+		if result.Status == services.BatchStatusCreated {
+			response.Created++
+		} else {
+			response.Conflicts++
+		}
+	}
+
+	context.JSON(http.StatusOK, response)
+}
+
+// BatchGetMemeCoins takes comma separated IDs, the ones that do not exist are listed in "not_found"
+func (handler *MemeCoinHandler) BatchGetMemeCoins(context *gin.Context) {
+	idsParam := context.Query("ids")
+	if idsParam == "" {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Invalid MemeCoin IDs",
+			Error:   "Query parameter \"ids\" is required",
+		})
+		return
+	}
+
+	tokens := strings.Split(idsParam, ",")
+	if len(tokens) > services.MaxBatchGetSize {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Too many MemeCoin IDs",
+			Error:   fmt.Sprintf("At most %d MemeCoins can be fetched in one batch", services.MaxBatchGetSize),
+		})
+		return
+	}
+
+	ids := make([]int, len(tokens))
+	for i, token := range tokens {
+		id, err := strconv.Atoi(strings.TrimSpace(token))
+		if err != nil || id <= 0 {
+			context.JSON(http.StatusBadRequest, HttpError{
+				Message: "Invalid MemeCoin IDs",
+				Error:   "Wrong ID format",
+			})
+			return
+		}
+		ids[i] = id
+	}
+
+	result, err := handler.service.GetMemeCoins(context.Request.Context(), ids)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, HttpError{
+			Message: "Database Error",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	context.JSON(http.StatusOK, result)
+}
+
+// BatchPokeMemeCoins maps a MemeCoin ID to its number of pokes
+func (handler *MemeCoinHandler) BatchPokeMemeCoins(context *gin.Context) {
+	var reqBody *BatchPokeMemeCoinsRequestBody
+	err := context.ShouldBindJSON(&reqBody)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Invalid request body",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if len(reqBody.Pokes) > services.MaxBatchPokeSize {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Too many MemeCoins",
+			Error:   fmt.Sprintf("At most %d MemeCoins can be poked in one batch", services.MaxBatchPokeSize),
+		})
+		return
+	}
+
+	for id, count := range reqBody.Pokes {
+		if id <= 0 || count <= 0 || count > services.MaxPokeCount {
+			context.JSON(http.StatusBadRequest, HttpError{
+				Message: "Invalid request body",
+				Error:   fmt.Sprintf("Poke count must be between 1 and %d for a positive MemeCoin ID", services.MaxPokeCount),
+			})
+			return
+		}
+	}
+
+	results, err := handler.service.PokeMemeCoins(context.Request.Context(), reqBody.Pokes)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, HttpError{
+			Message: "Database Error",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	context.JSON(http.StatusOK, BatchPokeMemeCoinsResponse{
+		Results: results,
+	})
+}
+
+// ExportMemeCoins streams the whole catalogue as CSV or newline delimited JSON
+func (handler *MemeCoinHandler) ExportMemeCoins(context *gin.Context) {
+	format := context.DefaultQuery("format", services.FormatCSV)
+	contentType, ok := transferContentTypes[format]
+	if !ok {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Invalid format",
+			Error:   "Format must be csv or ndjson",
+		})
+		return
+	}
+
+	context.Header("Content-Type", contentType)
+	context.Header("Content-Disposition", fmt.Sprintf("attachment; filename=meme_coins.%s", format))
+	context.Status(http.StatusOK)
+
+	err := handler.service.ExportMemeCoins(context.Request.Context(), context.Writer, format)
+	if err != nil && !context.Writer.Written() {
+		context.Writer.Header().Del("Content-Type")
+		context.Writer.Header().Del("Content-Disposition")
+		context.JSON(http.StatusInternalServerError, HttpError{
+			Message: "Database Error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		// The status is already sent once rows are streamed, so a failure can only cut the response short
+		handler.logger.ErrorContext(context.Request.Context(), "Failed to export meme coins", "error", err)
+		context.Abort()
+	}
+}
+
+// ImportMemeCoins upserts MemeCoins by name, invalid rows are listed in the report
+func (handler *MemeCoinHandler) ImportMemeCoins(context *gin.Context) {
+	format := context.Query("format")
+	if format == "" {
+		for transferFormat, contentType := range transferContentTypes {
+			if context.ContentType() == contentType {
+				format = transferFormat
+			}
+		}
+	}
+	if _, ok := transferContentTypes[format]; !ok {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Invalid format",
+			Error:   "Format must be csv or ndjson",
+		})
+		return
+	}
+
+	body := http.MaxBytesReader(context.Writer, context.Request.Body, MaxImportBodySize)
+	report, err := handler.service.ImportMemeCoins(context.Request.Context(), body, format)
+	var maxBytesError *http.MaxBytesError
+	if err != nil && errors.As(err, &maxBytesError) {
+		context.JSON(http.StatusRequestEntityTooLarge, ImportFailedResponse{
+			HttpError: HttpError{
+				Message: "Import file too large",
+				Error:   fmt.Sprintf("Import file must not exceed %d bytes", MaxImportBodySize),
+			},
+			Report: report,
+		})
+		return
+	}
+	if err != nil && errors.Is(err, services.ErrMalformedImport) {
+		context.JSON(http.StatusBadRequest, ImportFailedResponse{
+			HttpError: HttpError{
+				Message: "Invalid import file",
+				Error:   err.Error(),
+			},
+			Report: report,
+		})
+		return
+	}
+	if err != nil {
+		handler.logger.ErrorContext(context.Request.Context(), "Failed to import meme coins", "error", err)
+		context.JSON(http.StatusInternalServerError, HttpError{
+			Message: "Database Error",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	context.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"portto-assignment/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NewHealthHandler(service services.HealthServiceInterface) *HealthHandler {
+	return &HealthHandler{
+		service: service,
+	}
+}
+
+// Live answers as long as the process serves HTTP
+func (handler *HealthHandler) Live(context *gin.Context) {
+	context.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Ready stays ready while Redis is down, with the status reported as degraded
+func (handler *HealthHandler) Ready(context *gin.Context) {
+	readiness := handler.service.Readiness()
+	if readiness.Status == services.ReadinessStatusUnavailable {
+		context.JSON(http.StatusServiceUnavailable, readiness)
+		return
+	}
+
+	context.JSON(http.StatusOK, readiness)
+}
+
+// DatabasePoolStats reports the database connection pool usage
+func (handler *HealthHandler) DatabasePoolStats(context *gin.Context) {
+	context.JSON(http.StatusOK, handler.service.DatabasePoolStats())
+}
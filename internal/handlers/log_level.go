@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"portto-assignment/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NewLogLevelHandler(level *slog.LevelVar) *LogLevelHandler {
+	return &LogLevelHandler{
+		level: level,
+	}
+}
+
+// GetLogLevel reports the minimum level currently logged
+func (handler *LogLevelHandler) GetLogLevel(context *gin.Context) {
+	context.JSON(http.StatusOK, LogLevelBody{Level: handler.level.Level().String()})
+}
+
+// SetLogLevel changes the minimum level logged until the next restart
+func (handler *LogLevelHandler) SetLogLevel(context *gin.Context) {
+	var reqBody LogLevelBody
+	err := context.BindJSON(&reqBody)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Invalid request body",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	level, err := logging.ParseLevel(reqBody.Level)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Invalid log level",
+			Error:   err.Error(),
+		})
+		return
+	}
+	handler.level.Set(level)
+
+	context.JSON(http.StatusOK, LogLevelBody{Level: level.String()})
+}
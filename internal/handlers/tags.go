@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"portto-assignment/internal/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NewTagHandler(service services.TagServiceInterface, logger *slog.Logger) *TagHandler {
+	// Apply defaults if values aren't specified
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &TagHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// AddMemeCoinTag puts the tag on the MemeCoin, creating the tag on first use
+func (handler *TagHandler) AddMemeCoinTag(context *gin.Context) {
+	var urlParams *struct {
+		Id  int    `uri:"id" binding:"required"`
+		Tag string `uri:"tag" binding:"required"`
+	}
+	err := context.ShouldBindUri(&urlParams)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Invalid MemeCoin ID",
+			Error:   "Wrong ID format",
+		})
+		return
+	}
+
+	tag, err := handler.service.AddTag(context.Request.Context(), urlParams.Id, urlParams.Tag)
+	if handler.writeError(context, err, "Failed to add tag") {
+		return
+	}
+	if tag == nil {
+		context.JSON(http.StatusNotFound, HttpError{
+			Message: "MemeCoin not found",
+			Error:   "MemeCoin with the given ID does not exist",
+		})
+		return
+	}
+
+	context.JSON(http.StatusOK, tag)
+}
+
+// RemoveMemeCoinTag answers 404 when the MemeCoin doesn't have the tag
+func (handler *TagHandler) RemoveMemeCoinTag(context *gin.Context) {
+	var urlParams *struct {
+		Id  int    `uri:"id" binding:"required"`
+		Tag string `uri:"tag" binding:"required"`
+	}
+	err := context.ShouldBindUri(&urlParams)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Invalid MemeCoin ID",
+			Error:   "Wrong ID format",
+		})
+		return
+	}
+
+	removed, err := handler.service.RemoveTag(context.Request.Context(), urlParams.Id, urlParams.Tag)
+	if handler.writeError(context, err, "Failed to remove tag") {
+		return
+	}
+	if !removed {
+		context.JSON(http.StatusNotFound, HttpError{
+			Message: "Tag not found",
+			Error:   "MemeCoin with the given ID does not have the tag",
+		})
+		return
+	}
+
+	context.JSON(http.StatusNoContent, nil)
+}
+
+func (handler *TagHandler) GetMemeCoinTags(context *gin.Context) {
+	var urlParams *struct {
+		Id int `uri:"id" binding:"required"`
+	}
+	err := context.ShouldBindUri(&urlParams)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Invalid MemeCoin ID",
+			Error:   "Wrong ID format",
+		})
+		return
+	}
+
+	tags, err := handler.service.GetMemeCoinTags(context.Request.Context(), urlParams.Id)
+	if handler.writeError(context, err, "Failed to get tags") {
+		return
+	}
+	if tags == nil {
+		context.JSON(http.StatusNotFound, HttpError{
+			Message: "MemeCoin not found",
+			Error:   "MemeCoin with the given ID does not exist",
+		})
+		return
+	}
+
+	context.JSON(http.StatusOK, MemeCoinTagsResponse{Tags: tags})
+}
+
+// ListTags counts the MemeCoins of every tag, most used first, filtered by the category query parameter
+func (handler *TagHandler) ListTags(context *gin.Context) {
+	tagCounts, err := handler.service.ListTags(context.Request.Context(), context.Query("category"))
+	if handler.writeError(context, err, "Failed to list tags") {
+		return
+	}
+
+	context.JSON(http.StatusOK, ListTagsResponse{Tags: tagCounts})
+}
+
+// ListMemeCoinsByTag pages through the MemeCoins with the tag, next_after is passed as after to get the next page
+func (handler *TagHandler) ListMemeCoinsByTag(context *gin.Context) {
+	afterId, err := strconv.Atoi(context.DefaultQuery("after", "0"))
+	if err != nil || afterId < 0 {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Invalid after",
+			Error:   "After must be a MemeCoin ID",
+		})
+		return
+	}
+	limit, ok := handler.parseLimit(context, services.DefaultTagPageSize, services.MaxTagPageSize)
+	if !ok {
+		return
+	}
+
+	page, err := handler.service.ListMemeCoinsByTag(context.Request.Context(), context.Param("tag"), afterId, limit)
+	if handler.writeError(context, err, "Failed to list meme coins by tag") {
+		return
+	}
+
+	context.JSON(http.StatusOK, page)
+}
+
+// GetTagLeaderboard ranks the MemeCoins with the tag by their live popularity score
+func (handler *TagHandler) GetTagLeaderboard(context *gin.Context) {
+	limit, ok := handler.parseLimit(context, services.DefaultLeaderboardSize, services.MaxLeaderboardSize)
+	if !ok {
+		return
+	}
+
+	entries, err := handler.service.GetLeaderboard(context.Request.Context(), context.Param("tag"), limit)
+	if handler.writeError(context, err, "Failed to get tag leaderboard") {
+		return
+	}
+
+	context.JSON(http.StatusOK, TagLeaderboardResponse{Tag: context.Param("tag"), Entries: entries})
+}
+
+func (handler *TagHandler) ListCategories(context *gin.Context) {
+	categories, err := handler.service.ListCategories(context.Request.Context())
+	if handler.writeError(context, err, "Failed to list categories") {
+		return
+	}
+
+	context.JSON(http.StatusOK, ListCategoriesResponse{Categories: categories})
+}
+
+// CreateCategory answers 409 when the name is taken
+func (handler *TagHandler) CreateCategory(context *gin.Context) {
+	var reqBody *CreateCategoryRequestBody
+	err := context.ShouldBindJSON(&reqBody)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Invalid request body",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	category, err := handler.service.CreateCategory(context.Request.Context(), reqBody.Name, reqBody.Description)
+	if handler.writeError(context, err, "Failed to create category") {
+		return
+	}
+	if category == nil {
+		context.JSON(http.StatusConflict, HttpError{
+			Message: "Category already exists",
+			Error:   "Category with the same name already exists",
+		})
+		return
+	}
+
+	context.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory leaves the tags of the category without one
+func (handler *TagHandler) DeleteCategory(context *gin.Context) {
+	category, err := handler.service.DeleteCategory(context.Request.Context(), context.Param("name"))
+	if handler.writeError(context, err, "Failed to delete category") {
+		return
+	}
+	if category == nil {
+		context.JSON(http.StatusNotFound, HttpError{
+			Message: "Category not found",
+			Error:   "Category with the given name does not exist",
+		})
+		return
+	}
+
+	context.JSON(http.StatusOK, category)
+}
+
+// SetTagCategory files the tag under the category, an empty category takes it out of its current one
+func (handler *TagHandler) SetTagCategory(context *gin.Context) {
+	var reqBody *SetTagCategoryRequestBody
+	err := context.ShouldBindJSON(&reqBody)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Invalid request body",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tag, err := handler.service.SetTagCategory(context.Request.Context(), context.Param("tag"), reqBody.Category)
+	if handler.writeError(context, err, "Failed to set tag category") {
+		return
+	}
+	if tag == nil {
+		context.JSON(http.StatusNotFound, HttpError{
+			Message: "Tag or category not found",
+			Error:   "Tag or category with the given name does not exist",
+		})
+		return
+	}
+
+	context.JSON(http.StatusOK, tag)
+}
+
+// parseLimit answers 400 and returns false when the limit query parameter is out of range
+func (handler *TagHandler) parseLimit(context *gin.Context, defaultLimit int, maxLimit int) (int, bool) {
+	limit, err := strconv.Atoi(context.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if err != nil || limit <= 0 || limit > maxLimit {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Invalid limit",
+			Error:   fmt.Sprintf("Limit must be between 1 and %d", maxLimit),
+		})
+		return 0, false
+	}
+
+	return limit, true
+}
+
+// writeError answers 400 for invalid names and 500 otherwise, it returns false when there is no error
+func (handler *TagHandler) writeError(context *gin.Context, err error, message string) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, services.ErrInvalidName) {
+		context.JSON(http.StatusBadRequest, HttpError{
+			Message: "Invalid name",
+			Error:   err.Error(),
+		})
+		return true
+	}
+
+	handler.logger.ErrorContext(context.Request.Context(), message, "error", err)
+	context.JSON(http.StatusInternalServerError, HttpError{
+		Message: "Database Error",
+		Error:   err.Error(),
+	})
+	return true
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"log/slog"
+	"portto-assignment/internal/repositories"
 	"portto-assignment/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -20,14 +22,120 @@ type UpdateMemeCoinRequestBody struct {
 	Description string `json:"description" binding:"required"`
 }
 
+type BatchCreateMemeCoinsRequestBody struct {
+	Items []CreateMemeCoinRequestBody `json:"items" binding:"required,min=1,dive"`
+}
+
+type BatchCreateMemeCoinsResponse struct {
+	Created   int                                  `json:"created"`
+	Conflicts int                                  `json:"conflicts"`
+	Results   []services.BatchCreateMemeCoinResult `json:"results"`
+}
+
+// ImportFailedResponse reports the rows committed before the line the import failed at
+type ImportFailedResponse struct {
+	HttpError
+	Report *services.ImportReport `json:"report"`
+}
+
+type BatchPokeMemeCoinsRequestBody struct {
+	Pokes map[int]int `json:"pokes" binding:"required,min=1"`
+}
+
+type BatchPokeMemeCoinsResponse struct {
+	Results []services.BatchPokeMemeCoinResult `json:"results"`
+}
+
 type MemeCoinHandlerInterface interface {
 	CreateMemeCoin(context *gin.Context)
 	GetMemeCoin(context *gin.Context)
 	UpdateMemeCoin(context *gin.Context)
 	DeleteMemeCoin(context *gin.Context)
 	PokeMemeCoin(context *gin.Context)
+	BatchCreateMemeCoins(context *gin.Context)
+	BatchGetMemeCoins(context *gin.Context)
+	BatchPokeMemeCoins(context *gin.Context)
+	ExportMemeCoins(context *gin.Context)
+	ImportMemeCoins(context *gin.Context)
 }
 
 type MemeCoinHandler struct {
 	service services.MemeCoinServiceInterface
+	logger  *slog.Logger
+}
+
+type TagHandlerInterface interface {
+	AddMemeCoinTag(context *gin.Context)
+	RemoveMemeCoinTag(context *gin.Context)
+	GetMemeCoinTags(context *gin.Context)
+	ListTags(context *gin.Context)
+	ListMemeCoinsByTag(context *gin.Context)
+	GetTagLeaderboard(context *gin.Context)
+	ListCategories(context *gin.Context)
+	CreateCategory(context *gin.Context)
+	DeleteCategory(context *gin.Context)
+	SetTagCategory(context *gin.Context)
+}
+
+type TagHandler struct {
+	service services.TagServiceInterface
+	logger  *slog.Logger
+}
+
+type MemeCoinTagsResponse struct {
+	Tags []repositories.Tag `json:"tags"`
+}
+
+type ListTagsResponse struct {
+	Tags []repositories.TagCount `json:"tags"`
+}
+
+type TagLeaderboardResponse struct {
+	Tag     string                      `json:"tag"`
+	Entries []services.LeaderboardEntry `json:"entries"`
+}
+
+type ListCategoriesResponse struct {
+	Categories []repositories.Category `json:"categories"`
+}
+
+type CreateCategoryRequestBody struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description" binding:"-"`
+}
+
+type SetTagCategoryRequestBody struct {
+	// Category is empty to take the tag out of its category
+	Category string `json:"category" binding:"-"`
+}
+
+type HealthHandlerInterface interface {
+	Live(context *gin.Context)
+	Ready(context *gin.Context)
+	DatabasePoolStats(context *gin.Context)
+}
+
+type HealthHandler struct {
+	service services.HealthServiceInterface
+}
+
+type LogLevelHandlerInterface interface {
+	GetLogLevel(context *gin.Context)
+	SetLogLevel(context *gin.Context)
+}
+
+type LogLevelHandler struct {
+	level *slog.LevelVar
+}
+
+type LogLevelBody struct {
+	Level string `json:"level" binding:"required"`
+}
+
+// MaxImportBodySize is the largest import file accepted over HTTP
+const MaxImportBodySize = 64 * 1024 * 1024
+
+var transferContentTypes = map[string]string{
+	services.FormatCSV:    "text/csv",
+	services.FormatNDJSON: "application/x-ndjson",
 }
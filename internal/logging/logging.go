@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewLogger writes records of config.Level or above, with the request id and trace of the context when there are some
+func NewLogger(config LoggerConfig) *slog.Logger {
+	// Apply defaults if values aren't specified
+	if config.Level == nil {
+		config.Level = new(slog.LevelVar)
+	}
+	if config.Output == nil {
+		config.Output = os.Stderr
+	}
+
+	options := &slog.HandlerOptions{Level: config.Level}
+	var handler slog.Handler
+	if config.Format == FormatText {
+		handler = slog.NewTextHandler(config.Output, options)
+	} else {
+		handler = slog.NewJSONHandler(config.Output, options)
+	}
+
+	return slog.New(contextHandler{Handler: handler})
+}
+
+// ParseLevel validates a level name such as "debug" or "WARN", an empty name means info
+func ParseLevel(name string) (slog.Level, error) {
+	if name == "" {
+		return slog.LevelInfo, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+
+	return level, nil
+}
+
+// ParseFormat validates a format name, an empty name means json
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case "":
+		return FormatJSON, nil
+	case FormatJSON, FormatText:
+		return format, nil
+	}
+
+	return "", fmt.Errorf("unknown log format %q", name)
+}
+
+func WithRequestID(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestId)
+}
+
+// RequestID returns the id of the request the context belongs to, or an empty string
+func RequestID(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestId
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestId := RequestID(ctx); requestId != "" {
+		record.AddAttrs(slog.String(RequestIDAttribute, requestId))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String(TraceIDAttribute, spanContext.TraceID().String()),
+			slog.String(SpanIDAttribute, spanContext.SpanID().String()),
+		)
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
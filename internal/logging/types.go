@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"io"
+	"log/slog"
+)
+
+type LoggerConfig struct {
+	// Level is the minimum level written, changing it affects the logger right away
+	Level *slog.LevelVar
+	// Format is how records are written, json by default
+	Format Format
+	// Output is where records are written, stderr by default so CLI output stays pipeable
+	Output io.Writer
+}
+
+// Format is the encoding of the log records
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatText Format = "text"
+)
+
+// contextHandler adds the request id and the trace of the context to the records
+type contextHandler struct {
+	slog.Handler
+}
+
+type requestIDContextKey struct{}
+
+const (
+	// RequestIDAttribute is the attribute holding the request id in log records
+	RequestIDAttribute = "request_id"
+
+	// TraceIDAttribute and SpanIDAttribute link log records to the span they were written in
+	TraceIDAttribute = "trace_id"
+	SpanIDAttribute  = "span_id"
+)
@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewAccessLogMiddleware logs a sample of the requests, server errors are always logged
+func NewAccessLogMiddleware(logger *slog.Logger, config AccessLogConfig) *AccessLogMiddleware {
+	// Apply defaults if values aren't specified
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if config.SampleRate <= 0 || config.SampleRate > 1 {
+		config.SampleRate = DefaultAccessLogSampleRate
+	}
+
+	return &AccessLogMiddleware{
+		logger: logger,
+		config: config,
+	}
+}
+
+func (middleware *AccessLogMiddleware) Handle(context *gin.Context) {
+	start := time.Now()
+	context.Next()
+
+	status := context.Writer.Status()
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	} else if rand.Float64() >= middleware.config.SampleRate {
+		return
+	}
+
+	middleware.logger.LogAttrs(context.Request.Context(), level, "Request",
+		slog.String("method", context.Request.Method),
+		slog.String("path", context.Request.URL.Path),
+		slog.String("route", context.FullPath()),
+		slog.Int("status", status),
+		slog.Int("bytes", context.Writer.Size()),
+		slog.Duration("latency", time.Since(start)),
+		slog.String("client_ip", context.ClientIP()),
+	)
+}
@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"portto-assignment/internal/handlers"
+	"portto-assignment/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NewAPIKeyMiddleware(service services.APIKeyServiceInterface, logger *slog.Logger) *APIKeyMiddleware {
+	// Apply defaults if values aren't specified
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &APIKeyMiddleware{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (middleware *APIKeyMiddleware) Handle(context *gin.Context) {
+	key := context.GetHeader(APIKeyHeader)
+	if key == "" {
+		context.AbortWithStatusJSON(http.StatusUnauthorized, handlers.HttpError{
+			Message: "Unauthorized",
+			Error:   "X-API-Key header is required",
+		})
+		return
+	}
+
+	apiKey, err := middleware.service.Authenticate(key)
+	if err != nil {
+		middleware.logger.ErrorContext(context.Request.Context(), "Failed to authenticate api key", "error", err)
+		context.AbortWithStatusJSON(http.StatusInternalServerError, handlers.HttpError{
+			Message: "Database Error",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if apiKey == nil {
+		context.AbortWithStatusJSON(http.StatusUnauthorized, handlers.HttpError{
+			Message: "Unauthorized",
+			Error:   "API key is invalid or revoked",
+		})
+		return
+	}
+
+	context.Set(APIKeyContextKey, apiKey)
+	context.Next()
+}
@@ -0,0 +1,179 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"portto-assignment/internal/handlers"
+	"portto-assignment/internal/repositories"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NewIdempotencyMiddleware(repo repositories.IdempotencyRepositoryInterface, config IdempotencyConfig) *IdempotencyMiddleware {
+	// Apply defaults if values aren't specified
+	if config.TTL <= 0 {
+		config.TTL = DefaultIdempotencyTTL
+	}
+	if config.LockTTL <= 0 {
+		config.LockTTL = DefaultIdempotencyLockTTL
+	}
+	if config.RetryAfter < time.Second {
+		config.RetryAfter = DefaultIdempotencyRetryAfter
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	return &IdempotencyMiddleware{
+		repo:   repo,
+		config: config,
+	}
+}
+
+func (middleware *IdempotencyMiddleware) Handle(context *gin.Context) {
+	key := context.GetHeader(IdempotencyKeyHeader)
+	if key == "" {
+		context.Next()
+		return
+	}
+
+	if len(key) > MaxIdempotencyKeyLength {
+		context.AbortWithStatusJSON(http.StatusBadRequest, handlers.HttpError{
+			Message: "Invalid Idempotency-Key",
+			Error:   "Idempotency-Key is too long",
+		})
+		return
+	}
+
+	// Read the body for the fingerprint and put it back for the handler, it is held in memory so its size is capped
+	var body []byte
+	if context.Request.Body != nil {
+		var err error
+		body, err = io.ReadAll(http.MaxBytesReader(context.Writer, context.Request.Body, MaxIdempotentBodySize))
+		var maxBytesError *http.MaxBytesError
+		if err != nil && errors.As(err, &maxBytesError) {
+			context.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, handlers.HttpError{
+				Message: "Request body too large",
+				Error:   fmt.Sprintf("Requests with an Idempotency-Key must not exceed %d bytes", MaxIdempotentBodySize),
+			})
+			return
+		}
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, handlers.HttpError{
+				Message: "Invalid request body",
+				Error:   err.Error(),
+			})
+			return
+		}
+		context.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	// Keys are scoped to the caller, so that a client can't replay the response stored for another one
+	scope := middleware.getScope(context)
+	key = scope + ":" + key
+	fingerprint := middleware.getFingerprint(scope, context.Request.Method, context.Request.URL.Path, body)
+
+	record, reserved, err := middleware.repo.Reserve(key, fingerprint, middleware.config.LockTTL)
+	if err != nil {
+		// The client asked for deduplication, so the request is refused rather than risking a duplicate
+		middleware.config.Logger.WarnContext(context.Request.Context(), "Failed to reserve idempotency key", "key", key, "error", err)
+		context.Header("Retry-After", strconv.Itoa(int(middleware.config.RetryAfter/time.Second)))
+		context.AbortWithStatusJSON(http.StatusServiceUnavailable, handlers.HttpError{
+			Message: "Idempotency store unavailable",
+			Error:   "Requests with an Idempotency-Key can't be deduplicated right now, retry later",
+		})
+		return
+	}
+
+	if !reserved {
+		middleware.replay(context, record, fingerprint)
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: context.Writer}
+	context.Writer = recorder
+	context.Next()
+
+	// Server errors are not stored so that the client can retry with the same key
+	status := recorder.Status()
+	if status >= http.StatusInternalServerError {
+		if err := middleware.repo.Release(key); err != nil {
+			middleware.config.Logger.WarnContext(context.Request.Context(), "Failed to release idempotency key", "key", key, "error", err)
+		}
+		return
+	}
+
+	err = middleware.repo.Save(key, repositories.IdempotencyRecord{
+		Fingerprint: fingerprint,
+		Completed:   true,
+		StatusCode:  status,
+		ContentType: recorder.Header().Get("Content-Type"),
+		Body:        recorder.body.Bytes(),
+	}, middleware.config.TTL)
+	if err != nil {
+		middleware.config.Logger.WarnContext(context.Request.Context(), "Failed to save idempotency key", "key", key, "error", err)
+	}
+}
+
+func (middleware *IdempotencyMiddleware) replay(context *gin.Context, record *repositories.IdempotencyRecord, fingerprint string) {
+	if record.Fingerprint != fingerprint {
+		context.AbortWithStatusJSON(http.StatusUnprocessableEntity, handlers.HttpError{
+			Message: "Idempotency-Key reused",
+			Error:   "Idempotency-Key was already used with a different request",
+		})
+		return
+	}
+
+	if !record.Completed {
+		context.Header("Retry-After", "1")
+		context.AbortWithStatusJSON(http.StatusConflict, handlers.HttpError{
+			Message: "Request in progress",
+			Error:   "A request with the same Idempotency-Key is still in progress",
+		})
+		return
+	}
+
+	context.Header(IdempotentReplayedHeader, "true")
+	if record.ContentType == "" {
+		context.AbortWithStatus(record.StatusCode)
+		return
+	}
+	context.Data(record.StatusCode, record.ContentType, record.Body)
+	context.Abort()
+}
+
+// getScope is the id of the API key authenticated before the middleware, the anonymous scope on routes without authentication
+func (middleware *IdempotencyMiddleware) getScope(context *gin.Context) string {
+	value, found := context.Get(APIKeyContextKey)
+	apiKey, ok := value.(*repositories.APIKey)
+	if !found || !ok || apiKey == nil {
+		return AnonymousIdempotencyScope
+	}
+
+	return "api_key:" + strconv.Itoa(apiKey.Id)
+}
+
+func (middleware *IdempotencyMiddleware) getFingerprint(scope string, method string, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(scope + " " + method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (recorder *responseRecorder) Write(data []byte) (int, error) {
+	recorder.body.Write(data)
+	return recorder.ResponseWriter.Write(data)
+}
+
+func (recorder *responseRecorder) WriteString(data string) (int, error) {
+	recorder.body.WriteString(data)
+	return recorder.ResponseWriter.WriteString(data)
+}
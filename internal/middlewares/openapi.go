@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"net/http"
+	"portto-assignment/internal/handlers"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+)
+
+func NewOpenAPIValidationMiddleware(spec *openapi3.T) (*OpenAPIValidationMiddleware, error) {
+	router, err := gorillamux.NewRouter(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OpenAPIValidationMiddleware{
+		router: router,
+	}, nil
+}
+
+// Handle rejects requests whose parameters or JSON body don't match the OpenAPI document
+func (middleware *OpenAPIValidationMiddleware) Handle(context *gin.Context) {
+	route, pathParams, err := middleware.router.FindRoute(context.Request)
+	if err != nil {
+		// Unknown routes and methods are answered by the router
+		context.Next()
+		return
+	}
+
+	err = openapi3filter.ValidateRequest(context.Request.Context(), &openapi3filter.RequestValidationInput{
+		Request:    context.Request,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			// Import files are streamed by the handler instead of being read here
+			ExcludeRequestBody: !hasJSONBody(route.Operation),
+			// Keys are checked by the Authentication middleware, which is optional
+			AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+			SkipSettingDefaults: true,
+		},
+	})
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusBadRequest, handlers.HttpError{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	context.Next()
+}
+
+func hasJSONBody(operation *openapi3.Operation) bool {
+	if operation == nil || operation.RequestBody == nil || operation.RequestBody.Value == nil {
+		return false
+	}
+
+	return operation.RequestBody.Value.Content.Get("application/json") != nil
+}
@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"portto-assignment/internal/repositories"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NewReadYourWritesMiddleware() *ReadYourWritesMiddleware {
+	return &ReadYourWritesMiddleware{}
+}
+
+// Handle routes the reads of the request to the primary database when the client asks for it, typically right after a mutation
+func (middleware *ReadYourWritesMiddleware) Handle(context *gin.Context) {
+	readYourWrites, err := strconv.ParseBool(context.GetHeader(ReadYourWritesHeader))
+	if err == nil && readYourWrites {
+		context.Request = context.Request.WithContext(repositories.WithPrimaryReads(context.Request.Context()))
+	}
+
+	context.Next()
+}
@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"portto-assignment/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func NewRequestIDMiddleware() *RequestIDMiddleware {
+	return &RequestIDMiddleware{}
+}
+
+// Handle keeps the X-Request-ID of the client, or generates one, and puts it in the request context, the response and the request span
+func (middleware *RequestIDMiddleware) Handle(context *gin.Context) {
+	requestId := context.GetHeader(RequestIDHeader)
+	if !isValidRequestID(requestId) {
+		requestId = newRequestID()
+	}
+
+	context.Request = context.Request.WithContext(logging.WithRequestID(context.Request.Context(), requestId))
+	context.Header(RequestIDHeader, requestId)
+	trace.SpanFromContext(context.Request.Context()).SetAttributes(attribute.String("http.request_id", requestId))
+
+	context.Next()
+}
+
+// isValidRequestID accepts printable ASCII only, so that the id can't forge log lines or response headers
+func isValidRequestID(requestId string) bool {
+	if requestId == "" || len(requestId) > MaxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestId); i++ {
+		if requestId[i] < 0x21 || requestId[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,15 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// NewTracingMiddleware starts a server span per request, continuing the trace of the W3C traceparent header when there is one
+func NewTracingMiddleware(serviceName string) *TracingMiddleware {
+	return &TracingMiddleware{handler: otelgin.Middleware(serviceName)}
+}
+
+func (middleware *TracingMiddleware) Handle(context *gin.Context) {
+	middleware.handler(context)
+}
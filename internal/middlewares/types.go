@@ -0,0 +1,138 @@
+package middlewares
+
+import (
+	"bytes"
+	"log/slog"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/internal/services"
+	"time"
+
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+)
+
+type IdempotencyMiddlewareInterface interface {
+	Handle(context *gin.Context)
+}
+
+type IdempotencyMiddleware struct {
+	repo   repositories.IdempotencyRepositoryInterface
+	config IdempotencyConfig
+}
+
+type APIKeyMiddlewareInterface interface {
+	Handle(context *gin.Context)
+}
+
+type APIKeyMiddleware struct {
+	service services.APIKeyServiceInterface
+	logger  *slog.Logger
+}
+
+type ReadYourWritesMiddlewareInterface interface {
+	Handle(context *gin.Context)
+}
+
+type ReadYourWritesMiddleware struct{}
+
+type RequestIDMiddlewareInterface interface {
+	Handle(context *gin.Context)
+}
+
+type RequestIDMiddleware struct{}
+
+type TracingMiddlewareInterface interface {
+	Handle(context *gin.Context)
+}
+
+type TracingMiddleware struct {
+	handler gin.HandlerFunc
+}
+
+type OpenAPIValidationMiddlewareInterface interface {
+	Handle(context *gin.Context)
+}
+
+type OpenAPIValidationMiddleware struct {
+	router routers.Router
+}
+
+type AccessLogMiddlewareInterface interface {
+	Handle(context *gin.Context)
+}
+
+type AccessLogMiddleware struct {
+	logger *slog.Logger
+	config AccessLogConfig
+}
+
+type AccessLogConfig struct {
+	// SampleRate is the share of successful and client error requests logged, between 0 and 1
+	SampleRate float64
+}
+
+type IdempotencyConfig struct {
+	// TTL is how long a completed response is replayed for
+	TTL time.Duration
+	// LockTTL is how long an in-progress request holds the key, in case it never completes
+	LockTTL time.Duration
+	// RetryAfter is sent with the 503 answered to requests with a key while the store is unavailable
+	RetryAfter time.Duration
+	Logger     *slog.Logger
+}
+
+// responseRecorder keeps a copy of the response body so it can be stored for replays
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+const (
+	// APIKeyHeader is the request header carrying the API key
+	APIKeyHeader = "X-API-Key"
+
+	// APIKeyContextKey is where the authenticated key is kept in the gin context
+	APIKeyContextKey = "api_key"
+)
+
+const (
+	// RequestIDHeader carries the id of the request, it is generated when the client doesn't send a valid one
+	RequestIDHeader = "X-Request-ID"
+
+	// MaxRequestIDLength is the longest request id accepted from clients
+	MaxRequestIDLength = 128
+
+	// DefaultAccessLogSampleRate logs every request by default
+	DefaultAccessLogSampleRate = 1.0
+)
+
+const (
+	// ReadYourWritesHeader asks for the reads of the request to be served by the primary database
+	ReadYourWritesHeader = "X-Read-Your-Writes"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header carrying the client generated key
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader is set on responses replayed from a stored record
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// MaxIdempotencyKeyLength is the longest key accepted
+	MaxIdempotencyKeyLength = 255
+
+	// MaxIdempotentBodySize is the largest body of a request with a key, it is read in full for the fingerprint
+	MaxIdempotentBodySize = 1024 * 1024
+
+	// AnonymousIdempotencyScope holds the keys sent on routes without authentication
+	AnonymousIdempotencyScope = "anonymous"
+
+	// DefaultIdempotencyTTL is how long responses are kept by default
+	DefaultIdempotencyTTL = 24 * time.Hour
+
+	// DefaultIdempotencyLockTTL is how long an in-progress request holds the key by default
+	DefaultIdempotencyLockTTL = 30 * time.Second
+
+	// DefaultIdempotencyRetryAfter is how long clients are asked to wait by default while the store is unavailable
+	DefaultIdempotencyRetryAfter = 5 * time.Second
+)
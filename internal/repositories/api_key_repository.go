@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"portto-assignment/config"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func NewAPIKeyRepository(db config.DatabaseConnectionPoolInterface) *APIKeyRepository {
+	return &APIKeyRepository{
+		db: db,
+	}
+}
+
+func (repo *APIKeyRepository) CreateOne(name string, prefix string, keyHash string) (*APIKey, error) {
+	const sqlStatement string = `
+		INSERT INTO api_keys (name, prefix, key_hash)
+		VALUES ($1, $2, $3)
+		RETURNING id, name, prefix, created_at, revoked_at`
+
+	var apiKey APIKey
+	row := repo.db.QueryRow(context.Background(), sqlStatement, name, prefix, keyHash)
+	err := row.Scan(&apiKey.Id, &apiKey.Name, &apiKey.Prefix, &apiKey.CreatedAt, &apiKey.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiKey, nil
+}
+
+func (repo *APIKeyRepository) FindActiveByHash(keyHash string) (*APIKey, error) {
+	const sqlStatement string = `
+		SELECT id, name, prefix, created_at, revoked_at
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL`
+
+	var apiKey APIKey
+	row := repo.db.QueryRow(context.Background(), sqlStatement, keyHash)
+	err := row.Scan(&apiKey.Id, &apiKey.Name, &apiKey.Prefix, &apiKey.CreatedAt, &apiKey.RevokedAt)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &apiKey, nil
+}
+
+func (repo *APIKeyRepository) FindAll() ([]APIKey, error) {
+	const sqlStatement string = `
+		SELECT id, name, prefix, created_at, revoked_at
+		FROM api_keys
+		ORDER BY id`
+
+	rows, err := repo.db.Query(context.Background(), sqlStatement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	apiKeys := []APIKey{}
+	for rows.Next() {
+		var apiKey APIKey
+		err := rows.Scan(&apiKey.Id, &apiKey.Name, &apiKey.Prefix, &apiKey.CreatedAt, &apiKey.RevokedAt)
+		if err != nil {
+			return nil, err
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return apiKeys, nil
+}
+
+func (repo *APIKeyRepository) RevokeOne(id int) (*APIKey, error) {
+	const sqlStatement string = `
+		UPDATE api_keys
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND revoked_at IS NULL
+		RETURNING id, name, prefix, created_at, revoked_at`
+
+	var apiKey APIKey
+	row := repo.db.QueryRow(context.Background(), sqlStatement, id)
+	err := row.Scan(&apiKey.Id, &apiKey.Name, &apiKey.Prefix, &apiKey.CreatedAt, &apiKey.RevokedAt)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &apiKey, nil
+}
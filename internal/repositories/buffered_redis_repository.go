@@ -0,0 +1,337 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"portto-assignment/internal/tracing"
+	"portto-assignment/pkg/clock"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewBufferedRedisRepository combines increments in memory and flushes them to redis every FlushInterval with one pipelined INCRBY per key.
+//
+// Increments are acknowledged before they reach Redis, so a crash loses at most FlushInterval of them. A failed flush is merged back
+// into the pending increments and retried on the next tick, keys beyond MaxPendingKeys are lost.
+// Existence checks are answered from memory for ExistenceTTL, the flush checks again so that a coin deleted elsewhere isn't recreated.
+// Close flushes what is left.
+func NewBufferedRedisRepository(redis RedisRepositoryInterface, config BufferConfig) *BufferedRedisRepository {
+	// Apply defaults if values aren't specified
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultBufferFlushInterval
+	}
+	if config.MaxPendingKeys <= 0 {
+		config.MaxPendingKeys = DefaultBufferMaxPendingKeys
+	}
+	if config.ExistenceTTL <= 0 {
+		config.ExistenceTTL = DefaultBufferExistenceTTL
+	}
+	if config.Clock == nil {
+		config.Clock = clock.Real()
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	bufferedRepo := &BufferedRedisRepository{
+		redis:     redis,
+		config:    config,
+		pending:   make(map[string]int),
+		existence: make(map[string]existenceEntry),
+		flushNow:  make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go bufferedRepo.startFlushWorker()
+
+	return bufferedRepo
+}
+
+func (r *BufferedRedisRepository) IncrBy(ctx context.Context, key string, increment int) error {
+	return r.IncrByMany(ctx, map[string]int{key: increment})
+}
+
+func (r *BufferedRedisRepository) IncrByMany(ctx context.Context, increments map[string]int) error {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return r.redis.IncrByMany(ctx, increments)
+	}
+	for key, increment := range increments {
+		r.pending[key] += increment
+	}
+	if link := trace.LinkFromContext(ctx); link.SpanContext.IsValid() && len(r.pendingLinks) < MaxSyncBatchLinks {
+		r.pendingLinks = append(r.pendingLinks, link)
+	}
+	full := len(r.pending) >= r.config.MaxPendingKeys
+	r.mutex.Unlock()
+
+	if full {
+		select {
+		case r.flushNow <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Get adds the pending increments to the value in Redis, so callers read their own pokes
+func (r *BufferedRedisRepository) Get(ctx context.Context, key string) (int, bool, error) {
+	r.flushMutex.RLock()
+	defer r.flushMutex.RUnlock()
+
+	value, found, err := r.redis.Get(ctx, key)
+	if err != nil || !found {
+		return value, found, err
+	}
+
+	r.mutex.Lock()
+	value += r.pending[key]
+	r.mutex.Unlock()
+
+	return value, true, nil
+}
+
+// AdjustBy goes straight to Redis, the result includes the pokes not flushed yet as Get does
+func (r *BufferedRedisRepository) AdjustBy(ctx context.Context, key string, delta int) (int, error) {
+	r.flushMutex.RLock()
+	defer r.flushMutex.RUnlock()
+
+	value, err := r.redis.AdjustBy(ctx, key, delta)
+	if err != nil {
+		return 0, err
+	}
+
+	r.mutex.Lock()
+	value += r.pending[key]
+	r.mutex.Unlock()
+
+	return value, nil
+}
+
+// Set overwrites the pokes not flushed yet
+func (r *BufferedRedisRepository) Set(ctx context.Context, key string, value int) error {
+	return r.SetMany(ctx, map[string]int{key: value})
+}
+
+func (r *BufferedRedisRepository) SetMany(ctx context.Context, values map[string]int) error {
+	r.flushMutex.RLock()
+	defer r.flushMutex.RUnlock()
+
+	r.mutex.Lock()
+	for key := range values {
+		delete(r.pending, key)
+		delete(r.existence, key)
+	}
+	r.mutex.Unlock()
+
+	return r.redis.SetMany(ctx, values)
+}
+
+// Delete drops the pokes not flushed yet, so that they don't recreate the key
+func (r *BufferedRedisRepository) Delete(ctx context.Context, key string) error {
+	r.flushMutex.RLock()
+	defer r.flushMutex.RUnlock()
+
+	r.mutex.Lock()
+	delete(r.pending, key)
+	delete(r.existence, key)
+	r.mutex.Unlock()
+
+	return r.redis.Delete(ctx, key)
+}
+
+func (r *BufferedRedisRepository) Exists(ctx context.Context, key string) (bool, error) {
+	existsMap, err := r.ExistsMany(ctx, []string{key})
+	if err != nil {
+		return false, err
+	}
+
+	return existsMap[key], nil
+}
+
+func (r *BufferedRedisRepository) ExistsMany(ctx context.Context, keys []string) (map[string]bool, error) {
+	existsMap := make(map[string]bool, len(keys))
+	missingKeys := []string{}
+
+	now := r.config.Clock.Now()
+	r.mutex.Lock()
+	for _, key := range keys {
+		entry, found := r.existence[key]
+		if found && now.Before(entry.expiresAt) {
+			existsMap[key] = entry.exists
+			continue
+		}
+		missingKeys = append(missingKeys, key)
+	}
+	r.mutex.Unlock()
+
+	if len(missingKeys) == 0 {
+		return existsMap, nil
+	}
+
+	missingExistsMap, err := r.redis.ExistsMany(ctx, missingKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := r.config.Clock.Now().Add(r.config.ExistenceTTL)
+	r.mutex.Lock()
+	for _, key := range missingKeys {
+		existsMap[key] = missingExistsMap[key]
+		r.existence[key] = existenceEntry{
+			exists:    missingExistsMap[key],
+			expiresAt: expiresAt,
+		}
+	}
+	r.mutex.Unlock()
+
+	return existsMap, nil
+}
+
+// Flush writes the pending increments to Redis
+func (r *BufferedRedisRepository) Flush() error {
+	r.flushMutex.Lock()
+	defer r.flushMutex.Unlock()
+
+	r.mutex.Lock()
+	increments := r.pending
+	links := r.pendingLinks
+	r.pending = make(map[string]int)
+	r.pendingLinks = nil
+	r.mutex.Unlock()
+
+	if len(increments) == 0 {
+		return nil
+	}
+
+	// Pokes of many requests are flushed together, so the flush is a trace of its own linked to them
+	ctx, span := tracing.Tracer().Start(context.Background(), "BufferedRedisRepository.Flush",
+		trace.WithNewRoot(),
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.Int("flush.keys", len(increments))),
+	)
+	defer span.End()
+	err := r.flushExisting(ctx, increments)
+	if err != nil {
+		// Only the increments that didn't reach Redis are retried, the applied ones would be counted twice
+		failed := increments
+		var incrementErr *IncrementError
+		if errors.As(err, &incrementErr) {
+			failed = incrementErr.Failed
+		}
+		lost := r.requeue(failed, links)
+		r.config.Logger.Error("Failed to flush buffered pokes, they will be retried", "keys", len(failed), "lost_pokes", lost, "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// flushExisting only writes the increments of keys that still exist, the existence cache may not have seen a delete by another instance yet
+func (r *BufferedRedisRepository) flushExisting(ctx context.Context, increments map[string]int) error {
+	keys := make([]string, 0, len(increments))
+	for key := range increments {
+		keys = append(keys, key)
+	}
+	existsMap, err := r.redis.ExistsMany(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]int, len(increments))
+	expiresAt := r.config.Clock.Now().Add(r.config.ExistenceTTL)
+	r.mutex.Lock()
+	for key, increment := range increments {
+		if existsMap[key] {
+			existing[key] = increment
+			continue
+		}
+		r.existence[key] = existenceEntry{exists: false, expiresAt: expiresAt}
+		r.config.Logger.WarnContext(ctx, "Dropping buffered pokes of a deleted meme coin", "key", key, "pokes", increment)
+	}
+	r.mutex.Unlock()
+	if len(existing) == 0 {
+		return nil
+	}
+
+	return r.redis.IncrByMany(ctx, existing)
+}
+
+// requeue merges the increments of a failed flush back into the pending ones and returns the pokes that didn't fit under MaxPendingKeys
+func (r *BufferedRedisRepository) requeue(increments map[string]int, links []trace.Link) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	lost := 0
+	for key, increment := range increments {
+		// Nothing flushes the buffer once it is closed
+		_, pending := r.pending[key]
+		if r.closed || (!pending && len(r.pending) >= r.config.MaxPendingKeys) {
+			lost += increment
+			continue
+		}
+		r.pending[key] += increment
+	}
+	for _, link := range links {
+		if len(r.pendingLinks) >= MaxSyncBatchLinks {
+			break
+		}
+		r.pendingLinks = append(r.pendingLinks, link)
+	}
+
+	return lost
+}
+
+// Close stops combining increments and flushes the pending ones, increments after it go straight to Redis
+func (r *BufferedRedisRepository) Close() error {
+	r.closeOnce.Do(func() {
+		r.mutex.Lock()
+		r.closed = true
+		r.mutex.Unlock()
+
+		close(r.stop)
+		<-r.done
+		r.closeErr = r.Flush()
+	})
+
+	return r.closeErr
+}
+
+func (r *BufferedRedisRepository) startFlushWorker() {
+	defer close(r.done)
+
+	ticker := r.config.Clock.NewTicker(r.config.FlushInterval)
+	defer ticker.Stop()
+	sweepTicker := r.config.Clock.NewTicker(r.config.ExistenceTTL)
+	defer sweepTicker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			r.Flush()
+		case <-r.flushNow:
+			r.Flush()
+		case <-sweepTicker.C():
+			r.sweepExistence()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// sweepExistence forgets the expired existence checks so that the map doesn't grow with every key ever checked
+func (r *BufferedRedisRepository) sweepExistence() {
+	now := r.config.Clock.Now()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, entry := range r.existence {
+		if !now.Before(entry.expiresAt) {
+			delete(r.existence, key)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package repositories
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"portto-assignment/pkg/clock"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NewCachedMemeCoinRepository serves FindOne from a bounded LRU cache in front of repo.
+//
+// Updates and deletes evict the meme coin locally and broadcast the eviction to the other instances over Redis pub/sub.
+// A nil redis client keeps the invalidations local, the TTL still bounds how stale other instances can be.
+func NewCachedMemeCoinRepository(repo MemeCoinRepositoryInterface, redisClient redis.UniversalClient, config CacheConfig) *CachedMemeCoinRepository {
+	// Apply defaults if values aren't specified
+	if config.Size <= 0 {
+		config.Size = DefaultCacheSize
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultCacheTTL
+	}
+	if config.Keys == nil {
+		config.Keys = DefaultKeyBuilder()
+	}
+	if config.Clock == nil {
+		config.Clock = clock.Real()
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	instanceId := make([]byte, 8)
+	rand.Read(instanceId)
+
+	cachedRepo := &CachedMemeCoinRepository{
+		repo:       repo,
+		redis:      redisClient,
+		config:     config,
+		entries:    make(map[int]*list.Element),
+		lru:        list.New(),
+		instanceId: hex.EncodeToString(instanceId),
+	}
+
+	if redisClient != nil {
+		go cachedRepo.subscribe()
+	}
+
+	return cachedRepo
+}
+
+func (repo *CachedMemeCoinRepository) FindOne(ctx context.Context, id int) (*MemeCoin, error) {
+	// Reads asking for their own writes skip the cache, it may have been filled from a lagging replica
+	if PrimaryReads(ctx) {
+		return repo.repo.FindOne(ctx, id)
+	}
+
+	if memeCoin, found := repo.get(id); found {
+		return memeCoin, nil
+	}
+
+	// The shared load must not fail for every waiter because the first caller went away
+	loadCtx := context.WithoutCancel(ctx)
+	result, err, _ := repo.group.Do(strconv.Itoa(id), func() (interface{}, error) {
+		repo.mutex.Lock()
+		generation := repo.generation
+		repo.mutex.Unlock()
+
+		memeCoin, err := repo.repo.FindOne(loadCtx, id)
+		if err != nil || memeCoin == nil {
+			return memeCoin, err
+		}
+		repo.put(*memeCoin, generation)
+		return memeCoin, nil
+	})
+	if err != nil || result.(*MemeCoin) == nil {
+		return nil, err
+	}
+
+	// Every waiter gets its own copy
+	memeCoin := *result.(*MemeCoin)
+	return &memeCoin, nil
+}
+
+func (repo *CachedMemeCoinRepository) CreateOne(ctx context.Context, name string, description string) (*MemeCoin, error) {
+	return repo.repo.CreateOne(ctx, name, description)
+}
+
+func (repo *CachedMemeCoinRepository) UpdateOne(ctx context.Context, id int, description string) (*MemeCoin, error) {
+	updatedMemeCoin, err := repo.repo.UpdateOne(ctx, id, description)
+	if err != nil {
+		return nil, err
+	}
+	repo.invalidate(ctx, id)
+
+	return updatedMemeCoin, nil
+}
+
+func (repo *CachedMemeCoinRepository) DeleteOne(ctx context.Context, id int) (*MemeCoin, error) {
+	deletedMemeCoin, err := repo.repo.DeleteOne(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	repo.invalidate(ctx, id)
+
+	return deletedMemeCoin, nil
+}
+
+func (repo *CachedMemeCoinRepository) FindMany(ctx context.Context, ids []int) ([]MemeCoin, error) {
+	return repo.repo.FindMany(ctx, ids)
+}
+
+func (repo *CachedMemeCoinRepository) CreateMany(ctx context.Context, newMemeCoins []NewMemeCoin) ([]MemeCoin, error) {
+	return repo.repo.CreateMany(ctx, newMemeCoins)
+}
+
+func (repo *CachedMemeCoinRepository) StreamAll(ctx context.Context, fn func(memeCoin MemeCoin) error) error {
+	return repo.repo.StreamAll(ctx, fn)
+}
+
+func (repo *CachedMemeCoinRepository) UpsertMany(ctx context.Context, upsertMemeCoins []UpsertMemeCoin) ([]UpsertedMemeCoin, error) {
+	upsertedMemeCoins, err := repo.repo.UpsertMany(ctx, upsertMemeCoins)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(upsertedMemeCoins))
+	for _, upsertedMemeCoin := range upsertedMemeCoins {
+		if !upsertedMemeCoin.Inserted {
+			ids = append(ids, upsertedMemeCoin.Id)
+		}
+	}
+	repo.invalidate(ctx, ids...)
+
+	return upsertedMemeCoins, nil
+}
+
+func (repo *CachedMemeCoinRepository) UpdatePopularityScore(ctx context.Context, id int, popularityScore int) (*MemeCoin, error) {
+	updatedMemeCoin, err := repo.repo.UpdatePopularityScore(ctx, id, popularityScore)
+	if err != nil {
+		return nil, err
+	}
+	repo.invalidate(ctx, id)
+
+	return updatedMemeCoin, nil
+}
+
+func (repo *CachedMemeCoinRepository) IncrementPopularityScore(ctx context.Context, id int, increment int) (*MemeCoin, error) {
+	updatedMemeCoin, err := repo.repo.IncrementPopularityScore(ctx, id, increment)
+	if err != nil {
+		return nil, err
+	}
+	repo.invalidate(ctx, id)
+
+	return updatedMemeCoin, nil
+}
+
+// Len is the number of meme coins currently kept in memory
+func (repo *CachedMemeCoinRepository) Len() int {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	return repo.lru.Len()
+}
+
+func (repo *CachedMemeCoinRepository) get(id int) (*MemeCoin, bool) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	element, found := repo.entries[id]
+	if !found {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if repo.config.Clock.Now().After(entry.expiresAt) {
+		repo.lru.Remove(element)
+		delete(repo.entries, id)
+		return nil, false
+	}
+	repo.lru.MoveToFront(element)
+
+	memeCoin := entry.memeCoin
+	return &memeCoin, true
+}
+
+// put keeps the meme coin unless it was invalidated since generation was read
+func (repo *CachedMemeCoinRepository) put(memeCoin MemeCoin, generation uint64) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if repo.generation != generation {
+		return
+	}
+
+	entry := &cacheEntry{
+		memeCoin:  memeCoin,
+		expiresAt: repo.config.Clock.Now().Add(repo.config.TTL),
+	}
+	if element, found := repo.entries[memeCoin.Id]; found {
+		element.Value = entry
+		repo.lru.MoveToFront(element)
+		return
+	}
+	repo.entries[memeCoin.Id] = repo.lru.PushFront(entry)
+
+	for repo.lru.Len() > repo.config.Size {
+		oldest := repo.lru.Back()
+		repo.lru.Remove(oldest)
+		delete(repo.entries, oldest.Value.(*cacheEntry).memeCoin.Id)
+	}
+}
+
+func (repo *CachedMemeCoinRepository) evict(ids ...int) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.generation++
+	for _, id := range ids {
+		// Later misses must not join a load started before the invalidation
+		repo.group.Forget(strconv.Itoa(id))
+		if element, found := repo.entries[id]; found {
+			repo.lru.Remove(element)
+			delete(repo.entries, id)
+		}
+	}
+}
+
+// invalidate evicts the meme coins locally and tells the other instances to do the same
+func (repo *CachedMemeCoinRepository) invalidate(ctx context.Context, ids ...int) {
+	if len(ids) == 0 {
+		return
+	}
+	repo.evict(ids...)
+
+	if repo.redis == nil {
+		return
+	}
+	err := repo.redis.Publish(ctx, repo.config.Keys.CacheInvalidationChannel(), repo.instanceId+" "+joinIds(ids)).Err()
+	if err != nil {
+		repo.config.Logger.WarnContext(ctx, "Failed to broadcast the cache invalidation", "ids", ids, "error", err)
+	}
+}
+
+// subscribe evicts the meme coins invalidated by other instances, go-redis reconnects the subscription on its own
+func (repo *CachedMemeCoinRepository) subscribe() {
+	pubsub := repo.redis.Subscribe(context.Background(), repo.config.Keys.CacheInvalidationChannel())
+	defer pubsub.Close()
+
+	for message := range pubsub.Channel() {
+		instanceId, payload, found := strings.Cut(message.Payload, " ")
+		if !found {
+			repo.config.Logger.Warn("Invalid cache invalidation message", "payload", message.Payload)
+			continue
+		}
+		if instanceId == repo.instanceId {
+			continue
+		}
+
+		ids := []int{}
+		for _, field := range strings.Split(payload, ",") {
+			id, err := strconv.Atoi(field)
+			if err != nil {
+				repo.config.Logger.Warn("Invalid meme coin id in cache invalidation message", "payload", message.Payload)
+				continue
+			}
+			ids = append(ids, id)
+		}
+		repo.evict(ids...)
+	}
+}
+
+func joinIds(ids []int) string {
+	fields := make([]string, 0, len(ids))
+	for _, id := range ids {
+		fields = append(fields, strconv.Itoa(id))
+	}
+
+	return strings.Join(fields, ",")
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// clusterSlots is the number of hash slots of a Redis Cluster
+const clusterSlots = 16384
+
+// keySlot returns the Redis Cluster hash slot of a key, only the hash tag is hashed when the key has one
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+
+	return int(crc16(key)) % clusterSlots
+}
+
+// crc16 is the CRC16/XMODEM checksum Redis Cluster hashes keys with
+func crc16(key string) uint16 {
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
+
+// scanNodes returns the clients to SCAN, every master of a cluster holds a part of the keys
+func (r *RedisCachedRepository) scanNodes(ctx context.Context) ([]redis.Cmdable, error) {
+	cluster, ok := r.redis.(*redis.ClusterClient)
+	if !ok {
+		return []redis.Cmdable{r.redis}, nil
+	}
+
+	var mutex sync.Mutex
+	nodes := []redis.Cmdable{}
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		nodes = append(nodes, client)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
+// mget reads the keys with one MGET, or with one MGET per hash slot in a single pipeline on a cluster
+func (r *RedisCachedRepository) mget(ctx context.Context, keys []string) ([]interface{}, error) {
+	if _, ok := r.redis.(*redis.ClusterClient); !ok {
+		return r.redis.MGet(ctx, keys...).Result()
+	}
+
+	slots := []int{}
+	keysBySlot := make(map[int][]string)
+	indexesBySlot := make(map[int][]int)
+	for i, key := range keys {
+		slot := keySlot(key)
+		if _, found := keysBySlot[slot]; !found {
+			slots = append(slots, slot)
+		}
+		keysBySlot[slot] = append(keysBySlot[slot], key)
+		indexesBySlot[slot] = append(indexesBySlot[slot], i)
+	}
+
+	pipe := r.redis.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(slots))
+	for i, slot := range slots {
+		cmds[i] = pipe.MGet(ctx, keysBySlot[slot]...)
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(keys))
+	for i, slot := range slots {
+		for j, value := range cmds[i].Val() {
+			values[indexesBySlot[slot][j]] = value
+		}
+	}
+
+	return values, nil
+}
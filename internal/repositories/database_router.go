@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"context"
+	"log/slog"
+	"portto-assignment/config"
+	"portto-assignment/pkg/clock"
+	"time"
+)
+
+type primaryReadsContextKey struct{}
+
+// WithPrimaryReads makes the reads done with the returned context go to the primary, to read your own writes
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadsContextKey{}, true)
+}
+
+func PrimaryReads(ctx context.Context) bool {
+	primaryReads, _ := ctx.Value(primaryReadsContextKey{}).(bool)
+	return primaryReads
+}
+
+func NewDatabaseRouter(primary config.DatabaseConnectionPoolInterface, replicas []config.DatabaseConnectionPoolInterface, config DatabaseRouterConfig) *DatabaseRouter {
+	// Apply defaults if values aren't specified
+	if config.HealthCheckInterval <= 0 {
+		config.HealthCheckInterval = DefaultReplicaHealthCheckInterval
+	}
+	if config.HealthCheckTimeout <= 0 {
+		config.HealthCheckTimeout = DefaultReplicaHealthCheckTimeout
+	}
+	if config.Clock == nil {
+		config.Clock = clock.Real()
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	router := &DatabaseRouter{
+		primary: primary,
+		config:  config,
+	}
+	for _, pool := range replicas {
+		router.replicas = append(router.replicas, &databaseReplica{pool: pool})
+	}
+
+	if len(router.replicas) > 0 {
+		router.CheckReplicas()
+		ticker := config.Clock.NewTicker(config.HealthCheckInterval)
+		go func() {
+			defer ticker.Stop()
+			for range ticker.C() {
+				router.CheckReplicas()
+			}
+		}()
+	}
+
+	return router
+}
+
+func (router *DatabaseRouter) Primary() config.DatabaseConnectionPoolInterface {
+	return router.primary
+}
+
+// Now is the time new rows are created at, truncated to the precision of a PostgreSQL timestamp
+func (router *DatabaseRouter) Now() time.Time {
+	return router.config.Clock.Now().UTC().Truncate(time.Microsecond)
+}
+
+// Reader picks the next healthy replica, the primary serves reads when none is healthy or the context asks for it
+func (router *DatabaseRouter) Reader(ctx context.Context) config.DatabaseConnectionPoolInterface {
+	if len(router.replicas) == 0 || PrimaryReads(ctx) {
+		return router.primary
+	}
+
+	count := uint64(len(router.replicas))
+	start := router.next.Add(1)
+	for i := uint64(0); i < count; i++ {
+		replica := router.replicas[(start+i)%count]
+		if replica.healthy.Load() {
+			return replica.pool
+		}
+	}
+
+	return router.primary
+}
+
+// CheckReplicas pings every replica and logs the ones changing state
+func (router *DatabaseRouter) CheckReplicas() {
+	for i, replica := range router.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), router.config.HealthCheckTimeout)
+		err := replica.pool.Ping(ctx)
+		cancel()
+
+		healthy := err == nil
+		if replica.healthy.Swap(healthy) != healthy {
+			if healthy {
+				router.config.Logger.Info("Database replica is healthy", "replica", i)
+			} else {
+				router.config.Logger.Warn("Database replica is unhealthy, reads skip it", "replica", i, "error", err)
+			}
+		}
+	}
+}
+
+func (router *DatabaseRouter) ReplicaStatus() (int, int) {
+	healthy := 0
+	for _, replica := range router.replicas {
+		if replica.healthy.Load() {
+			healthy++
+		}
+	}
+
+	return healthy, len(router.replicas)
+}
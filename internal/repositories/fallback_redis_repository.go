@@ -0,0 +1,411 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"portto-assignment/pkg/clock"
+)
+
+const (
+	circuitClosed int32 = iota
+	circuitOpen
+	circuitRecovering
+)
+
+// NewFallbackRedisRepository serves popularity scores from Redis and falls back to the database behind a circuit breaker.
+//
+// Skipped cache writes open the circuit right away because Redis has to be re-warmed afterwards.
+func NewFallbackRedisRepository(redis *RedisCachedRepository, repo MemeCoinRepositoryInterface, config FallbackConfig) *FallbackRedisRepository {
+	// Apply defaults if values aren't specified
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultFailureThreshold
+	}
+	if config.RecoveryInterval <= 0 {
+		config.RecoveryInterval = DefaultRecoveryInterval
+	}
+	if config.Clock == nil {
+		config.Clock = clock.Real()
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	fallbackRepo := &FallbackRedisRepository{
+		redis:      redis,
+		repo:       repo,
+		config:     config,
+		touchedIds: make(map[int]bool),
+	}
+
+	if err := redis.Ping(); err != nil {
+		config.Logger.Warn("Redis is unavailable, serving popularity scores from the database", "error", err)
+		fallbackRepo.open()
+	}
+
+	return fallbackRepo
+}
+
+func (r *FallbackRedisRepository) Status() RedisStatus {
+	switch r.state.Load() {
+	case circuitOpen:
+		return RedisStatusDown
+	case circuitRecovering:
+		return RedisStatusRecovering
+	}
+
+	return RedisStatusUp
+}
+
+func (r *FallbackRedisRepository) IncrBy(ctx context.Context, key string, increment int) error {
+	return r.IncrByMany(ctx, map[string]int{key: increment})
+}
+
+func (r *FallbackRedisRepository) Get(ctx context.Context, key string) (int, bool, error) {
+	var value int
+	var found bool
+	handled, err := r.degraded(func() error {
+		var err error
+		value, found, err = r.getFromDatabase(ctx, key)
+		return err
+	})
+	if handled {
+		return value, found, err
+	}
+
+	value, found, err = r.redis.Get(ctx, key)
+	if err != nil {
+		r.failed(err, false)
+		return r.getFromDatabase(ctx, key)
+	}
+	r.succeeded()
+
+	return value, found, nil
+}
+
+func (r *FallbackRedisRepository) Set(ctx context.Context, key string, value int) error {
+	return r.SetMany(ctx, map[string]int{key: value})
+}
+
+func (r *FallbackRedisRepository) Delete(ctx context.Context, key string) error {
+	handled, err := r.degraded(func() error {
+		return r.touch([]string{key})
+	})
+	if handled {
+		return err
+	}
+
+	err = r.redis.Delete(ctx, key)
+	if err != nil {
+		// The orphan key is removed when Redis is re-warmed
+		r.failed(err, true)
+		return nil
+	}
+	r.succeeded()
+
+	return nil
+}
+
+func (r *FallbackRedisRepository) Exists(ctx context.Context, key string) (bool, error) {
+	existsMap, err := r.ExistsMany(ctx, []string{key})
+	if err != nil {
+		return false, err
+	}
+
+	return existsMap[key], nil
+}
+
+func (r *FallbackRedisRepository) SetMany(ctx context.Context, values map[string]int) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	// The scores are already in the database, Redis is re-warmed from there
+	handled, err := r.degraded(func() error {
+		return r.touch(keys)
+	})
+	if handled {
+		return err
+	}
+
+	err = r.redis.SetMany(ctx, values)
+	if err != nil {
+		r.failed(err, true)
+		return nil
+	}
+	r.succeeded()
+
+	return nil
+}
+
+func (r *FallbackRedisRepository) IncrByMany(ctx context.Context, increments map[string]int) error {
+	handled, err := r.degraded(func() error {
+		return r.incrementInDatabase(ctx, increments)
+	})
+	if handled {
+		return err
+	}
+
+	err = r.redis.IncrByMany(ctx, increments)
+	if err != nil {
+		r.failed(err, false)
+		return err
+	}
+	r.succeeded()
+
+	return nil
+}
+
+func (r *FallbackRedisRepository) AdjustBy(ctx context.Context, key string, delta int) (int, error) {
+	var value int
+	handled, err := r.degraded(func() error {
+		var err error
+		value, err = r.adjustInDatabase(ctx, key, delta)
+		return err
+	})
+	if handled {
+		return value, err
+	}
+
+	value, err = r.redis.AdjustBy(ctx, key, delta)
+	if err != nil && errors.Is(err, ErrNegativePopularityScore) {
+		r.succeeded()
+		return 0, err
+	}
+	if err != nil {
+		r.failed(err, false)
+		return 0, err
+	}
+	r.succeeded()
+
+	return value, nil
+}
+
+func (r *FallbackRedisRepository) ExistsMany(ctx context.Context, keys []string) (map[string]bool, error) {
+	var existsMap map[string]bool
+	handled, err := r.degraded(func() error {
+		var err error
+		existsMap, err = r.existsInDatabase(ctx, keys)
+		return err
+	})
+	if handled {
+		return existsMap, err
+	}
+
+	existsMap, err = r.redis.ExistsMany(ctx, keys)
+	if err != nil {
+		r.failed(err, false)
+		return r.existsInDatabase(ctx, keys)
+	}
+	r.succeeded()
+
+	return existsMap, nil
+}
+
+// Recover re-warms Redis from the database and closes the circuit once Redis answers again
+func (r *FallbackRedisRepository) Recover() error {
+	if r.state.Load() != circuitOpen {
+		return nil
+	}
+	err := r.redis.Ping()
+	if err != nil {
+		return err
+	}
+
+	r.state.Store(circuitRecovering)
+
+	// Scores that drifted while Redis was down are replaced by the database ones, pokes not synced yet are left to the sync worker
+	_, err = r.redis.Reconcile(ReconcilePolicyDatabase)
+	if err != nil {
+		r.state.Store(circuitOpen)
+		return err
+	}
+
+	// The tag leaderboards missed the scores written to the database, the scores copied below are written on top
+	ctx := context.Background()
+	if r.config.Tags != nil && r.redis.config.TagLeaderboards != nil {
+		_, err = r.redis.config.TagLeaderboards.WarmUp(ctx, r.config.Tags, r.redis)
+		if err != nil {
+			r.state.Store(circuitOpen)
+			return err
+		}
+	}
+
+	// Block the database fallbacks while the scores written since the circuit opened are copied over,
+	// the database wins for them even when Redis holds pokes the sync worker hasn't written yet
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for id := range r.touchedIds {
+		memeCoin, err := r.repo.FindOne(r.databaseContext(ctx), id)
+		if err == nil && memeCoin == nil {
+			err = r.redis.Delete(ctx, r.getPopularityScoreKey(id))
+		} else if err == nil {
+			err = r.redis.Set(ctx, r.getPopularityScoreKey(id), memeCoin.PopularityScore)
+		}
+		if err != nil {
+			r.state.Store(circuitOpen)
+			return err
+		}
+	}
+	copied := len(r.touchedIds)
+	r.touchedIds = make(map[int]bool)
+	r.failures.Store(0)
+	r.state.Store(circuitClosed)
+	r.redis.syncHeld.Store(false)
+	r.config.Logger.Info("Redis recovered, popularity scores written to the database copied over", "count", copied)
+
+	return nil
+}
+
+// degraded runs the fallback instead of Redis when the circuit is not closed
+func (r *FallbackRedisRepository) degraded(fallback func() error) (bool, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.state.Load() == circuitClosed {
+		return false, nil
+	}
+
+	return true, fallback()
+}
+
+func (r *FallbackRedisRepository) succeeded() {
+	r.failures.Store(0)
+}
+
+func (r *FallbackRedisRepository) failed(err error, skippedWrite bool) {
+	r.config.Logger.Warn("Redis error", "error", err)
+	if skippedWrite || int(r.failures.Add(1)) >= r.config.FailureThreshold {
+		r.open()
+	}
+}
+
+func (r *FallbackRedisRepository) open() {
+	if !r.state.CompareAndSwap(circuitClosed, circuitOpen) {
+		return
+	}
+	// The sync worker would write the stale Redis scores over the ones written to the database until Recover copies them
+	r.redis.syncHeld.Store(true)
+	r.config.Logger.Warn("Circuit opened, serving popularity scores from the database")
+
+	go func() {
+		ticker := r.config.Clock.NewTicker(r.config.RecoveryInterval)
+		defer ticker.Stop()
+		for range ticker.C() {
+			err := r.Recover()
+			if err == nil {
+				return
+			}
+			r.config.Logger.Debug("Redis is still unavailable", "error", err)
+		}
+	}()
+}
+
+func (r *FallbackRedisRepository) getFromDatabase(ctx context.Context, key string) (int, bool, error) {
+	id, err := r.parsePopularityScoreKey(key)
+	if err != nil {
+		return 0, false, err
+	}
+	memeCoin, err := r.repo.FindOne(r.databaseContext(ctx), id)
+	if err != nil || memeCoin == nil {
+		return 0, false, err
+	}
+
+	return memeCoin.PopularityScore, true, nil
+}
+
+func (r *FallbackRedisRepository) existsInDatabase(ctx context.Context, keys []string) (map[string]bool, error) {
+	ids := make([]int, len(keys))
+	for i, key := range keys {
+		id, err := r.parsePopularityScoreKey(key)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	memeCoins, err := r.repo.FindMany(r.databaseContext(ctx), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	existsMap := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		existsMap[key] = false
+	}
+	for _, memeCoin := range memeCoins {
+		existsMap[r.getPopularityScoreKey(memeCoin.Id)] = true
+	}
+
+	return existsMap, nil
+}
+
+func (r *FallbackRedisRepository) incrementInDatabase(ctx context.Context, increments map[string]int) error {
+	keys := make([]string, 0, len(increments))
+	for key, increment := range increments {
+		id, err := r.parsePopularityScoreKey(key)
+		if err != nil {
+			return err
+		}
+		_, err = r.repo.IncrementPopularityScore(r.databaseContext(ctx), id, increment)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+
+	return r.touch(keys)
+}
+
+func (r *FallbackRedisRepository) adjustInDatabase(ctx context.Context, key string, delta int) (int, error) {
+	value, _, err := r.getFromDatabase(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if value+delta < 0 {
+		return 0, ErrNegativePopularityScore
+	}
+	id, err := r.parsePopularityScoreKey(key)
+	if err != nil {
+		return 0, err
+	}
+	memeCoin, err := r.repo.IncrementPopularityScore(r.databaseContext(ctx), id, delta)
+	if err != nil || memeCoin == nil {
+		return 0, err
+	}
+
+	return memeCoin.PopularityScore, r.touch([]string{key})
+}
+
+// touch remembers the meme coins written to the database, Recover copies their scores to Redis
+func (r *FallbackRedisRepository) touch(keys []string) error {
+	r.touchedIdsMutex.Lock()
+	defer r.touchedIdsMutex.Unlock()
+	for _, key := range keys {
+		id, err := r.parsePopularityScoreKey(key)
+		if err != nil {
+			return err
+		}
+		r.touchedIds[id] = true
+	}
+
+	return nil
+}
+
+// databaseContext reads from the primary, a lagging replica would miss meme coins created moments ago
+func (r *FallbackRedisRepository) databaseContext(ctx context.Context) context.Context {
+	return WithPrimaryReads(ctx)
+}
+
+func (r *FallbackRedisRepository) parsePopularityScoreKey(key string) (int, error) {
+	id, err := r.redis.config.Keys.ParsePopularityScore(key)
+	if err != nil {
+		return 0, fmt.Errorf("key %q has no database fallback", key)
+	}
+
+	return id, nil
+}
+
+func (r *FallbackRedisRepository) getPopularityScoreKey(id int) string {
+	return r.redis.config.Keys.PopularityScore(id)
+}
@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NewIdempotencyRepository stores the records under the keys of the builder, nil means the default namespace
+func NewIdempotencyRepository(redis redis.UniversalClient, keys *KeyBuilder) *IdempotencyRepository {
+	// Apply defaults if values aren't specified
+	if keys == nil {
+		keys = DefaultKeyBuilder()
+	}
+
+	return &IdempotencyRepository{
+		keys:  keys,
+		redis: redis,
+	}
+}
+
+// Reserve stores an in-progress record for the key unless the key is already taken.
+// It returns true when the caller owns the key, otherwise it returns the stored record.
+func (r *IdempotencyRepository) Reserve(key string, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	ctx := context.Background()
+	record, err := json.Marshal(IdempotencyRecord{
+		Fingerprint: fingerprint,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// SETNX makes concurrent duplicates race on a single key, only one of them wins
+	reserved, err := r.redis.SetNX(ctx, r.getIdempotencyKey(key), record, ttl).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if reserved {
+		return nil, true, nil
+	}
+
+	storedRecord, err := r.Find(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if storedRecord == nil {
+		// The key expired in between, try once more
+		reserved, err = r.redis.SetNX(ctx, r.getIdempotencyKey(key), record, ttl).Result()
+		if err != nil {
+			return nil, false, err
+		}
+		if reserved {
+			return nil, true, nil
+		}
+		return nil, false, errors.New("idempotency key is contended")
+	}
+
+	return storedRecord, false, nil
+}
+
+func (r *IdempotencyRepository) Find(key string) (*IdempotencyRecord, error) {
+	value, err := r.redis.Get(context.Background(), r.getIdempotencyKey(key)).Bytes()
+	if err != nil && errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var record IdempotencyRecord
+	err = json.Unmarshal(value, &record)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func (r *IdempotencyRepository) Save(key string, record IdempotencyRecord, ttl time.Duration) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.redis.Set(context.Background(), r.getIdempotencyKey(key), value, ttl).Result()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *IdempotencyRepository) Release(key string) error {
+	_, err := r.redis.Del(context.Background(), r.getIdempotencyKey(key)).Result()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *IdempotencyRepository) getIdempotencyKey(key string) string {
+	return r.keys.Idempotency(key)
+}
@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	popularityScoreKeySegment = "popularity_score"
+	scoreBucketKeySegment     = "ps"
+	idempotencyKeySegment     = "idempotency"
+	cacheInvalidationSegment  = "cache:invalidate"
+	tagLeaderboardKeySegment  = "tag_leaderboard"
+	memeCoinTagsKeySegment    = "meme_coin_tags"
+	unsyncedScoresKeySegment  = "unsynced_scores"
+	// The leaderboards and tag sets are built under these segments before they are renamed over the live ones
+	tagLeaderboardRebuildKeySegment = "tag_leaderboard_rebuild"
+	memeCoinTagsRebuildKeySegment   = "meme_coin_tags_rebuild"
+)
+
+// NewKeyBuilder validates the namespace and environment, they end up in SCAN patterns so glob and hash tag characters are refused
+func NewKeyBuilder(config KeyBuilderConfig) (*KeyBuilder, error) {
+	if config.Namespace == "" {
+		config.Namespace = DefaultKeyNamespace
+	}
+
+	prefix := config.Namespace
+	if config.Environment != "" {
+		prefix = config.Environment + ":" + config.Namespace
+	}
+	if strings.ContainsAny(prefix, "*?[]\\{} ") {
+		return nil, fmt.Errorf("invalid Redis key prefix %q", prefix)
+	}
+	if config.HashTags {
+		prefix = "{" + prefix + "}"
+	}
+
+	return &KeyBuilder{
+		prefix: prefix,
+	}, nil
+}
+
+// DefaultKeyBuilder builds the keys in the default namespace, without environment or hash tags
+func DefaultKeyBuilder() *KeyBuilder {
+	return &KeyBuilder{
+		prefix: DefaultKeyNamespace,
+	}
+}
+
+func (k *KeyBuilder) PopularityScore(id int) string {
+	return k.popularityScorePrefix() + strconv.Itoa(id)
+}
+
+// PopularityScorePattern matches every popularity score key of the string layout
+func (k *KeyBuilder) PopularityScorePattern() string {
+	return k.popularityScorePrefix() + "*"
+}
+
+// ParsePopularityScore returns the meme coin id of a popularity score key
+func (k *KeyBuilder) ParsePopularityScore(key string) (int, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(key, k.popularityScorePrefix()))
+	if err != nil || !strings.HasPrefix(key, k.popularityScorePrefix()) {
+		return 0, fmt.Errorf("key %q is not a popularity score key", key)
+	}
+
+	return id, nil
+}
+
+func (k *KeyBuilder) ScoreBucket(bucket int) string {
+	return k.prefix + ":" + scoreBucketKeySegment + ":" + strconv.Itoa(bucket)
+}
+
+// ScoreBucketPattern matches every hash of the hash layout
+func (k *KeyBuilder) ScoreBucketPattern() string {
+	return k.prefix + ":" + scoreBucketKeySegment + ":*"
+}
+
+func (k *KeyBuilder) Idempotency(key string) string {
+	return k.prefix + ":" + idempotencyKeySegment + ":" + key
+}
+
+// TagLeaderboard is the sorted set of the popularity scores of the coins with the tag
+func (k *KeyBuilder) TagLeaderboard(tag string) string {
+	return k.prefix + ":" + tagLeaderboardKeySegment + ":" + tag
+}
+
+// TagLeaderboardPattern matches every tag leaderboard
+func (k *KeyBuilder) TagLeaderboardPattern() string {
+	return k.prefix + ":" + tagLeaderboardKeySegment + ":*"
+}
+
+// MemeCoinTags is the set of the tags of a coin, read on every poke to find its leaderboards
+func (k *KeyBuilder) MemeCoinTags(id int) string {
+	return k.prefix + ":" + memeCoinTagsKeySegment + ":" + strconv.Itoa(id)
+}
+
+// MemeCoinTagsPattern matches the tag sets of every coin
+func (k *KeyBuilder) MemeCoinTagsPattern() string {
+	return k.prefix + ":" + memeCoinTagsKeySegment + ":*"
+}
+
+// TagLeaderboardRebuild is where the leaderboard of the tag is built during a warm-up, a cluster needs REDIS_KEY_HASH_TAGS to rename it
+func (k *KeyBuilder) TagLeaderboardRebuild(tag string) string {
+	return k.prefix + ":" + tagLeaderboardRebuildKeySegment + ":" + tag
+}
+
+func (k *KeyBuilder) TagLeaderboardRebuildPattern() string {
+	return k.prefix + ":" + tagLeaderboardRebuildKeySegment + ":*"
+}
+
+// MemeCoinTagsRebuild is where the tag set of the coin is built during a warm-up, a cluster needs REDIS_KEY_HASH_TAGS to rename it
+func (k *KeyBuilder) MemeCoinTagsRebuild(id int) string {
+	return k.prefix + ":" + memeCoinTagsRebuildKeySegment + ":" + strconv.Itoa(id)
+}
+
+func (k *KeyBuilder) MemeCoinTagsRebuildPattern() string {
+	return k.prefix + ":" + memeCoinTagsRebuildKeySegment + ":*"
+}
+
+// UnsyncedPopularityScores is the hash of the increments per score key not written to the database yet, shared by every process
+func (k *KeyBuilder) UnsyncedPopularityScores() string {
+	return k.prefix + ":" + unsyncedScoresKeySegment
+}
+
+func (k *KeyBuilder) CacheInvalidationChannel() string {
+	return k.prefix + ":" + cacheInvalidationSegment
+}
+
+func (k *KeyBuilder) popularityScorePrefix() string {
+	return k.prefix + ":" + popularityScoreKeySegment + ":"
+}
@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"portto-assignment/pkg/clock"
+	"time"
+)
+
+// NewMemoryIdempotencyRepository expires the records on timeSource, the real clock when nil
+func NewMemoryIdempotencyRepository(timeSource clock.Clock) *MemoryIdempotencyRepository {
+	// Apply defaults if values aren't specified
+	if timeSource == nil {
+		timeSource = clock.Real()
+	}
+
+	return &MemoryIdempotencyRepository{
+		records: map[string]memoryIdempotencyRecord{},
+		clock:   timeSource,
+	}
+}
+
+// Reserve returns true when the caller owns the key, otherwise the stored record
+func (r *MemoryIdempotencyRepository) Reserve(key string, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.sweep()
+	stored, found := r.records[key]
+	if found {
+		return &stored.record, false, nil
+	}
+	r.records[key] = memoryIdempotencyRecord{
+		record:    IdempotencyRecord{Fingerprint: fingerprint},
+		expiresAt: r.clock.Now().Add(ttl),
+	}
+
+	return nil, true, nil
+}
+
+func (r *MemoryIdempotencyRepository) Find(key string) (*IdempotencyRecord, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, found := r.records[key]
+	if !found || !r.clock.Now().Before(stored.expiresAt) {
+		return nil, nil
+	}
+
+	return &stored.record, nil
+}
+
+func (r *MemoryIdempotencyRepository) Save(key string, record IdempotencyRecord, ttl time.Duration) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.records[key] = memoryIdempotencyRecord{
+		record:    record,
+		expiresAt: r.clock.Now().Add(ttl),
+	}
+	return nil
+}
+
+func (r *MemoryIdempotencyRepository) Release(key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.records, key)
+	return nil
+}
+
+// sweep is called with the lock held, it drops the expired records as Redis would
+func (r *MemoryIdempotencyRepository) sweep() {
+	now := r.clock.Now()
+	for key, stored := range r.records {
+		if !now.Before(stored.expiresAt) {
+			delete(r.records, key)
+		}
+	}
+}
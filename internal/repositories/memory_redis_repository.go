@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"context"
+	"maps"
+)
+
+// NewMemoryRedisRepository serves the popularity scores from memory, the database stays the source of truth when config.Repo is set
+func NewMemoryRedisRepository(config MemoryRedisConfig) *MemoryRedisRepository {
+	// Apply defaults if values aren't specified
+	if config.Keys == nil {
+		config.Keys = DefaultKeyBuilder()
+	}
+
+	return &MemoryRedisRepository{
+		values: map[string]int{},
+		config: config,
+	}
+}
+
+// Status is always up, there is no connection to lose
+func (r *MemoryRedisRepository) Status() RedisStatus {
+	return RedisStatusUp
+}
+
+// WarmUp loads the popularity score of every meme coin of the repository
+func (r *MemoryRedisRepository) WarmUp(ctx context.Context) (int, error) {
+	if r.config.Repo == nil {
+		return 0, nil
+	}
+
+	popularityScores := map[string]int{}
+	err := r.config.Repo.StreamAll(ctx, func(memeCoin MemeCoin) error {
+		popularityScores[r.config.Keys.PopularityScore(memeCoin.Id)] = memeCoin.PopularityScore
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	maps.Copy(r.values, popularityScores)
+
+	return len(popularityScores), nil
+}
+
+func (r *MemoryRedisRepository) IncrBy(ctx context.Context, key string, increment int) error {
+	return r.IncrByMany(ctx, map[string]int{key: increment})
+}
+
+func (r *MemoryRedisRepository) Get(ctx context.Context, key string) (int, bool, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	value, found := r.values[key]
+	return value, found, nil
+}
+
+func (r *MemoryRedisRepository) Set(ctx context.Context, key string, value int) error {
+	return r.SetMany(ctx, map[string]int{key: value})
+}
+
+func (r *MemoryRedisRepository) Delete(ctx context.Context, key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.values, key)
+	return nil
+}
+
+func (r *MemoryRedisRepository) Exists(ctx context.Context, key string) (bool, error) {
+	existsMap, err := r.ExistsMany(ctx, []string{key})
+	return existsMap[key], err
+}
+
+// SetMany writes the scores through to the repository before they are served
+func (r *MemoryRedisRepository) SetMany(ctx context.Context, values map[string]int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, value := range values {
+		id, stored := r.storedId(key)
+		if stored {
+			_, err := r.config.Repo.UpdatePopularityScore(ctx, id, value)
+			if err != nil {
+				return err
+			}
+		}
+		r.values[key] = value
+	}
+
+	return nil
+}
+
+// IncrByMany increments the repository first, so that the served score is the stored one
+func (r *MemoryRedisRepository) IncrByMany(ctx context.Context, increments map[string]int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, increment := range increments {
+		err := r.incrBy(ctx, key, increment)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AdjustBy checks the result with the lock held, so that no increment comes between the check and the adjustment
+func (r *MemoryRedisRepository) AdjustBy(ctx context.Context, key string, delta int) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.values[key]+delta < 0 {
+		return 0, ErrNegativePopularityScore
+	}
+	err := r.incrBy(ctx, key, delta)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.values[key], nil
+}
+
+// incrBy is called with the lock held
+func (r *MemoryRedisRepository) incrBy(ctx context.Context, key string, increment int) error {
+	id, stored := r.storedId(key)
+	if !stored {
+		r.values[key] += increment
+		return nil
+	}
+
+	memeCoin, err := r.config.Repo.IncrementPopularityScore(ctx, id, increment)
+	if err != nil {
+		return err
+	}
+	if memeCoin == nil {
+		// As Redis would, a key is created for a meme coin that doesn't exist
+		r.values[key] += increment
+		return nil
+	}
+	r.values[key] = memeCoin.PopularityScore
+
+	return nil
+}
+
+func (r *MemoryRedisRepository) ExistsMany(ctx context.Context, keys []string) (map[string]bool, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	existsMap := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		_, existsMap[key] = r.values[key]
+	}
+	return existsMap, nil
+}
+
+// Values returns a copy of every key and its value
+func (r *MemoryRedisRepository) Values() map[string]int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return maps.Clone(r.values)
+}
+
+// storedId returns the meme coin of a popularity score key, other keys only live in memory
+func (r *MemoryRedisRepository) storedId(key string) (int, bool) {
+	if r.config.Repo == nil {
+		return 0, false
+	}
+	id, err := r.config.Keys.ParsePopularityScore(key)
+	if err != nil {
+		return 0, false
+	}
+
+	return id, true
+}
@@ -0,0 +1,201 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ParseReconcilePolicy validates a policy name, an empty name means report only
+func ParseReconcilePolicy(name string) (ReconcilePolicy, error) {
+	switch policy := ReconcilePolicy(name); policy {
+	case "":
+		return ReconcilePolicyReport, nil
+	case ReconcilePolicyReport, ReconcilePolicyRedis, ReconcilePolicyDatabase:
+		return policy, nil
+	}
+
+	return "", fmt.Errorf("unknown reconcile policy %q", name)
+}
+
+func (r *RedisCachedRepository) startReconcileWorker() {
+	ticker := r.config.Clock.NewTicker(r.config.ReconcileInterval)
+
+	go func() {
+		for range ticker.C() {
+			report, err := r.Reconcile(r.config.ReconcilePolicy)
+			if err != nil {
+				r.config.Logger.Error("Error reconciling popularity scores", "error", err)
+				continue
+			}
+			r.config.Logger.Info(
+				"Reconciled popularity scores",
+				"orphan_keys", len(report.OrphanKeys), "missing_keys", len(report.MissingKeys), "mismatches", len(report.Mismatches), "repaired", report.Repaired,
+			)
+		}
+	}()
+}
+
+// Reconcile compares every popularity score key in Redis with the database and repairs the drift under the given policy.
+//
+// Orphan keys are deleted and missing keys are created from the database by every repairing policy,
+// the policy only decides which side wins for mismatched scores.
+func (r *RedisCachedRepository) Reconcile(policy ReconcilePolicy) (*ReconcileReport, error) {
+	policy, err := ParseReconcilePolicy(string(policy))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReconcileReport{
+		Policy:      policy,
+		OrphanKeys:  []string{},
+		MissingKeys: []StoredScore{},
+		Mismatches:  []ScoreMismatch{},
+	}
+
+	cachedScores, err := r.scanPopularityScores(report)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(context.Background(), "SELECT id, popularity_score FROM meme_coins ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row memeCoinPopularityScore
+		if err := rows.Scan(&row.Id, &row.PopularityScore); err != nil {
+			return nil, err
+		}
+		report.ScannedRows++
+
+		cached, found := cachedScores[row.Id]
+		if !found {
+			report.MissingKeys = append(report.MissingKeys, StoredScore{Id: row.Id, Stored: row.PopularityScore})
+			continue
+		}
+		delete(cachedScores, row.Id)
+
+		if cached != row.PopularityScore {
+			report.Mismatches = append(report.Mismatches, ScoreMismatch{
+				Id:     row.Id,
+				Cached: cached,
+				Stored: row.PopularityScore,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Whatever is left in Redis has no meme coin behind it
+	for id := range cachedScores {
+		report.OrphanKeys = append(report.OrphanKeys, r.config.Keys.PopularityScore(id))
+	}
+	sort.Strings(report.OrphanKeys)
+
+	if policy != ReconcilePolicyReport {
+		err = r.repairDrift(report)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// scanPopularityScores reads every popularity score in Redis, invalid keys are reported as orphans
+func (r *RedisCachedRepository) scanPopularityScores(report *ReconcileReport) (map[int]int, error) {
+	cachedScores := make(map[int]int)
+	err := r.scanScores(context.Background(), r.config.ScoreLayout, func(page scorePage) error {
+		for id, score := range page.scores {
+			cachedScores[id] = score
+		}
+		report.OrphanKeys = append(report.OrphanKeys, page.invalidKeys...)
+		report.ScannedKeys += page.scanned
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cachedScores, nil
+}
+
+func (r *RedisCachedRepository) repairDrift(report *ReconcileReport) error {
+	ctx := context.Background()
+	pipe := r.redis.Pipeline()
+	for _, key := range report.OrphanKeys {
+		location, err := r.locate(key)
+		if err != nil {
+			return err
+		}
+		location.del(ctx, pipe)
+	}
+	// SETNX keeps a key created by a poke since the scan
+	for _, missing := range report.MissingKeys {
+		location, err := r.locate(r.config.Keys.PopularityScore(missing.Id))
+		if err != nil {
+			return err
+		}
+		location.setNX(ctx, pipe, missing.Stored)
+	}
+	// Keys with pokes not synced yet are left to the sync worker, the others are only replaced if no poke landed since the scan
+	replaced := []*redis.Cmd{}
+	if report.Policy == ReconcilePolicyDatabase {
+		keys := make([]string, len(report.Mismatches))
+		for i, mismatch := range report.Mismatches {
+			keys[i] = r.config.Keys.PopularityScore(mismatch.Id)
+		}
+		unsynced, err := r.readUnsynced(ctx, keys)
+		if err != nil {
+			return err
+		}
+		for i, mismatch := range report.Mismatches {
+			if unsynced[keys[i]] {
+				continue
+			}
+			location, err := r.locate(keys[i])
+			if err != nil {
+				return err
+			}
+			replaced = append(replaced, location.compareAndSet(ctx, pipe, mismatch.Cached, mismatch.Stored))
+		}
+	}
+	if pipe.Len() > 0 {
+		_, err := pipe.Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if report.Policy == ReconcilePolicyRedis && len(report.Mismatches) > 0 {
+		// Re-read the keys so increments since the scan are not written back as stale values
+		keys := make([]string, len(report.Mismatches))
+		for i, mismatch := range report.Mismatches {
+			keys[i] = r.config.Keys.PopularityScore(mismatch.Id)
+		}
+		err := r.SyncKeys(keys)
+		if err != nil {
+			return err
+		}
+	}
+
+	report.Repaired = len(report.OrphanKeys) + len(report.MissingKeys) + len(report.Mismatches)
+	if report.Policy == ReconcilePolicyDatabase {
+		report.Skipped = len(report.Mismatches)
+		for _, cmd := range replaced {
+			if cmd.Val() == int64(1) {
+				report.Skipped--
+			}
+		}
+		report.Repaired -= report.Skipped
+	}
+
+	return nil
+}
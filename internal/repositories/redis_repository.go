@@ -2,17 +2,23 @@ package repositories
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"portto-assignment/config"
+	"portto-assignment/internal/tracing"
+	"portto-assignment/pkg/clock"
+	"sort"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func NewRedisCachedRepository(db *sql.DB, redis *redis.Client, config RepositoryConfig) *RedisCachedRepository {
+func NewRedisCachedRepository(db config.DatabaseConnectionPoolInterface, redis redis.UniversalClient, config RepositoryConfig) *RedisCachedRepository {
 	// Apply defaults if values aren't specified
 	if config.SyncBatchSize <= 0 {
 		config.SyncBatchSize = DefaultSyncBatchSize // Default value
@@ -20,176 +26,640 @@ func NewRedisCachedRepository(db *sql.DB, redis *redis.Client, config Repository
 	if config.SyncInterval <= 0 {
 		config.SyncInterval = DefaultSyncInterval // Default value
 	}
+	if config.MaxSyncRetryBackoff <= 0 {
+		config.MaxSyncRetryBackoff = DefaultMaxSyncRetryBackoff
+	}
+	if config.WarmUpPageSize <= 0 {
+		config.WarmUpPageSize = DefaultWarmUpPageSize
+	}
+	if config.WarmUpWorkers <= 0 {
+		config.WarmUpWorkers = DefaultWarmUpWorkers
+	}
+	if config.ScoreLayout == "" {
+		config.ScoreLayout = ScoreLayoutString
+	}
+	if config.ScoreBucketSize <= 0 {
+		config.ScoreBucketSize = DefaultScoreBucketSize
+	}
+	if config.Keys == nil {
+		config.Keys = DefaultKeyBuilder()
+	}
+	if config.Clock == nil {
+		config.Clock = clock.Real()
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
 
 	repo := &RedisCachedRepository{
 		db:       db,
 		redis:    redis,
 		config:   config,
-		syncKeys: make(chan string, config.SyncBatchSize*2), // Buffer size based on batch size
+		syncKeys: make(chan dirtyKey, config.SyncBatchSize*2), // Buffer size based on batch size
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
 	}
 
 	if config.NeedToSync {
-		// Sync Redis with the database
-		isDone := make(chan bool)
-		go func() {
-			repo.setPopularityScoreToRedis()
-			isDone <- true
-		}()
-		<-isDone
-
 		// Start the sync worker to sync Redis with the database
 		repo.startPopularityScoreSyncWorker()
+
+		// Redis is warmed up by the recovery of the fallback when it is down, warming up here would only wait for its timeouts
+		if err := repo.Ping(); err != nil {
+			config.Logger.Warn("Redis is unavailable, popularity scores are warmed up once it recovers", "error", err)
+		} else {
+			// Sync Redis with the database
+			isDone := make(chan bool)
+			go func() {
+				if _, err := repo.WarmUp(); err != nil {
+					config.Logger.Error("Error warming up popularity scores", "error", err)
+				}
+				isDone <- true
+			}()
+			<-isDone
+
+			if err := repo.syncLeftovers(); err != nil {
+				config.Logger.Error("Error reading the popularity scores left unsynced", "error", err)
+			}
+		}
+
+		if config.ReconcileInterval > 0 {
+			repo.startReconcileWorker()
+		}
 	}
 
 	return repo
 }
 
-func (r *RedisCachedRepository) IncrBy(key string, increment int) error {
-	_, err := r.redis.IncrBy(context.Background(), key, int64(increment)).Result()
+func (r *RedisCachedRepository) Ping() error {
+	return r.redis.Ping(context.Background()).Err()
+}
+
+func (r *RedisCachedRepository) IncrBy(ctx context.Context, key string, increment int) error {
+	return r.IncrByMany(ctx, map[string]int{key: increment})
+}
+
+func (r *RedisCachedRepository) Get(ctx context.Context, key string) (int, bool, error) {
+	location, err := r.locate(key)
 	if err != nil {
-		return err
+		return 0, false, err
+	}
+	value, err := location.get(ctx, r.redis).Int()
+	if err != nil && errors.Is(err, redis.Nil) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
 	}
 
-	// Add the key to the dirty keys channel
-	r.syncKeys <- key
+	return value, true, nil
+}
 
-	return nil
+func (r *RedisCachedRepository) Set(ctx context.Context, key string, value int) error {
+	return r.SetMany(ctx, map[string]int{key: value})
 }
 
-func (r *RedisCachedRepository) Set(key string, value int) error {
-	_, err := r.redis.Set(context.Background(), key, value, 0).Result()
+// Delete takes the coin off the tag leaderboards too
+func (r *RedisCachedRepository) Delete(ctx context.Context, key string) error {
+	location, err := r.locate(key)
 	if err != nil {
 		return err
 	}
 
+	pipe := r.redis.Pipeline()
+	del := location.del(ctx, pipe)
+	tagCmds := r.readLeaderboardTags(ctx, pipe, map[string]int{key: 0})
+	pipe.Exec(ctx)
+	if del.Err() != nil {
+		return del.Err()
+	}
+
+	r.updateLeaderboards(ctx, func() error {
+		return r.config.TagLeaderboards.removeMemeCoins(ctx, tagCmds)
+	})
+
 	return nil
 }
 
-func (r *RedisCachedRepository) Delete(key string) error {
-	_, err := r.redis.Del(context.Background(), key).Result()
+func (r *RedisCachedRepository) Exists(ctx context.Context, key string) (bool, error) {
+	location, err := r.locate(key)
 	if err != nil {
-		return err
+		return false, err
 	}
+	exists, err := scoreExists(location.exists(ctx, r.redis))
+	if err != nil {
+		return false, err
+	}
+	r.config.Logger.Debug("Checked popularity score key", "key", key, "exists", exists)
 
-	return nil
+	return exists, nil
 }
 
-func (r *RedisCachedRepository) Exists(key string) (bool, error) {
-	log.Printf("Checking key: %s", key)
-	count, err := r.redis.Exists(context.Background(), key).Result()
+// markUnsynced queues the mark of an increment of the key before the increment, so that no process sees it in Redis without the mark
+func (r *RedisCachedRepository) markUnsynced(ctx context.Context, pipe redis.Pipeliner, key string) *redis.IntCmd {
+	return pipe.HIncrBy(ctx, r.config.Keys.UnsyncedPopularityScores(), key, 1)
+}
+
+// clearMarks takes back the marks of failed increments, a mark that didn't reach Redis either is left alone
+func (r *RedisCachedRepository) clearMarks(ctx context.Context, marks map[string]*redis.IntCmd) {
+	increments := make(map[string]int, len(marks))
+	for key, mark := range marks {
+		if mark.Err() == nil {
+			increments[key] = 1
+		}
+	}
+	err := r.markSynced(ctx, increments)
 	if err != nil {
-		return false, err
+		r.config.Logger.WarnContext(ctx, "Error clearing the marks of failed increments", "keys", len(increments), "error", err)
+	}
+}
+
+// markSynced clears the given number of increments per key once they are written to the database
+func (r *RedisCachedRepository) markSynced(ctx context.Context, increments map[string]int) error {
+	if len(increments) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(increments))
+	for key := range increments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pipe := r.redis.Pipeline()
+	for _, key := range keys {
+		pipe.Eval(ctx, markSyncedScript, []string{r.config.Keys.UnsyncedPopularityScores()}, key, increments[key])
+	}
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+// readUnsynced returns which of the keys hold increments that no process has written to the database yet
+func (r *RedisCachedRepository) readUnsynced(ctx context.Context, keys []string) (map[string]bool, error) {
+	unsynced := make(map[string]bool, len(keys))
+	if len(keys) == 0 {
+		return unsynced, nil
+	}
+	values, err := r.redis.HMGet(ctx, r.config.Keys.UnsyncedPopularityScores(), keys...).Result()
+	if err != nil {
+		return nil, err
 	}
-	log.Printf("Count: %d", count)
+	for i, key := range keys {
+		unsynced[key] = values[i] != nil
+	}
+
+	return unsynced, nil
+}
+
+// syncLeftovers hands the keys still marked in Redis to the sync worker, a process that stopped before syncing them left them behind
+func (r *RedisCachedRepository) syncLeftovers() error {
+	leftovers, err := r.redis.HGetAll(context.Background(), r.config.Keys.UnsyncedPopularityScores()).Result()
+	if err != nil {
+		return err
+	}
+	for key, value := range leftovers {
+		increments, err := strconv.Atoi(value)
+		if err != nil || increments <= 0 {
+			continue
+		}
+		r.syncKeys <- dirtyKey{key: key, increments: increments}
+	}
+
+	return nil
+}
 
-	return count > 0, nil
+// Close stops the sync worker once the pending keys are written, keys that can't be written stay marked for the next start
+func (r *RedisCachedRepository) Close() error {
+	r.closeOnce.Do(func() {
+		if !r.config.NeedToSync {
+			return
+		}
+		close(r.stop)
+		<-r.done
+	})
+
+	return r.closeErr
 }
 
 func (r *RedisCachedRepository) startPopularityScoreSyncWorker() {
-	ticker := time.NewTicker(r.config.SyncInterval)
+	ticker := r.config.Clock.NewTicker(r.config.SyncInterval)
 	pendingCounts := 0
-	pendingSync := make(map[string]bool) // Just tracking which IDs need sync
+	pendingSync := make(map[string]bool)      // Just tracking which IDs need sync
+	pendingIncrements := make(map[string]int) // The increments of each key the batch carries
+	pendingLinks := []trace.Link{}            // The requests whose increments the batch carries
+	failures := 0                             // Consecutive failed syncs, the next one waits until retryAt
+	var retryAt time.Time
+
+	// A failed batch stays pending and keeps collecting dirty keys until the backoff is over
+	syncPending := func() {
+		if r.syncHeld.Load() || r.config.Clock.Now().Before(retryAt) {
+			return
+		}
+		if !r.syncPending(pendingSync, pendingIncrements, pendingLinks) {
+			failures++
+			retryAt = r.config.Clock.Now().Add(r.syncRetryBackoff(failures))
+			return
+		}
+		pendingSync = make(map[string]bool)
+		pendingIncrements = make(map[string]int)
+		pendingLinks = []trace.Link{}
+		pendingCounts = 0
+		failures = 0
+		retryAt = time.Time{}
+	}
+
+	addPending := func(dirty dirtyKey) {
+		if !pendingSync[dirty.key] {
+			pendingSync[dirty.key] = true
+			pendingCounts++
+		}
+		pendingIncrements[dirty.key] += dirty.increments
+		if dirty.link.SpanContext.IsValid() && len(pendingLinks) < MaxSyncBatchLinks {
+			pendingLinks = append(pendingLinks, dirty.link)
+		}
+	}
 
 	go func() {
+		defer close(r.done)
+		defer ticker.Stop()
+
 		for {
 			select {
-			case key := <-r.syncKeys:
-				if !pendingSync[key] {
-					pendingSync[key] = true
-					pendingCounts++
-				}
+			case dirty := <-r.syncKeys:
+				addPending(dirty)
 
 				// If we have enough pending items, trigger a sync
 				if pendingCounts >= r.config.SyncBatchSize {
-					r.syncPopularityScoreBatch(pendingSync)
-					pendingSync = make(map[string]bool)
-					pendingCounts = 0
+					syncPending()
 				}
 
-			case <-ticker.C:
+			case <-ticker.C():
 				// Time-based sync for any remaining items
 				if pendingCounts > 0 {
-					r.syncPopularityScoreBatch(pendingSync)
-					pendingSync = make(map[string]bool)
-					pendingCounts = 0
+					syncPending()
 				}
-			}
 
+			case <-r.stop:
+				// The last batch is written without waiting for the backoff, the database holds the newer scores while the sync is held
+				for len(r.syncKeys) > 0 {
+					addPending(<-r.syncKeys)
+				}
+				if pendingCounts > 0 && !r.syncHeld.Load() && !r.syncPending(pendingSync, pendingIncrements, pendingLinks) {
+					r.closeErr = fmt.Errorf("%d popularity score key(s) left unsynced", pendingCounts)
+				}
+				return
+			}
 		}
 	}()
 }
 
-func (r *RedisCachedRepository) syncPopularityScoreBatch(keysExistMap map[string]bool) {
-	// Start a transaction
-	ctx := context.Background()
-	tx, err := r.db.Begin()
+// syncRetryBackoff doubles the wait after every consecutive failure, starting from the sync interval
+func (r *RedisCachedRepository) syncRetryBackoff(failures int) time.Duration {
+	backoff := r.config.SyncInterval
+	for i := 1; i < failures && backoff < r.config.MaxSyncRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, r.config.MaxSyncRetryBackoff)
+}
+
+// syncPending syncs a batch of the worker, the increments it carries are no longer unsynced once it is written
+func (r *RedisCachedRepository) syncPending(keysExistMap map[string]bool, increments map[string]int, links []trace.Link) bool {
+	err := r.syncLinkedBatch(keysExistMap, links)
 	if err != nil {
-		log.Printf("Error starting transaction: %v", err)
-		return
+		r.config.Logger.Error("Error syncing popularity scores, the batch will be retried", "keys", len(keysExistMap), "error", err)
+		return false
+	}
+	// The marks left behind only keep reconcile off the keys until they are synced again
+	err = r.markSynced(context.Background(), increments)
+	if err != nil {
+		r.config.Logger.Warn("Error clearing the marks of synced popularity scores", "keys", len(increments), "error", err)
 	}
 
-	keys := []string{}
+	return true
+}
+
+// syncLinkedBatch traces the batch as a background trace of its own, linked to the requests that made the keys dirty
+func (r *RedisCachedRepository) syncLinkedBatch(keysExistMap map[string]bool, links []trace.Link) error {
+	ctx, span := tracing.Tracer().Start(context.Background(), "RedisCachedRepository.SyncBatch",
+		trace.WithNewRoot(),
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.Int("sync.keys", len(keysExistMap))),
+	)
+	defer span.End()
+
+	err := r.syncPopularityScoreBatch(ctx, keysExistMap)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// SyncAll writes every popularity score in Redis to the database, regardless of the pending dirty keys
+func (r *RedisCachedRepository) SyncAll() (int, error) {
+	ctx := context.Background()
+	synced := 0
+	err := r.scanScores(ctx, r.config.ScoreLayout, func(page scorePage) error {
+		ids := make([]int, 0, len(page.scores))
+		scores := make([]int, 0, len(page.scores))
+		for id, score := range page.scores {
+			ids = append(ids, id)
+			scores = append(scores, score)
+		}
+		err := r.writePopularityScores(ctx, ids, scores)
+		if err != nil {
+			return err
+		}
+		synced += len(ids)
+
+		return nil
+	})
+
+	return synced, err
+}
+
+// SyncKeys writes the popularity scores of the given keys from Redis to the database
+func (r *RedisCachedRepository) SyncKeys(keys []string) error {
+	keysExistMap := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		keysExistMap[key] = true
+	}
+
+	return r.syncPopularityScoreBatch(context.Background(), keysExistMap)
+}
+
+// syncPopularityScoreBatch reads the keys with one MGET, or one HMGET per bucket, and writes them with one UPDATE, so a batch is applied entirely or not at all
+func (r *RedisCachedRepository) syncPopularityScoreBatch(ctx context.Context, keysExistMap map[string]bool) error {
+	keys := make([]string, 0, len(keysExistMap))
 	for key := range keysExistMap {
-		// Get current score from Redis - this will include ALL increments that have happened
-		score, err := r.redis.Get(context.Background(), key).Int()
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+
+	// Get current scores from Redis - this will include ALL increments that have happened
+	values, err := r.getMany(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int, 0, len(keys))
+	scores := make([]int, 0, len(keys))
+	for i, key := range keys {
+		// Keys deleted since they were marked dirty come back as nil
+		value, ok := values[i].(string)
+		if !ok {
+			continue
+		}
+		score, err := strconv.Atoi(value)
 		if err != nil {
+			r.config.Logger.Warn("Skipping popularity score with invalid value", "key", key, "value", value)
 			continue
 		}
-
-		// Update database with the accurate count from Redis
-		tokens := strings.Split(key, ":")
-		id, _ := strconv.Atoi(tokens[len(tokens)-1])
-		_, err = tx.ExecContext(ctx, "UPDATE meme_coins SET popularity_score = $2 WHERE id = $1", id, score)
+		id, err := r.config.Keys.ParsePopularityScore(key)
 		if err != nil {
-			log.Printf("Error updating score for %s: %v", key, err)
+			r.config.Logger.Warn("Skipping popularity score key with invalid id", "key", key)
+			continue
 		}
 
-		keys = append(keys, key)
+		ids = append(ids, id)
+		scores = append(scores, score)
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		log.Printf("Error committing transaction: %v", err)
-		tx.Rollback()
+	return r.writePopularityScores(ctx, ids, scores)
+}
+
+// writePopularityScores updates the scores of the meme coins with one statement
+func (r *RedisCachedRepository) writePopularityScores(ctx context.Context, ids []int, scores []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// Update database with the accurate counts from Redis
+	const sqlStatement string = `
+		UPDATE meme_coins
+		SET popularity_score = input.popularity_score
+		FROM unnest($1::int[], $2::int[]) AS input(id, popularity_score)
+		WHERE meme_coins.id = input.id`
+	_, err := r.db.Exec(ctx, sqlStatement, ids, scores)
+	if err != nil {
+		return err
 	}
 
 	// Log the sync
-	log.Printf("Synced keys: %v", keys)
+	r.config.Logger.InfoContext(ctx, "Synced popularity scores", "count", len(ids))
+
+	return nil
 }
 
-func (r *RedisCachedRepository) setPopularityScoreToRedis() {
-	// Fetch all popularity scores from the database
-	var popularityScoreRows []memeCoinPopularityScore
-	const limit = 100
-	page := 0
-	for {
-		rows, err := r.db.Query("SELECT id, popularity_score FROM meme_coins LIMIT $1 OFFSET $2", limit, limit*page)
+// SetMany overwrites the scores on the tag leaderboards too
+func (r *RedisCachedRepository) SetMany(ctx context.Context, values map[string]int) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	pipe := r.redis.Pipeline()
+	cmds := make([]redis.Cmder, 0, len(values))
+	for key, value := range values {
+		location, err := r.locate(key)
 		if err != nil {
-			log.Printf("Error fetching popularity scores: %v\b", err)
-			return
+			return err
 		}
+		cmds = append(cmds, location.set(ctx, pipe, value))
+	}
+	tagCmds := r.readLeaderboardTags(ctx, pipe, values)
+	pipe.Exec(ctx)
+	err := scoreError(cmds)
+	if err != nil {
+		return err
+	}
 
-		for rows.Next() {
-			var popularityScoreRow memeCoinPopularityScore
-			rows.Scan(&popularityScoreRow.Id, &popularityScoreRow.PopularityScore)
-			popularityScoreRows = append(popularityScoreRows, popularityScoreRow)
+	r.updateLeaderboards(ctx, func() error {
+		return r.config.TagLeaderboards.setScores(ctx, tagCmds, r.leaderboardValues(values))
+	})
+
+	return nil
+}
+
+// IncrByMany adds the increments to the tag leaderboards too, reading the tags in the pipeline of the scores.
+// When only some increments fail, the applied ones are synced as usual and the failed ones are returned in an IncrementError.
+func (r *RedisCachedRepository) IncrByMany(ctx context.Context, increments map[string]int) error {
+	if len(increments) == 0 {
+		return nil
+	}
+
+	// Keys are pipelined in a stable order, a retried batch sends the same commands
+	keys := make([]string, 0, len(increments))
+	for key := range increments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pipe := r.redis.Pipeline()
+	cmds := make(map[string]redis.Cmder, len(increments))
+	marks := make(map[string]*redis.IntCmd, len(increments))
+	for _, key := range keys {
+		location, err := r.locate(key)
+		if err != nil {
+			return err
+		}
+		marks[key] = r.markUnsynced(ctx, pipe, key)
+		cmds[key] = location.incrBy(ctx, pipe, int64(increments[key]))
+	}
+	tagCmds := r.readLeaderboardTags(ctx, pipe, increments)
+	pipe.Exec(ctx)
+
+	applied := make(map[string]int, len(increments))
+	failed := map[string]int{}
+	failedMarks := map[string]*redis.IntCmd{}
+	var err error
+	for key, cmd := range cmds {
+		if cmd.Err() == nil {
+			applied[key] = increments[key]
+			continue
+		}
+		failed[key] = increments[key]
+		failedMarks[key] = marks[key]
+		if err == nil {
+			err = cmd.Err()
 		}
+	}
+	// Failed increments aren't sent to the sync worker, which would be the one clearing their marks
+	if len(failedMarks) > 0 {
+		r.clearMarks(ctx, failedMarks)
+	}
+
+	// Add the keys to the dirty keys channel
+	link := trace.LinkFromContext(ctx)
+	for key := range applied {
+		r.syncKeys <- dirtyKey{key: key, increments: 1, link: link}
+	}
 
-		ctx := context.Background()
-		pipe := r.redis.Pipeline()
-		for _, row := range popularityScoreRows {
-			pipe.Set(ctx, fmt.Sprintf("meme:popularity_score:%d", row.Id), row.PopularityScore, 0)
+	if len(applied) > 0 {
+		appliedValues := r.leaderboardValues(applied)
+		appliedTagCmds := make(map[int]*redis.StringSliceCmd, len(appliedValues))
+		for id := range appliedValues {
+			appliedTagCmds[id] = tagCmds[id]
 		}
-		_, err = pipe.Exec(ctx)
+		r.updateLeaderboards(ctx, func() error {
+			return r.config.TagLeaderboards.incrBy(ctx, appliedTagCmds, appliedValues)
+		})
+	}
+
+	if len(failed) > 0 {
+		return &IncrementError{Failed: failed, Err: err}
+	}
+
+	return nil
+}
+
+// AdjustBy checks and increments the score in one script, so that pokes can't interleave and take it below zero
+func (r *RedisCachedRepository) AdjustBy(ctx context.Context, key string, delta int) (int, error) {
+	location, err := r.locate(key)
+	if err != nil {
+		return 0, err
+	}
+
+	pipe := r.redis.Pipeline()
+	mark := r.markUnsynced(ctx, pipe, key)
+	adjust := location.adjustBy(ctx, pipe, delta)
+	tagCmds := r.readLeaderboardTags(ctx, pipe, map[string]int{key: delta})
+	pipe.Exec(ctx)
+	value, err := adjust.Int()
+	if err != nil {
+		r.clearMarks(ctx, map[string]*redis.IntCmd{key: mark})
+		if errors.Is(err, redis.Nil) {
+			return 0, ErrNegativePopularityScore
+		}
+		return 0, err
+	}
+
+	r.syncKeys <- dirtyKey{key: key, increments: 1, link: trace.LinkFromContext(ctx)}
+	r.updateLeaderboards(ctx, func() error {
+		return r.config.TagLeaderboards.setScores(ctx, tagCmds, r.leaderboardValues(map[string]int{key: value}))
+	})
+
+	return value, nil
+}
+
+// leaderboardValues maps the values of popularity score keys to their coin ids, other keys have no leaderboard
+func (r *RedisCachedRepository) leaderboardValues(values map[string]int) map[int]int {
+	ids := make(map[int]int, len(values))
+	for key, value := range values {
+		id, err := r.config.Keys.ParsePopularityScore(key)
 		if err != nil {
-			log.Printf("Error setting popularity scores in Redis: %v\n", err)
-			return
+			continue
+		}
+		ids[id] = value
+	}
+
+	return ids
+}
+
+// readLeaderboardTags queues the tag reads of the coins on the pipeline of their scores, nil without tag leaderboards
+func (r *RedisCachedRepository) readLeaderboardTags(ctx context.Context, pipe redis.Pipeliner, values map[string]int) map[int]*redis.StringSliceCmd {
+	if r.config.TagLeaderboards == nil {
+		return nil
+	}
+
+	ids := []int{}
+	for id := range r.leaderboardValues(values) {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	return r.config.TagLeaderboards.readTags(ctx, pipe, ids)
+}
+
+// updateLeaderboards only logs failures, the scores are written by then and a retry would count them twice.
+// The leaderboards are rebuilt from the database on start.
+func (r *RedisCachedRepository) updateLeaderboards(ctx context.Context, update func() error) {
+	if r.config.TagLeaderboards == nil {
+		return
+	}
+	err := update()
+	if err != nil {
+		r.config.Logger.WarnContext(ctx, "Failed to update the tag leaderboards", "error", err)
+	}
+}
+
+// scoreError returns the first failed score write of a pipeline, the tag reads sharing it don't fail the write
+func scoreError(cmds []redis.Cmder) error {
+	for _, cmd := range cmds {
+		if cmd.Err() != nil {
+			return cmd.Err()
 		}
+	}
+
+	return nil
+}
+
+func (r *RedisCachedRepository) ExistsMany(ctx context.Context, keys []string) (map[string]bool, error) {
+	existsMap := make(map[string]bool, len(keys))
+	if len(keys) == 0 {
+		return existsMap, nil
+	}
 
-		if len(popularityScoreRows) < limit {
-			break
+	pipe := r.redis.Pipeline()
+	cmds := make([]redis.Cmder, len(keys))
+	for i, key := range keys {
+		location, err := r.locate(key)
+		if err != nil {
+			return nil, err
 		}
-		page++
+		cmds[i] = location.exists(ctx, pipe)
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, key := range keys {
+		existsMap[key], _ = scoreExists(cmds[i])
 	}
 
+	return existsMap, nil
 }
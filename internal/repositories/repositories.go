@@ -2,26 +2,33 @@ package repositories
 
 import (
 	"context"
-	"database/sql"
 	"errors"
+	"portto-assignment/config"
+
+	"github.com/jackc/pgx/v5"
 )
 
-func NewMemeCoinRepository(db *sql.DB) *MemeCoinRepository {
+func NewMemeCoinRepository(db config.DatabaseConnectionPoolInterface) *MemeCoinRepository {
+	return NewRoutedMemeCoinRepository(NewDatabaseRouter(db, nil, DatabaseRouterConfig{}))
+}
+
+// NewRoutedMemeCoinRepository reads from the router's replicas, FindOne, FindMany and StreamAll may see replication lag
+func NewRoutedMemeCoinRepository(router *DatabaseRouter) *MemeCoinRepository {
 	return &MemeCoinRepository{
-		db: db,
+		router: router,
 	}
 }
 
-func (repo *MemeCoinRepository) FindOne(id int) (*MemeCoin, error) {
+func (repo *MemeCoinRepository) FindOne(ctx context.Context, id int) (*MemeCoin, error) {
 	const sqlStatement string = `
 		SELECT id, name, description, created_at, popularity_score
 		FROM meme_coins
 		WHERE id = $1`
 
 	var memeCoin MemeCoin
-	row := repo.db.QueryRowContext(context.Background(), sqlStatement, id)
+	row := repo.router.Reader(ctx).QueryRow(ctx, sqlStatement, id)
 	err := row.Scan(&memeCoin.Id, &memeCoin.Name, &memeCoin.Description, &memeCoin.CreatedAt, &memeCoin.PopularityScore)
-	if err != nil && errors.Is(err, sql.ErrNoRows) {
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	} else if err != nil {
 		return nil, err
@@ -30,17 +37,17 @@ func (repo *MemeCoinRepository) FindOne(id int) (*MemeCoin, error) {
 	return &memeCoin, nil
 }
 
-func (repo *MemeCoinRepository) CreateOne(name string, description string) (*MemeCoin, error) {
+func (repo *MemeCoinRepository) CreateOne(ctx context.Context, name string, description string) (*MemeCoin, error) {
 	const sqlStatement string = `
-		INSERT INTO meme_coins (name, description) 
-		VALUES ($1, $2)
+		INSERT INTO meme_coins (name, description, created_at) 
+		VALUES ($1, $2, $3)
 	 	ON CONFLICT (name) DO NOTHING
 		RETURNING id, name, description, created_at, popularity_score`
 
 	var newMemeCoin MemeCoin
-	row := repo.db.QueryRowContext(context.Background(), sqlStatement, name, description)
+	row := repo.router.Primary().QueryRow(ctx, sqlStatement, name, description, repo.router.Now())
 	err := row.Scan(&newMemeCoin.Id, &newMemeCoin.Name, &newMemeCoin.Description, &newMemeCoin.CreatedAt, &newMemeCoin.PopularityScore)
-	if err != nil && errors.Is(err, sql.ErrNoRows) {
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	} else if err != nil {
 		return nil, err
@@ -49,7 +56,7 @@ func (repo *MemeCoinRepository) CreateOne(name string, description string) (*Mem
 	return &newMemeCoin, nil
 }
 
-func (repo *MemeCoinRepository) UpdateOne(id int, description string) (*MemeCoin, error) {
+func (repo *MemeCoinRepository) UpdateOne(ctx context.Context, id int, description string) (*MemeCoin, error) {
 	const sqlStatement string = `
 		UPDATE meme_coins
 		SET description = $2
@@ -57,9 +64,9 @@ func (repo *MemeCoinRepository) UpdateOne(id int, description string) (*MemeCoin
 		RETURNING id, name, description, created_at, popularity_score`
 
 	var updatedMemeCoin MemeCoin
-	row := repo.db.QueryRowContext(context.Background(), sqlStatement, id, description)
+	row := repo.router.Primary().QueryRow(ctx, sqlStatement, id, description)
 	err := row.Scan(&updatedMemeCoin.Id, &updatedMemeCoin.Name, &updatedMemeCoin.Description, &updatedMemeCoin.CreatedAt, &updatedMemeCoin.PopularityScore)
-	if err != nil && errors.Is(err, sql.ErrNoRows) {
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	} else if err != nil {
 		return nil, err
@@ -68,16 +75,16 @@ func (repo *MemeCoinRepository) UpdateOne(id int, description string) (*MemeCoin
 	return &updatedMemeCoin, nil
 }
 
-func (repo *MemeCoinRepository) DeleteOne(id int) (*MemeCoin, error) {
+func (repo *MemeCoinRepository) DeleteOne(ctx context.Context, id int) (*MemeCoin, error) {
 	// Delete from database
 	const sqlStatement string = `
 		DELETE FROM meme_coins
 		WHERE id = $1
 		RETURNING id, name, description, created_at, popularity_score`
 	var deletedMemeCoin MemeCoin
-	row := repo.db.QueryRowContext(context.Background(), sqlStatement, id)
+	row := repo.router.Primary().QueryRow(ctx, sqlStatement, id)
 	err := row.Scan(&deletedMemeCoin.Id, &deletedMemeCoin.Name, &deletedMemeCoin.Description, &deletedMemeCoin.CreatedAt, &deletedMemeCoin.PopularityScore)
-	if err != nil && errors.Is(err, sql.ErrNoRows) {
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	} else if err != nil {
 		return nil, err
@@ -85,3 +92,179 @@ func (repo *MemeCoinRepository) DeleteOne(id int) (*MemeCoin, error) {
 
 	return &deletedMemeCoin, nil
 }
+
+func (repo *MemeCoinRepository) FindMany(ctx context.Context, ids []int) ([]MemeCoin, error) {
+	const sqlStatement string = `
+		SELECT id, name, description, created_at, popularity_score
+		FROM meme_coins
+		WHERE id = ANY($1)
+		ORDER BY id`
+
+	rows, err := repo.router.Reader(ctx).Query(ctx, sqlStatement, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memeCoins := []MemeCoin{}
+	for rows.Next() {
+		var memeCoin MemeCoin
+		err := rows.Scan(&memeCoin.Id, &memeCoin.Name, &memeCoin.Description, &memeCoin.CreatedAt, &memeCoin.PopularityScore)
+		if err != nil {
+			return nil, err
+		}
+		memeCoins = append(memeCoins, memeCoin)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return memeCoins, nil
+}
+
+func (repo *MemeCoinRepository) CreateMany(ctx context.Context, newMemeCoins []NewMemeCoin) ([]MemeCoin, error) {
+	// Names that already exist are skipped, so only the inserted rows are returned
+	const sqlStatement string = `
+		INSERT INTO meme_coins (name, description, created_at)
+		SELECT name, description, $3 FROM unnest($1::text[], $2::text[]) AS input(name, description)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING id, name, description, created_at, popularity_score`
+
+	names := make([]string, 0, len(newMemeCoins))
+	descriptions := make([]string, 0, len(newMemeCoins))
+	for _, newMemeCoin := range newMemeCoins {
+		names = append(names, newMemeCoin.Name)
+		descriptions = append(descriptions, newMemeCoin.Description)
+	}
+
+	rows, err := repo.router.Primary().Query(ctx, sqlStatement, names, descriptions, repo.router.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	createdMemeCoins := []MemeCoin{}
+	for rows.Next() {
+		var createdMemeCoin MemeCoin
+		err := rows.Scan(&createdMemeCoin.Id, &createdMemeCoin.Name, &createdMemeCoin.Description, &createdMemeCoin.CreatedAt, &createdMemeCoin.PopularityScore)
+		if err != nil {
+			return nil, err
+		}
+		createdMemeCoins = append(createdMemeCoins, createdMemeCoin)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return createdMemeCoins, nil
+}
+
+// StreamAll calls fn for every meme coin ordered by id, rows are read one by one instead of being loaded at once
+func (repo *MemeCoinRepository) StreamAll(ctx context.Context, fn func(memeCoin MemeCoin) error) error {
+	const sqlStatement string = `
+		SELECT id, name, description, created_at, popularity_score
+		FROM meme_coins
+		ORDER BY id`
+
+	rows, err := repo.router.Reader(ctx).Query(ctx, sqlStatement)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var memeCoin MemeCoin
+		err := rows.Scan(&memeCoin.Id, &memeCoin.Name, &memeCoin.Description, &memeCoin.CreatedAt, &memeCoin.PopularityScore)
+		if err != nil {
+			return err
+		}
+		err = fn(memeCoin)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (repo *MemeCoinRepository) UpsertMany(ctx context.Context, upsertMemeCoins []UpsertMemeCoin) ([]UpsertedMemeCoin, error) {
+	// Names must be unique within one call, a missing popularity_score keeps the stored one
+	const sqlStatement string = `
+		WITH input AS (
+			SELECT * FROM unnest($1::text[], $2::text[], $3::int[]) AS input(name, description, popularity_score)
+		)
+		INSERT INTO meme_coins AS meme_coin (name, description, popularity_score, created_at)
+		SELECT name, description, COALESCE(popularity_score, 0), $4 FROM input
+		ON CONFLICT (name) DO UPDATE
+		SET description = EXCLUDED.description,
+			popularity_score = COALESCE((SELECT input.popularity_score FROM input WHERE input.name = EXCLUDED.name), meme_coin.popularity_score)
+		RETURNING id, name, description, created_at, popularity_score, (xmax = 0) AS inserted`
+
+	names := make([]string, 0, len(upsertMemeCoins))
+	descriptions := make([]string, 0, len(upsertMemeCoins))
+	popularityScores := make([]*int, 0, len(upsertMemeCoins))
+	for _, upsertMemeCoin := range upsertMemeCoins {
+		names = append(names, upsertMemeCoin.Name)
+		descriptions = append(descriptions, upsertMemeCoin.Description)
+		popularityScores = append(popularityScores, upsertMemeCoin.PopularityScore)
+	}
+
+	rows, err := repo.router.Primary().Query(ctx, sqlStatement, names, descriptions, popularityScores, repo.router.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	upsertedMemeCoins := []UpsertedMemeCoin{}
+	for rows.Next() {
+		var upsertedMemeCoin UpsertedMemeCoin
+		err := rows.Scan(&upsertedMemeCoin.Id, &upsertedMemeCoin.Name, &upsertedMemeCoin.Description, &upsertedMemeCoin.CreatedAt, &upsertedMemeCoin.PopularityScore, &upsertedMemeCoin.Inserted)
+		if err != nil {
+			return nil, err
+		}
+		upsertedMemeCoins = append(upsertedMemeCoins, upsertedMemeCoin)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return upsertedMemeCoins, nil
+}
+
+func (repo *MemeCoinRepository) UpdatePopularityScore(ctx context.Context, id int, popularityScore int) (*MemeCoin, error) {
+	const sqlStatement string = `
+		UPDATE meme_coins
+		SET popularity_score = $2
+		WHERE id = $1
+		RETURNING id, name, description, created_at, popularity_score`
+
+	var updatedMemeCoin MemeCoin
+	row := repo.router.Primary().QueryRow(ctx, sqlStatement, id, popularityScore)
+	err := row.Scan(&updatedMemeCoin.Id, &updatedMemeCoin.Name, &updatedMemeCoin.Description, &updatedMemeCoin.CreatedAt, &updatedMemeCoin.PopularityScore)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &updatedMemeCoin, nil
+}
+
+func (repo *MemeCoinRepository) IncrementPopularityScore(ctx context.Context, id int, increment int) (*MemeCoin, error) {
+	const sqlStatement string = `
+		UPDATE meme_coins
+		SET popularity_score = popularity_score + $2
+		WHERE id = $1
+		RETURNING id, name, description, created_at, popularity_score`
+
+	var updatedMemeCoin MemeCoin
+	row := repo.router.Primary().QueryRow(ctx, sqlStatement, id, increment)
+	err := row.Scan(&updatedMemeCoin.Id, &updatedMemeCoin.Name, &updatedMemeCoin.Description, &updatedMemeCoin.CreatedAt, &updatedMemeCoin.PopularityScore)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &updatedMemeCoin, nil
+}
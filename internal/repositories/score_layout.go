@@ -0,0 +1,348 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	compareAndSetScript = `if redis.call('GET', KEYS[1]) == ARGV[1] then redis.call('SET', KEYS[1], ARGV[2]) return 1 end return 0`
+
+	compareAndSetFieldScript = `if redis.call('HGET', KEYS[1], ARGV[1]) == ARGV[2] then redis.call('HSET', KEYS[1], ARGV[1], ARGV[3]) return 1 end return 0`
+
+	adjustScript = `if tonumber(redis.call('GET', KEYS[1]) or '0') + tonumber(ARGV[1]) < 0 then return false end return redis.call('INCRBY', KEYS[1], ARGV[1])`
+
+	adjustFieldScript = `if tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0') + tonumber(ARGV[2]) < 0 then return false end return redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[2])`
+
+	markSyncedScript = `if redis.call('HINCRBY', KEYS[1], ARGV[1], -tonumber(ARGV[2])) <= 0 then redis.call('HDEL', KEYS[1], ARGV[1]) end return 0`
+)
+
+// ParseScoreLayout validates a layout name, an empty name means the string layout
+func ParseScoreLayout(name string) (ScoreLayout, error) {
+	switch layout := ScoreLayout(name); layout {
+	case "":
+		return ScoreLayoutString, nil
+	case ScoreLayoutString, ScoreLayoutHash:
+		return layout, nil
+	}
+
+	return "", fmt.Errorf("unknown score layout %q", name)
+}
+
+// locate maps a popularity score key to where the configured layout keeps it
+func (r *RedisCachedRepository) locate(key string) (scoreLocation, error) {
+	return r.locateIn(r.config.ScoreLayout, key)
+}
+
+// locateIn keeps keys as they are with the string layout, so any key can be stored there
+func (r *RedisCachedRepository) locateIn(layout ScoreLayout, key string) (scoreLocation, error) {
+	if layout != ScoreLayoutHash {
+		return scoreLocation{key: key}, nil
+	}
+
+	id, err := r.config.Keys.ParsePopularityScore(key)
+	if err != nil {
+		return scoreLocation{}, err
+	}
+
+	return scoreLocation{
+		key:   r.config.Keys.ScoreBucket(id / r.config.ScoreBucketSize),
+		field: strconv.Itoa(id),
+	}, nil
+}
+
+func (l scoreLocation) incrBy(ctx context.Context, c redis.Cmdable, increment int64) *redis.IntCmd {
+	if l.field == "" {
+		return c.IncrBy(ctx, l.key, increment)
+	}
+	return c.HIncrBy(ctx, l.key, l.field, increment)
+}
+
+func (l scoreLocation) get(ctx context.Context, c redis.Cmdable) *redis.StringCmd {
+	if l.field == "" {
+		return c.Get(ctx, l.key)
+	}
+	return c.HGet(ctx, l.key, l.field)
+}
+
+func (l scoreLocation) set(ctx context.Context, c redis.Cmdable, value int) redis.Cmder {
+	if l.field == "" {
+		return c.Set(ctx, l.key, value, 0)
+	}
+	return c.HSet(ctx, l.key, l.field, value)
+}
+
+// compareAndSet replaces the value only if it is still the expected one, the command returns 1 when it did
+func (l scoreLocation) compareAndSet(ctx context.Context, c redis.Cmdable, expected int, value int) *redis.Cmd {
+	if l.field == "" {
+		return c.Eval(ctx, compareAndSetScript, []string{l.key}, expected, value)
+	}
+	return c.Eval(ctx, compareAndSetFieldScript, []string{l.key}, l.field, expected, value)
+}
+
+// adjustBy increments the value unless the result would be negative, the command returns redis.Nil when it didn't
+func (l scoreLocation) adjustBy(ctx context.Context, c redis.Cmdable, delta int) *redis.Cmd {
+	if l.field == "" {
+		return c.Eval(ctx, adjustScript, []string{l.key}, delta)
+	}
+	return c.Eval(ctx, adjustFieldScript, []string{l.key}, l.field, delta)
+}
+
+func (l scoreLocation) setNX(ctx context.Context, c redis.Cmdable, value int) *redis.BoolCmd {
+	if l.field == "" {
+		return c.SetNX(ctx, l.key, value, 0)
+	}
+	return c.HSetNX(ctx, l.key, l.field, value)
+}
+
+func (l scoreLocation) del(ctx context.Context, c redis.Cmdable) *redis.IntCmd {
+	if l.field == "" {
+		return c.Del(ctx, l.key)
+	}
+	return c.HDel(ctx, l.key, l.field)
+}
+
+// exists returns the command to pass to scoreExists once it has run
+func (l scoreLocation) exists(ctx context.Context, c redis.Cmdable) redis.Cmder {
+	if l.field == "" {
+		return c.Exists(ctx, l.key)
+	}
+	return c.HExists(ctx, l.key, l.field)
+}
+
+func scoreExists(cmd redis.Cmder) (bool, error) {
+	switch cmd := cmd.(type) {
+	case *redis.IntCmd:
+		return cmd.Val() > 0, cmd.Err()
+	case *redis.BoolCmd:
+		return cmd.Val(), cmd.Err()
+	}
+
+	return false, fmt.Errorf("unexpected exists command %T", cmd)
+}
+
+// getMany reads the keys like MGET does, missing scores come back as nil.
+// The hash layout reads each bucket with one HMGET in a single pipeline, which the cluster client splits by node.
+func (r *RedisCachedRepository) getMany(ctx context.Context, keys []string) ([]interface{}, error) {
+	if r.config.ScoreLayout != ScoreLayoutHash {
+		return r.mget(ctx, keys)
+	}
+
+	fieldsByBucket := make(map[string][]string)
+	indexesByBucket := make(map[string][]int)
+	for i, key := range keys {
+		location, err := r.locate(key)
+		if err != nil {
+			return nil, err
+		}
+		fieldsByBucket[location.key] = append(fieldsByBucket[location.key], location.field)
+		indexesByBucket[location.key] = append(indexesByBucket[location.key], i)
+	}
+
+	pipe := r.redis.Pipeline()
+	cmds := make(map[string]*redis.SliceCmd, len(fieldsByBucket))
+	for bucket, fields := range fieldsByBucket {
+		cmds[bucket] = pipe.HMGet(ctx, bucket, fields...)
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(keys))
+	for bucket, cmd := range cmds {
+		for i, value := range cmd.Val() {
+			values[indexesByBucket[bucket][i]] = value
+		}
+	}
+
+	return values, nil
+}
+
+// scanScores reads every popularity score stored with the layout, one SCAN page at a time and one master after the other on a cluster.
+// Keys or fields that don't hold a valid score are reported as invalid with their string layout key.
+func (r *RedisCachedRepository) scanScores(ctx context.Context, layout ScoreLayout, fn func(page scorePage) error) error {
+	nodes, err := r.scanNodes(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		if layout == ScoreLayoutHash {
+			err = r.scanScoreBuckets(ctx, node, fn)
+		} else {
+			err = r.scanScoreKeys(ctx, node, fn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scanScoreKeys scans the keys on the node and reads them through the client, which follows slots moved since the scan
+func (r *RedisCachedRepository) scanScoreKeys(ctx context.Context, node redis.Cmdable, fn func(page scorePage) error) error {
+	seenKeys := make(map[string]bool)
+	var cursor uint64
+	for {
+		keys, nextCursor, err := node.Scan(ctx, cursor, r.config.Keys.PopularityScorePattern(), int64(r.config.SyncBatchSize)).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(keys) > 0 {
+			pipe := r.redis.Pipeline()
+			cmds := make([]*redis.StringCmd, len(keys))
+			for i, key := range keys {
+				cmds[i] = pipe.Get(ctx, key)
+			}
+			_, err = pipe.Exec(ctx)
+			if err != nil && !errors.Is(err, redis.Nil) {
+				return err
+			}
+
+			page := scorePage{
+				scores:  make(map[int]int, len(keys)),
+				scanned: len(keys),
+			}
+			for i, key := range keys {
+				// SCAN may return a key more than once
+				if seenKeys[key] {
+					continue
+				}
+				seenKeys[key] = true
+
+				value, err := cmds[i].Int()
+				if errors.Is(err, redis.Nil) {
+					// Deleted since it was scanned
+					continue
+				}
+				id, idErr := r.config.Keys.ParsePopularityScore(key)
+				if err != nil || idErr != nil {
+					page.invalidKeys = append(page.invalidKeys, key)
+					continue
+				}
+				page.scores[id] = value
+			}
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+
+		cursor = nextCursor
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+func (r *RedisCachedRepository) scanScoreBuckets(ctx context.Context, node redis.Cmdable, fn func(page scorePage) error) error {
+	seenBuckets := make(map[string]bool)
+	var cursor uint64
+	for {
+		buckets, nextCursor, err := node.Scan(ctx, cursor, r.config.Keys.ScoreBucketPattern(), int64(r.config.SyncBatchSize)).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(buckets) > 0 {
+			pipe := r.redis.Pipeline()
+			cmds := make([]*redis.MapStringStringCmd, 0, len(buckets))
+			for _, bucket := range buckets {
+				// SCAN may return a key more than once
+				if seenBuckets[bucket] {
+					continue
+				}
+				seenBuckets[bucket] = true
+				cmds = append(cmds, pipe.HGetAll(ctx, bucket))
+			}
+			_, err = pipe.Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			page := scorePage{
+				scores: make(map[int]int),
+			}
+			for _, cmd := range cmds {
+				for field, value := range cmd.Val() {
+					page.scanned++
+					id, idErr := strconv.Atoi(field)
+					score, err := strconv.Atoi(value)
+					if err != nil || idErr != nil {
+						r.config.Logger.Warn("Skipping invalid popularity score field", "field", field, "bucket", cmd.Args()[1])
+						continue
+					}
+					page.scores[id] = score
+				}
+			}
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+
+		cursor = nextCursor
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// MigrateScoreLayout moves every popularity score stored with the from layout to the configured layout.
+//
+// Scores are overwritten in the configured layout and removed from the old one, so the API must be stopped while it runs.
+// Running it again after an interruption moves what is left.
+func (r *RedisCachedRepository) MigrateScoreLayout(from ScoreLayout) (*ScoreLayoutMigrationReport, error) {
+	from, err := ParseScoreLayout(string(from))
+	if err != nil {
+		return nil, err
+	}
+	report := &ScoreLayoutMigrationReport{
+		From: from,
+		To:   r.config.ScoreLayout,
+	}
+	if from == r.config.ScoreLayout {
+		return report, nil
+	}
+
+	ctx := context.Background()
+	err = r.scanScores(ctx, from, func(page scorePage) error {
+		report.Skipped += len(page.invalidKeys)
+		if len(page.scores) == 0 {
+			return nil
+		}
+
+		pipe := r.redis.Pipeline()
+		for id, score := range page.scores {
+			key := r.config.Keys.PopularityScore(id)
+			target, err := r.locate(key)
+			if err != nil {
+				return err
+			}
+			source, err := r.locateIn(from, key)
+			if err != nil {
+				return err
+			}
+			target.set(ctx, pipe, score)
+			source.del(ctx, pipe)
+		}
+		_, err := pipe.Exec(ctx)
+		if err != nil {
+			return err
+		}
+		report.Moved += len(page.scores)
+
+		return nil
+	})
+	r.config.Logger.Info("Moved popularity scores between layouts", "from", report.From, "to", report.To, "moved", report.Moved, "skipped", report.Skipped)
+	if err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
@@ -0,0 +1,235 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"portto-assignment/pkg/clock"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const sqliteMemeCoinColumns = "id, name, description, created_at, popularity_score"
+
+// NewSQLiteMemeCoinRepository behaves as MemeCoinRepository on a database migrated with migrations.MigrateSQLite
+func NewSQLiteMemeCoinRepository(db *sql.DB, config SQLiteConfig) *SQLiteMemeCoinRepository {
+	// Apply defaults if values aren't specified
+	if config.StreamPageSize <= 0 {
+		config.StreamPageSize = DefaultSQLiteStreamPageSize
+	}
+	if config.Clock == nil {
+		config.Clock = clock.Real()
+	}
+
+	return &SQLiteMemeCoinRepository{
+		db:     db,
+		config: config,
+	}
+}
+
+func (repo *SQLiteMemeCoinRepository) FindOne(ctx context.Context, id int) (*MemeCoin, error) {
+	row := repo.db.QueryRowContext(ctx, "SELECT "+sqliteMemeCoinColumns+" FROM meme_coins WHERE id = ?", id)
+	return scanSQLiteMemeCoin(row)
+}
+
+func (repo *SQLiteMemeCoinRepository) CreateOne(ctx context.Context, name string, description string) (*MemeCoin, error) {
+	const sqlStatement string = `
+		INSERT INTO meme_coins (name, description, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING ` + sqliteMemeCoinColumns
+
+	row := repo.db.QueryRowContext(ctx, sqlStatement, name, description, repo.now())
+	return scanSQLiteMemeCoin(row)
+}
+
+func (repo *SQLiteMemeCoinRepository) UpdateOne(ctx context.Context, id int, description string) (*MemeCoin, error) {
+	row := repo.db.QueryRowContext(ctx, "UPDATE meme_coins SET description = ? WHERE id = ? RETURNING "+sqliteMemeCoinColumns, description, id)
+	return scanSQLiteMemeCoin(row)
+}
+
+func (repo *SQLiteMemeCoinRepository) DeleteOne(ctx context.Context, id int) (*MemeCoin, error) {
+	row := repo.db.QueryRowContext(ctx, "DELETE FROM meme_coins WHERE id = ? RETURNING "+sqliteMemeCoinColumns, id)
+	return scanSQLiteMemeCoin(row)
+}
+
+// FindMany passes the ids as one JSON array, so that a batch is a single statement whatever its size
+func (repo *SQLiteMemeCoinRepository) FindMany(ctx context.Context, ids []int) ([]MemeCoin, error) {
+	const sqlStatement string = `
+		SELECT ` + sqliteMemeCoinColumns + `
+		FROM meme_coins
+		WHERE id IN (SELECT value FROM json_each(?))
+		ORDER BY id`
+
+	idsJSON, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := repo.db.QueryContext(ctx, sqlStatement, string(idsJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	return collectSQLiteMemeCoins(rows)
+}
+
+// CreateMany inserts in one transaction, names that already exist are skipped as with MemeCoinRepository
+func (repo *SQLiteMemeCoinRepository) CreateMany(ctx context.Context, newMemeCoins []NewMemeCoin) ([]MemeCoin, error) {
+	const sqlStatement string = `
+		INSERT INTO meme_coins (name, description, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING ` + sqliteMemeCoinColumns
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	createdAt := repo.now()
+	createdMemeCoins := []MemeCoin{}
+	for _, newMemeCoin := range newMemeCoins {
+		memeCoin, err := scanSQLiteMemeCoin(tx.QueryRowContext(ctx, sqlStatement, newMemeCoin.Name, newMemeCoin.Description, createdAt))
+		if err != nil {
+			return nil, err
+		}
+		if memeCoin != nil {
+			createdMemeCoins = append(createdMemeCoins, *memeCoin)
+		}
+	}
+
+	return createdMemeCoins, tx.Commit()
+}
+
+// StreamAll reads the meme coins in pages ordered by id, so that fn never holds the only connection of the pool
+func (repo *SQLiteMemeCoinRepository) StreamAll(ctx context.Context, fn func(memeCoin MemeCoin) error) error {
+	const sqlStatement string = `
+		SELECT ` + sqliteMemeCoinColumns + `
+		FROM meme_coins
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?`
+
+	lastId := 0
+	for {
+		rows, err := repo.db.QueryContext(ctx, sqlStatement, lastId, repo.config.StreamPageSize)
+		if err != nil {
+			return err
+		}
+		memeCoins, err := collectSQLiteMemeCoins(rows)
+		if err != nil {
+			return err
+		}
+
+		for _, memeCoin := range memeCoins {
+			err := fn(memeCoin)
+			if err != nil {
+				return err
+			}
+			lastId = memeCoin.Id
+		}
+		if len(memeCoins) < repo.config.StreamPageSize {
+			return nil
+		}
+	}
+}
+
+// UpsertMany keeps the stored popularity score when the upsert has none
+func (repo *SQLiteMemeCoinRepository) UpsertMany(ctx context.Context, upsertMemeCoins []UpsertMemeCoin) ([]UpsertedMemeCoin, error) {
+	const insertStatement string = `
+		INSERT INTO meme_coins (name, description, popularity_score, created_at)
+		VALUES (?, ?, COALESCE(?, 0), ?)
+		RETURNING ` + sqliteMemeCoinColumns
+	const updateStatement string = `
+		UPDATE meme_coins
+		SET description = ?, popularity_score = COALESCE(?, popularity_score)
+		WHERE id = ?
+		RETURNING ` + sqliteMemeCoinColumns
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	createdAt := repo.now()
+	upsertedMemeCoins := []UpsertedMemeCoin{}
+	for _, upsertMemeCoin := range upsertMemeCoins {
+		var id int
+		err := tx.QueryRowContext(ctx, "SELECT id FROM meme_coins WHERE name = ?", upsertMemeCoin.Name).Scan(&id)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		inserted := errors.Is(err, sql.ErrNoRows)
+		var row *sql.Row
+		if inserted {
+			row = tx.QueryRowContext(ctx, insertStatement, upsertMemeCoin.Name, upsertMemeCoin.Description, upsertMemeCoin.PopularityScore, createdAt)
+		} else {
+			row = tx.QueryRowContext(ctx, updateStatement, upsertMemeCoin.Description, upsertMemeCoin.PopularityScore, id)
+		}
+		memeCoin, err := scanSQLiteMemeCoin(row)
+		if err != nil {
+			return nil, err
+		}
+		upsertedMemeCoins = append(upsertedMemeCoins, UpsertedMemeCoin{MemeCoin: *memeCoin, Inserted: inserted})
+	}
+
+	return upsertedMemeCoins, tx.Commit()
+}
+
+func (repo *SQLiteMemeCoinRepository) UpdatePopularityScore(ctx context.Context, id int, popularityScore int) (*MemeCoin, error) {
+	row := repo.db.QueryRowContext(ctx, "UPDATE meme_coins SET popularity_score = ? WHERE id = ? RETURNING "+sqliteMemeCoinColumns, popularityScore, id)
+	return scanSQLiteMemeCoin(row)
+}
+
+func (repo *SQLiteMemeCoinRepository) IncrementPopularityScore(ctx context.Context, id int, increment int) (*MemeCoin, error) {
+	row := repo.db.QueryRowContext(ctx, "UPDATE meme_coins SET popularity_score = popularity_score + ? WHERE id = ? RETURNING "+sqliteMemeCoinColumns, increment, id)
+	return scanSQLiteMemeCoin(row)
+}
+
+// Ping and Stat let the health service report the SQLite database, it has no pool statistics
+func (repo *SQLiteMemeCoinRepository) Ping(ctx context.Context) error {
+	return repo.db.PingContext(ctx)
+}
+
+func (repo *SQLiteMemeCoinRepository) Stat() *pgxpool.Stat {
+	return nil
+}
+
+// now is truncated to microseconds, as created_at is with PostgreSQL
+func (repo *SQLiteMemeCoinRepository) now() time.Time {
+	return repo.config.Clock.Now().UTC().Truncate(time.Microsecond)
+}
+
+// scanSQLiteMemeCoin returns nil when the statement matched no row
+func scanSQLiteMemeCoin(row *sql.Row) (*MemeCoin, error) {
+	var memeCoin MemeCoin
+	err := row.Scan(&memeCoin.Id, &memeCoin.Name, &memeCoin.Description, &memeCoin.CreatedAt, &memeCoin.PopularityScore)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &memeCoin, nil
+}
+
+func collectSQLiteMemeCoins(rows *sql.Rows) ([]MemeCoin, error) {
+	defer rows.Close()
+
+	memeCoins := []MemeCoin{}
+	for rows.Next() {
+		var memeCoin MemeCoin
+		err := rows.Scan(&memeCoin.Id, &memeCoin.Name, &memeCoin.Description, &memeCoin.CreatedAt, &memeCoin.PopularityScore)
+		if err != nil {
+			return nil, err
+		}
+		memeCoins = append(memeCoins, memeCoin)
+	}
+
+	return memeCoins, rows.Err()
+}
@@ -0,0 +1,299 @@
+package repositories
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func NewTagLeaderboardRepository(redis redis.UniversalClient, config TagLeaderboardConfig) *TagLeaderboardRepository {
+	// Apply defaults if values aren't specified
+	if config.Keys == nil {
+		config.Keys = DefaultKeyBuilder()
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	return &TagLeaderboardRepository{
+		redis:  redis,
+		config: config,
+	}
+}
+
+// Add puts the coin on the leaderboard of the tag with its current score
+func (r *TagLeaderboardRepository) Add(ctx context.Context, tag string, id int, popularityScore int) error {
+	pipe := r.redis.Pipeline()
+	pipe.SAdd(ctx, r.config.Keys.MemeCoinTags(id), tag)
+	pipe.ZAdd(ctx, r.config.Keys.TagLeaderboard(tag), redis.Z{Score: float64(popularityScore), Member: strconv.Itoa(id)})
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+func (r *TagLeaderboardRepository) Remove(ctx context.Context, tag string, id int) error {
+	pipe := r.redis.Pipeline()
+	pipe.SRem(ctx, r.config.Keys.MemeCoinTags(id), tag)
+	pipe.ZRem(ctx, r.config.Keys.TagLeaderboard(tag), strconv.Itoa(id))
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+// Top returns the coins with the highest scores first, ties are broken by the highest id as with ZREVRANGE
+func (r *TagLeaderboardRepository) Top(ctx context.Context, tag string, limit int) ([]LeaderboardEntry, error) {
+	members, err := r.redis.ZRevRangeWithScores(ctx, r.config.Keys.TagLeaderboard(tag), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]LeaderboardEntry, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.Atoi(member.Member.(string))
+		if err != nil {
+			r.config.Logger.WarnContext(ctx, "Skipping leaderboard member with invalid id", "tag", tag, "member", member.Member)
+			continue
+		}
+		entries = append(entries, LeaderboardEntry{Id: id, PopularityScore: int(member.Score)})
+	}
+
+	return entries, nil
+}
+
+// WarmUp rebuilds the leaderboards and tag sets from the database, with the live scores read from Redis through scores.
+//
+// Every key is built under a rebuild key and renamed over the live one, the keys of tags and coins left without
+// tagged coins are deleted. Tags added while the warm-up runs are lost until the next one.
+func (r *TagLeaderboardRepository) WarmUp(ctx context.Context, tags TagRepositoryInterface, scores *RedisCachedRepository) (int, error) {
+	// Leftovers of an interrupted warm-up would be merged into this one
+	leftovers, err := r.scanKeys(ctx, scores, r.config.Keys.TagLeaderboardRebuildPattern(), r.config.Keys.MemeCoinTagsRebuildPattern())
+	if err != nil {
+		return 0, err
+	}
+	if len(leftovers) > 0 {
+		// One DEL per key, the keys of different tags may live in different slots
+		pipe := r.redis.Pipeline()
+		for _, key := range leftovers {
+			pipe.Del(ctx, key)
+		}
+		_, err = pipe.Exec(ctx)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	loaded := 0
+	rebuiltTags := map[string]bool{}
+	rebuiltIds := map[int]bool{}
+	page := make([]MemeCoinTag, 0, DefaultWarmUpPageSize)
+	writePage := func() error {
+		if len(page) == 0 {
+			return nil
+		}
+		err := r.readLiveScores(ctx, scores, page)
+		if err != nil {
+			return err
+		}
+
+		pipe := r.redis.Pipeline()
+		for _, memeCoinTag := range page {
+			pipe.SAdd(ctx, r.config.Keys.MemeCoinTagsRebuild(memeCoinTag.MemeCoinId), memeCoinTag.Tag)
+			pipe.ZAdd(ctx, r.config.Keys.TagLeaderboardRebuild(memeCoinTag.Tag), redis.Z{Score: float64(memeCoinTag.PopularityScore), Member: strconv.Itoa(memeCoinTag.MemeCoinId)})
+			rebuiltTags[memeCoinTag.Tag] = true
+			rebuiltIds[memeCoinTag.MemeCoinId] = true
+		}
+		loaded += len(page)
+		page = page[:0]
+		_, err = pipe.Exec(ctx)
+
+		return err
+	}
+	// Tags missing on a lagging replica would have their leaderboards deleted
+	err = tags.StreamMemeCoinTags(WithPrimaryReads(ctx), func(memeCoinTag MemeCoinTag) error {
+		page = append(page, memeCoinTag)
+		if len(page) >= DefaultWarmUpPageSize {
+			return writePage()
+		}
+		return nil
+	})
+	if err == nil {
+		err = writePage()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Whatever live key isn't replaced belongs to a tag or a coin without tagged coins left
+	live, err := r.scanKeys(ctx, scores, r.config.Keys.TagLeaderboardPattern(), r.config.Keys.MemeCoinTagsPattern())
+	if err != nil {
+		return 0, err
+	}
+	stale := make(map[string]bool, len(live))
+	for _, key := range live {
+		stale[key] = true
+	}
+
+	pipe := r.redis.Pipeline()
+	for _, tag := range sortedKeys(rebuiltTags) {
+		pipe.Rename(ctx, r.config.Keys.TagLeaderboardRebuild(tag), r.config.Keys.TagLeaderboard(tag))
+		delete(stale, r.config.Keys.TagLeaderboard(tag))
+	}
+	for _, id := range sortedKeys(rebuiltIds) {
+		pipe.Rename(ctx, r.config.Keys.MemeCoinTagsRebuild(id), r.config.Keys.MemeCoinTags(id))
+		delete(stale, r.config.Keys.MemeCoinTags(id))
+	}
+	for _, key := range sortedKeys(stale) {
+		pipe.Del(ctx, key)
+	}
+	if pipe.Len() > 0 {
+		_, err = pipe.Exec(ctx)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	r.config.Logger.InfoContext(ctx, "Warmed up tag leaderboards", "count", loaded, "tags", len(rebuiltTags), "deleted", len(stale))
+	return loaded, nil
+}
+
+// readLiveScores replaces the stored scores of the page with the ones in Redis, the database lags behind by the pokes not synced yet
+func (r *TagLeaderboardRepository) readLiveScores(ctx context.Context, scores *RedisCachedRepository, page []MemeCoinTag) error {
+	keys := make([]string, len(page))
+	for i, memeCoinTag := range page {
+		keys[i] = scores.config.Keys.PopularityScore(memeCoinTag.MemeCoinId)
+	}
+	values, err := scores.getMany(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	for i, value := range values {
+		text, ok := value.(string)
+		if !ok {
+			continue
+		}
+		score, err := strconv.Atoi(text)
+		if err != nil {
+			continue
+		}
+		page[i].PopularityScore = score
+	}
+
+	return nil
+}
+
+// scanKeys returns the keys matching the patterns on every node
+func (r *TagLeaderboardRepository) scanKeys(ctx context.Context, scores *RedisCachedRepository, patterns ...string) ([]string, error) {
+	nodes, err := scores.scanNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seenKeys := map[string]bool{}
+	keys := []string{}
+	for _, node := range nodes {
+		for _, pattern := range patterns {
+			var cursor uint64
+			for {
+				page, nextCursor, err := node.Scan(ctx, cursor, pattern, int64(DefaultWarmUpPageSize)).Result()
+				if err != nil {
+					return nil, err
+				}
+				// SCAN may return a key more than once
+				for _, key := range page {
+					if !seenKeys[key] {
+						seenKeys[key] = true
+						keys = append(keys, key)
+					}
+				}
+				cursor = nextCursor
+				if cursor == 0 {
+					break
+				}
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+// readTags queues the SMEMBERS of the coins on the pipeline of a score write, so that the tags come back in the same round trip
+func (r *TagLeaderboardRepository) readTags(ctx context.Context, pipe redis.Pipeliner, ids []int) map[int]*redis.StringSliceCmd {
+	cmds := make(map[int]*redis.StringSliceCmd, len(ids))
+	for _, id := range ids {
+		cmds[id] = pipe.SMembers(ctx, r.config.Keys.MemeCoinTags(id))
+	}
+
+	return cmds
+}
+
+// incrBy adds the increments of the coins to the leaderboards of the tags read by readTags
+func (r *TagLeaderboardRepository) incrBy(ctx context.Context, tagCmds map[int]*redis.StringSliceCmd, increments map[int]int) error {
+	return r.writeTagged(ctx, tagCmds, func(pipe redis.Pipeliner, id int, tags []string) {
+		for _, tag := range tags {
+			pipe.ZIncrBy(ctx, r.config.Keys.TagLeaderboard(tag), float64(increments[id]), strconv.Itoa(id))
+		}
+	})
+}
+
+// setScores overwrites the scores of the coins on the leaderboards of the tags read by readTags
+func (r *TagLeaderboardRepository) setScores(ctx context.Context, tagCmds map[int]*redis.StringSliceCmd, scores map[int]int) error {
+	return r.writeTagged(ctx, tagCmds, func(pipe redis.Pipeliner, id int, tags []string) {
+		for _, tag := range tags {
+			pipe.ZAdd(ctx, r.config.Keys.TagLeaderboard(tag), redis.Z{Score: float64(scores[id]), Member: strconv.Itoa(id)})
+		}
+	})
+}
+
+// removeMemeCoins takes deleted coins off every leaderboard of the tags read by readTags
+func (r *TagLeaderboardRepository) removeMemeCoins(ctx context.Context, tagCmds map[int]*redis.StringSliceCmd) error {
+	return r.writeTagged(ctx, tagCmds, func(pipe redis.Pipeliner, id int, tags []string) {
+		for _, tag := range tags {
+			pipe.ZRem(ctx, r.config.Keys.TagLeaderboard(tag), strconv.Itoa(id))
+		}
+		pipe.Del(ctx, r.config.Keys.MemeCoinTags(id))
+	})
+}
+
+// writeTagged pipelines the writes of the tagged coins in id order, coins without tags cost no round trip
+func (r *TagLeaderboardRepository) writeTagged(ctx context.Context, tagCmds map[int]*redis.StringSliceCmd, write func(pipe redis.Pipeliner, id int, tags []string)) error {
+	ids := make([]int, 0, len(tagCmds))
+	for id, cmd := range tagCmds {
+		if cmd.Err() != nil {
+			return cmd.Err()
+		}
+		if len(cmd.Val()) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Ints(ids)
+
+	pipe := r.redis.Pipeline()
+	for _, id := range ids {
+		tags := tagCmds[id].Val()
+		sort.Strings(tags)
+		write(pipe, id, tags)
+	}
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+// sortedKeys returns the keys of the map in order, so that the pipelines of a warm-up are the same every time
+func sortedKeys[K cmp.Ordered](m map[K]bool) []K {
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
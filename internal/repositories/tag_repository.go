@@ -0,0 +1,275 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"portto-assignment/config"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func NewTagRepository(db config.DatabaseConnectionPoolInterface) *TagRepository {
+	return NewRoutedTagRepository(NewDatabaseRouter(db, nil, DatabaseRouterConfig{}))
+}
+
+// NewRoutedTagRepository reads from the router's replicas, the tag counts and listings may see replication lag
+func NewRoutedTagRepository(router *DatabaseRouter) *TagRepository {
+	return &TagRepository{
+		router: router,
+	}
+}
+
+// AddOne creates the tag when it doesn't exist yet and puts it on the meme coin, adding a tag twice is not an error
+func (repo *TagRepository) AddOne(ctx context.Context, memeCoinId int, name string) (*Tag, error) {
+	const sqlStatement string = `
+		WITH tag AS (
+			INSERT INTO tags (name)
+			VALUES ($2)
+			ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id, name, category_id, created_at
+		), tagged AS (
+			INSERT INTO meme_coin_tags (meme_coin_id, tag_id)
+			SELECT $1, id FROM tag
+			ON CONFLICT DO NOTHING
+		)
+		SELECT tag.id, tag.name, categories.name, tag.created_at
+		FROM tag
+		LEFT JOIN categories ON categories.id = tag.category_id`
+
+	var tag Tag
+	row := repo.router.Primary().QueryRow(ctx, sqlStatement, memeCoinId, name)
+	err := row.Scan(&tag.Id, &tag.Name, &tag.Category, &tag.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+// RemoveOne returns false when the meme coin didn't have the tag
+func (repo *TagRepository) RemoveOne(ctx context.Context, memeCoinId int, name string) (bool, error) {
+	const sqlStatement string = `
+		DELETE FROM meme_coin_tags
+		USING tags
+		WHERE meme_coin_tags.tag_id = tags.id AND meme_coin_tags.meme_coin_id = $1 AND tags.name = $2`
+
+	commandTag, err := repo.router.Primary().Exec(ctx, sqlStatement, memeCoinId, name)
+	if err != nil {
+		return false, err
+	}
+
+	return commandTag.RowsAffected() > 0, nil
+}
+
+func (repo *TagRepository) FindByMemeCoin(ctx context.Context, memeCoinId int) ([]Tag, error) {
+	const sqlStatement string = `
+		SELECT tags.id, tags.name, categories.name, tags.created_at
+		FROM meme_coin_tags
+		JOIN tags ON tags.id = meme_coin_tags.tag_id
+		LEFT JOIN categories ON categories.id = tags.category_id
+		WHERE meme_coin_tags.meme_coin_id = $1
+		ORDER BY tags.name`
+
+	rows, err := repo.router.Reader(ctx).Query(ctx, sqlStatement, memeCoinId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		err := rows.Scan(&tag.Id, &tag.Name, &tag.Category, &tag.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// CountAll lists every tag with the number of coins carrying it, most used first, only the tags of the category when one is given
+func (repo *TagRepository) CountAll(ctx context.Context, category string) ([]TagCount, error) {
+	const sqlStatement string = `
+		SELECT tags.id, tags.name, categories.name, tags.created_at, COUNT(meme_coin_tags.meme_coin_id)
+		FROM tags
+		LEFT JOIN categories ON categories.id = tags.category_id
+		LEFT JOIN meme_coin_tags ON meme_coin_tags.tag_id = tags.id
+		WHERE $1 = '' OR categories.name = $1
+		GROUP BY tags.id, categories.name
+		ORDER BY COUNT(meme_coin_tags.meme_coin_id) DESC, tags.name`
+
+	rows, err := repo.router.Reader(ctx).Query(ctx, sqlStatement, category)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tagCounts := []TagCount{}
+	for rows.Next() {
+		var tagCount TagCount
+		err := rows.Scan(&tagCount.Id, &tagCount.Name, &tagCount.Category, &tagCount.CreatedAt, &tagCount.Count)
+		if err != nil {
+			return nil, err
+		}
+		tagCounts = append(tagCounts, tagCount)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tagCounts, nil
+}
+
+// FindMemeCoins pages through the coins with the tag in id order, starting after afterId
+func (repo *TagRepository) FindMemeCoins(ctx context.Context, name string, afterId int, limit int) ([]MemeCoin, error) {
+	const sqlStatement string = `
+		SELECT meme_coins.id, meme_coins.name, meme_coins.description, meme_coins.created_at, meme_coins.popularity_score
+		FROM meme_coin_tags
+		JOIN tags ON tags.id = meme_coin_tags.tag_id
+		JOIN meme_coins ON meme_coins.id = meme_coin_tags.meme_coin_id
+		WHERE tags.name = $1 AND meme_coin_tags.meme_coin_id > $2
+		ORDER BY meme_coin_tags.meme_coin_id
+		LIMIT $3`
+
+	rows, err := repo.router.Reader(ctx).Query(ctx, sqlStatement, name, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memeCoins := []MemeCoin{}
+	for rows.Next() {
+		var memeCoin MemeCoin
+		err := rows.Scan(&memeCoin.Id, &memeCoin.Name, &memeCoin.Description, &memeCoin.CreatedAt, &memeCoin.PopularityScore)
+		if err != nil {
+			return nil, err
+		}
+		memeCoins = append(memeCoins, memeCoin)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return memeCoins, nil
+}
+
+// StreamMemeCoinTags reads every tagged coin with its stored score, the leaderboards are rebuilt from it
+func (repo *TagRepository) StreamMemeCoinTags(ctx context.Context, fn func(memeCoinTag MemeCoinTag) error) error {
+	const sqlStatement string = `
+		SELECT meme_coin_tags.meme_coin_id, tags.name, meme_coins.popularity_score
+		FROM meme_coin_tags
+		JOIN tags ON tags.id = meme_coin_tags.tag_id
+		JOIN meme_coins ON meme_coins.id = meme_coin_tags.meme_coin_id`
+
+	rows, err := repo.router.Reader(ctx).Query(ctx, sqlStatement)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var memeCoinTag MemeCoinTag
+		err := rows.Scan(&memeCoinTag.MemeCoinId, &memeCoinTag.Tag, &memeCoinTag.PopularityScore)
+		if err != nil {
+			return err
+		}
+		err = fn(memeCoinTag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// SetCategory files the tag under the category, or under none when category is empty; nil when either doesn't exist
+func (repo *TagRepository) SetCategory(ctx context.Context, name string, category string) (*Tag, error) {
+	const sqlStatement string = `
+		UPDATE tags
+		SET category_id = (SELECT id FROM categories WHERE name = $2)
+		WHERE tags.name = $1 AND ($2 = '' OR EXISTS (SELECT 1 FROM categories WHERE name = $2))
+		RETURNING id, name, NULLIF($2, ''), created_at`
+
+	var tag Tag
+	row := repo.router.Primary().QueryRow(ctx, sqlStatement, name, category)
+	err := row.Scan(&tag.Id, &tag.Name, &tag.Category, &tag.CreatedAt)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+// CreateCategory returns nil when the name is taken
+func (repo *TagRepository) CreateCategory(ctx context.Context, name string, description string) (*Category, error) {
+	const sqlStatement string = `
+		INSERT INTO categories (name, description)
+		VALUES ($1, $2)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING id, name, description, created_at`
+
+	var category Category
+	row := repo.router.Primary().QueryRow(ctx, sqlStatement, name, description)
+	err := row.Scan(&category.Id, &category.Name, &category.Description, &category.CreatedAt)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+func (repo *TagRepository) FindCategories(ctx context.Context) ([]Category, error) {
+	const sqlStatement string = `
+		SELECT id, name, description, created_at
+		FROM categories
+		ORDER BY name`
+
+	rows, err := repo.router.Reader(ctx).Query(ctx, sqlStatement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		var category Category
+		err := rows.Scan(&category.Id, &category.Name, &category.Description, &category.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+// DeleteCategory leaves its tags without a category
+func (repo *TagRepository) DeleteCategory(ctx context.Context, name string) (*Category, error) {
+	const sqlStatement string = `
+		DELETE FROM categories
+		WHERE name = $1
+		RETURNING id, name, description, created_at`
+
+	var category Category
+	row := repo.router.Primary().QueryRow(ctx, sqlStatement, name)
+	err := row.Scan(&category.Id, &category.Name, &category.Description, &category.CreatedAt)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &category, nil
+}
@@ -1,10 +1,21 @@
 package repositories
 
 import (
+	"container/list"
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"portto-assignment/config"
+	"portto-assignment/pkg/clock"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 )
 
 type MemeCoin struct {
@@ -15,47 +26,547 @@ type MemeCoin struct {
 	PopularityScore int       `db:"popularity_score" json:"popularity_score"`
 }
 
+type NewMemeCoin struct {
+	Name        string
+	Description string
+}
+
+type UpsertMemeCoin struct {
+	Name            string
+	Description     string
+	PopularityScore *int
+}
+
+type UpsertedMemeCoin struct {
+	MemeCoin
+	Inserted bool
+}
+
 type memeCoinPopularityScore struct {
 	Id              int `db:"id" json:"id"`
 	PopularityScore int `db:"popularity_score" json:"popularity_score"`
 }
 
 type MemeCoinRepositoryInterface interface {
-	FindOne(id int) (*MemeCoin, error)
-	CreateOne(name string, description string) (*MemeCoin, error)
-	UpdateOne(id int, description string) (*MemeCoin, error)
-	DeleteOne(id int) (*MemeCoin, error)
+	FindOne(ctx context.Context, id int) (*MemeCoin, error)
+	CreateOne(ctx context.Context, name string, description string) (*MemeCoin, error)
+	UpdateOne(ctx context.Context, id int, description string) (*MemeCoin, error)
+	DeleteOne(ctx context.Context, id int) (*MemeCoin, error)
+	FindMany(ctx context.Context, ids []int) ([]MemeCoin, error)
+	CreateMany(ctx context.Context, newMemeCoins []NewMemeCoin) ([]MemeCoin, error)
+	StreamAll(ctx context.Context, fn func(memeCoin MemeCoin) error) error
+	UpsertMany(ctx context.Context, upsertMemeCoins []UpsertMemeCoin) ([]UpsertedMemeCoin, error)
+	UpdatePopularityScore(ctx context.Context, id int, popularityScore int) (*MemeCoin, error)
+	IncrementPopularityScore(ctx context.Context, id int, increment int) (*MemeCoin, error)
 }
 
 type MemeCoinRepository struct {
-	db *sql.DB
+	router *DatabaseRouter
+}
+
+// SQLiteMemeCoinRepository keeps the meme coins in an SQLite database, so that the API can run as a single process
+type SQLiteMemeCoinRepository struct {
+	db     *sql.DB
+	config SQLiteConfig
+}
+
+type SQLiteConfig struct {
+	// StreamPageSize is the number of rows StreamAll reads per query, the connection is free for other queries between pages
+	StreamPageSize int
+	// Clock stamps created_at
+	Clock clock.Clock
+}
+
+// DatabaseRouter sends reads to the healthy replicas in turn, and everything else to the primary
+type DatabaseRouter struct {
+	primary  config.DatabaseConnectionPoolInterface
+	replicas []*databaseReplica
+	config   DatabaseRouterConfig
+	next     atomic.Uint64
+}
+
+type databaseReplica struct {
+	pool    config.DatabaseConnectionPoolInterface
+	healthy atomic.Bool
+}
+
+type DatabaseRouterConfig struct {
+	// How often replicas are pinged, and how long a ping may take
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+	// Clock drives the health checks and stamps created_at, the real clock when nil
+	Clock  clock.Clock
+	Logger *slog.Logger
+}
+
+type ReplicaStatusInterface interface {
+	ReplicaStatus() (healthy int, total int)
 }
 
 type RedisRepositoryInterface interface {
-	IncrBy(key string, increment int) error
-	Set(key string, value int) error
-	Delete(key string) error
-	Exists(key string) (bool, error)
+	IncrBy(ctx context.Context, key string, increment int) error
+	Get(ctx context.Context, key string) (int, bool, error)
+	Set(ctx context.Context, key string, value int) error
+	Delete(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	SetMany(ctx context.Context, values map[string]int) error
+	IncrByMany(ctx context.Context, increments map[string]int) error
+	ExistsMany(ctx context.Context, keys []string) (map[string]bool, error)
+	// AdjustBy increments the score and returns the result, unless it would be negative
+	AdjustBy(ctx context.Context, key string, delta int) (int, error)
+}
+
+// ErrNegativePopularityScore is returned by AdjustBy when the adjustment would take the score below zero
+var ErrNegativePopularityScore = errors.New("popularity score must not be negative")
+
+// IncrementError is returned by IncrByMany when some increments didn't reach Redis, the others were applied
+type IncrementError struct {
+	Failed map[string]int
+	Err    error
+}
+
+func (err *IncrementError) Error() string {
+	return fmt.Sprintf("%d increment(s) failed: %v", len(err.Failed), err.Err)
+}
+
+func (err *IncrementError) Unwrap() error {
+	return err.Err
+}
+
+// MemoryRedisRepository keeps the popularity scores in process memory in place of Redis
+type MemoryRedisRepository struct {
+	mutex  sync.RWMutex
+	values map[string]int
+	config MemoryRedisConfig
+}
+
+type MemoryRedisConfig struct {
+	// Repo receives every score change and is read by WarmUp, the scores only live in memory when nil
+	Repo MemeCoinRepositoryInterface
+	// Keys parses the popularity score keys, the default namespace when nil
+	Keys *KeyBuilder
 }
 
 type RedisCachedRepository struct {
-	db     *sql.DB
-	redis  *redis.Client
+	db     config.DatabaseConnectionPoolInterface
+	redis  redis.UniversalClient
 	config RepositoryConfig
 	// Channel for tracking coins that need syncing
-	syncKeys chan string
+	syncKeys chan dirtyKey
+	// Set while scores are served from the database, syncing Redis then would overwrite the newer database scores
+	syncHeld  atomic.Bool
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// dirtyKey is a key waiting to be synced, linked to the span that changed it
+type dirtyKey struct {
+	key string
+	// The marks of the increments the sync clears
+	increments int
+	link       trace.Link
+}
+
+// RedisStatus is the state of the circuit breaker in front of Redis
+type RedisStatus string
+
+const (
+	RedisStatusUp RedisStatus = "up"
+	// RedisStatusDown means popularity scores are served from the database
+	RedisStatusDown RedisStatus = "down"
+	// RedisStatusRecovering means Redis is reachable again and being re-warmed
+	RedisStatusRecovering RedisStatus = "recovering"
+)
+
+type RedisStatusInterface interface {
+	Status() RedisStatus
+}
+
+// FallbackRedisRepository falls back to the database while Redis is unavailable
+type FallbackRedisRepository struct {
+	redis  *RedisCachedRepository
+	repo   MemeCoinRepositoryInterface
+	config FallbackConfig
+	state  atomic.Int32
+	// Consecutive Redis failures while the circuit is closed
+	failures atomic.Int32
+	// Held for reading by database fallbacks and for writing when the circuit closes
+	mutex sync.RWMutex
+	// Meme coins written to the database since the circuit opened
+	touchedIds      map[int]bool
+	touchedIdsMutex sync.Mutex
+}
+
+// CachedMemeCoinRepository keeps recently read meme coins in memory in front of another repository
+type CachedMemeCoinRepository struct {
+	repo   MemeCoinRepositoryInterface
+	redis  redis.UniversalClient
+	config CacheConfig
+	// Concurrent misses for the same id share one database query
+	group singleflight.Group
+	mutex sync.Mutex
+	// Least recently used entries are at the back
+	entries map[int]*list.Element
+	lru     *list.List
+	// Bumped on every invalidation so that loads started before it aren't cached
+	generation uint64
+	// Tells our own invalidation messages apart from the other instances' ones
+	instanceId string
+}
+
+type cacheEntry struct {
+	memeCoin  MemeCoin
+	expiresAt time.Time
+}
+
+type CacheConfig struct {
+	// Maximum number of meme coins kept in memory
+	Size int
+	// How long a meme coin is served from memory, it bounds staleness when an invalidation message is missed
+	TTL time.Duration
+	// Builds the Redis pub/sub channel the invalidations are broadcast on
+	Keys *KeyBuilder
+	// Clock decides when cached entries expire
+	Clock  clock.Clock
+	Logger *slog.Logger
+}
+
+// BufferedRedisRepository combines popularity score increments in memory before they are written to Redis
+type BufferedRedisRepository struct {
+	redis  RedisRepositoryInterface
+	config BufferConfig
+	// Guards pending, pendingLinks, existence and closed
+	mutex   sync.Mutex
+	pending map[string]int
+	// The spans of the pokes being combined, the flush is linked to them
+	pendingLinks []trace.Link
+	existence    map[string]existenceEntry
+	closed       bool
+	// Held for writing while a flush is in flight, so that reads and overwrites don't see it half applied
+	flushMutex sync.RWMutex
+	flushNow   chan struct{}
+	stop       chan struct{}
+	done       chan struct{}
+	closeOnce  sync.Once
+	closeErr   error
+}
+
+type existenceEntry struct {
+	exists    bool
+	expiresAt time.Time
+}
+
+type BufferConfig struct {
+	// How long increments are combined before they are flushed, it is also the most a crash can lose
+	FlushInterval time.Duration
+	// Flushes early once this many keys have pending increments
+	MaxPendingKeys int
+	// How long an existence check is answered from memory, a coin created or deleted by another instance is seen after it
+	ExistenceTTL time.Duration
+	Clock        clock.Clock
+	Logger       *slog.Logger
+}
+
+type FallbackConfig struct {
+	// Consecutive Redis failures that open the circuit
+	FailureThreshold int
+	// How often Redis is probed while the circuit is open
+	RecoveryInterval time.Duration
+	// Rebuilds the tag leaderboards on recovery when set
+	Tags   TagRepositoryInterface
+	Clock  clock.Clock
+	Logger *slog.Logger
+}
+
+type APIKey struct {
+	Id        int        `db:"id" json:"id"`
+	Name      string     `db:"name" json:"name"`
+	Prefix    string     `db:"prefix" json:"prefix"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	RevokedAt *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+type APIKeyRepositoryInterface interface {
+	CreateOne(name string, prefix string, keyHash string) (*APIKey, error)
+	FindActiveByHash(keyHash string) (*APIKey, error)
+	FindAll() ([]APIKey, error)
+	RevokeOne(id int) (*APIKey, error)
+}
+
+type APIKeyRepository struct {
+	db config.DatabaseConnectionPoolInterface
+}
+
+type Category struct {
+	Id          int       `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+type Tag struct {
+	Id   int    `db:"id" json:"id"`
+	Name string `db:"name" json:"name"`
+	// Category is the name of the category the tag is filed under, nil when it has none
+	Category  *string   `db:"category" json:"category"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type TagCount struct {
+	Tag
+	Count int `db:"count" json:"count"`
+}
+
+// MemeCoinTag is one row of the join between meme coins and tags, with the stored score of the coin
+type MemeCoinTag struct {
+	MemeCoinId      int
+	Tag             string
+	PopularityScore int
+}
+
+type TagRepositoryInterface interface {
+	AddOne(ctx context.Context, memeCoinId int, name string) (*Tag, error)
+	RemoveOne(ctx context.Context, memeCoinId int, name string) (bool, error)
+	FindByMemeCoin(ctx context.Context, memeCoinId int) ([]Tag, error)
+	CountAll(ctx context.Context, category string) ([]TagCount, error)
+	FindMemeCoins(ctx context.Context, name string, afterId int, limit int) ([]MemeCoin, error)
+	StreamMemeCoinTags(ctx context.Context, fn func(memeCoinTag MemeCoinTag) error) error
+	SetCategory(ctx context.Context, name string, category string) (*Tag, error)
+	CreateCategory(ctx context.Context, name string, description string) (*Category, error)
+	FindCategories(ctx context.Context) ([]Category, error)
+	DeleteCategory(ctx context.Context, name string) (*Category, error)
+}
+
+type TagRepository struct {
+	router *DatabaseRouter
+}
+
+type LeaderboardEntry struct {
+	Id              int
+	PopularityScore int
+}
+
+// TagLeaderboardRepositoryInterface keeps one sorted set of popularity scores per tag, the score writes of RedisCachedRepository keep them in step
+type TagLeaderboardRepositoryInterface interface {
+	Add(ctx context.Context, tag string, id int, popularityScore int) error
+	Remove(ctx context.Context, tag string, id int) error
+	Top(ctx context.Context, tag string, limit int) ([]LeaderboardEntry, error)
+}
+
+type TagLeaderboardRepository struct {
+	redis  redis.UniversalClient
+	config TagLeaderboardConfig
+}
+
+type TagLeaderboardConfig struct {
+	Keys   *KeyBuilder
+	Logger *slog.Logger
+}
+
+type IdempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Completed   bool   `json:"completed"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+type IdempotencyRepositoryInterface interface {
+	Reserve(key string, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error)
+	Find(key string) (*IdempotencyRecord, error)
+	Save(key string, record IdempotencyRecord, ttl time.Duration) error
+	Release(key string) error
+}
+
+// MemoryIdempotencyRepository keeps idempotency records in process memory until their TTL passes
+type MemoryIdempotencyRepository struct {
+	mutex   sync.Mutex
+	records map[string]memoryIdempotencyRecord
+	clock   clock.Clock
+}
+
+type memoryIdempotencyRecord struct {
+	record    IdempotencyRecord
+	expiresAt time.Time
+}
+
+type IdempotencyRepository struct {
+	keys  *KeyBuilder
+	redis redis.UniversalClient
 }
 
 type RepositoryConfig struct {
 	SyncBatchSize int
 	SyncInterval  time.Duration
 	NeedToSync    bool
+	// A failed sync is retried with the batch merged into the next one, waiting up to MaxSyncRetryBackoff between attempts
+	MaxSyncRetryBackoff time.Duration
+	// Warm-up reads the database in pages of WarmUpPageSize rows, written to Redis by WarmUpWorkers workers
+	WarmUpPageSize int
+	WarmUpWorkers  int
+	// Reconciliation runs periodically when the interval is positive and NeedToSync is set
+	ReconcileInterval time.Duration
+	ReconcilePolicy   ReconcilePolicy
+	// ScoreLayout is how popularity scores are stored in Redis, hashes group ScoreBucketSize coins each
+	ScoreLayout     ScoreLayout
+	ScoreBucketSize int
+	// Keys builds the popularity score keys, the default namespace when nil
+	Keys *KeyBuilder
+	// TagLeaderboards are written in the same round trips as the scores, nil when tags aren't supported
+	TagLeaderboards *TagLeaderboardRepository
+	// Clock drives the sync and reconciliation tickers and the warm-up progress logs
+	Clock  clock.Clock
+	Logger *slog.Logger
+}
+
+// KeyBuilder builds every Redis key and channel name of the application under one prefix
+type KeyBuilder struct {
+	// prefix is "[environment:]namespace", wrapped in a hash tag when enabled
+	prefix string
+}
+
+type KeyBuilderConfig struct {
+	// Namespace of the application, "meme" when empty
+	Namespace string
+	// Environment keeps deployments sharing one Redis apart, for example "staging"
+	Environment string
+	// HashTags puts every key in the same Redis Cluster slot, so that multi-key commands never cross slots
+	HashTags bool
+}
+
+// ScoreLayout is how popularity scores are stored in Redis
+type ScoreLayout string
+
+const (
+	// ScoreLayoutString stores one string key per meme coin
+	ScoreLayoutString ScoreLayout = "string"
+	// ScoreLayoutHash stores the scores as fields of hashes holding a bucket of meme coins each
+	ScoreLayoutHash ScoreLayout = "hash"
+)
+
+// scoreLocation is where a popularity score lives in Redis, field is empty for the string layout
+type scoreLocation struct {
+	key   string
+	field string
+}
+
+// scorePage is one SCAN page of popularity scores keyed by meme coin id
+type scorePage struct {
+	scores map[int]int
+	// Keys that aren't popularity scores, only reported for the string layout
+	invalidKeys []string
+	// Entries returned by Redis, including duplicates and the ones deleted since the SCAN
+	scanned int
+}
+
+// ScoreLayoutMigrationReport describes a move of the popularity scores from one layout to another
+type ScoreLayoutMigrationReport struct {
+	From    ScoreLayout `json:"from"`
+	To      ScoreLayout `json:"to"`
+	Moved   int         `json:"moved"`
+	Skipped int         `json:"skipped"`
+}
+
+// WarmUpReport describes a warm-up, keys already in Redis are newer than the database and left untouched
+type WarmUpReport struct {
+	Rows        int `json:"rows"`
+	Loaded      int `json:"loaded"`
+	Skipped     int `json:"skipped"`
+	FailedPages int `json:"failed_pages"`
+}
+
+// ReconcilePolicy decides how drift between Redis and the database is repaired
+type ReconcilePolicy string
+
+const (
+	// ReconcilePolicyReport only reports drift
+	ReconcilePolicyReport ReconcilePolicy = "report"
+	// ReconcilePolicyRedis treats Redis as the source of truth for mismatched scores
+	ReconcilePolicyRedis ReconcilePolicy = "redis"
+	// ReconcilePolicyDatabase treats the database as the source of truth for mismatched scores
+	ReconcilePolicyDatabase ReconcilePolicy = "database"
+)
+
+type ScoreMismatch struct {
+	Id     int `json:"id"`
+	Cached int `json:"cached"`
+	Stored int `json:"stored"`
+}
+
+type StoredScore struct {
+	Id     int `json:"id"`
+	Stored int `json:"stored"`
+}
+
+// ReconcileReport describes the drift found between Redis and the database
+type ReconcileReport struct {
+	Policy      ReconcilePolicy `json:"policy"`
+	ScannedKeys int             `json:"scanned_keys"`
+	ScannedRows int             `json:"scanned_rows"`
+	// Keys without a matching meme coin, or whose id or value cannot be parsed
+	OrphanKeys []string `json:"orphan_keys"`
+	// Meme coins without a popularity score key
+	MissingKeys []StoredScore   `json:"missing_keys"`
+	Mismatches  []ScoreMismatch `json:"mismatches"`
+	Repaired    int             `json:"repaired"`
+	// Mismatches left alone by the database policy because of pokes not synced yet or made since the scan
+	Skipped int `json:"skipped"`
 }
 
 const (
+	// DefaultReplicaHealthCheckInterval is how often replicas are pinged
+	DefaultReplicaHealthCheckInterval = 5 * time.Second
+
+	// DefaultReplicaHealthCheckTimeout is how long a replica ping may take
+	DefaultReplicaHealthCheckTimeout = time.Second
+
+	// DefaultCacheSize is the number of meme coins kept in memory by default
+	DefaultCacheSize = 10000
+
+	// DefaultCacheTTL is how long a meme coin is served from memory by default
+	DefaultCacheTTL = 30 * time.Second
+
+	// DefaultKeyNamespace is the namespace of every Redis key by default
+	DefaultKeyNamespace = "meme"
+
+	// DefaultBufferFlushInterval is how long increments are combined in memory by default
+	DefaultBufferFlushInterval = 5 * time.Millisecond
+
+	// DefaultBufferMaxPendingKeys is the number of keys with pending increments that triggers an early flush by default
+	DefaultBufferMaxPendingKeys = 1000
+
+	// DefaultBufferExistenceTTL is how long an existence check is answered from memory by default
+	DefaultBufferExistenceTTL = time.Second
+
+	// DefaultFailureThreshold is the number of consecutive Redis failures that open the circuit
+	DefaultFailureThreshold = 3
+
+	// DefaultRecoveryInterval is how often Redis is probed while the circuit is open
+	DefaultRecoveryInterval = 5 * time.Second
+
 	// DefaultSyncBatchSize is the number of records to sync in one batch
 	DefaultSyncBatchSize = 100
 
+	// MaxSyncBatchLinks caps the spans a sync batch is linked to, the pokes of a busy coin would add one each
+	MaxSyncBatchLinks = 128
+
 	// DefaultSyncInterval is how often to sync cache to database
 	DefaultSyncInterval = 5 * time.Second
+
+	// DefaultMaxSyncRetryBackoff is the longest wait between retries of a failed sync by default
+	DefaultMaxSyncRetryBackoff = time.Minute
+
+	// DefaultScoreBucketSize is the number of meme coins per hash with the hash layout
+	DefaultScoreBucketSize = 1000
+
+	// DefaultWarmUpPageSize is the number of rows read from the database per warm-up page
+	DefaultWarmUpPageSize = 1000
+
+	// DefaultWarmUpWorkers is the number of pages written to Redis concurrently during warm-up
+	DefaultWarmUpWorkers = 4
+
+	// DefaultSQLiteStreamPageSize is the number of rows StreamAll reads per query from SQLite
+	DefaultSQLiteStreamPageSize = 500
 )
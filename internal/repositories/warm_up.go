@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// warmUpProgressInterval is how often warm-up progress is logged
+const warmUpProgressInterval = 5 * time.Second
+
+// WarmUp streams every popularity score from the database into Redis.
+//
+// The database is read page by page with a keyset scan, so memory stays bounded by the pages in flight.
+// Keys are written with SETNX, a key already in Redis holds increments the database hasn't seen yet.
+// A page that fails to reach Redis is reported without stopping the others.
+func (r *RedisCachedRepository) WarmUp() (*WarmUpReport, error) {
+	ctx := context.Background()
+	report := &WarmUpReport{}
+	var reportMutex sync.Mutex
+	var firstPageErr error
+	lastLoggedAt := r.config.Clock.Now()
+
+	// The buffer keeps every worker busy while the next page is read
+	pages := make(chan []memeCoinPopularityScore, r.config.WarmUpWorkers)
+	var workers sync.WaitGroup
+	for i := 0; i < r.config.WarmUpWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for page := range pages {
+				loaded, err := r.warmUpPage(ctx, page)
+
+				reportMutex.Lock()
+				if err != nil {
+					r.config.Logger.ErrorContext(ctx, "Error warming up popularity scores", "first_id", page[0].Id, "last_id", page[len(page)-1].Id, "error", err)
+					report.FailedPages++
+					if firstPageErr == nil {
+						firstPageErr = err
+					}
+				} else {
+					report.Loaded += loaded
+					report.Skipped += len(page) - loaded
+				}
+				if r.config.Clock.Now().Sub(lastLoggedAt) >= warmUpProgressInterval {
+					r.config.Logger.InfoContext(ctx, "Warming up popularity scores", "rows", report.Rows, "loaded", report.Loaded, "skipped", report.Skipped)
+					lastLoggedAt = r.config.Clock.Now()
+				}
+				reportMutex.Unlock()
+			}
+		}()
+	}
+
+	lastId := 0
+	var readErr error
+	for {
+		page, err := r.readPopularityScorePage(ctx, lastId)
+		if err != nil {
+			readErr = err
+			break
+		}
+		if len(page) == 0 {
+			break
+		}
+
+		reportMutex.Lock()
+		report.Rows += len(page)
+		reportMutex.Unlock()
+		pages <- page
+
+		lastId = page[len(page)-1].Id
+		if len(page) < r.config.WarmUpPageSize {
+			break
+		}
+	}
+	close(pages)
+	workers.Wait()
+
+	r.config.Logger.InfoContext(ctx, "Warmed up popularity scores", "rows", report.Rows, "loaded", report.Loaded, "skipped", report.Skipped, "failed_pages", report.FailedPages)
+
+	if firstPageErr != nil {
+		firstPageErr = fmt.Errorf("%d page(s) failed, first error: %w", report.FailedPages, firstPageErr)
+	}
+	if err := errors.Join(readErr, firstPageErr); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+func (r *RedisCachedRepository) readPopularityScorePage(ctx context.Context, lastId int) ([]memeCoinPopularityScore, error) {
+	rows, err := r.db.Query(ctx, "SELECT id, popularity_score FROM meme_coins WHERE id > $1 ORDER BY id LIMIT $2", lastId, r.config.WarmUpPageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := make([]memeCoinPopularityScore, 0, r.config.WarmUpPageSize)
+	for rows.Next() {
+		var row memeCoinPopularityScore
+		if err := rows.Scan(&row.Id, &row.PopularityScore); err != nil {
+			return nil, err
+		}
+		page = append(page, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// warmUpPage writes a page with one pipeline and returns how many keys were missing from Redis
+func (r *RedisCachedRepository) warmUpPage(ctx context.Context, page []memeCoinPopularityScore) (int, error) {
+	pipe := r.redis.Pipeline()
+	cmds := make([]*redis.BoolCmd, len(page))
+	for i, row := range page {
+		location, err := r.locate(r.config.Keys.PopularityScore(row.Id))
+		if err != nil {
+			return 0, err
+		}
+		cmds[i] = location.setNX(ctx, pipe, row.PopularityScore)
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	loaded := 0
+	for _, cmd := range cmds {
+		if cmd.Val() {
+			loaded++
+		}
+	}
+
+	return loaded, nil
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"portto-assignment/internal/handlers"
+)
+
+func SetupAdminRoutes(router *gin.Engine, handler handlers.LogLevelHandlerInterface, config RouterConfig) {
+	// Changing the log level is as sensitive as changing the catalogue
+	admin := router.Group("/admin", config.Authentication.Handle)
+	{
+		admin.GET("/log-level", handler.GetLogLevel)
+		admin.PUT("/log-level", handler.SetLogLevel)
+	}
+}
@@ -1,11 +1,46 @@
 package routes
 
 import (
+	"io/fs"
+	"net/http"
+	docs "portto-assignment/api"
+	"strings"
+
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+// SetupDocsRoutes serves the OpenAPI document with Swagger UI and ReDoc, every file comes from the binary
 func SetupDocsRoutes(rg *gin.RouterGroup) {
-	rg.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	rg.GET("/docs/*file", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	rg.GET("/openapi.yaml", func(context *gin.Context) {
+		context.Data(http.StatusOK, "application/yaml", docs.Spec())
+	})
+	rg.GET("/redoc", func(context *gin.Context) {
+		context.Redirect(http.StatusMovedPermanently, "redoc/")
+	})
+	rg.GET("/redoc/*file", serveReDoc)
+}
+
+func serveReDoc(context *gin.Context) {
+	name := strings.TrimPrefix(context.Param("file"), "/")
+	contentType := "text/javascript; charset=utf-8"
+	switch name {
+	case "", "index.html":
+		name = "index.html"
+		contentType = "text/html; charset=utf-8"
+	case docs.ReDocBundle:
+	default:
+		context.Status(http.StatusNotFound)
+		return
+	}
+
+	content, err := fs.ReadFile(docs.ReDoc(), name)
+	if err != nil {
+		// The bundle is only embedded when it was fetched with go generate before the build
+		context.String(http.StatusNotFound, "%s is not embedded, run go generate ./api before building", name)
+		return
+	}
+	context.Data(http.StatusOK, contentType, content)
 }
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"portto-assignment/internal/handlers"
+)
+
+func SetupHealthRoutes(router *gin.Engine, handler handlers.HealthHandlerInterface) {
+	health := router.Group("/health")
+	{
+		health.GET("/live", handler.Live)
+		health.GET("/ready", handler.Ready)
+		health.GET("/database", handler.DatabasePoolStats)
+	}
+}
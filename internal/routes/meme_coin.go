@@ -6,13 +6,41 @@ import (
 	"portto-assignment/internal/handlers"
 )
 
-func SetupMemeCoinRoutes(rg *gin.RouterGroup, handlers handlers.MemeCoinHandlerInterface) {
+func SetupMemeCoinRoutes(rg *gin.RouterGroup, handlers handlers.MemeCoinHandlerInterface, config RouterConfig) {
+	// Optional middlewares are skipped when they aren't configured
+	idempotent := []gin.HandlerFunc{}
+	if config.Idempotency != nil {
+		idempotent = append(idempotent, config.Idempotency.Handle)
+	}
+	authenticated := []gin.HandlerFunc{}
+	if config.Authentication != nil {
+		authenticated = append(authenticated, config.Authentication.Handle)
+	}
+
 	memeCoinService := rg.Group("/meme-coin")
 	{
-		memeCoinService.POST("/create", handlers.CreateMemeCoin)
+		memeCoinService.GET("", handlers.BatchGetMemeCoins)
 		memeCoinService.GET("/:id", handlers.GetMemeCoin)
-		memeCoinService.PATCH("/:id", handlers.UpdateMemeCoin)
-		memeCoinService.DELETE("/:id", handlers.DeleteMemeCoin)
-		memeCoinService.POST("/:id/poke", handlers.PokeMemeCoin)
+	}
+
+	// Poking is not safe to retry, so it honours the Idempotency-Key header
+	idempotentMemeCoinService := memeCoinService.Group("", idempotent...)
+	{
+		idempotentMemeCoinService.POST("/:id/poke", handlers.PokeMemeCoin)
+		idempotentMemeCoinService.POST("/pokes", handlers.BatchPokeMemeCoins)
+	}
+
+	authenticatedMemeCoinService := memeCoinService.Group("", authenticated...)
+	{
+		authenticatedMemeCoinService.PATCH("/:id", handlers.UpdateMemeCoin)
+		authenticatedMemeCoinService.DELETE("/:id", handlers.DeleteMemeCoin)
+		authenticatedMemeCoinService.GET("/export", handlers.ExportMemeCoins)
+		authenticatedMemeCoinService.POST("/import", handlers.ImportMemeCoins)
+	}
+
+	authenticatedIdempotentMemeCoinService := authenticatedMemeCoinService.Group("", idempotent...)
+	{
+		authenticatedIdempotentMemeCoinService.POST("/create", handlers.CreateMemeCoin)
+		authenticatedIdempotentMemeCoinService.POST("/batch", handlers.BatchCreateMemeCoins)
 	}
 }
@@ -6,12 +6,48 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func NewRouter(handlers handlers.MemeCoinHandlerInterface) *gin.Engine {
-	router := gin.Default()
+func NewRouter(handlers handlers.MemeCoinHandlerInterface, config RouterConfig) *gin.Engine {
+	// Access lines are written by the AccessLog middleware, not by the gin logger
+	router := gin.New()
+	// "GET /v1/meme-coin" is the batch endpoint, so "/v1/meme-coin/" must stay a 404 instead of a redirect
+	router.RedirectTrailingSlash = false
+
+	if config.Tracing != nil {
+		router.Use(config.Tracing.Handle)
+	}
+	if config.RequestID != nil {
+		router.Use(config.RequestID.Handle)
+	}
+	if config.AccessLog != nil {
+		router.Use(config.AccessLog.Handle)
+	}
+	// Recovery comes after the access log so that panics are logged as 500s
+	router.Use(gin.Recovery())
+	// Validation runs before the route middlewares, so that invalid requests never reserve an idempotency key
+	if config.OpenAPIValidation != nil {
+		router.Use(config.OpenAPIValidation.Handle)
+	}
+
+	if config.Health != nil {
+		SetupHealthRoutes(router, config.Health)
+	}
+	// The admin routes are only served behind authentication, they are left out when it isn't configured
+	if config.LogLevel != nil && config.Authentication != nil {
+		SetupAdminRoutes(router, config.LogLevel, config)
+	}
+	if config.Tags != nil && config.Authentication != nil {
+		SetupCategoryRoutes(router, config.Tags, config)
+	}
 
 	v1 := router.Group("/v1")
+	if config.ReadYourWrites != nil {
+		v1.Use(config.ReadYourWrites.Handle)
+	}
 	{
-		SetupMemeCoinRoutes(v1, handlers)
+		SetupMemeCoinRoutes(v1, handlers, config)
+		if config.Tags != nil {
+			SetupTagRoutes(v1, config.Tags, config)
+		}
 		SetupDocsRoutes(v1)
 	}
 
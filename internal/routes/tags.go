@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"portto-assignment/internal/handlers"
+)
+
+func SetupTagRoutes(rg *gin.RouterGroup, handler handlers.TagHandlerInterface, config RouterConfig) {
+	authenticated := []gin.HandlerFunc{}
+	if config.Authentication != nil {
+		authenticated = append(authenticated, config.Authentication.Handle)
+	}
+
+	rg.GET("/meme-coin/:id/tags", handler.GetMemeCoinTags)
+
+	// Putting and removing a tag are idempotent, so they don't need the Idempotency-Key header
+	authenticatedMemeCoinTags := rg.Group("/meme-coin/:id/tags", authenticated...)
+	{
+		authenticatedMemeCoinTags.PUT("/:tag", handler.AddMemeCoinTag)
+		authenticatedMemeCoinTags.DELETE("/:tag", handler.RemoveMemeCoinTag)
+	}
+
+	tags := rg.Group("/tags")
+	{
+		tags.GET("", handler.ListTags)
+		tags.GET("/:tag/meme-coins", handler.ListMemeCoinsByTag)
+		tags.GET("/:tag/leaderboard", handler.GetTagLeaderboard)
+	}
+}
+
+// SetupCategoryRoutes lets admins manage the categories tags are filed under
+func SetupCategoryRoutes(router *gin.Engine, handler handlers.TagHandlerInterface, config RouterConfig) {
+	admin := router.Group("/admin", config.Authentication.Handle)
+	{
+		admin.GET("/categories", handler.ListCategories)
+		admin.POST("/categories", handler.CreateCategory)
+		admin.DELETE("/categories/:name", handler.DeleteCategory)
+		admin.PUT("/tags/:tag/category", handler.SetTagCategory)
+	}
+}
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"portto-assignment/internal/handlers"
+	"portto-assignment/internal/middlewares"
+)
+
+type RouterConfig struct {
+	// Tracing is applied to every route when set, first so that the other middlewares run inside the request span
+	Tracing middlewares.TracingMiddlewareInterface
+	// RequestID is applied to every route when set, before the access log so that its lines carry the id
+	RequestID middlewares.RequestIDMiddlewareInterface
+	// AccessLog is applied to every route when set
+	AccessLog middlewares.AccessLogMiddlewareInterface
+	// OpenAPIValidation is applied to every route when set, after the access log so that rejected requests are logged
+	OpenAPIValidation middlewares.OpenAPIValidationMiddlewareInterface
+	// Idempotency is applied to the create and poke routes when set
+	Idempotency middlewares.IdempotencyMiddlewareInterface
+	// Authentication is applied to the routes changing or dumping the catalogue when set, the admin routes are only registered with it
+	Authentication middlewares.APIKeyMiddlewareInterface
+	// ReadYourWrites is applied to all the v1 routes when set
+	ReadYourWrites middlewares.ReadYourWritesMiddlewareInterface
+	// Health registers the liveness and readiness probes when set
+	Health handlers.HealthHandlerInterface
+	// LogLevel registers the admin routes changing the log level at runtime when set along with Authentication
+	LogLevel handlers.LogLevelHandlerInterface
+	// Tags registers the tag and tag leaderboard routes when set, and the category routes along with Authentication
+	Tags handlers.TagHandlerInterface
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"portto-assignment/internal/repositories"
+	"strings"
+)
+
+func NewAPIKeyService(apiKeyRepository repositories.APIKeyRepositoryInterface) *APIKeyService {
+	return &APIKeyService{
+		repo: apiKeyRepository,
+	}
+}
+
+// CreateAPIKey generates a new key, only its hash is stored so the plain key is returned once
+func (service *APIKeyService) CreateAPIKey(name string) (*CreatedAPIKey, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, errors.New("api key name is required")
+	}
+
+	secret := make([]byte, apiKeySecretSize)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, err
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey, err := service.repo.CreateOne(name, key[:apiKeyDisplayPrefixLength], service.hashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreatedAPIKey{
+		APIKey: *apiKey,
+		Key:    key,
+	}, nil
+}
+
+func (service *APIKeyService) ListAPIKeys() ([]repositories.APIKey, error) {
+	return service.repo.FindAll()
+}
+
+func (service *APIKeyService) RevokeAPIKey(id int) (*repositories.APIKey, error) {
+	return service.repo.RevokeOne(id)
+}
+
+// Authenticate returns the active key matching the plain key, or nil when there is none
+func (service *APIKeyService) Authenticate(key string) (*repositories.APIKey, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, nil
+	}
+
+	return service.repo.FindActiveByHash(service.hashAPIKey(key))
+}
+
+func (service *APIKeyService) hashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
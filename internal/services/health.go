@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"portto-assignment/internal/repositories"
+	"time"
+)
+
+func NewHealthService(database DatabaseHealthInterface, redisStatus repositories.RedisStatusInterface, logger *slog.Logger) *HealthService {
+	// Apply defaults if values aren't specified
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &HealthService{
+		database: database,
+		redis:    redisStatus,
+		logger:   logger,
+	}
+}
+
+// Readiness reports the API as degraded while popularity scores are served without Redis
+func (service *HealthService) Readiness() Readiness {
+	readiness := Readiness{
+		Status:   ReadinessStatusReady,
+		Database: DependencyStatusUp,
+		Redis:    service.redis.Status(),
+	}
+
+	if err := service.database.Ping(context.Background()); err != nil {
+		service.logger.Error("Database health check failed", "error", err)
+		readiness.Status = ReadinessStatusUnavailable
+		readiness.Database = DependencyStatusDown
+		return readiness
+	}
+	if readiness.Redis != repositories.RedisStatusUp {
+		readiness.Status = ReadinessStatusDegraded
+	}
+
+	return readiness
+}
+
+func (service *HealthService) DatabasePoolStats() DatabasePoolStats {
+	stat := service.database.Stat()
+	if stat == nil {
+		return DatabasePoolStats{}
+	}
+
+	return DatabasePoolStats{
+		MaxConns:                stat.MaxConns(),
+		TotalConns:              stat.TotalConns(),
+		AcquiredConns:           stat.AcquiredConns(),
+		IdleConns:               stat.IdleConns(),
+		ConstructingConns:       stat.ConstructingConns(),
+		AcquireCount:            stat.AcquireCount(),
+		AcquireDurationMs:       float64(stat.AcquireDuration()) / float64(time.Millisecond),
+		EmptyAcquireCount:       stat.EmptyAcquireCount(),
+		CanceledAcquireCount:    stat.CanceledAcquireCount(),
+		NewConnsCount:           stat.NewConnsCount(),
+		MaxLifetimeDestroyCount: stat.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     stat.MaxIdleDestroyCount(),
+	}
+}
@@ -1,24 +1,41 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"portto-assignment/internal/repositories"
+	"portto-assignment/internal/tracing"
+	"sort"
 )
 
-func NewMemeCoinService(memeCoinRepository repositories.MemeCoinRepositoryInterface, redisRepository repositories.RedisRepositoryInterface) *MemeCoinService {
+func NewMemeCoinService(memeCoinRepository repositories.MemeCoinRepositoryInterface, redisRepository repositories.RedisRepositoryInterface, keys *repositories.KeyBuilder, logger *slog.Logger) *MemeCoinService {
+	// Apply defaults if values aren't specified
+	if keys == nil {
+		keys = repositories.DefaultKeyBuilder()
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	return &MemeCoinService{
-		repo:  memeCoinRepository,
-		redis: redisRepository,
+		repo:   memeCoinRepository,
+		redis:  redisRepository,
+		keys:   keys,
+		logger: logger,
 	}
 }
 
-func (service *MemeCoinService) CreateMemeCoin(input CreateMemeCoinInput) (*repositories.MemeCoin, error) {
-	memeCoin, err := service.repo.CreateOne(input.Name, input.Description)
+func (service *MemeCoinService) CreateMemeCoin(ctx context.Context, input CreateMemeCoinInput) (*repositories.MemeCoin, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "MemeCoinService.CreateMemeCoin")
+	defer span.End()
+
+	memeCoin, err := service.repo.CreateOne(ctx, input.Name, input.Description)
 	if err != nil {
 		return nil, err
 	}
-	err = service.redis.Set(service.getMemeCoinPopularityScoreKey(memeCoin.Id), memeCoin.PopularityScore)
+	err = service.redis.Set(ctx, service.getMemeCoinPopularityScoreKey(memeCoin.Id), memeCoin.PopularityScore)
 	if err != nil {
 		return nil, err
 	}
@@ -26,22 +43,45 @@ func (service *MemeCoinService) CreateMemeCoin(input CreateMemeCoinInput) (*repo
 	return memeCoin, nil
 }
 
-func (service *MemeCoinService) GetMemeCoin(id int) (*repositories.MemeCoin, error) {
-	return service.repo.FindOne(id)
+func (service *MemeCoinService) GetMemeCoin(ctx context.Context, id int) (*repositories.MemeCoin, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "MemeCoinService.GetMemeCoin")
+	defer span.End()
+
+	memeCoin, err := service.repo.FindOne(ctx, id)
+	if err != nil || memeCoin == nil {
+		return nil, err
+	}
+
+	// The stored popularity_score lags behind Redis until the next sync, and further behind when the coin is cached
+	popularityScore, found, err := service.redis.Get(ctx, service.getMemeCoinPopularityScoreKey(id))
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		memeCoin.PopularityScore = popularityScore
+	}
+
+	return memeCoin, nil
 }
 
-func (service *MemeCoinService) UpdateMemeCoin(id int, description string) (*repositories.MemeCoin, error) {
-	return service.repo.UpdateOne(id, description)
+func (service *MemeCoinService) UpdateMemeCoin(ctx context.Context, id int, description string) (*repositories.MemeCoin, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "MemeCoinService.UpdateMemeCoin")
+	defer span.End()
+
+	return service.repo.UpdateOne(ctx, id, description)
 }
 
-func (service *MemeCoinService) DeleteMemeCoin(id int) (*repositories.MemeCoin, error) {
+func (service *MemeCoinService) DeleteMemeCoin(ctx context.Context, id int) (*repositories.MemeCoin, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "MemeCoinService.DeleteMemeCoin")
+	defer span.End()
+
 	// Delete popularity_score at redis
-	err := service.redis.Delete(service.getMemeCoinPopularityScoreKey(id))
+	err := service.redis.Delete(ctx, service.getMemeCoinPopularityScoreKey(id))
 	if err != nil {
 		return nil, err
 	}
 
-	deletedMemeCoin, err := service.repo.DeleteOne(id)
+	deletedMemeCoin, err := service.repo.DeleteOne(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -49,21 +89,261 @@ func (service *MemeCoinService) DeleteMemeCoin(id int) (*repositories.MemeCoin,
 	return deletedMemeCoin, nil
 }
 
-func (service *MemeCoinService) PokeMemeCoin(id int) error {
+func (service *MemeCoinService) PokeMemeCoin(ctx context.Context, id int) error {
+	ctx, span := tracing.Tracer().Start(ctx, "MemeCoinService.PokeMemeCoin")
+	defer span.End()
+
 	// Check if meme coin exists in Redis
-	exist, err := service.redis.Exists(service.getMemeCoinPopularityScoreKey(id))
+	exist, err := service.redis.Exists(ctx, service.getMemeCoinPopularityScoreKey(id))
 	if err != nil {
 		return err
 	}
 
 	if !exist {
+		service.logger.DebugContext(ctx, "Poke for a meme coin without popularity score", "id", id)
 		return errors.New("no such meme coin")
 	}
 
 	// Increment popularity_score at redis
-	return service.redis.IncrBy(service.getMemeCoinPopularityScoreKey(id), 1)
+	err = service.redis.IncrBy(ctx, service.getMemeCoinPopularityScoreKey(id), 1)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (service *MemeCoinService) CreateMemeCoins(ctx context.Context, inputs []CreateMemeCoinInput) ([]BatchCreateMemeCoinResult, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "MemeCoinService.CreateMemeCoins")
+	defer span.End()
+
+	if len(inputs) > MaxBatchCreateSize {
+		return nil, fmt.Errorf("batch size %d exceeds the limit of %d", len(inputs), MaxBatchCreateSize)
+	}
+
+	// Only the first occurrence of a name in the batch is inserted
+	results := make([]BatchCreateMemeCoinResult, len(inputs))
+	firstIndexByName := make(map[string]int, len(inputs))
+	newMemeCoins := make([]repositories.NewMemeCoin, 0, len(inputs))
+	for i, input := range inputs {
+		results[i].Index = i
+		if _, duplicated := firstIndexByName[input.Name]; duplicated {
+			results[i].Status = BatchStatusConflict
+			results[i].Error = "MemeCoin with the same name appears earlier in the batch"
+			continue
+		}
+		firstIndexByName[input.Name] = i
+		newMemeCoins = append(newMemeCoins, repositories.NewMemeCoin{
+			Name:        input.Name,
+			Description: input.Description,
+		})
+	}
+
+	createdMemeCoins, err := service.repo.CreateMany(ctx, newMemeCoins)
+	if err != nil {
+		return nil, err
+	}
+
+	popularityScores := make(map[string]int, len(createdMemeCoins))
+	for i := range createdMemeCoins {
+		createdMemeCoin := &createdMemeCoins[i]
+		results[firstIndexByName[createdMemeCoin.Name]].MemeCoin = createdMemeCoin
+		popularityScores[service.getMemeCoinPopularityScoreKey(createdMemeCoin.Id)] = createdMemeCoin.PopularityScore
+	}
+	err = service.redis.SetMany(ctx, popularityScores)
+	if err != nil {
+		return nil, err
+	}
+
+	// Names skipped by the database already exist
+	for _, i := range firstIndexByName {
+		if results[i].MemeCoin != nil {
+			results[i].Status = BatchStatusCreated
+			continue
+		}
+		results[i].Status = BatchStatusConflict
+		results[i].Error = "MemeCoin with the same name already exists"
+	}
+
+	return results, nil
+}
+
+func (service *MemeCoinService) GetMemeCoins(ctx context.Context, ids []int) (*BatchGetMemeCoinsResult, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "MemeCoinService.GetMemeCoins")
+	defer span.End()
+
+	if len(ids) > MaxBatchGetSize {
+		return nil, fmt.Errorf("batch size %d exceeds the limit of %d", len(ids), MaxBatchGetSize)
+	}
+
+	memeCoins, err := service.repo.FindMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the order of the requested IDs
+	memeCoinById := make(map[int]repositories.MemeCoin, len(memeCoins))
+	for _, memeCoin := range memeCoins {
+		memeCoinById[memeCoin.Id] = memeCoin
+	}
+	result := &BatchGetMemeCoinsResult{
+		MemeCoins: []repositories.MemeCoin{},
+		NotFound:  []int{},
+	}
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		memeCoin, found := memeCoinById[id]
+		if !found {
+			result.NotFound = append(result.NotFound, id)
+			continue
+		}
+		result.MemeCoins = append(result.MemeCoins, memeCoin)
+	}
+
+	return result, nil
+}
+
+func (service *MemeCoinService) PokeMemeCoins(ctx context.Context, pokes map[int]int) ([]BatchPokeMemeCoinResult, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "MemeCoinService.PokeMemeCoins")
+	defer span.End()
+
+	if len(pokes) > MaxBatchPokeSize {
+		return nil, fmt.Errorf("batch size %d exceeds the limit of %d", len(pokes), MaxBatchPokeSize)
+	}
+
+	ids := make([]int, 0, len(pokes))
+	keys := make([]string, 0, len(pokes))
+	for id, count := range pokes {
+		if count <= 0 || count > MaxPokeCount {
+			return nil, fmt.Errorf("poke count %d for meme coin %d is out of range", count, id)
+		}
+		ids = append(ids, id)
+		keys = append(keys, service.getMemeCoinPopularityScoreKey(id))
+	}
+	sort.Ints(ids)
+
+	// Check which meme coins exist in Redis
+	existsMap, err := service.redis.ExistsMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchPokeMemeCoinResult, 0, len(ids))
+	increments := make(map[string]int, len(ids))
+	for _, id := range ids {
+		key := service.getMemeCoinPopularityScoreKey(id)
+		result := BatchPokeMemeCoinResult{
+			Id:     id,
+			Count:  pokes[id],
+			Status: BatchStatusNotFound,
+		}
+		if existsMap[key] {
+			result.Status = BatchStatusPoked
+			increments[key] = pokes[id]
+		}
+		results = append(results, result)
+	}
+
+	// Increment popularity_score at redis
+	err = service.redis.IncrByMany(ctx, increments)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (service *MemeCoinService) GetPopularityScore(ctx context.Context, id int) (*PopularityScore, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "MemeCoinService.GetPopularityScore")
+	defer span.End()
+
+	// The stored score is compared with Redis, so it must not come from the cache or a lagging replica
+	memeCoin, err := service.repo.FindOne(repositories.WithPrimaryReads(ctx), id)
+	if err != nil || memeCoin == nil {
+		return nil, err
+	}
+
+	popularityScore := &PopularityScore{
+		Id:     id,
+		Stored: memeCoin.PopularityScore,
+	}
+	cached, found, err := service.redis.Get(ctx, service.getMemeCoinPopularityScoreKey(id))
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		popularityScore.Cached = &cached
+	}
+
+	return popularityScore, nil
+}
+
+// SetPopularityScore overwrites the score in both Redis and the database
+func (service *MemeCoinService) SetPopularityScore(ctx context.Context, id int, popularityScore int) (*PopularityScore, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "MemeCoinService.SetPopularityScore")
+	defer span.End()
+
+	if popularityScore < 0 {
+		return nil, errors.New("popularity score must not be negative")
+	}
+
+	updatedMemeCoin, err := service.repo.UpdatePopularityScore(ctx, id, popularityScore)
+	if err != nil || updatedMemeCoin == nil {
+		return nil, err
+	}
+
+	err = service.redis.Set(ctx, service.getMemeCoinPopularityScoreKey(id), popularityScore)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PopularityScore{
+		Id:     id,
+		Cached: &popularityScore,
+		Stored: updatedMemeCoin.PopularityScore,
+	}, nil
+}
+
+// AdjustPopularityScore increments the score in Redis and writes the result through to the database, the score can't go below zero
+func (service *MemeCoinService) AdjustPopularityScore(ctx context.Context, id int, delta int) (*PopularityScore, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "MemeCoinService.AdjustPopularityScore")
+	defer span.End()
+
+	key := service.getMemeCoinPopularityScoreKey(id)
+	exist, err := service.redis.Exists(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, errors.New("no such meme coin")
+	}
+
+	// The database gets the result of the increment itself, a later read could include pokes the sync worker writes too
+	cached, err := service.redis.AdjustBy(ctx, key, delta)
+	if err != nil {
+		return nil, err
+	}
+
+	updatedMemeCoin, err := service.repo.UpdatePopularityScore(ctx, id, cached)
+	if err != nil {
+		return nil, err
+	}
+	if updatedMemeCoin == nil {
+		return nil, errors.New("no such meme coin")
+	}
+
+	return &PopularityScore{
+		Id:     id,
+		Cached: &cached,
+		Stored: updatedMemeCoin.PopularityScore,
+	}, nil
 }
 
 func (service *MemeCoinService) getMemeCoinPopularityScoreKey(id int) string {
-	return fmt.Sprintf("meme:popularity_score:%d", id)
+	return service.keys.PopularityScore(id)
 }
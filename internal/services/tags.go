@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/internal/tracing"
+	"strings"
+)
+
+func NewTagService(tagRepository repositories.TagRepositoryInterface, memeCoinRepository repositories.MemeCoinRepositoryInterface, redisRepository repositories.RedisRepositoryInterface, tagLeaderboards repositories.TagLeaderboardRepositoryInterface, keys *repositories.KeyBuilder, logger *slog.Logger) *TagService {
+	// Apply defaults if values aren't specified
+	if keys == nil {
+		keys = repositories.DefaultKeyBuilder()
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &TagService{
+		repo:         tagRepository,
+		memeCoins:    memeCoinRepository,
+		redis:        redisRepository,
+		leaderboards: tagLeaderboards,
+		keys:         keys,
+		logger:       logger,
+	}
+}
+
+// AddTag returns nil when the meme coin doesn't exist, the tag is created on first use
+func (service *TagService) AddTag(ctx context.Context, memeCoinId int, tag string) (*repositories.Tag, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "TagService.AddTag")
+	defer span.End()
+
+	tag, err := normalizeName(tag)
+	if err != nil {
+		return nil, err
+	}
+	memeCoin, err := service.memeCoins.FindOne(repositories.WithPrimaryReads(ctx), memeCoinId)
+	if err != nil || memeCoin == nil {
+		return nil, err
+	}
+
+	addedTag, err := service.repo.AddOne(ctx, memeCoinId, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	// The coin joins the leaderboard with its live score, the stored one lags behind until the next sync
+	popularityScore, found, err := service.redis.Get(ctx, service.keys.PopularityScore(memeCoinId))
+	if err != nil || !found {
+		popularityScore = memeCoin.PopularityScore
+	}
+	err = service.leaderboards.Add(ctx, tag, memeCoinId, popularityScore)
+	if err != nil {
+		service.logger.WarnContext(ctx, "Failed to add meme coin to the tag leaderboard", "id", memeCoinId, "tag", tag, "error", err)
+	}
+
+	return addedTag, nil
+}
+
+// RemoveTag returns false when the meme coin didn't have the tag
+func (service *TagService) RemoveTag(ctx context.Context, memeCoinId int, tag string) (bool, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "TagService.RemoveTag")
+	defer span.End()
+
+	tag, err := normalizeName(tag)
+	if err != nil {
+		return false, err
+	}
+	removed, err := service.repo.RemoveOne(ctx, memeCoinId, tag)
+	if err != nil || !removed {
+		return false, err
+	}
+
+	err = service.leaderboards.Remove(ctx, tag, memeCoinId)
+	if err != nil {
+		service.logger.WarnContext(ctx, "Failed to remove meme coin from the tag leaderboard", "id", memeCoinId, "tag", tag, "error", err)
+	}
+
+	return true, nil
+}
+
+// GetMemeCoinTags returns nil when the meme coin doesn't exist
+func (service *TagService) GetMemeCoinTags(ctx context.Context, memeCoinId int) ([]repositories.Tag, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "TagService.GetMemeCoinTags")
+	defer span.End()
+
+	memeCoin, err := service.memeCoins.FindOne(ctx, memeCoinId)
+	if err != nil || memeCoin == nil {
+		return nil, err
+	}
+
+	return service.repo.FindByMemeCoin(ctx, memeCoinId)
+}
+
+// ListTags counts the coins of every tag, or of the tags of the category when one is given
+func (service *TagService) ListTags(ctx context.Context, category string) ([]repositories.TagCount, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "TagService.ListTags")
+	defer span.End()
+
+	if category != "" {
+		var err error
+		category, err = normalizeName(category)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return service.repo.CountAll(ctx, category)
+}
+
+// ListMemeCoinsByTag pages through the coins with the tag in id order, with their stored scores as the batch endpoint
+func (service *TagService) ListMemeCoinsByTag(ctx context.Context, tag string, afterId int, limit int) (*MemeCoinsByTagPage, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "TagService.ListMemeCoinsByTag")
+	defer span.End()
+
+	tag, err := normalizeName(tag)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > MaxTagPageSize {
+		return nil, fmt.Errorf("limit %d is out of range", limit)
+	}
+
+	// One more row tells whether there is a next page
+	memeCoins, err := service.repo.FindMemeCoins(ctx, tag, afterId, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &MemeCoinsByTagPage{
+		MemeCoins: memeCoins,
+	}
+	if len(memeCoins) > limit {
+		page.MemeCoins = memeCoins[:limit]
+		page.NextAfter = &memeCoins[limit-1].Id
+	}
+
+	return page, nil
+}
+
+// GetLeaderboard ranks the coins with the tag by their live popularity score
+func (service *TagService) GetLeaderboard(ctx context.Context, tag string, limit int) ([]LeaderboardEntry, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "TagService.GetLeaderboard")
+	defer span.End()
+
+	tag, err := normalizeName(tag)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > MaxLeaderboardSize {
+		return nil, fmt.Errorf("limit %d is out of range", limit)
+	}
+
+	top, err := service.leaderboards.Top(ctx, tag, limit)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, len(top))
+	for i, entry := range top {
+		ids[i] = entry.Id
+	}
+	memeCoins, err := service.memeCoins.FindMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	memeCoinById := make(map[int]repositories.MemeCoin, len(memeCoins))
+	for _, memeCoin := range memeCoins {
+		memeCoinById[memeCoin.Id] = memeCoin
+	}
+
+	// Coins deleted since they were ranked are left out
+	entries := make([]LeaderboardEntry, 0, len(top))
+	for _, entry := range top {
+		memeCoin, found := memeCoinById[entry.Id]
+		if !found {
+			continue
+		}
+		memeCoin.PopularityScore = entry.PopularityScore
+		entries = append(entries, LeaderboardEntry{
+			Rank:     len(entries) + 1,
+			MemeCoin: memeCoin,
+		})
+	}
+
+	return entries, nil
+}
+
+// CreateCategory returns nil when the name is taken
+func (service *TagService) CreateCategory(ctx context.Context, name string, description string) (*repositories.Category, error) {
+	name, err := normalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.repo.CreateCategory(ctx, name, description)
+}
+
+func (service *TagService) ListCategories(ctx context.Context) ([]repositories.Category, error) {
+	return service.repo.FindCategories(ctx)
+}
+
+func (service *TagService) DeleteCategory(ctx context.Context, name string) (*repositories.Category, error) {
+	name, err := normalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.repo.DeleteCategory(ctx, name)
+}
+
+// SetTagCategory files the tag under the category, an empty category takes it out of its current one
+func (service *TagService) SetTagCategory(ctx context.Context, tag string, category string) (*repositories.Tag, error) {
+	tag, err := normalizeName(tag)
+	if err != nil {
+		return nil, err
+	}
+	if category != "" {
+		category, err = normalizeName(category)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return service.repo.SetCategory(ctx, tag, category)
+}
+
+// normalizeName lowercases tag and category names, so that "Dogs" and "dogs" are the same tag
+func normalizeName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !tagNamePattern.MatchString(name) {
+		return "", ErrInvalidName
+	}
+
+	return name, nil
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/internal/tracing"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (service *MemeCoinService) ExportMemeCoins(ctx context.Context, writer io.Writer, format string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "MemeCoinService.ExportMemeCoins")
+	defer span.End()
+
+	switch format {
+	case FormatCSV:
+		return service.exportCSV(ctx, writer)
+	case FormatNDJSON:
+		return service.exportNDJSON(ctx, writer)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+}
+
+// ImportMemeCoins upserts the rows in batches while the file is read. When the file turns out malformed or too large,
+// the rows before the failing line are committed and the report of them is returned along with the error.
+func (service *MemeCoinService) ImportMemeCoins(ctx context.Context, reader io.Reader, format string) (*ImportReport, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "MemeCoinService.ImportMemeCoins")
+	defer span.End()
+
+	importer := &memeCoinImporter{
+		service: service,
+		report: &ImportReport{
+			Errors: []ImportRowError{},
+		},
+		batchNames: map[string]bool{},
+	}
+
+	var err error
+	switch format {
+	case FormatCSV:
+		err = importer.readCSV(ctx, reader)
+	case FormatNDJSON:
+		err = importer.readNDJSON(ctx, reader)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+
+	// Flush the last batch, also when reading failed so that every row before the failing line is committed
+	flushErr := importer.flush(ctx)
+	if err != nil {
+		return importer.report, err
+	}
+	if flushErr != nil {
+		return importer.report, flushErr
+	}
+
+	return importer.report, nil
+}
+
+func (service *MemeCoinService) exportCSV(ctx context.Context, writer io.Writer) error {
+	csvWriter := csv.NewWriter(writer)
+	err := csvWriter.Write(csvExportHeader)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	err = service.repo.StreamAll(ctx, func(memeCoin repositories.MemeCoin) error {
+		err := csvWriter.Write([]string{
+			strconv.Itoa(memeCoin.Id),
+			memeCoin.Name,
+			memeCoin.Description,
+			memeCoin.CreatedAt.Format(time.RFC3339Nano),
+			strconv.Itoa(memeCoin.PopularityScore),
+		})
+		if err != nil {
+			return err
+		}
+
+		// Flush regularly so rows are streamed instead of buffered
+		count++
+		if count%exportFlushSize == 0 {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func (service *MemeCoinService) exportNDJSON(ctx context.Context, writer io.Writer) error {
+	bufferedWriter := bufio.NewWriter(writer)
+	encoder := json.NewEncoder(bufferedWriter)
+
+	count := 0
+	err := service.repo.StreamAll(ctx, func(memeCoin repositories.MemeCoin) error {
+		err := encoder.Encode(memeCoin)
+		if err != nil {
+			return err
+		}
+
+		// Flush regularly so rows are streamed instead of buffered
+		count++
+		if count%exportFlushSize == 0 {
+			return bufferedWriter.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return bufferedWriter.Flush()
+}
+
+func (importer *memeCoinImporter) readCSV(ctx context.Context, reader io.Reader) error {
+	csvReader := csv.NewReader(reader)
+	header, err := csvReader.Read()
+	if err != nil && errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: missing CSV header", ErrMalformedImport)
+	} else if err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformedImport, err)
+	}
+
+	// Columns are matched by name, so unknown columns such as "id" and "created_at" are ignored
+	columns := map[string]int{}
+	for i, column := range header {
+		columns[strings.TrimSpace(column)] = i
+	}
+	nameColumn, ok := columns["name"]
+	if !ok {
+		return fmt.Errorf("%w: CSV header has no \"name\" column", ErrMalformedImport)
+	}
+	descriptionColumn, hasDescription := columns["description"]
+	popularityScoreColumn, hasPopularityScore := columns["popularity_score"]
+
+	for {
+		record, err := csvReader.Read()
+		if err != nil && errors.Is(err, io.EOF) {
+			return nil
+		}
+		// FieldPos panics when the record could not be parsed, so the line of other parse errors comes from the error
+		var parseError *csv.ParseError
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) && errors.As(err, &parseError) {
+			return fmt.Errorf("%w: line %d: %w", ErrMalformedImport, parseError.Line, parseError.Err)
+		} else if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			return fmt.Errorf("%w: %w", ErrMalformedImport, err)
+		}
+		importer.report.Total++
+		line, _ := csvReader.FieldPos(0)
+		if err != nil {
+			importer.reject(line, "", "wrong number of fields")
+			continue
+		}
+
+		row := importRow{line: line}
+		row.memeCoin.Name = strings.TrimSpace(record[nameColumn])
+		if hasDescription {
+			row.memeCoin.Description = record[descriptionColumn]
+		}
+		if hasPopularityScore && strings.TrimSpace(record[popularityScoreColumn]) != "" {
+			popularityScore, err := strconv.Atoi(strings.TrimSpace(record[popularityScoreColumn]))
+			if err != nil {
+				importer.reject(line, row.memeCoin.Name, "popularity_score is not an integer")
+				continue
+			}
+			row.memeCoin.PopularityScore = &popularityScore
+		}
+
+		err = importer.add(ctx, row)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (importer *memeCoinImporter) readNDJSON(ctx context.Context, reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxImportLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		importer.report.Total++
+
+		var item struct {
+			Name            *string `json:"name"`
+			Description     string  `json:"description"`
+			PopularityScore *int    `json:"popularity_score"`
+		}
+		err := json.Unmarshal([]byte(text), &item)
+		if err != nil {
+			importer.reject(line, "", "invalid JSON: "+err.Error())
+			continue
+		}
+
+		row := importRow{line: line}
+		if item.Name != nil {
+			row.memeCoin.Name = strings.TrimSpace(*item.Name)
+		}
+		row.memeCoin.Description = item.Description
+		row.memeCoin.PopularityScore = item.PopularityScore
+
+		err = importer.add(ctx, row)
+		if err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformedImport, err)
+	}
+
+	return nil
+}
+
+func (importer *memeCoinImporter) add(ctx context.Context, row importRow) error {
+	if row.memeCoin.Name == "" {
+		importer.reject(row.line, "", "name is required")
+		return nil
+	}
+	if row.memeCoin.PopularityScore != nil && *row.memeCoin.PopularityScore < 0 {
+		importer.reject(row.line, row.memeCoin.Name, "popularity_score must not be negative")
+		return nil
+	}
+
+	// A name can only be upserted once per statement, so a repeated name starts a new batch
+	if importer.batchNames[row.memeCoin.Name] {
+		err := importer.flush(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	importer.batch = append(importer.batch, row)
+	importer.batchNames[row.memeCoin.Name] = true
+	if len(importer.batch) >= ImportBatchSize {
+		return importer.flush(ctx)
+	}
+
+	return nil
+}
+
+func (importer *memeCoinImporter) reject(line int, name string, reason string) {
+	importer.report.Failed++
+	if len(importer.report.Errors) < MaxImportErrors {
+		importer.report.Errors = append(importer.report.Errors, ImportRowError{
+			Line:  line,
+			Name:  name,
+			Error: reason,
+		})
+	}
+}
+
+func (importer *memeCoinImporter) flush(ctx context.Context) error {
+	if len(importer.batch) == 0 {
+		return nil
+	}
+
+	// The batch is taken even when the flush fails, it is never written twice
+	batch := importer.batch
+	importer.batch = nil
+	importer.batchNames = map[string]bool{}
+
+	upsertMemeCoins := make([]repositories.UpsertMemeCoin, len(batch))
+	for i, row := range batch {
+		upsertMemeCoins[i] = row.memeCoin
+	}
+	upsertedMemeCoins, err := importer.service.repo.UpsertMany(ctx, upsertMemeCoins)
+	if err != nil {
+		return err
+	}
+
+	// Only overwrite scores in Redis when the row carried one, otherwise pending pokes would be lost
+	hasPopularityScore := make(map[string]bool, len(batch))
+	for _, row := range batch {
+		hasPopularityScore[row.memeCoin.Name] = row.memeCoin.PopularityScore != nil
+	}
+	popularityScores := map[string]int{}
+	for _, upsertedMemeCoin := range upsertedMemeCoins {
+		if upsertedMemeCoin.Inserted {
+			importer.report.Created++
+		} else {
+			importer.report.Updated++
+		}
+
+		if upsertedMemeCoin.Inserted || hasPopularityScore[upsertedMemeCoin.Name] {
+			popularityScores[importer.service.getMemeCoinPopularityScoreKey(upsertedMemeCoin.Id)] = upsertedMemeCoin.PopularityScore
+		}
+	}
+	return importer.service.redis.SetMany(ctx, popularityScores)
+}
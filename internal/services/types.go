@@ -1,10 +1,21 @@
 package services
 
-import "portto-assignment/internal/repositories"
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"portto-assignment/internal/repositories"
+	"regexp"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
 
 type MemeCoinService struct {
-	repo  repositories.MemeCoinRepositoryInterface
-	redis repositories.RedisRepositoryInterface
+	repo   repositories.MemeCoinRepositoryInterface
+	redis  repositories.RedisRepositoryInterface
+	keys   *repositories.KeyBuilder
+	logger *slog.Logger
 }
 
 type CreateMemeCoinInput struct {
@@ -12,10 +23,247 @@ type CreateMemeCoinInput struct {
 	Description string
 }
 
+type BatchCreateMemeCoinResult struct {
+	Index    int                    `json:"index"`
+	Status   string                 `json:"status"`
+	MemeCoin *repositories.MemeCoin `json:"meme_coin,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+type BatchGetMemeCoinsResult struct {
+	MemeCoins []repositories.MemeCoin `json:"meme_coins"`
+	NotFound  []int                   `json:"not_found"`
+}
+
+type BatchPokeMemeCoinResult struct {
+	Id     int    `json:"id"`
+	Count  int    `json:"count"`
+	Status string `json:"status"`
+}
+
+type PopularityScore struct {
+	Id int `json:"id"`
+	// Cached is the live score in Redis, nil when the key is missing
+	Cached *int `json:"cached"`
+	// Stored is the score last synced to the database
+	Stored int `json:"stored"`
+}
+
+type ImportRowError struct {
+	Line  int    `json:"line"`
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error"`
+}
+
+type ImportReport struct {
+	Total   int              `json:"total"`
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Failed  int              `json:"failed"`
+	Errors  []ImportRowError `json:"errors"`
+}
+
+type importRow struct {
+	line     int
+	memeCoin repositories.UpsertMemeCoin
+}
+
+// memeCoinImporter validates rows and upserts them batch by batch
+type memeCoinImporter struct {
+	service    *MemeCoinService
+	report     *ImportReport
+	batch      []importRow
+	batchNames map[string]bool
+}
+
+type TagService struct {
+	repo         repositories.TagRepositoryInterface
+	memeCoins    repositories.MemeCoinRepositoryInterface
+	redis        repositories.RedisRepositoryInterface
+	leaderboards repositories.TagLeaderboardRepositoryInterface
+	keys         *repositories.KeyBuilder
+	logger       *slog.Logger
+}
+
+type MemeCoinsByTagPage struct {
+	MemeCoins []repositories.MemeCoin `json:"meme_coins"`
+	// NextAfter is the after parameter of the next page, nil on the last page
+	NextAfter *int `json:"next_after"`
+}
+
+type LeaderboardEntry struct {
+	Rank int `json:"rank"`
+	repositories.MemeCoin
+}
+
+type APIKeyService struct {
+	repo repositories.APIKeyRepositoryInterface
+}
+
+type CreatedAPIKey struct {
+	repositories.APIKey
+	Key string `json:"key"`
+}
+
+type DatabaseHealthInterface interface {
+	Ping(ctx context.Context) error
+	Stat() *pgxpool.Stat
+}
+
+type HealthService struct {
+	database DatabaseHealthInterface
+	redis    repositories.RedisStatusInterface
+	logger   *slog.Logger
+}
+
+// DatabasePoolStats is a snapshot of the database connection pool
+type DatabasePoolStats struct {
+	MaxConns                int32   `json:"max_conns"`
+	TotalConns              int32   `json:"total_conns"`
+	AcquiredConns           int32   `json:"acquired_conns"`
+	IdleConns               int32   `json:"idle_conns"`
+	ConstructingConns       int32   `json:"constructing_conns"`
+	AcquireCount            int64   `json:"acquire_count"`
+	AcquireDurationMs       float64 `json:"acquire_duration_ms"`
+	EmptyAcquireCount       int64   `json:"empty_acquire_count"`
+	CanceledAcquireCount    int64   `json:"canceled_acquire_count"`
+	NewConnsCount           int64   `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64   `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64   `json:"max_idle_destroy_count"`
+}
+
+type Readiness struct {
+	Status   string                   `json:"status"`
+	Database string                   `json:"database"`
+	Redis    repositories.RedisStatus `json:"redis"`
+}
+
+type HealthServiceInterface interface {
+	Readiness() Readiness
+	DatabasePoolStats() DatabasePoolStats
+}
+
+const (
+	ReadinessStatusReady = "ready"
+	// ReadinessStatusDegraded means requests are served, but without Redis
+	ReadinessStatusDegraded    = "degraded"
+	ReadinessStatusUnavailable = "unavailable"
+
+	DependencyStatusUp   = "up"
+	DependencyStatusDown = "down"
+)
+
+type APIKeyServiceInterface interface {
+	CreateAPIKey(name string) (*CreatedAPIKey, error)
+	ListAPIKeys() ([]repositories.APIKey, error)
+	RevokeAPIKey(id int) (*repositories.APIKey, error)
+	Authenticate(key string) (*repositories.APIKey, error)
+}
+
+type TagServiceInterface interface {
+	AddTag(ctx context.Context, memeCoinId int, tag string) (*repositories.Tag, error)
+	RemoveTag(ctx context.Context, memeCoinId int, tag string) (bool, error)
+	GetMemeCoinTags(ctx context.Context, memeCoinId int) ([]repositories.Tag, error)
+	ListTags(ctx context.Context, category string) ([]repositories.TagCount, error)
+	ListMemeCoinsByTag(ctx context.Context, tag string, afterId int, limit int) (*MemeCoinsByTagPage, error)
+	GetLeaderboard(ctx context.Context, tag string, limit int) ([]LeaderboardEntry, error)
+	CreateCategory(ctx context.Context, name string, description string) (*repositories.Category, error)
+	ListCategories(ctx context.Context) ([]repositories.Category, error)
+	DeleteCategory(ctx context.Context, name string) (*repositories.Category, error)
+	SetTagCategory(ctx context.Context, tag string, category string) (*repositories.Tag, error)
+}
+
 type MemeCoinServiceInterface interface {
-	CreateMemeCoin(input CreateMemeCoinInput) (*repositories.MemeCoin, error)
-	GetMemeCoin(id int) (*repositories.MemeCoin, error)
-	UpdateMemeCoin(id int, description string) (*repositories.MemeCoin, error)
-	DeleteMemeCoin(id int) (*repositories.MemeCoin, error)
-	PokeMemeCoin(id int) error
+	CreateMemeCoin(ctx context.Context, input CreateMemeCoinInput) (*repositories.MemeCoin, error)
+	GetMemeCoin(ctx context.Context, id int) (*repositories.MemeCoin, error)
+	UpdateMemeCoin(ctx context.Context, id int, description string) (*repositories.MemeCoin, error)
+	DeleteMemeCoin(ctx context.Context, id int) (*repositories.MemeCoin, error)
+	PokeMemeCoin(ctx context.Context, id int) error
+	CreateMemeCoins(ctx context.Context, inputs []CreateMemeCoinInput) ([]BatchCreateMemeCoinResult, error)
+	GetMemeCoins(ctx context.Context, ids []int) (*BatchGetMemeCoinsResult, error)
+	PokeMemeCoins(ctx context.Context, pokes map[int]int) ([]BatchPokeMemeCoinResult, error)
+	ExportMemeCoins(ctx context.Context, writer io.Writer, format string) error
+	ImportMemeCoins(ctx context.Context, reader io.Reader, format string) (*ImportReport, error)
+	GetPopularityScore(ctx context.Context, id int) (*PopularityScore, error)
+	SetPopularityScore(ctx context.Context, id int, popularityScore int) (*PopularityScore, error)
+	AdjustPopularityScore(ctx context.Context, id int, delta int) (*PopularityScore, error)
 }
+
+const (
+	// MaxBatchCreateSize is the maximum number of meme coins created in one batch
+	MaxBatchCreateSize = 500
+
+	// MaxBatchGetSize is the maximum number of meme coins fetched in one batch
+	MaxBatchGetSize = 100
+
+	// MaxBatchPokeSize is the maximum number of distinct meme coins poked in one batch
+	MaxBatchPokeSize = 100
+
+	// MaxPokeCount is the maximum number of pokes for a single meme coin in one batch
+	MaxPokeCount = 1000
+)
+
+const (
+	// MaxTagPageSize is the maximum number of meme coins listed by tag in one page
+	MaxTagPageSize = 100
+
+	// DefaultTagPageSize is the number of meme coins listed by tag when no limit is given
+	DefaultTagPageSize = 20
+
+	// MaxLeaderboardSize is the maximum number of meme coins in a tag leaderboard
+	MaxLeaderboardSize = 100
+
+	// DefaultLeaderboardSize is the number of meme coins in a tag leaderboard when no limit is given
+	DefaultLeaderboardSize = 10
+)
+
+const (
+	BatchStatusCreated  = "created"
+	BatchStatusConflict = "conflict"
+	BatchStatusPoked    = "poked"
+	BatchStatusNotFound = "not_found"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+
+	// ImportBatchSize is the number of rows upserted in one statement
+	ImportBatchSize = 500
+
+	// MaxImportErrors is the maximum number of row errors listed in an import report
+	MaxImportErrors = 1000
+
+	// MaxImportLineSize is the longest NDJSON line accepted
+	MaxImportLineSize = 1024 * 1024
+
+	// exportFlushSize is the number of rows written between flushes
+	exportFlushSize = 100
+)
+
+const (
+	// apiKeyPrefix marks the keys issued by this service
+	apiKeyPrefix = "mc_"
+
+	// apiKeySecretSize is the number of random bytes in a key
+	apiKeySecretSize = 32
+
+	// apiKeyDisplayPrefixLength is the number of leading characters kept to recognise a key
+	apiKeyDisplayPrefixLength = 10
+)
+
+var (
+	// ErrUnsupportedFormat is returned for formats other than FormatCSV and FormatNDJSON
+	ErrUnsupportedFormat = errors.New("unsupported format")
+
+	// ErrMalformedImport is returned when the import file cannot be read at all
+	ErrMalformedImport = errors.New("malformed import file")
+
+	// ErrInvalidName is returned for tag and category names that don't match tagNamePattern
+	ErrInvalidName = errors.New("names must be 1 to 32 lowercase letters, digits or dashes, starting with a letter or a digit")
+)
+
+// tagNamePattern keeps tag names usable as URL path segments and Redis key segments
+var tagNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+var csvExportHeader = []string{"id", "name", "description", "created_at", "popularity_score"}
@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewTracerProvider registers a tracer provider sending spans to the configured exporter, and the W3C trace context propagator.
+// Shutdown flushes the spans still buffered.
+func NewTracerProvider(ctx context.Context, config TracerConfig) (*sdktrace.TracerProvider, error) {
+	// Apply defaults if values aren't specified
+	if config.Exporter == "" {
+		config.Exporter = ExporterNone
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = DefaultServiceName
+	}
+	if config.SampleRatio <= 0 || config.SampleRatio > 1 {
+		config.SampleRatio = DefaultSampleRatio
+	}
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", config.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	}
+	if config.Exporter == ExporterNone {
+		// Spans aren't recorded, but the incoming trace context is still passed on
+		options[1] = sdktrace.WithSampler(sdktrace.NeverSample())
+	} else {
+		exporter, err := newExporter(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+
+	return provider, nil
+}
+
+// ParseExporter validates an exporter name, an empty name means none
+func ParseExporter(name string) (Exporter, error) {
+	switch exporter := Exporter(strings.ToLower(name)); exporter {
+	case "":
+		return ExporterNone, nil
+	case ExporterNone, ExporterStdout, ExporterFile, ExporterOTLP:
+		return exporter, nil
+	}
+
+	return "", fmt.Errorf("unknown tracing exporter %q", name)
+}
+
+// Tracer starts the spans of the application, it follows the registered tracer provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+func newExporter(ctx context.Context, config TracerConfig) (sdktrace.SpanExporter, error) {
+	switch config.Exporter {
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		if config.FilePath == "" {
+			return nil, fmt.Errorf("a file path is required by the file exporter")
+		}
+		file, err := os.OpenFile(config.FilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &fileExporter{Exporter: exporter, file: file}, nil
+	case ExporterOTLP:
+		// The endpoint, headers and TLS come from the standard OTEL_EXPORTER_OTLP_* variables
+		return otlptracehttp.New(ctx)
+	}
+
+	return nil, fmt.Errorf("unknown tracing exporter %q", config.Exporter)
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	err := e.Exporter.Shutdown(ctx)
+	if closeErr := e.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"os"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+)
+
+type TracerConfig struct {
+	// Exporter is where spans are sent, spans are only propagated when it is empty
+	Exporter Exporter
+	// FilePath is the file the file exporter appends spans to
+	FilePath string
+	// ServiceName names the service the spans come from
+	ServiceName string
+	// SampleRatio is the share of traces started here that are recorded, traces started by a caller follow its decision
+	SampleRatio float64
+}
+
+// Exporter is the destination of the recorded spans
+type Exporter string
+
+const (
+	ExporterNone   Exporter = "none"
+	ExporterStdout Exporter = "stdout"
+	ExporterFile   Exporter = "file"
+	// ExporterOTLP sends spans over OTLP/HTTP to OTEL_EXPORTER_OTLP_ENDPOINT
+	ExporterOTLP Exporter = "otlp"
+)
+
+// QueryTracer records a span per SQL statement run through pgx
+type QueryTracer struct{}
+
+// fileExporter closes the file once the spans are flushed
+type fileExporter struct {
+	*stdouttrace.Exporter
+	file *os.File
+}
+
+const (
+	// TracerName is the instrumentation scope of the spans started by the application
+	TracerName = "portto-assignment"
+
+	// DefaultServiceName is the service name of the spans by default
+	DefaultServiceName = "meme-coin-api"
+
+	// DefaultSampleRatio records every trace by default
+	DefaultSampleRatio = 1.0
+)
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"portto-assignment/internal/handlers"
+)
+
+// Ready returns the readiness even when the service is unavailable, the status says so
+func (client *Client) Ready(ctx context.Context) (*Readiness, error) {
+	response, err := client.send(ctx, request{
+		method: http.MethodGet,
+		path:   "/health/ready",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	// An unavailable service still answers with its readiness
+	if response.StatusCode != http.StatusServiceUnavailable {
+		err = checkResponse(response)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var readiness Readiness
+	err = json.NewDecoder(response.Body).Decode(&readiness)
+	if err != nil {
+		return nil, err
+	}
+
+	return &readiness, nil
+}
+
+func (client *Client) GetLogLevel(ctx context.Context) (string, error) {
+	var body handlers.LogLevelBody
+	err := client.call(ctx, request{
+		method: http.MethodGet,
+		path:   "/admin/log-level",
+	}, &body)
+
+	return body.Level, err
+}
+
+// SetLogLevel changes the log level of the server until it restarts
+func (client *Client) SetLogLevel(ctx context.Context, level string) (string, error) {
+	req, err := jsonRequest(http.MethodPut, "/admin/log-level", handlers.LogLevelBody{
+		Level: level,
+	}, false, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var body handlers.LogLevelBody
+	err = client.call(ctx, req, &body)
+
+	return body.Level, err
+}
@@ -0,0 +1,195 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"portto-assignment/internal/middlewares"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func NewClient(config Config) *Client {
+	// Apply defaults if values aren't specified
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = DefaultMaxRetries
+	}
+	if config.MinBackoff == 0 {
+		config.MinBackoff = DefaultMinBackoff
+	}
+	if config.MaxBackoff == 0 {
+		config.MaxBackoff = DefaultMaxBackoff
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+
+	return &Client{
+		config: config,
+	}
+}
+
+// WithIdempotencyKey replaces the key generated for the create and poke requests, so that a replay can span several calls
+func WithIdempotencyKey(key string) RequestOption {
+	return func(request *http.Request) {
+		request.Header.Set(middlewares.IdempotencyKeyHeader, key)
+	}
+}
+
+// WithReadYourWrites reads from the primary database, so that a write made just before is seen
+func WithReadYourWrites() RequestOption {
+	return func(request *http.Request) {
+		request.Header.Set(middlewares.ReadYourWritesHeader, "true")
+	}
+}
+
+// send retries the responses with a 5xx or a 429 status, the caller closes the body of the response returned
+func (client *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	idempotencyKey := ""
+	if req.idempotent {
+		idempotencyKey = newIdempotencyKey()
+	}
+
+	for attempt := 0; ; attempt++ {
+		httpRequest, err := client.newRequest(ctx, req, idempotencyKey)
+		if err != nil {
+			return nil, err
+		}
+		response, err := client.config.HTTPClient.Do(httpRequest)
+		if err != nil {
+			return nil, err
+		}
+
+		retryable := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= http.StatusInternalServerError
+		if !retryable || req.stream != nil || attempt >= client.config.MaxRetries {
+			return response, nil
+		}
+
+		delay := client.backoff(attempt, response.Header.Get("Retry-After"))
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (client *Client) newRequest(ctx context.Context, req request, idempotencyKey string) (*http.Request, error) {
+	url := client.config.BaseURL + req.path
+	if len(req.query) > 0 {
+		url += "?" + req.query.Encode()
+	}
+	var body io.Reader
+	if req.stream != nil {
+		body = req.stream
+	} else if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, req.method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Accept", "application/json")
+	if req.contentType != "" {
+		httpRequest.Header.Set("Content-Type", req.contentType)
+	}
+	if client.config.APIKey != "" {
+		httpRequest.Header.Set(middlewares.APIKeyHeader, client.config.APIKey)
+	}
+	if idempotencyKey != "" {
+		httpRequest.Header.Set(middlewares.IdempotencyKeyHeader, idempotencyKey)
+	}
+	for _, option := range req.options {
+		option(httpRequest)
+	}
+
+	return httpRequest, nil
+}
+
+// backoff doubles the wait with every attempt, with jitter so that clients don't retry in step, Retry-After wins when the server sends it
+func (client *Client) backoff(attempt int, retryAfter string) time.Duration {
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		return min(time.Duration(seconds)*time.Second, client.config.MaxBackoff)
+	}
+
+	delay := client.config.MaxBackoff
+	if attempt < 32 {
+		delay = min(client.config.MinBackoff<<attempt, client.config.MaxBackoff)
+	}
+	jitter, err := rand.Int(rand.Reader, big.NewInt(int64(delay/2)+1))
+	if err != nil {
+		return delay
+	}
+
+	return delay/2 + time.Duration(jitter.Int64())
+}
+
+// call sends the request and decodes the JSON response into out, unless out is nil
+func (client *Client) call(ctx context.Context, req request, out any) error {
+	response, err := client.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	err = checkResponse(response)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+// checkResponse turns an error status into an APIError
+func checkResponse(response *http.Response) error {
+	if response.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	apiError := &APIError{StatusCode: response.StatusCode}
+	err := json.NewDecoder(response.Body).Decode(&apiError.HttpError)
+	if err != nil || apiError.Message == "" {
+		apiError.Message = http.StatusText(response.StatusCode)
+	}
+
+	return apiError
+}
+
+// jsonRequest encodes body as the JSON body of the request
+func jsonRequest(method string, path string, body any, idempotent bool, options []RequestOption) (request, error) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return request{}, err
+	}
+
+	return request{
+		method:      method,
+		path:        path,
+		body:        encoded,
+		contentType: "application/json",
+		idempotent:  idempotent,
+		options:     options,
+	}, nil
+}
+
+func newIdempotencyKey() string {
+	key := make([]byte, 16)
+	rand.Read(key)
+	return hex.EncodeToString(key)
+}
@@ -0,0 +1,249 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"portto-assignment/internal/handlers"
+	"strconv"
+	"strings"
+)
+
+const memeCoinPath = "/v1/meme-coin"
+
+// CreateMemeCoin returns an APIError with a 409 status when the name is taken
+func (client *Client) CreateMemeCoin(ctx context.Context, name string, description string, options ...RequestOption) (*MemeCoin, error) {
+	req, err := jsonRequest(http.MethodPost, memeCoinPath+"/create", handlers.CreateMemeCoinRequestBody{
+		Name:        name,
+		Description: description,
+	}, true, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var memeCoin MemeCoin
+	err = client.call(ctx, req, &memeCoin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &memeCoin, nil
+}
+
+// GetMemeCoin returns an APIError with a 404 status when the MemeCoin doesn't exist
+func (client *Client) GetMemeCoin(ctx context.Context, id int, options ...RequestOption) (*MemeCoin, error) {
+	var memeCoin MemeCoin
+	err := client.call(ctx, request{
+		method:  http.MethodGet,
+		path:    memeCoinPath + "/" + strconv.Itoa(id),
+		options: options,
+	}, &memeCoin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &memeCoin, nil
+}
+
+func (client *Client) UpdateMemeCoin(ctx context.Context, id int, description string, options ...RequestOption) (*MemeCoin, error) {
+	req, err := jsonRequest(http.MethodPatch, memeCoinPath+"/"+strconv.Itoa(id), handlers.UpdateMemeCoinRequestBody{
+		Description: description,
+	}, false, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var memeCoin MemeCoin
+	err = client.call(ctx, req, &memeCoin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &memeCoin, nil
+}
+
+// DeleteMemeCoin returns the MemeCoin as it was before the delete
+func (client *Client) DeleteMemeCoin(ctx context.Context, id int, options ...RequestOption) (*MemeCoin, error) {
+	var memeCoin MemeCoin
+	err := client.call(ctx, request{
+		method:  http.MethodDelete,
+		path:    memeCoinPath + "/" + strconv.Itoa(id),
+		options: options,
+	}, &memeCoin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &memeCoin, nil
+}
+
+func (client *Client) PokeMemeCoin(ctx context.Context, id int, options ...RequestOption) error {
+	return client.call(ctx, request{
+		method:     http.MethodPost,
+		path:       memeCoinPath + "/" + strconv.Itoa(id) + "/poke",
+		idempotent: true,
+		options:    options,
+	}, nil)
+}
+
+// CreateMemeCoins reports a result per item, names already taken are conflicts rather than errors
+func (client *Client) CreateMemeCoins(ctx context.Context, items []CreateMemeCoinRequestBody, options ...RequestOption) (*BatchCreateMemeCoinsResponse, error) {
+	req, err := jsonRequest(http.MethodPost, memeCoinPath+"/batch", handlers.BatchCreateMemeCoinsRequestBody{
+		Items: items,
+	}, true, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var response BatchCreateMemeCoinsResponse
+	err = client.call(ctx, req, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// GetMemeCoins fetches at most PageSize MemeCoins, IterateMemeCoins takes any number of IDs
+func (client *Client) GetMemeCoins(ctx context.Context, ids []int, options ...RequestOption) (*BatchGetMemeCoinsResult, error) {
+	tokens := make([]string, len(ids))
+	for i, id := range ids {
+		tokens[i] = strconv.Itoa(id)
+	}
+
+	var result BatchGetMemeCoinsResult
+	err := client.call(ctx, request{
+		method:  http.MethodGet,
+		path:    memeCoinPath,
+		query:   url.Values{"ids": {strings.Join(tokens, ",")}},
+		options: options,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// IterateMemeCoins fetches the MemeCoins a page of IDs at a time, IDs that don't exist are skipped
+func (client *Client) IterateMemeCoins(ctx context.Context, ids []int, options ...RequestOption) iter.Seq2[MemeCoin, error] {
+	return func(yield func(MemeCoin, error) bool) {
+		for start := 0; start < len(ids); start += PageSize {
+			result, err := client.GetMemeCoins(ctx, ids[start:min(start+PageSize, len(ids))], options...)
+			if err != nil {
+				yield(MemeCoin{}, err)
+				return
+			}
+			for _, memeCoin := range result.MemeCoins {
+				if !yield(memeCoin, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// PokeMemeCoins maps a MemeCoin ID to its number of pokes
+func (client *Client) PokeMemeCoins(ctx context.Context, pokes map[int]int, options ...RequestOption) (*BatchPokeMemeCoinsResponse, error) {
+	req, err := jsonRequest(http.MethodPost, memeCoinPath+"/pokes", handlers.BatchPokeMemeCoinsRequestBody{
+		Pokes: pokes,
+	}, true, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var response BatchPokeMemeCoinsResponse
+	err = client.call(ctx, req, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// ExportMemeCoins copies the whole catalogue to writer in the given format
+func (client *Client) ExportMemeCoins(ctx context.Context, writer io.Writer, format Format, options ...RequestOption) error {
+	response, err := client.export(ctx, format, options)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	_, err = io.Copy(writer, response.Body)
+	return err
+}
+
+// AllMemeCoins streams the whole catalogue, it stops at the first error
+func (client *Client) AllMemeCoins(ctx context.Context, options ...RequestOption) iter.Seq2[MemeCoin, error] {
+	return func(yield func(MemeCoin, error) bool) {
+		response, err := client.export(ctx, FormatNDJSON, options)
+		if err != nil {
+			yield(MemeCoin{}, err)
+			return
+		}
+		defer response.Body.Close()
+
+		decoder := json.NewDecoder(bufio.NewReader(response.Body))
+		for {
+			var memeCoin MemeCoin
+			err := decoder.Decode(&memeCoin)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(MemeCoin{}, err)
+				return
+			}
+			if !yield(memeCoin, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (client *Client) export(ctx context.Context, format Format, options []RequestOption) (*http.Response, error) {
+	response, err := client.send(ctx, request{
+		method:  http.MethodGet,
+		path:    memeCoinPath + "/export",
+		query:   url.Values{"format": {string(format)}},
+		options: options,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkResponse(response)
+	if err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ImportMemeCoins upserts MemeCoins by name, the file is streamed so the request is never retried
+func (client *Client) ImportMemeCoins(ctx context.Context, file io.Reader, format Format, options ...RequestOption) (*ImportReport, error) {
+	contentType := "text/csv"
+	if format == FormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+
+	var report ImportReport
+	err := client.call(ctx, request{
+		method:      http.MethodPost,
+		path:        memeCoinPath + "/import",
+		query:       url.Values{"format": {string(format)}},
+		stream:      file,
+		contentType: contentType,
+		options:     options,
+	}, &report)
+	if err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
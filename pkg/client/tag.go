@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"portto-assignment/internal/handlers"
+	"strconv"
+)
+
+const tagPath = "/v1/tags"
+
+// AddTag puts the tag on the MemeCoin, adding it twice is not an error
+func (client *Client) AddTag(ctx context.Context, id int, tag string, options ...RequestOption) (*Tag, error) {
+	var addedTag Tag
+	err := client.call(ctx, request{
+		method:  http.MethodPut,
+		path:    memeCoinTagsPath(id) + "/" + url.PathEscape(tag),
+		options: options,
+	}, &addedTag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &addedTag, nil
+}
+
+// RemoveTag returns an APIError with a 404 status when the MemeCoin doesn't have the tag
+func (client *Client) RemoveTag(ctx context.Context, id int, tag string, options ...RequestOption) error {
+	return client.call(ctx, request{
+		method:  http.MethodDelete,
+		path:    memeCoinTagsPath(id) + "/" + url.PathEscape(tag),
+		options: options,
+	}, nil)
+}
+
+func (client *Client) GetMemeCoinTags(ctx context.Context, id int, options ...RequestOption) ([]Tag, error) {
+	var response handlers.MemeCoinTagsResponse
+	err := client.call(ctx, request{
+		method:  http.MethodGet,
+		path:    memeCoinTagsPath(id),
+		options: options,
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Tags, nil
+}
+
+// ListTags counts the MemeCoins of every tag, most used first, only the tags of the category when one is given
+func (client *Client) ListTags(ctx context.Context, category string, options ...RequestOption) ([]TagCount, error) {
+	query := url.Values{}
+	if category != "" {
+		query.Set("category", category)
+	}
+
+	var response handlers.ListTagsResponse
+	err := client.call(ctx, request{
+		method:  http.MethodGet,
+		path:    tagPath,
+		query:   query,
+		options: options,
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Tags, nil
+}
+
+// ListMemeCoinsByTag fetches the page after the given MemeCoin ID, a limit of 0 takes the server default
+func (client *Client) ListMemeCoinsByTag(ctx context.Context, tag string, afterId int, limit int, options ...RequestOption) (*MemeCoinsByTagPage, error) {
+	query := url.Values{"after": {strconv.Itoa(afterId)}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var page MemeCoinsByTagPage
+	err := client.call(ctx, request{
+		method:  http.MethodGet,
+		path:    tagPath + "/" + url.PathEscape(tag) + "/meme-coins",
+		query:   query,
+		options: options,
+	}, &page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// IterateMemeCoinsByTag follows next_after through every page of the MemeCoins with the tag
+func (client *Client) IterateMemeCoinsByTag(ctx context.Context, tag string, limit int, options ...RequestOption) iter.Seq2[MemeCoin, error] {
+	return func(yield func(MemeCoin, error) bool) {
+		afterId := 0
+		for {
+			page, err := client.ListMemeCoinsByTag(ctx, tag, afterId, limit, options...)
+			if err != nil {
+				yield(MemeCoin{}, err)
+				return
+			}
+			for _, memeCoin := range page.MemeCoins {
+				if !yield(memeCoin, nil) {
+					return
+				}
+			}
+			if page.NextAfter == nil {
+				return
+			}
+			afterId = *page.NextAfter
+		}
+	}
+}
+
+// GetLeaderboard ranks the MemeCoins with the tag by their live popularity score, a limit of 0 takes the server default
+func (client *Client) GetLeaderboard(ctx context.Context, tag string, limit int, options ...RequestOption) (*TagLeaderboard, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var leaderboard TagLeaderboard
+	err := client.call(ctx, request{
+		method:  http.MethodGet,
+		path:    tagPath + "/" + url.PathEscape(tag) + "/leaderboard",
+		query:   query,
+		options: options,
+	}, &leaderboard)
+	if err != nil {
+		return nil, err
+	}
+
+	return &leaderboard, nil
+}
+
+func memeCoinTagsPath(id int) string {
+	return memeCoinPath + "/" + strconv.Itoa(id) + "/tags"
+}
@@ -0,0 +1,94 @@
+// Package client is the Go client of the MemeCoin API
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"portto-assignment/internal/handlers"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/internal/services"
+	"time"
+)
+
+// The types are the ones the server encodes, so that the client can't drift from the API
+type (
+	MemeCoin                     = repositories.MemeCoin
+	CreateMemeCoinRequestBody    = handlers.CreateMemeCoinRequestBody
+	BatchCreateMemeCoinsResponse = handlers.BatchCreateMemeCoinsResponse
+	BatchCreateMemeCoinResult    = services.BatchCreateMemeCoinResult
+	BatchGetMemeCoinsResult      = services.BatchGetMemeCoinsResult
+	BatchPokeMemeCoinsResponse   = handlers.BatchPokeMemeCoinsResponse
+	BatchPokeMemeCoinResult      = services.BatchPokeMemeCoinResult
+	ImportReport                 = services.ImportReport
+	ImportRowError               = services.ImportRowError
+	Readiness                    = services.Readiness
+	Tag                          = repositories.Tag
+	TagCount                     = repositories.TagCount
+	MemeCoinsByTagPage           = services.MemeCoinsByTagPage
+	TagLeaderboard               = handlers.TagLeaderboardResponse
+	LeaderboardEntry             = services.LeaderboardEntry
+)
+
+type Client struct {
+	config Config
+}
+
+type Config struct {
+	// BaseURL is where the API is served, e.g. http://localhost:8080
+	BaseURL string
+	// HTTPClient sends the requests, http.DefaultClient by default
+	HTTPClient *http.Client
+	// APIKey is sent as X-API-Key when set
+	APIKey string
+	// MaxRetries is how many times a request answered with a 5xx or a 429 is sent again, negative disables retries
+	MaxRetries int
+	// MinBackoff is the wait before the first retry, it doubles up to MaxBackoff with every retry
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// APIError is a response with an error status, its message and error come from the HttpError body when there is one
+type APIError struct {
+	StatusCode int
+	handlers.HttpError
+}
+
+func (err *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", err.StatusCode, err.Message, err.HttpError.Error)
+}
+
+// RequestOption changes a single request
+type RequestOption func(request *http.Request)
+
+// Format is the encoding of the export and import files
+type Format string
+
+const (
+	FormatCSV    Format = Format(services.FormatCSV)
+	FormatNDJSON Format = Format(services.FormatNDJSON)
+)
+
+const (
+	DefaultMaxRetries = 3
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 5 * time.Second
+
+	// PageSize is how many IDs IterateMemeCoins fetches per request, the most the API accepts
+	PageSize = services.MaxBatchGetSize
+)
+
+// request is a call to the API, its body is kept in memory so that it can be sent again
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+	// stream is sent instead of body when set, it can't be sent twice so the request isn't retried
+	stream io.Reader
+	// idempotent requests carry an Idempotency-Key, the same one for every retry
+	idempotent bool
+	options    []RequestOption
+}
@@ -0,0 +1,98 @@
+package clock
+
+import (
+	"slices"
+	"time"
+)
+
+// Real returns the clock of the time package
+func Real() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(period time.Duration) Ticker {
+	return realTicker{ticker: time.NewTicker(period)}
+}
+
+func (ticker realTicker) C() <-chan time.Time {
+	return ticker.ticker.C
+}
+
+func (ticker realTicker) Stop() {
+	ticker.ticker.Stop()
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{
+		now: now,
+	}
+}
+
+func (fake *Fake) Now() time.Time {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	return fake.now
+}
+
+func (fake *Fake) NewTicker(period time.Duration) Ticker {
+	if period <= 0 {
+		panic("non-positive interval for clock.Fake.NewTicker")
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	// The channel holds one tick and later ones are dropped until it is read, as with time.Ticker
+	ticker := &fakeTicker{
+		fake:   fake,
+		c:      make(chan time.Time, 1),
+		period: period,
+		next:   fake.now.Add(period),
+	}
+	fake.tickers = append(fake.tickers, ticker)
+
+	return ticker
+}
+
+// Advance moves the clock forward and fires the tickers whose next tick has passed
+func (fake *Fake) Advance(duration time.Duration) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	fake.now = fake.now.Add(duration)
+	for _, ticker := range fake.tickers {
+		for !ticker.next.After(fake.now) {
+			select {
+			case ticker.c <- ticker.next:
+			default:
+			}
+			ticker.next = ticker.next.Add(ticker.period)
+		}
+	}
+}
+
+// Tickers returns how many tickers are running, so that a test can wait for a worker to start before advancing
+func (fake *Fake) Tickers() int {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	return len(fake.tickers)
+}
+
+func (ticker *fakeTicker) C() <-chan time.Time {
+	return ticker.c
+}
+
+func (ticker *fakeTicker) Stop() {
+	ticker.fake.mutex.Lock()
+	defer ticker.fake.mutex.Unlock()
+
+	ticker.fake.tickers = slices.DeleteFunc(ticker.fake.tickers, func(other *fakeTicker) bool {
+		return other == ticker
+	})
+}
@@ -0,0 +1,38 @@
+// Package clock lets time-dependent code run on a fake clock in tests
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+	// NewTicker behaves as time.NewTicker
+	NewTicker(period time.Duration) Ticker
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type realClock struct{}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+// Fake only moves when advanced, its tickers fire as the time passes their next tick
+type Fake struct {
+	mutex   sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+type fakeTicker struct {
+	fake   *Fake
+	c      chan time.Time
+	period time.Duration
+	next   time.Time
+}
@@ -0,0 +1,39 @@
+package memecointest
+
+import (
+	"fmt"
+	"time"
+)
+
+// NewMemeCoinFixture starts a fixture with an empty description, a zero popularity score and FixtureTime as created_at
+func NewMemeCoinFixture(name string) *MemeCoinFixture {
+	return &MemeCoinFixture{
+		name:      name,
+		createdAt: FixtureTime,
+	}
+}
+
+// NewMemeCoinFixtures returns count fixtures named "Coin 1", "Coin 2" and so on
+func NewMemeCoinFixtures(count int) []*MemeCoinFixture {
+	fixtures := make([]*MemeCoinFixture, count)
+	for i := range fixtures {
+		fixtures[i] = NewMemeCoinFixture(fmt.Sprintf("Coin %d", i+1))
+	}
+
+	return fixtures
+}
+
+func (fixture *MemeCoinFixture) WithDescription(description string) *MemeCoinFixture {
+	fixture.description = description
+	return fixture
+}
+
+func (fixture *MemeCoinFixture) WithPopularityScore(popularityScore int) *MemeCoinFixture {
+	fixture.popularityScore = popularityScore
+	return fixture
+}
+
+func (fixture *MemeCoinFixture) WithCreatedAt(createdAt time.Time) *MemeCoinFixture {
+	fixture.createdAt = createdAt
+	return fixture
+}
@@ -0,0 +1,11 @@
+package memecointest
+
+import (
+	"portto-assignment/internal/repositories"
+	"portto-assignment/pkg/clock"
+)
+
+// NewIdempotencyRepository expires records on timeSource, the real clock when nil
+func NewIdempotencyRepository(timeSource clock.Clock) *IdempotencyRepository {
+	return repositories.NewMemoryIdempotencyRepository(timeSource)
+}
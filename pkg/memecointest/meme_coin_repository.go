@@ -0,0 +1,243 @@
+package memecointest
+
+import (
+	"context"
+	"fmt"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/pkg/clock"
+	"sort"
+	"time"
+)
+
+// NewMemeCoinRepository stamps created_at from timeSource, the real clock when nil
+func NewMemeCoinRepository(timeSource clock.Clock) *MemeCoinRepository {
+	// Apply defaults if values aren't specified
+	if timeSource == nil {
+		timeSource = clock.Real()
+	}
+
+	return &MemeCoinRepository{
+		memeCoins: map[int]repositories.MemeCoin{},
+		names:     map[string]int{},
+		clock:     timeSource,
+	}
+}
+
+func (repo *MemeCoinRepository) FindOne(ctx context.Context, id int) (*repositories.MemeCoin, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	memeCoin, found := repo.memeCoins[id]
+	if !found {
+		return nil, nil
+	}
+
+	return &memeCoin, nil
+}
+
+// CreateOne returns nil when the name is taken
+func (repo *MemeCoinRepository) CreateOne(ctx context.Context, name string, description string) (*repositories.MemeCoin, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	memeCoin, created := repo.insert(name, description, 0, repo.now())
+	if !created {
+		return nil, nil
+	}
+
+	return &memeCoin, nil
+}
+
+func (repo *MemeCoinRepository) UpdateOne(ctx context.Context, id int, description string) (*repositories.MemeCoin, error) {
+	return repo.update(id, func(memeCoin *repositories.MemeCoin) {
+		memeCoin.Description = description
+	})
+}
+
+func (repo *MemeCoinRepository) DeleteOne(ctx context.Context, id int) (*repositories.MemeCoin, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	memeCoin, found := repo.memeCoins[id]
+	if !found {
+		return nil, nil
+	}
+	delete(repo.memeCoins, id)
+	delete(repo.names, memeCoin.Name)
+
+	return &memeCoin, nil
+}
+
+func (repo *MemeCoinRepository) FindMany(ctx context.Context, ids []int) ([]repositories.MemeCoin, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	memeCoins := []repositories.MemeCoin{}
+	seen := map[int]bool{}
+	for _, id := range ids {
+		memeCoin, found := repo.memeCoins[id]
+		if found && !seen[id] {
+			seen[id] = true
+			memeCoins = append(memeCoins, memeCoin)
+		}
+	}
+	sortById(memeCoins)
+
+	return memeCoins, nil
+}
+
+// CreateMany skips the names that are taken, including by an earlier item of the same call
+func (repo *MemeCoinRepository) CreateMany(ctx context.Context, newMemeCoins []repositories.NewMemeCoin) ([]repositories.MemeCoin, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	createdAt := repo.now()
+	createdMemeCoins := []repositories.MemeCoin{}
+	for _, newMemeCoin := range newMemeCoins {
+		memeCoin, created := repo.insert(newMemeCoin.Name, newMemeCoin.Description, 0, createdAt)
+		if created {
+			createdMemeCoins = append(createdMemeCoins, memeCoin)
+		}
+	}
+
+	return createdMemeCoins, nil
+}
+
+// StreamAll calls fn on a snapshot, so fn may use the repository
+func (repo *MemeCoinRepository) StreamAll(ctx context.Context, fn func(memeCoin repositories.MemeCoin) error) error {
+	for _, memeCoin := range repo.All() {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+		err = fn(memeCoin)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpsertMany keeps the stored popularity score when the upsert has none
+func (repo *MemeCoinRepository) UpsertMany(ctx context.Context, upsertMemeCoins []repositories.UpsertMemeCoin) ([]repositories.UpsertedMemeCoin, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	createdAt := repo.now()
+	upsertedMemeCoins := []repositories.UpsertedMemeCoin{}
+	for _, upsertMemeCoin := range upsertMemeCoins {
+		id, found := repo.names[upsertMemeCoin.Name]
+		if !found {
+			popularityScore := 0
+			if upsertMemeCoin.PopularityScore != nil {
+				popularityScore = *upsertMemeCoin.PopularityScore
+			}
+			memeCoin, _ := repo.insert(upsertMemeCoin.Name, upsertMemeCoin.Description, popularityScore, createdAt)
+			upsertedMemeCoins = append(upsertedMemeCoins, repositories.UpsertedMemeCoin{MemeCoin: memeCoin, Inserted: true})
+			continue
+		}
+
+		memeCoin := repo.memeCoins[id]
+		memeCoin.Description = upsertMemeCoin.Description
+		if upsertMemeCoin.PopularityScore != nil {
+			memeCoin.PopularityScore = *upsertMemeCoin.PopularityScore
+		}
+		repo.memeCoins[id] = memeCoin
+		upsertedMemeCoins = append(upsertedMemeCoins, repositories.UpsertedMemeCoin{MemeCoin: memeCoin})
+	}
+
+	return upsertedMemeCoins, nil
+}
+
+func (repo *MemeCoinRepository) UpdatePopularityScore(ctx context.Context, id int, popularityScore int) (*repositories.MemeCoin, error) {
+	return repo.update(id, func(memeCoin *repositories.MemeCoin) {
+		memeCoin.PopularityScore = popularityScore
+	})
+}
+
+func (repo *MemeCoinRepository) IncrementPopularityScore(ctx context.Context, id int, increment int) (*repositories.MemeCoin, error) {
+	return repo.update(id, func(memeCoin *repositories.MemeCoin) {
+		memeCoin.PopularityScore += increment
+	})
+}
+
+// All returns every MemeCoin ordered by ID
+func (repo *MemeCoinRepository) All() []repositories.MemeCoin {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	memeCoins := make([]repositories.MemeCoin, 0, len(repo.memeCoins))
+	for _, memeCoin := range repo.memeCoins {
+		memeCoins = append(memeCoins, memeCoin)
+	}
+	sortById(memeCoins)
+
+	return memeCoins
+}
+
+// Seed inserts the fixtures with the next IDs, a name that is taken fails the whole seed
+func (repo *MemeCoinRepository) Seed(fixtures ...*MemeCoinFixture) ([]repositories.MemeCoin, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	names := map[string]bool{}
+	for _, fixture := range fixtures {
+		if _, found := repo.names[fixture.name]; found || names[fixture.name] {
+			return nil, fmt.Errorf("meme coin %q already exists", fixture.name)
+		}
+		names[fixture.name] = true
+	}
+
+	memeCoins := make([]repositories.MemeCoin, len(fixtures))
+	for i, fixture := range fixtures {
+		memeCoins[i], _ = repo.insert(fixture.name, fixture.description, fixture.popularityScore, fixture.createdAt)
+	}
+
+	return memeCoins, nil
+}
+
+// insert is called with the lock held, it returns false when the name is taken
+func (repo *MemeCoinRepository) insert(name string, description string, popularityScore int, createdAt time.Time) (repositories.MemeCoin, bool) {
+	if _, found := repo.names[name]; found {
+		return repositories.MemeCoin{}, false
+	}
+
+	repo.lastId++
+	memeCoin := repositories.MemeCoin{
+		Id:              repo.lastId,
+		Name:            name,
+		Description:     description,
+		CreatedAt:       createdAt,
+		PopularityScore: popularityScore,
+	}
+	repo.memeCoins[memeCoin.Id] = memeCoin
+	repo.names[name] = memeCoin.Id
+
+	return memeCoin, true
+}
+
+func (repo *MemeCoinRepository) update(id int, change func(memeCoin *repositories.MemeCoin)) (*repositories.MemeCoin, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	memeCoin, found := repo.memeCoins[id]
+	if !found {
+		return nil, nil
+	}
+	change(&memeCoin)
+	repo.memeCoins[id] = memeCoin
+
+	return &memeCoin, nil
+}
+
+func sortById(memeCoins []repositories.MemeCoin) {
+	sort.Slice(memeCoins, func(i, j int) bool {
+		return memeCoins[i].Id < memeCoins[j].Id
+	})
+}
+
+// now has the precision of a PostgreSQL timestamp
+func (repo *MemeCoinRepository) now() time.Time {
+	return repo.clock.Now().UTC().Truncate(time.Microsecond)
+}
@@ -0,0 +1,8 @@
+package memecointest
+
+import "portto-assignment/internal/repositories"
+
+// NewRedisRepository keeps the popularity scores in memory only, with no database behind them
+func NewRedisRepository() *RedisRepository {
+	return repositories.NewMemoryRedisRepository(repositories.MemoryRedisConfig{})
+}
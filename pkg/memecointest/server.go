@@ -0,0 +1,98 @@
+package memecointest
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	docs "portto-assignment/api"
+	"portto-assignment/internal/handlers"
+	"portto-assignment/internal/middlewares"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/internal/routes"
+	"portto-assignment/internal/services"
+	"portto-assignment/pkg/client"
+	"portto-assignment/pkg/clock"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NewServer serves the router of cmd/main.go without API key authentication, so without the admin routes, it is closed when the test ends
+func NewServer(t testing.TB, config ServerConfig) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if config.Clock == nil {
+		config.Clock = clock.Real()
+	}
+
+	memeCoins := NewMemeCoinRepository(config.Clock)
+	redis := NewRedisRepository()
+	memeCoinService := services.NewMemeCoinService(memeCoins, redis, nil, logger)
+	healthService := services.NewHealthService(healthyDatabase{}, redis, logger)
+
+	routerConfig := routes.RouterConfig{
+		RequestID: middlewares.NewRequestIDMiddleware(),
+		Idempotency: middlewares.NewIdempotencyMiddleware(NewIdempotencyRepository(config.Clock), middlewares.IdempotencyConfig{
+			Logger: logger,
+		}),
+		ReadYourWrites: middlewares.NewReadYourWritesMiddleware(),
+		Health:         handlers.NewHealthHandler(healthService),
+	}
+	if !config.DisableOpenAPIValidation {
+		spec, err := docs.OpenAPI()
+		if err != nil {
+			t.Fatal(err)
+		}
+		routerConfig.OpenAPIValidation, err = middlewares.NewOpenAPIValidationMiddleware(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	router := routes.NewRouter(handlers.NewMemeCoinHandler(memeCoinService, logger), routerConfig)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	clientConfig := config.Client
+	clientConfig.BaseURL = server.URL
+
+	return &Server{
+		Server:    server,
+		Client:    client.NewClient(clientConfig),
+		Router:    router,
+		MemeCoins: memeCoins,
+		Redis:     redis,
+		Clock:     config.Clock,
+	}
+}
+
+// Seed stores the fixtures and their popularity scores in Redis, as the service does on create
+func (server *Server) Seed(t testing.TB, fixtures ...*MemeCoinFixture) []repositories.MemeCoin {
+	t.Helper()
+	memeCoins, err := server.MemeCoins.Seed(fixtures...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := repositories.DefaultKeyBuilder()
+	popularityScores := make(map[string]int, len(memeCoins))
+	for _, memeCoin := range memeCoins {
+		popularityScores[keys.PopularityScore(memeCoin.Id)] = memeCoin.PopularityScore
+	}
+	err = server.Redis.SetMany(context.Background(), popularityScores)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return memeCoins
+}
+
+func (healthyDatabase) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (healthyDatabase) Stat() *pgxpool.Stat {
+	return nil
+}
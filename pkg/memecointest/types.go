@@ -0,0 +1,64 @@
+// Package memecointest runs the MemeCoin API in memory, for the tests of this repository and of the services calling it
+package memecointest
+
+import (
+	"net/http/httptest"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/pkg/client"
+	"portto-assignment/pkg/clock"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MemeCoinRepository behaves as the PostgreSQL repository: names are unique, IDs are never reused and results are ordered by ID
+type MemeCoinRepository struct {
+	mutex     sync.RWMutex
+	memeCoins map[int]repositories.MemeCoin
+	names     map[string]int
+	lastId    int
+	clock     clock.Clock
+}
+
+// RedisRepository behaves as Redis for popularity scores: increments create missing keys.
+// It is the store the API uses with the sqlite driver, without the database behind it.
+type RedisRepository = repositories.MemoryRedisRepository
+
+// IdempotencyRepository keeps idempotency records in memory until their TTL passes
+type IdempotencyRepository = repositories.MemoryIdempotencyRepository
+
+// MemeCoinFixture builds a MemeCoin to seed, every field but the name has a default
+type MemeCoinFixture struct {
+	name            string
+	description     string
+	popularityScore int
+	createdAt       time.Time
+}
+
+// Server serves the full router over HTTP with in-memory repositories
+type Server struct {
+	*httptest.Server
+	// Client calls the server
+	Client    *client.Client
+	Router    *gin.Engine
+	MemeCoins *MemeCoinRepository
+	Redis     *RedisRepository
+	// Clock stamps created_at and expires idempotency keys
+	Clock clock.Clock
+}
+
+type ServerConfig struct {
+	// DisableOpenAPIValidation lets requests that don't match api/openapi.yaml reach the handlers
+	DisableOpenAPIValidation bool
+	// Client configures Server.Client, its BaseURL is always the server
+	Client client.Config
+	// Clock is the real clock when nil, a *clock.Fake makes created_at and expiries deterministic
+	Clock clock.Clock
+}
+
+// healthyDatabase reports the in-memory database as up, without pool statistics
+type healthyDatabase struct{}
+
+// FixtureTime is the created_at of the fixtures unless they set one
+var FixtureTime = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
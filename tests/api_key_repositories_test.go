@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"portto-assignment/internal/repositories"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyRepository(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mock.Close()
+
+	apiKeyRepository := repositories.NewAPIKeyRepository(mock)
+	columns := []string{"id", "name", "prefix", "created_at", "revoked_at"}
+	createdAt := time.Now()
+
+	// Case 1: create a key
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO api_keys (name, prefix, key_hash)")).
+		WithArgs("importer", "mc_abcdefg", "hash").
+		WillReturnRows(pgxmock.NewRows(columns).AddRow(1, "importer", "mc_abcdefg", createdAt, nil))
+	apiKey, err := apiKeyRepository.CreateOne("importer", "mc_abcdefg", "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, repositories.APIKey{Id: 1, Name: "importer", Prefix: "mc_abcdefg", CreatedAt: createdAt}, *apiKey)
+
+	// Case 2: unknown hash
+	mock.ExpectQuery(regexp.QuoteMeta("FROM api_keys")).
+		WithArgs("unknown").
+		WillReturnRows(pgxmock.NewRows(columns))
+	apiKey, err = apiKeyRepository.FindActiveByHash("unknown")
+	assert.NoError(t, err)
+	assert.Nil(t, apiKey)
+
+	// Case 3: list keys
+	mock.ExpectQuery(regexp.QuoteMeta("FROM api_keys")).
+		WillReturnRows(pgxmock.NewRows(columns).AddRow(1, "importer", "mc_abcdefg", createdAt, &createdAt))
+	apiKeys, err := apiKeyRepository.FindAll()
+	assert.NoError(t, err)
+	assert.Len(t, apiKeys, 1)
+	assert.Equal(t, createdAt, *apiKeys[0].RevokedAt)
+
+	// Case 4: revoke an already revoked key
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE api_keys")).
+		WithArgs(1).
+		WillReturnRows(pgxmock.NewRows(columns))
+	apiKey, err = apiKeyRepository.RevokeOne(1)
+	assert.NoError(t, err)
+	assert.Nil(t, apiKey)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/pkg/clock"
+	"portto-assignment/tests/mocks"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferedRedisRepository(t *testing.T) {
+	key := "meme:popularity_score:1"
+
+	t.Run("Write combining", func(t *testing.T) {
+		redisRepository := &mocks.MockMemoryRedisRepository{}
+		redisRepository.Set(context.Background(), key, 10)
+		bufferedRepository := repositories.NewBufferedRedisRepository(redisRepository, repositories.BufferConfig{
+			FlushInterval: time.Hour,
+		})
+		defer bufferedRepository.Close()
+
+		// Case 1: concurrent pokes are combined in memory
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, bufferedRepository.IncrBy(context.Background(), key, 1))
+			}()
+		}
+		wg.Wait()
+		incrByManyCalls, _ := redisRepository.Calls()
+		assert.Equal(t, 0, incrByManyCalls)
+
+		// Case 2: reads include the pending pokes
+		value, found, err := bufferedRepository.Get(context.Background(), key)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, 110, value)
+
+		// Case 3: a flush writes them with one batch
+		assert.NoError(t, bufferedRepository.Flush())
+		incrByManyCalls, _ = redisRepository.Calls()
+		assert.Equal(t, 1, incrByManyCalls)
+		value, _, _ = redisRepository.Get(context.Background(), key)
+		assert.Equal(t, 110, value)
+
+		// Case 4: overwriting or deleting a key drops its pending pokes
+		bufferedRepository.IncrBy(context.Background(), key, 5)
+		assert.NoError(t, bufferedRepository.Set(context.Background(), key, 1))
+		bufferedRepository.IncrBy(context.Background(), key, 5)
+		assert.NoError(t, bufferedRepository.Delete(context.Background(), key))
+		assert.NoError(t, bufferedRepository.Flush())
+		_, found, _ = redisRepository.Get(context.Background(), key)
+		assert.False(t, found)
+	})
+
+	t.Run("Flush triggers", func(t *testing.T) {
+		redisRepository := &mocks.MockMemoryRedisRepository{}
+		redisRepository.SetMany(context.Background(), map[string]int{key: 0, "meme:popularity_score:2": 0, "meme:popularity_score:3": 0})
+
+		// Case 1: pokes are flushed after the flush interval, not before
+		fake := clock.NewFake(time.Now())
+		bufferedRepository := repositories.NewBufferedRedisRepository(redisRepository, repositories.BufferConfig{
+			FlushInterval: time.Minute,
+			Clock:         fake,
+		})
+		bufferedRepository.IncrBy(context.Background(), key, 3)
+		assert.Eventually(t, func() bool { return fake.Tickers() == 2 }, time.Second, time.Millisecond)
+		fake.Advance(59 * time.Second)
+		value, _, _ := redisRepository.Get(context.Background(), key)
+		assert.Equal(t, 0, value)
+		fake.Advance(time.Second)
+		assert.Eventually(t, func() bool {
+			value, _, _ := redisRepository.Get(context.Background(), key)
+			return value == 3
+		}, time.Second, time.Millisecond)
+		bufferedRepository.Close()
+
+		// Case 2: too many pending keys flush early
+		bufferedRepository = repositories.NewBufferedRedisRepository(redisRepository, repositories.BufferConfig{
+			FlushInterval:  time.Hour,
+			MaxPendingKeys: 2,
+		})
+		bufferedRepository.IncrByMany(context.Background(), map[string]int{"meme:popularity_score:2": 1, "meme:popularity_score:3": 1})
+		assert.Eventually(t, func() bool {
+			value, _, _ := redisRepository.Get(context.Background(), "meme:popularity_score:3")
+			return value == 1
+		}, time.Second, 5*time.Millisecond)
+
+		// Case 3: closing flushes the pending pokes and later ones go straight to Redis
+		bufferedRepository.IncrBy(context.Background(), key, 1)
+		assert.NoError(t, bufferedRepository.Close())
+		value, _, _ = redisRepository.Get(context.Background(), key)
+		assert.Equal(t, 4, value)
+
+		assert.NoError(t, bufferedRepository.IncrBy(context.Background(), key, 1))
+		value, _, _ = redisRepository.Get(context.Background(), key)
+		assert.Equal(t, 5, value)
+	})
+
+	t.Run("Existence cache", func(t *testing.T) {
+		redisRepository := &mocks.MockMemoryRedisRepository{}
+		redisRepository.Set(context.Background(), key, 0)
+		fake := clock.NewFake(time.Now())
+		bufferedRepository := repositories.NewBufferedRedisRepository(redisRepository, repositories.BufferConfig{
+			FlushInterval: time.Hour,
+			ExistenceTTL:  time.Minute,
+			Clock:         fake,
+		})
+		defer bufferedRepository.Close()
+
+		// Case 1: positive and negative answers are kept in memory
+		for i := 0; i < 3; i++ {
+			existsMap, err := bufferedRepository.ExistsMany(context.Background(), []string{key, "meme:popularity_score:2"})
+			assert.NoError(t, err)
+			assert.True(t, existsMap[key])
+			assert.False(t, existsMap["meme:popularity_score:2"])
+		}
+		_, existsManyCalls := redisRepository.Calls()
+		assert.Equal(t, 1, existsManyCalls)
+
+		// Case 2: a local delete is seen right away
+		assert.NoError(t, bufferedRepository.Delete(context.Background(), key))
+		exists, err := bufferedRepository.Exists(context.Background(), key)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+
+		// Case 3: a coin created elsewhere is seen once the answer expires
+		redisRepository.Set(context.Background(), "meme:popularity_score:2", 0)
+		fake.Advance(time.Minute)
+		exists, err = bufferedRepository.Exists(context.Background(), "meme:popularity_score:2")
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("Failed flush", func(t *testing.T) {
+		redisRepository := &mocks.MockMemoryRedisRepository{}
+		redisRepository.Set(context.Background(), key, 0)
+		bufferedRepository := repositories.NewBufferedRedisRepository(redisRepository, repositories.BufferConfig{
+			FlushInterval: time.Hour,
+		})
+		defer bufferedRepository.Close()
+
+		// Case 1: the pokes of a failed flush are merged with the ones made since and written by the next flush
+		redisRepository.Err = errors.New("connection refused")
+		bufferedRepository.IncrBy(context.Background(), key, 1)
+		assert.Error(t, bufferedRepository.Flush())
+
+		redisRepository.Err = nil
+		bufferedRepository.IncrBy(context.Background(), key, 2)
+		assert.NoError(t, bufferedRepository.Flush())
+		value, _, _ := redisRepository.Get(context.Background(), key)
+		assert.Equal(t, 3, value)
+
+		// Case 2: only the increments that failed are retried when the others reached Redis
+		otherKey := "meme:popularity_score:2"
+		redisRepository.Set(context.Background(), otherKey, 0)
+		redisRepository.FailKeys = map[string]bool{otherKey: true}
+		bufferedRepository.IncrByMany(context.Background(), map[string]int{key: 1, otherKey: 1})
+		assert.Error(t, bufferedRepository.Flush())
+
+		redisRepository.FailKeys = nil
+		assert.NoError(t, bufferedRepository.Flush())
+		value, _, _ = redisRepository.Get(context.Background(), key)
+		assert.Equal(t, 4, value)
+		value, _, _ = redisRepository.Get(context.Background(), otherKey)
+		assert.Equal(t, 1, value)
+	})
+
+	t.Run("Deleted elsewhere", func(t *testing.T) {
+		redisRepository := &mocks.MockMemoryRedisRepository{}
+		redisRepository.Set(context.Background(), key, 10)
+		bufferedRepository := repositories.NewBufferedRedisRepository(redisRepository, repositories.BufferConfig{
+			FlushInterval: time.Hour,
+			ExistenceTTL:  time.Hour,
+		})
+		defer bufferedRepository.Close()
+
+		// Case 1: the coin is deleted by another instance while the existence cache still says it exists
+		exists, err := bufferedRepository.Exists(context.Background(), key)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+		bufferedRepository.IncrBy(context.Background(), key, 1)
+		redisRepository.Delete(context.Background(), key)
+
+		assert.NoError(t, bufferedRepository.Flush())
+		_, found, _ := redisRepository.Get(context.Background(), key)
+		assert.False(t, found)
+		exists, err = bufferedRepository.Exists(context.Background(), key)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/pkg/clock"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedMemeCoinRepository(t *testing.T) {
+	dbmock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbmock.Close()
+
+	selectStatement := regexp.QuoteMeta("SELECT id, name, description, created_at, popularity_score FROM meme_coins WHERE id = $1")
+	updateStatement := regexp.QuoteMeta("UPDATE meme_coins SET description = $2")
+	columns := []string{"id", "name", "description", "created_at", "popularity_score"}
+	createdAt := time.Now()
+
+	newCachedRepository := func(config repositories.CacheConfig) *repositories.CachedMemeCoinRepository {
+		return repositories.NewCachedMemeCoinRepository(repositories.NewMemeCoinRepository(dbmock), nil, config)
+	}
+
+	t.Run("Read through", func(t *testing.T) {
+		cachedRepository := newCachedRepository(repositories.CacheConfig{})
+
+		// Case 1: the first read loads the meme coin, the second one is served from memory
+		dbmock.ExpectQuery(selectStatement).WithArgs(1).WillReturnRows(pgxmock.NewRows(columns).AddRow(1, "Doge", "Such wow", createdAt, 3))
+		for i := 0; i < 2; i++ {
+			memeCoin, err := cachedRepository.FindOne(context.Background(), 1)
+			assert.NoError(t, err)
+			assert.Equal(t, "Such wow", memeCoin.Description)
+		}
+
+		// Case 2: callers can't change the cached meme coin
+		memeCoin, _ := cachedRepository.FindOne(context.Background(), 1)
+		memeCoin.Description = "Changed"
+		memeCoin, _ = cachedRepository.FindOne(context.Background(), 1)
+		assert.Equal(t, "Such wow", memeCoin.Description)
+
+		// Case 3: missing meme coins and errors aren't cached
+		for i := 0; i < 2; i++ {
+			dbmock.ExpectQuery(selectStatement).WithArgs(2).WillReturnRows(pgxmock.NewRows(columns))
+			memeCoin, err := cachedRepository.FindOne(context.Background(), 2)
+			assert.NoError(t, err)
+			assert.Nil(t, memeCoin)
+		}
+		dbmock.ExpectQuery(selectStatement).WithArgs(3).WillReturnError(errors.New("connection refused"))
+		memeCoin, err = cachedRepository.FindOne(context.Background(), 3)
+		assert.Error(t, err)
+		assert.Nil(t, memeCoin)
+
+		// Case 4: reads asking for their own writes skip the cache
+		dbmock.ExpectQuery(selectStatement).WithArgs(1).WillReturnRows(pgxmock.NewRows(columns).AddRow(1, "Doge", "Much wow", createdAt, 3))
+		memeCoin, err = cachedRepository.FindOne(repositories.WithPrimaryReads(context.Background()), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "Much wow", memeCoin.Description)
+
+		assert.NoError(t, dbmock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalidation", func(t *testing.T) {
+		cachedRepository := newCachedRepository(repositories.CacheConfig{})
+
+		dbmock.ExpectQuery(selectStatement).WithArgs(1).WillReturnRows(pgxmock.NewRows(columns).AddRow(1, "Doge", "Such wow", createdAt, 3))
+		_, err := cachedRepository.FindOne(context.Background(), 1)
+		assert.NoError(t, err)
+
+		// Case 1: an update evicts the meme coin
+		dbmock.ExpectQuery(updateStatement).WithArgs(1, "Much wow").WillReturnRows(pgxmock.NewRows(columns).AddRow(1, "Doge", "Much wow", createdAt, 3))
+		_, err = cachedRepository.UpdateOne(context.Background(), 1, "Much wow")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, cachedRepository.Len())
+
+		dbmock.ExpectQuery(selectStatement).WithArgs(1).WillReturnRows(pgxmock.NewRows(columns).AddRow(1, "Doge", "Much wow", createdAt, 3))
+		memeCoin, err := cachedRepository.FindOne(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "Much wow", memeCoin.Description)
+
+		// Case 2: a delete evicts the meme coin
+		dbmock.ExpectQuery(regexp.QuoteMeta("DELETE FROM meme_coins")).WithArgs(1).WillReturnRows(pgxmock.NewRows(columns).AddRow(1, "Doge", "Much wow", createdAt, 3))
+		_, err = cachedRepository.DeleteOne(context.Background(), 1)
+		assert.NoError(t, err)
+
+		dbmock.ExpectQuery(selectStatement).WithArgs(1).WillReturnRows(pgxmock.NewRows(columns))
+		memeCoin, err = cachedRepository.FindOne(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Nil(t, memeCoin)
+
+		// Case 3: a load racing with an update isn't cached
+		gatedRepository := &gatedMemeCoinRepository{
+			MemeCoinRepositoryInterface: repositories.NewMemeCoinRepository(dbmock),
+			started:                     make(chan struct{}),
+			release:                     make(chan struct{}),
+		}
+		racingRepository := repositories.NewCachedMemeCoinRepository(gatedRepository, nil, repositories.CacheConfig{})
+		dbmock.ExpectQuery(updateStatement).WithArgs(2, "New").WillReturnRows(pgxmock.NewRows(columns).AddRow(2, "Pepe", "New", createdAt, 0))
+		dbmock.ExpectQuery(selectStatement).WithArgs(2).WillReturnRows(pgxmock.NewRows(columns).AddRow(2, "Pepe", "Old", createdAt, 0))
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			racingRepository.FindOne(context.Background(), 2)
+		}()
+		<-gatedRepository.started
+		_, err = racingRepository.UpdateOne(context.Background(), 2, "New")
+		assert.NoError(t, err)
+		close(gatedRepository.release)
+		<-done
+		assert.Equal(t, 0, racingRepository.Len())
+
+		assert.NoError(t, dbmock.ExpectationsWereMet())
+	})
+
+	t.Run("Bounds", func(t *testing.T) {
+		fake := clock.NewFake(createdAt)
+		cachedRepository := newCachedRepository(repositories.CacheConfig{
+			Size:  2,
+			TTL:   time.Minute,
+			Clock: fake,
+		})
+
+		// Case 1: the least recently used meme coin is evicted
+		for _, id := range []int{1, 2, 3} {
+			dbmock.ExpectQuery(selectStatement).WithArgs(id).WillReturnRows(pgxmock.NewRows(columns).AddRow(id, "Coin", "", createdAt, 0))
+			_, err := cachedRepository.FindOne(context.Background(), id)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 2, cachedRepository.Len())
+
+		dbmock.ExpectQuery(selectStatement).WithArgs(1).WillReturnRows(pgxmock.NewRows(columns).AddRow(1, "Coin", "", createdAt, 0))
+		_, err := cachedRepository.FindOne(context.Background(), 1)
+		assert.NoError(t, err)
+
+		// Case 2: expired meme coins are loaded again
+		fake.Advance(time.Minute + time.Nanosecond)
+		dbmock.ExpectQuery(selectStatement).WithArgs(3).WillReturnRows(pgxmock.NewRows(columns).AddRow(3, "Coin", "", createdAt, 0))
+		_, err = cachedRepository.FindOne(context.Background(), 3)
+		assert.NoError(t, err)
+
+		assert.NoError(t, dbmock.ExpectationsWereMet())
+	})
+
+	t.Run("Stampede", func(t *testing.T) {
+		cachedRepository := newCachedRepository(repositories.CacheConfig{})
+
+		// Case 1: concurrent misses share one query
+		dbmock.ExpectQuery(selectStatement).WithArgs(1).WillReturnRows(pgxmock.NewRows(columns).AddRow(1, "Doge", "Such wow", createdAt, 3)).WillDelayFor(100 * time.Millisecond)
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				memeCoin, err := cachedRepository.FindOne(context.Background(), 1)
+				assert.NoError(t, err)
+				assert.Equal(t, "Such wow", memeCoin.Description)
+			}()
+		}
+		wg.Wait()
+
+		assert.NoError(t, dbmock.ExpectationsWereMet())
+	})
+}
+
+// gatedMemeCoinRepository holds FindOne until release is closed, started is closed once it is called
+type gatedMemeCoinRepository struct {
+	repositories.MemeCoinRepositoryInterface
+	started chan struct{}
+	release chan struct{}
+}
+
+func (repo *gatedMemeCoinRepository) FindOne(ctx context.Context, id int) (*repositories.MemeCoin, error) {
+	close(repo.started)
+	<-repo.release
+
+	return repo.MemeCoinRepositoryInterface.FindOne(ctx, id)
+}
@@ -0,0 +1,269 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"portto-assignment/internal/middlewares"
+	"portto-assignment/pkg/client"
+	"portto-assignment/tests/mocks"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newClientServer serves the real router, failures lets a test answer a request before it reaches the router
+func newClientServer(t *testing.T, failures func(w http.ResponseWriter, req *http.Request) bool) (*client.Client, *[]*http.Request) {
+	router := newDocumentedRouter(loadOpenAPI(t))
+	var mutex sync.Mutex
+	requests := []*http.Request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
+		requests = append(requests, req)
+		mutex.Unlock()
+		if failures != nil && failures(w, req) {
+			return
+		}
+		router.ServeHTTP(w, req)
+	}))
+	t.Cleanup(server.Close)
+
+	return client.NewClient(client.Config{
+		BaseURL:    server.URL + "/",
+		APIKey:     "mc_test",
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 10 * time.Millisecond,
+	}), &requests
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Routes", func(t *testing.T) {
+		memeCoinClient, requests := newClientServer(t, nil)
+
+		// Case 1: single MemeCoin routes
+		memeCoin, err := memeCoinClient.CreateMemeCoin(ctx, "Doge", "Wow")
+		assert.NoError(t, err)
+		assert.Equal(t, "Doge", memeCoin.Name)
+		memeCoin, err = memeCoinClient.GetMemeCoin(ctx, 7, client.WithReadYourWrites())
+		assert.NoError(t, err)
+		assert.Equal(t, 7, memeCoin.Id)
+		memeCoin, err = memeCoinClient.UpdateMemeCoin(ctx, 7, "Such update")
+		assert.NoError(t, err)
+		assert.Equal(t, "Such update", memeCoin.Description)
+		memeCoin, err = memeCoinClient.DeleteMemeCoin(ctx, 7)
+		assert.NoError(t, err)
+		assert.Equal(t, 7, memeCoin.Id)
+		assert.NoError(t, memeCoinClient.PokeMemeCoin(ctx, 7))
+
+		// Case 2: batch routes
+		created, err := memeCoinClient.CreateMemeCoins(ctx, []client.CreateMemeCoinRequestBody{{Name: "Doge"}, {Name: mocks.ExistingMemeCoinName}})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, created.Created)
+		assert.Equal(t, 1, created.Conflicts)
+		found, err := memeCoinClient.GetMemeCoins(ctx, []int{1, 2})
+		assert.NoError(t, err)
+		assert.Len(t, found.MemeCoins, 2)
+		poked, err := memeCoinClient.PokeMemeCoins(ctx, map[int]int{1: 3})
+		assert.NoError(t, err)
+		assert.Len(t, poked.Results, 1)
+
+		// Case 3: the headers of the client and of the options are sent
+		for _, req := range *requests {
+			assert.Equal(t, "mc_test", req.Header.Get(middlewares.APIKeyHeader))
+		}
+		assert.Equal(t, "true", (*requests)[1].Header.Get(middlewares.ReadYourWritesHeader))
+		assert.NotEmpty(t, (*requests)[0].Header.Get(middlewares.IdempotencyKeyHeader))
+		assert.Empty(t, (*requests)[2].Header.Get(middlewares.IdempotencyKeyHeader))
+
+		// Case 4: transfer routes
+		var exported bytes.Buffer
+		assert.NoError(t, memeCoinClient.ExportMemeCoins(ctx, &exported, client.FormatCSV))
+		assert.Equal(t, 4, strings.Count(exported.String(), "\n"))
+		report, err := memeCoinClient.ImportMemeCoins(ctx, strings.NewReader("name,description\nDoge,Wow\n"), client.FormatCSV)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Total)
+
+		// Case 5: health and admin routes
+		readiness, err := memeCoinClient.Ready(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "ready", readiness.Status)
+		level, err := memeCoinClient.SetLogLevel(ctx, "debug")
+		assert.NoError(t, err)
+		assert.Equal(t, "DEBUG", level)
+		level, err = memeCoinClient.GetLogLevel(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "DEBUG", level)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		memeCoinClient, _ := newClientServer(t, nil)
+
+		// Case 1: error statuses are returned as an APIError with the HttpError of the body
+		_, err := memeCoinClient.GetMemeCoin(ctx, 0)
+		var apiError *client.APIError
+		if assert.True(t, errors.As(err, &apiError)) {
+			assert.Equal(t, http.StatusBadRequest, apiError.StatusCode)
+			assert.Equal(t, "Invalid request", apiError.Message)
+			assert.NotEmpty(t, apiError.HttpError.Error)
+		}
+
+		// Case 2: unknown routes have no HttpError body
+		_, err = client.NewClient(client.Config{BaseURL: strings.TrimSuffix(memeCoinClientURL(t), "/") + "/missing"}).GetMemeCoin(ctx, 1)
+		if assert.True(t, errors.As(err, &apiError)) {
+			assert.Equal(t, http.StatusNotFound, apiError.StatusCode)
+			assert.Equal(t, "Not Found", apiError.Message)
+		}
+	})
+
+	t.Run("Iterators", func(t *testing.T) {
+		memeCoinClient, requests := newClientServer(t, nil)
+
+		// Case 1: IDs are fetched a page at a time
+		ids := make([]int, 2*client.PageSize+1)
+		for i := range ids {
+			ids[i] = i + 1
+		}
+		count := 0
+		for memeCoin, err := range memeCoinClient.IterateMemeCoins(ctx, ids) {
+			assert.NoError(t, err)
+			assert.Equal(t, ids[count], memeCoin.Id)
+			count++
+		}
+		assert.Equal(t, len(ids), count)
+		assert.Len(t, *requests, 3)
+
+		// Case 2: stopping early doesn't fetch the next pages
+		*requests = nil
+		for range memeCoinClient.IterateMemeCoins(ctx, ids) {
+			break
+		}
+		assert.Len(t, *requests, 1)
+
+		// Case 3: the whole catalogue is streamed
+		exportedIds := []int{}
+		for memeCoin, err := range memeCoinClient.AllMemeCoins(ctx) {
+			assert.NoError(t, err)
+			exportedIds = append(exportedIds, memeCoin.Id)
+		}
+		assert.Equal(t, []int{1, 2, 3}, exportedIds)
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		memeCoinClient, requests := newClientServer(t, nil)
+
+		// Case 1: tags are put on and removed from MemeCoins
+		for _, id := range []int{1, 2, 3} {
+			tag, err := memeCoinClient.AddTag(ctx, id, "dogs")
+			assert.NoError(t, err)
+			assert.Equal(t, "dogs", tag.Name)
+		}
+		_, err := memeCoinClient.AddTag(ctx, 1, "cute")
+		assert.NoError(t, err)
+		tags, err := memeCoinClient.GetMemeCoinTags(ctx, 1)
+		assert.NoError(t, err)
+		assert.Len(t, tags, 2)
+		assert.NoError(t, memeCoinClient.RemoveTag(ctx, 1, "cute"))
+		err = memeCoinClient.RemoveTag(ctx, 1, "cute")
+		var apiError *client.APIError
+		if assert.True(t, errors.As(err, &apiError)) {
+			assert.Equal(t, http.StatusNotFound, apiError.StatusCode)
+		}
+
+		// Case 2: tags are counted and ranked
+		tagCounts, err := memeCoinClient.ListTags(ctx, "")
+		assert.NoError(t, err)
+		if assert.NotEmpty(t, tagCounts) {
+			assert.Equal(t, "dogs", tagCounts[0].Name)
+			assert.Equal(t, 3, tagCounts[0].Count)
+		}
+		leaderboard, err := memeCoinClient.GetLeaderboard(ctx, "dogs", 2)
+		assert.NoError(t, err)
+		assert.Equal(t, "dogs", leaderboard.Tag)
+		assert.Len(t, leaderboard.Entries, 2)
+
+		// Case 3: the iterator follows next_after through every page
+		*requests = nil
+		ids := []int{}
+		for memeCoin, err := range memeCoinClient.IterateMemeCoinsByTag(ctx, "dogs", 2) {
+			assert.NoError(t, err)
+			ids = append(ids, memeCoin.Id)
+		}
+		assert.Equal(t, []int{1, 2, 3}, ids)
+		assert.Len(t, *requests, 2)
+
+		// Case 4: stopping early doesn't fetch the next pages
+		*requests = nil
+		for range memeCoinClient.IterateMemeCoinsByTag(ctx, "dogs", 2) {
+			break
+		}
+		assert.Len(t, *requests, 1)
+	})
+
+	t.Run("Retries", func(t *testing.T) {
+		// Case 1: 5xx and 429 are retried with the same Idempotency-Key, Retry-After is honoured
+		attempts := 0
+		memeCoinClient, requests := newClientServer(t, func(w http.ResponseWriter, req *http.Request) bool {
+			attempts++
+			switch attempts {
+			case 1:
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return true
+			case 2:
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return true
+			}
+			return false
+		})
+		assert.NoError(t, memeCoinClient.PokeMemeCoin(ctx, 1))
+		assert.Len(t, *requests, 3)
+		key := (*requests)[0].Header.Get(middlewares.IdempotencyKeyHeader)
+		assert.NotEmpty(t, key)
+		for _, req := range *requests {
+			assert.Equal(t, key, req.Header.Get(middlewares.IdempotencyKeyHeader))
+		}
+
+		// Case 2: the given key replaces the generated one
+		*requests = nil
+		assert.NoError(t, memeCoinClient.PokeMemeCoin(ctx, 1, client.WithIdempotencyKey("poke-1")))
+		assert.Equal(t, "poke-1", (*requests)[0].Header.Get(middlewares.IdempotencyKeyHeader))
+
+		// Case 3: the last response is returned once the retries are used up
+		failingClient, requests := newClientServer(t, func(w http.ResponseWriter, req *http.Request) bool {
+			w.WriteHeader(http.StatusBadGateway)
+			return true
+		})
+		_, err := failingClient.GetMemeCoin(ctx, 1)
+		var apiError *client.APIError
+		if assert.True(t, errors.As(err, &apiError)) {
+			assert.Equal(t, http.StatusBadGateway, apiError.StatusCode)
+		}
+		assert.Len(t, *requests, client.DefaultMaxRetries+1)
+
+		// Case 4: streamed imports are sent once
+		*requests = nil
+		_, err = failingClient.ImportMemeCoins(ctx, strings.NewReader("name\nDoge\n"), client.FormatCSV)
+		assert.Error(t, err)
+		assert.Len(t, *requests, 1)
+
+		// Case 5: a cancelled context stops the retries
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = failingClient.GetMemeCoin(cancelledCtx, 1)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+// memeCoinClientURL returns the URL of a server serving the real router
+func memeCoinClientURL(t *testing.T) string {
+	server := httptest.NewServer(newDocumentedRouter(loadOpenAPI(t)))
+	t.Cleanup(server.Close)
+	return server.URL
+}
@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"portto-assignment/pkg/clock"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Now", func(t *testing.T) {
+		fake := clock.NewFake(start)
+
+		// Case 1: the time only moves when advanced
+		assert.Equal(t, start, fake.Now())
+		fake.Advance(time.Hour)
+		assert.Equal(t, start.Add(time.Hour), fake.Now())
+	})
+
+	t.Run("Tickers", func(t *testing.T) {
+		fake := clock.NewFake(start)
+		ticker := fake.NewTicker(time.Minute)
+		assert.Equal(t, 1, fake.Tickers())
+
+		// Case 1: nothing fires before the period has passed
+		fake.Advance(59 * time.Second)
+		assert.Empty(t, ticker.C())
+
+		// Case 2: the tick carries the time it was due at
+		fake.Advance(time.Second)
+		assert.Equal(t, start.Add(time.Minute), <-ticker.C())
+
+		// Case 3: ticks that aren't read are dropped, as with time.Ticker
+		fake.Advance(5 * time.Minute)
+		assert.Equal(t, start.Add(2*time.Minute), <-ticker.C())
+		assert.Empty(t, ticker.C())
+
+		// Case 4: a stopped ticker no longer fires
+		ticker.Stop()
+		assert.Equal(t, 0, fake.Tickers())
+		fake.Advance(time.Hour)
+		assert.Empty(t, ticker.C())
+	})
+}
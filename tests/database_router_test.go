@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"portto-assignment/config"
+	"portto-assignment/internal/middlewares"
+	"portto-assignment/internal/repositories"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabaseRouter(t *testing.T) {
+	primary, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+	healthyReplica, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer healthyReplica.Close()
+	unhealthyReplica, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unhealthyReplica.Close()
+
+	selectStatement := regexp.QuoteMeta("SELECT id, name, description, created_at, popularity_score FROM meme_coins WHERE id = $1")
+	createdAt := time.Now()
+	columns := []string{"id", "name", "description", "created_at", "popularity_score"}
+
+	t.Run("Without replicas", func(t *testing.T) {
+		// Case 1: the primary serves the reads
+		router := repositories.NewDatabaseRouter(primary, nil, repositories.DatabaseRouterConfig{})
+		assert.Equal(t, config.DatabaseConnectionPoolInterface(primary), router.Reader(context.Background()))
+
+		healthy, total := router.ReplicaStatus()
+		assert.Equal(t, 0, healthy)
+		assert.Equal(t, 0, total)
+	})
+
+	t.Run("With replicas", func(t *testing.T) {
+		healthyReplica.ExpectPing()
+		unhealthyReplica.ExpectPing().WillReturnError(errors.New("connection refused"))
+		router := repositories.NewDatabaseRouter(primary, []config.DatabaseConnectionPoolInterface{healthyReplica, unhealthyReplica}, repositories.DatabaseRouterConfig{
+			HealthCheckInterval: time.Hour,
+		})
+
+		healthy, total := router.ReplicaStatus()
+		assert.Equal(t, 1, healthy)
+		assert.Equal(t, 2, total)
+
+		// Case 1: reads skip the unhealthy replica
+		repo := repositories.NewRoutedMemeCoinRepository(router)
+		for i := 0; i < 2; i++ {
+			healthyReplica.ExpectQuery(selectStatement).WithArgs(1).WillReturnRows(pgxmock.NewRows(columns).AddRow(1, "Doge", "Such wow", createdAt, 3))
+			memeCoin, err := repo.FindOne(context.Background(), 1)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, memeCoin.Id)
+		}
+
+		// Case 2: reads asking for their own writes go to the primary
+		primary.ExpectQuery(selectStatement).WithArgs(1).WillReturnRows(pgxmock.NewRows(columns).AddRow(1, "Doge", "Such wow", createdAt, 4))
+		memeCoin, err := repo.FindOne(repositories.WithPrimaryReads(context.Background()), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, memeCoin.PopularityScore)
+
+		// Case 3: writes always go to the primary
+		primary.ExpectQuery(regexp.QuoteMeta("UPDATE meme_coins SET description = $2")).WithArgs(1, "Much wow").WillReturnRows(pgxmock.NewRows(columns).AddRow(1, "Doge", "Much wow", createdAt, 4))
+		memeCoin, err = repo.UpdateOne(context.Background(), 1, "Much wow")
+		assert.NoError(t, err)
+		assert.Equal(t, "Much wow", memeCoin.Description)
+
+		// Case 4: the primary serves the reads when no replica is healthy
+		healthyReplica.ExpectPing().WillReturnError(errors.New("connection refused"))
+		unhealthyReplica.ExpectPing().WillReturnError(errors.New("connection refused"))
+		router.CheckReplicas()
+		assert.Equal(t, config.DatabaseConnectionPoolInterface(primary), router.Reader(context.Background()))
+
+		// Case 5: a replica answering again serves the reads
+		healthyReplica.ExpectPing()
+		unhealthyReplica.ExpectPing().WillReturnError(errors.New("connection refused"))
+		router.CheckReplicas()
+		assert.Equal(t, config.DatabaseConnectionPoolInterface(healthyReplica), router.Reader(context.Background()))
+
+		// Case 6: tag reads go to the replicas and tag writes to the primary
+		tagRepo := repositories.NewRoutedTagRepository(router)
+		healthyReplica.ExpectQuery(regexp.QuoteMeta("SELECT tags.id, tags.name, categories.name, tags.created_at, COUNT(meme_coin_tags.meme_coin_id)")).WithArgs("").
+			WillReturnRows(pgxmock.NewRows([]string{"id", "name", "category", "created_at", "count"}).AddRow(1, "dogs", nil, createdAt, 2))
+		tagCounts, err := tagRepo.CountAll(context.Background(), "")
+		assert.NoError(t, err)
+		assert.Len(t, tagCounts, 1)
+		primary.ExpectExec(regexp.QuoteMeta("DELETE FROM meme_coin_tags")).WithArgs(1, "dogs").WillReturnResult(pgxmock.NewResult("DELETE", 1))
+		removed, err := tagRepo.RemoveOne(context.Background(), 1, "dogs")
+		assert.NoError(t, err)
+		assert.True(t, removed)
+
+		assert.NoError(t, primary.ExpectationsWereMet())
+		assert.NoError(t, healthyReplica.ExpectationsWereMet())
+		assert.NoError(t, unhealthyReplica.ExpectationsWereMet())
+	})
+}
+
+func TestReadYourWritesMiddleware(t *testing.T) {
+	router := gin.New()
+	router.Use(middlewares.NewReadYourWritesMiddleware().Handle)
+	router.GET("/", func(context *gin.Context) {
+		context.JSON(http.StatusOK, gin.H{"primary": repositories.PrimaryReads(context.Request.Context())})
+	})
+
+	// Case 1: reads go to the replicas by default
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	router.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"primary": false}`, w.Body.String())
+
+	// Case 2: the header sends the reads to the primary
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.Header.Set(middlewares.ReadYourWritesHeader, "true")
+	router.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"primary": true}`, w.Body.String())
+
+	// Case 3: an invalid value is ignored
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.Header.Set(middlewares.ReadYourWritesHeader, "maybe")
+	router.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"primary": false}`, w.Body.String())
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	docs "portto-assignment/api"
+	"portto-assignment/assets"
+	"portto-assignment/internal/handlers"
+	"portto-assignment/internal/routes"
+	"portto-assignment/internal/services"
+	"portto-assignment/tests/mocks"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The tests run from ./tests, where the files used to be missing when read from the working directory
+func TestEmbeddedAssets(t *testing.T) {
+	t.Run("Migrations", func(t *testing.T) {
+		// Case 1: the migrations are embedded in file name order
+		entries, err := fs.ReadDir(assets.Migrations(), ".")
+		assert.NoError(t, err)
+		if assert.NotEmpty(t, entries) {
+			assert.Equal(t, "0001_create_meme_coins.sql", entries[0].Name())
+		}
+	})
+
+	t.Run("Docs", func(t *testing.T) {
+		memeCoinService := services.NewMemeCoinService(&mocks.MockMemeCoinRepository{}, &mocks.MockRedisCachedRepository{}, nil, nil)
+		router := routes.NewRouter(handlers.NewMemeCoinHandler(memeCoinService, nil), routes.RouterConfig{})
+		get := func(path string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			// gin-swagger routes on the raw request URI, which only the server sets
+			req.RequestURI = path
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		// Case 1: the OpenAPI document is served as is and through Swagger UI
+		w := get("/v1/openapi.yaml")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "openapi: 3.0.3")
+		w = get("/v1/docs/doc.json")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "openapi: 3.0.3")
+
+		// Case 2: Swagger UI loads its scripts from the binary
+		w = get("/v1/docs/index.html")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "https://")
+		w = get("/v1/docs/swagger-ui-bundle.js")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// Case 3: the ReDoc page loads the bundle and the document from the binary
+		w = get("/v1/redoc")
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		w = get("/v1/redoc/")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `spec-url="../openapi.yaml"`)
+		assert.False(t, strings.Contains(w.Body.String(), "https://"))
+
+		// Case 4: only the page and the bundle are served
+		w = get("/v1/redoc/README.md")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = get("/v1/redoc/" + docs.ReDocChecksumFile)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		// Case 5: a bundle that isn't the pinned one is refused
+		assert.Error(t, docs.VerifyReDoc([]byte("alert(1)")))
+	})
+}
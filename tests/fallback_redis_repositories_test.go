@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/internal/services"
+	"portto-assignment/tests/mocks"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// fixedScoreMemeCoinRepository reads every meme coin with the same score, so that the scores copied to Redis are known
+type fixedScoreMemeCoinRepository struct {
+	*mocks.MockMemeCoinRepository
+	score int
+}
+
+func (r *fixedScoreMemeCoinRepository) FindOne(ctx context.Context, id int) (*repositories.MemeCoin, error) {
+	memeCoin, err := r.MockMemeCoinRepository.FindOne(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	memeCoin.PopularityScore = r.score
+
+	return memeCoin, nil
+}
+
+func TestFallbackRedisRepository(t *testing.T) {
+	dbmock, err := pgxmock.NewPool(pgxmock.QueryMatcherOption(pgxmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbmock.Close()
+
+	mockRedisClient, redismock := redismock.NewClientMock()
+	defer mockRedisClient.Close()
+
+	redisCachedRepository := repositories.NewRedisCachedRepository(dbmock, mockRedisClient, repositories.RepositoryConfig{
+		NeedToSync: false,
+	})
+	errConnectionRefused := errors.New("connection refused")
+	key := "meme:popularity_score:1"
+
+	// Case 1: Redis is up at boot
+	redismock.ExpectPing().SetVal("PONG")
+	fallbackRepository := repositories.NewFallbackRedisRepository(redisCachedRepository, &fixedScoreMemeCoinRepository{MockMemeCoinRepository: &mocks.MockMemeCoinRepository{}, score: 7}, repositories.FallbackConfig{
+		FailureThreshold: 2,
+		RecoveryInterval: time.Hour,
+	})
+	assert.Equal(t, repositories.RedisStatusUp, fallbackRepository.Status())
+
+	redismock.ExpectExists(key).SetVal(1)
+	exists, err := fallbackRepository.Exists(context.Background(), key)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	// Case 2: failed reads are answered by the database until the circuit opens
+	redismock.ExpectExists(key).SetErr(errConnectionRefused)
+	exists, err = fallbackRepository.Exists(context.Background(), key)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, repositories.RedisStatusUp, fallbackRepository.Status())
+
+	redismock.ExpectGet(key).SetErr(errConnectionRefused)
+	value, found, err := fallbackRepository.Get(context.Background(), key)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Greater(t, value, 0)
+	assert.Equal(t, repositories.RedisStatusDown, fallbackRepository.Status())
+
+	// Case 3: the database serves everything while the circuit is open
+	err = fallbackRepository.IncrBy(context.Background(), key, 1)
+	assert.NoError(t, err)
+
+	existsMap, err := fallbackRepository.ExistsMany(context.Background(), []string{key, "meme:popularity_score:0"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{key: true, "meme:popularity_score:0": false}, existsMap)
+
+	err = fallbackRepository.Set(context.Background(), key, 10)
+	assert.NoError(t, err)
+
+	err = fallbackRepository.IncrBy(context.Background(), "unknown_key", 1)
+	assert.Error(t, err)
+
+	// Case 4: Redis is still down
+	redismock.ExpectPing().SetErr(errConnectionRefused)
+	err = fallbackRepository.Recover()
+	assert.Error(t, err)
+	assert.Equal(t, repositories.RedisStatusDown, fallbackRepository.Status())
+
+	// Case 5: Redis is re-warmed from the database when it comes back, the coins written while it was down are copied over
+	redismock.ExpectPing().SetVal("PONG")
+	redismock.ExpectScan(0, "meme:popularity_score:*", repositories.DefaultSyncBatchSize).SetVal([]string{key}, 0)
+	redismock.ExpectGet(key).SetVal("3")
+	dbmock.ExpectQuery("SELECT id, popularity_score FROM meme_coins ORDER BY id").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "popularity_score"}).AddRow(1, 5))
+	redismock.ExpectHMGet(unsyncedKey, key).SetVal([]interface{}{nil})
+	redismock.ExpectEval(compareAndSetScript, []string{key}, 3, 5).SetVal(int64(1))
+	redismock.ExpectSet(key, 7, 0).SetVal("OK")
+	err = fallbackRepository.Recover()
+	assert.NoError(t, err)
+	assert.Equal(t, repositories.RedisStatusUp, fallbackRepository.Status())
+
+	// Case 6: a skipped cache write opens the circuit right away
+	redismock.ExpectSet(key, 10, 0).SetErr(errConnectionRefused)
+	err = fallbackRepository.Set(context.Background(), key, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, repositories.RedisStatusDown, fallbackRepository.Status())
+
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+	assert.NoError(t, redismock.ExpectationsWereMet())
+}
+
+func TestHealthService(t *testing.T) {
+	redisStatus := &mocks.MockRedisStatus{RedisStatus: repositories.RedisStatusUp}
+	database := &mocks.MockDatabaseHealth{}
+	healthService := services.NewHealthService(database, redisStatus, nil)
+
+	// Case 1: everything is up
+	readiness := healthService.Readiness()
+	assert.Equal(t, services.ReadinessStatusReady, readiness.Status)
+
+	// Case 2: Redis is down
+	redisStatus.RedisStatus = repositories.RedisStatusDown
+	readiness = healthService.Readiness()
+	assert.Equal(t, services.ReadinessStatusDegraded, readiness.Status)
+	assert.Equal(t, repositories.RedisStatusDown, readiness.Redis)
+
+	// Case 3: the database is down
+	database.Err = errors.New("connection refused")
+	readiness = healthService.Readiness()
+	assert.Equal(t, services.ReadinessStatusUnavailable, readiness.Status)
+	assert.Equal(t, services.DependencyStatusDown, readiness.Database)
+}
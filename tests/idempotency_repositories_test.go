@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"encoding/json"
+	"portto-assignment/internal/repositories"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type IdempotencyRepositoryTest struct {
+	redismock             redismock.ClientMock
+	idempotencyRepository *repositories.IdempotencyRepository
+}
+
+func TestIdempotencyRepository(t *testing.T) {
+	mockRedisClient, redismock := redismock.NewClientMock()
+	defer mockRedisClient.Close()
+
+	idempotencyRepositoryTest := IdempotencyRepositoryTest{
+		redismock:             redismock,
+		idempotencyRepository: repositories.NewIdempotencyRepository(mockRedisClient, nil),
+	}
+
+	t.Run("TestReserve", idempotencyRepositoryTest.testReserve)
+	t.Run("TestSave", idempotencyRepositoryTest.testSave)
+	t.Run("TestRelease", idempotencyRepositoryTest.testRelease)
+
+	err := redismock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func (r *IdempotencyRepositoryTest) testReserve(t *testing.T) {
+	key := "test_key"
+	inProgressRecord, _ := json.Marshal(repositories.IdempotencyRecord{Fingerprint: "fingerprint"})
+	completedRecord, _ := json.Marshal(repositories.IdempotencyRecord{
+		Fingerprint: "fingerprint",
+		Completed:   true,
+		StatusCode:  200,
+		ContentType: "application/json",
+		Body:        []byte(`{}`),
+	})
+
+	// Case 1: the key is free
+	r.redismock.ExpectSetNX("meme:idempotency:"+key, inProgressRecord, time.Minute).SetVal(true)
+	record, reserved, err := r.idempotencyRepository.Reserve(key, "fingerprint", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+	assert.Nil(t, record)
+
+	// Case 2: the key is taken by a completed request
+	r.redismock.ExpectSetNX("meme:idempotency:"+key, inProgressRecord, time.Minute).SetVal(false)
+	r.redismock.ExpectGet("meme:idempotency:" + key).SetVal(string(completedRecord))
+	record, reserved, err = r.idempotencyRepository.Reserve(key, "fingerprint", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, &repositories.IdempotencyRecord{
+		Fingerprint: "fingerprint",
+		Completed:   true,
+		StatusCode:  200,
+		ContentType: "application/json",
+		Body:        []byte(`{}`),
+	}, record)
+}
+
+func (r *IdempotencyRepositoryTest) testSave(t *testing.T) {
+	key := "test_key"
+	record := repositories.IdempotencyRecord{
+		Fingerprint: "fingerprint",
+		Completed:   true,
+		StatusCode:  204,
+	}
+	value, _ := json.Marshal(record)
+	r.redismock.ExpectSet("meme:idempotency:"+key, value, time.Hour).SetVal("OK")
+
+	err := r.idempotencyRepository.Save(key, record, time.Hour)
+	assert.NoError(t, err)
+}
+
+func (r *IdempotencyRepositoryTest) testRelease(t *testing.T) {
+	key := "test_key"
+	r.redismock.ExpectDel("meme:idempotency:" + key).SetVal(1)
+
+	err := r.idempotencyRepository.Release(key)
+	assert.NoError(t, err)
+}
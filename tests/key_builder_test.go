@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"context"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/internal/services"
+	"portto-assignment/tests/mocks"
+	"testing"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyBuilder(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		// Case 1: the default keys are the ones used before namespaces were configurable
+		keys := repositories.DefaultKeyBuilder()
+		assert.Equal(t, "meme:popularity_score:1", keys.PopularityScore(1))
+		assert.Equal(t, "meme:popularity_score:*", keys.PopularityScorePattern())
+		assert.Equal(t, "meme:ps:2", keys.ScoreBucket(2))
+		assert.Equal(t, "meme:ps:*", keys.ScoreBucketPattern())
+		assert.Equal(t, "meme:idempotency:abc", keys.Idempotency("abc"))
+		assert.Equal(t, "meme:cache:invalidate", keys.CacheInvalidationChannel())
+		assert.Equal(t, "meme:unsynced_scores", keys.UnsyncedPopularityScores())
+
+		// Case 2: an empty config builds the default keys
+		keys, err := repositories.NewKeyBuilder(repositories.KeyBuilderConfig{})
+		assert.NoError(t, err)
+		assert.Equal(t, "meme:popularity_score:1", keys.PopularityScore(1))
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		// Case 1: the environment comes before the namespace
+		keys, err := repositories.NewKeyBuilder(repositories.KeyBuilderConfig{Namespace: "coin", Environment: "staging"})
+		assert.NoError(t, err)
+		assert.Equal(t, "staging:coin:popularity_score:1", keys.PopularityScore(1))
+		assert.Equal(t, "staging:coin:ps:*", keys.ScoreBucketPattern())
+
+		// Case 2: hash tags wrap the whole prefix so every key lands in the same cluster slot
+		keys, err = repositories.NewKeyBuilder(repositories.KeyBuilderConfig{Environment: "prod", HashTags: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "{prod:meme}:popularity_score:1", keys.PopularityScore(1))
+		assert.Equal(t, "{prod:meme}:idempotency:abc", keys.Idempotency("abc"))
+
+		// Case 3: glob and hash tag characters are refused
+		for _, namespace := range []string{"me*me", "meme?", "[meme]", "{meme}", "me me", `me\me`} {
+			_, err = repositories.NewKeyBuilder(repositories.KeyBuilderConfig{Namespace: namespace})
+			assert.Error(t, err, namespace)
+		}
+	})
+
+	t.Run("ParsePopularityScore", func(t *testing.T) {
+		keys, err := repositories.NewKeyBuilder(repositories.KeyBuilderConfig{Environment: "prod", HashTags: true})
+		assert.NoError(t, err)
+
+		// Case 1: key of the builder
+		id, err := keys.ParsePopularityScore("{prod:meme}:popularity_score:42")
+		assert.NoError(t, err)
+		assert.Equal(t, 42, id)
+
+		// Case 2: key of another namespace
+		_, err = keys.ParsePopularityScore("meme:popularity_score:42")
+		assert.Error(t, err)
+
+		// Case 3: key without an id
+		_, err = keys.ParsePopularityScore("{prod:meme}:popularity_score:abc")
+		assert.Error(t, err)
+	})
+
+	t.Run("Repository", func(t *testing.T) {
+		mockRedisClient, redismock := redismock.NewClientMock()
+		defer mockRedisClient.Close()
+
+		keys, err := repositories.NewKeyBuilder(repositories.KeyBuilderConfig{Environment: "prod", HashTags: true})
+		assert.NoError(t, err)
+		redisCachedRepository := repositories.NewRedisCachedRepository(nil, mockRedisClient, repositories.RepositoryConfig{
+			NeedToSync:  false,
+			ScoreLayout: repositories.ScoreLayoutHash,
+			Keys:        keys,
+		})
+
+		// Case 1: buckets are named with the prefix of the builder
+		redismock.ExpectHIncrBy("{prod:meme}:unsynced_scores", keys.PopularityScore(1234), 1).SetVal(1)
+		redismock.ExpectHIncrBy("{prod:meme}:ps:1", "1234", 2).SetVal(2)
+		assert.NoError(t, redisCachedRepository.IncrBy(context.Background(), keys.PopularityScore(1234), 2))
+
+		// Case 2: keys of another namespace can't be placed in a bucket
+		err = redisCachedRepository.IncrBy(context.Background(), "meme:popularity_score:1234", 2)
+		assert.Error(t, err)
+
+		assert.NoError(t, redismock.ExpectationsWereMet())
+	})
+
+	t.Run("Service", func(t *testing.T) {
+		mockRedisCachedRepository := &mocks.MockMemoryRedisRepository{}
+		keys, err := repositories.NewKeyBuilder(repositories.KeyBuilderConfig{Environment: "prod"})
+		assert.NoError(t, err)
+		memeCoinService := services.NewMemeCoinService(&mocks.MockMemeCoinRepository{}, mockRedisCachedRepository, keys, nil)
+
+		// Case 1: pokes increment the key of the builder
+		assert.NoError(t, mockRedisCachedRepository.Set(context.Background(), "prod:meme:popularity_score:1", 0))
+		err = memeCoinService.PokeMemeCoin(context.Background(), 1)
+		assert.NoError(t, err)
+		value, found, err := mockRedisCachedRepository.Get(context.Background(), "prod:meme:popularity_score:1")
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, 1, value)
+	})
+}
@@ -0,0 +1,238 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"portto-assignment/internal/handlers"
+	"portto-assignment/internal/logging"
+	"portto-assignment/internal/middlewares"
+	"portto-assignment/internal/routes"
+	"portto-assignment/internal/services"
+	"portto-assignment/tests/mocks"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// logLines decodes the JSON lines written by a logger
+func logLines(t *testing.T, output *bytes.Buffer) []map[string]any {
+	lines := []map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, record)
+	}
+	output.Reset()
+
+	return lines
+}
+
+func TestLogger(t *testing.T) {
+	t.Run("Parse", func(t *testing.T) {
+		// Case 1: defaults
+		level, err := logging.ParseLevel("")
+		assert.NoError(t, err)
+		assert.Equal(t, slog.LevelInfo, level)
+		format, err := logging.ParseFormat("")
+		assert.NoError(t, err)
+		assert.Equal(t, logging.FormatJSON, format)
+
+		// Case 2: names are case insensitive
+		level, err = logging.ParseLevel("DEBUG")
+		assert.NoError(t, err)
+		assert.Equal(t, slog.LevelDebug, level)
+		format, err = logging.ParseFormat("Text")
+		assert.NoError(t, err)
+		assert.Equal(t, logging.FormatText, format)
+
+		// Case 3: unknown names
+		_, err = logging.ParseLevel("verbose")
+		assert.Error(t, err)
+		_, err = logging.ParseFormat("xml")
+		assert.Error(t, err)
+	})
+
+	t.Run("RequestID", func(t *testing.T) {
+		var output bytes.Buffer
+		logger := logging.NewLogger(logging.LoggerConfig{Output: &output})
+
+		// Case 1: the request id of the context is added to the record, also through derived loggers
+		ctx := logging.WithRequestID(context.Background(), "abc")
+		logger.With("component", "test").InfoContext(ctx, "Hello", "id", 1)
+		lines := logLines(t, &output)
+		assert.Len(t, lines, 1)
+		assert.Equal(t, "Hello", lines[0]["msg"])
+		assert.Equal(t, "abc", lines[0][logging.RequestIDAttribute])
+		assert.Equal(t, "test", lines[0]["component"])
+
+		// Case 2: records without a request context have no request id
+		logger.Info("Hello")
+		lines = logLines(t, &output)
+		assert.Len(t, lines, 1)
+		assert.NotContains(t, lines[0], logging.RequestIDAttribute)
+	})
+
+	t.Run("Level", func(t *testing.T) {
+		var output bytes.Buffer
+		level := new(slog.LevelVar)
+		level.Set(slog.LevelWarn)
+		logger := logging.NewLogger(logging.LoggerConfig{Level: level, Output: &output})
+
+		// Case 1: records below the level are dropped
+		logger.Info("Dropped")
+		logger.Warn("Kept")
+		lines := logLines(t, &output)
+		assert.Len(t, lines, 1)
+		assert.Equal(t, "Kept", lines[0]["msg"])
+
+		// Case 2: changing the level applies to the existing logger
+		level.Set(slog.LevelDebug)
+		logger.Debug("Kept")
+		assert.Len(t, logLines(t, &output), 1)
+	})
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	router := gin.New()
+	router.Use(middlewares.NewRequestIDMiddleware().Handle)
+	router.GET("/", func(context *gin.Context) {
+		context.JSON(http.StatusOK, gin.H{"request_id": logging.RequestID(context.Request.Context())})
+	})
+
+	// Case 1: the id of the client is kept
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(middlewares.RequestIDHeader, "client-id-1")
+	router.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"request_id": "client-id-1"}`, w.Body.String())
+	assert.Equal(t, "client-id-1", w.Header().Get(middlewares.RequestIDHeader))
+
+	// Case 2: an id is generated when the client doesn't send one
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	router.ServeHTTP(w, req)
+	generatedId := w.Header().Get(middlewares.RequestIDHeader)
+	assert.Len(t, generatedId, 32)
+	assert.JSONEq(t, `{"request_id": "`+generatedId+`"}`, w.Body.String())
+
+	// Case 3: ids with spaces or over the length limit are replaced
+	for _, invalidId := range []string{"two words", strings.Repeat("a", middlewares.MaxRequestIDLength+1)} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/", nil)
+		req.Header.Set(middlewares.RequestIDHeader, invalidId)
+		router.ServeHTTP(w, req)
+		assert.NotEqual(t, invalidId, w.Header().Get(middlewares.RequestIDHeader))
+		assert.Len(t, w.Header().Get(middlewares.RequestIDHeader), 32)
+	}
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	var output bytes.Buffer
+	logger := logging.NewLogger(logging.LoggerConfig{Output: &output})
+	newRouter := func(sampleRate float64) *gin.Engine {
+		router := gin.New()
+		router.Use(middlewares.NewRequestIDMiddleware().Handle)
+		router.Use(middlewares.NewAccessLogMiddleware(logger, middlewares.AccessLogConfig{SampleRate: sampleRate}).Handle)
+		router.GET("/coins/:id", func(context *gin.Context) {
+			context.Status(http.StatusOK)
+		})
+		router.GET("/fail", func(context *gin.Context) {
+			context.Status(http.StatusInternalServerError)
+		})
+		return router
+	}
+
+	// Case 1: every request is logged by default, with its request id
+	router := newRouter(0)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/coins/1", nil)
+	req.Header.Set(middlewares.RequestIDHeader, "access-1")
+	router.ServeHTTP(w, req)
+	lines := logLines(t, &output)
+	assert.Len(t, lines, 1)
+	assert.Equal(t, "INFO", lines[0]["level"])
+	assert.Equal(t, "GET", lines[0]["method"])
+	assert.Equal(t, "/coins/1", lines[0]["path"])
+	assert.Equal(t, "/coins/:id", lines[0]["route"])
+	assert.Equal(t, float64(http.StatusOK), lines[0]["status"])
+	assert.Equal(t, "access-1", lines[0][logging.RequestIDAttribute])
+
+	// Case 2: with a tiny sample rate, successful requests are skipped but server errors are logged
+	router = newRouter(1e-12)
+	for i := 0; i < 10; i++ {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/coins/1", nil)
+		router.ServeHTTP(w, req)
+	}
+	assert.Empty(t, logLines(t, &output))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/fail", nil)
+	router.ServeHTTP(w, req)
+	lines = logLines(t, &output)
+	assert.Len(t, lines, 1)
+	assert.Equal(t, "ERROR", lines[0]["level"])
+}
+
+func TestLogLevelRoutes(t *testing.T) {
+	level := new(slog.LevelVar)
+	memeCoinService := services.NewMemeCoinService(&mocks.MockMemeCoinRepository{}, &mocks.MockRedisCachedRepository{}, nil, nil)
+	router := routes.NewRouter(handlers.NewMemeCoinHandler(memeCoinService, nil), routes.RouterConfig{
+		Authentication: mocks.MockAuthentication{},
+		LogLevel:       handlers.NewLogLevelHandler(level),
+	})
+
+	// Case 1: the current level is reported
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/log-level", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level": "INFO"}`, w.Body.String())
+
+	// Case 2: the level is changed
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/admin/log-level", strings.NewReader(`{"level": "debug"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level": "DEBUG"}`, w.Body.String())
+	assert.Equal(t, slog.LevelDebug, level.Level())
+
+	// Case 3: unknown levels are refused and the level is kept
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/admin/log-level", strings.NewReader(`{"level": "verbose"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, slog.LevelDebug, level.Level())
+
+	// Case 4: the routes need an API key when authentication is enabled
+	authenticatedRouter := routes.NewRouter(handlers.NewMemeCoinHandler(memeCoinService, nil), routes.RouterConfig{
+		Authentication: middlewares.NewAPIKeyMiddleware(services.NewAPIKeyService(&mocks.MockAPIKeyRepository{}), nil),
+		LogLevel:       handlers.NewLogLevelHandler(level),
+	})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/admin/log-level", strings.NewReader(`{"level": "error"}`))
+	authenticatedRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, slog.LevelDebug, level.Level())
+
+	// Case 5: the routes are not served at all without authentication
+	unauthenticatedRouter := routes.NewRouter(handlers.NewMemeCoinHandler(memeCoinService, nil), routes.RouterConfig{
+		LogLevel: handlers.NewLogLevelHandler(level),
+	})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/admin/log-level", strings.NewReader(`{"level": "error"}`))
+	unauthenticatedRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, slog.LevelDebug, level.Level())
+}
@@ -3,15 +3,21 @@ package tests
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"portto-assignment/internal/handlers"
+	"portto-assignment/internal/middlewares"
+	"portto-assignment/internal/repositories"
 	"portto-assignment/internal/routes"
 	"portto-assignment/internal/services"
+	"portto-assignment/pkg/clock"
 	"portto-assignment/tests/mocks"
 
 	"github.com/gin-gonic/gin"
@@ -19,6 +25,10 @@ import (
 )
 
 var router *gin.Engine
+var mockIdempotencyRepository *mocks.MockIdempotencyRepository
+
+// testClock stamps the meme coins of the mock repository, tests advance it instead of sleeping
+var testClock *clock.Fake
 
 func TestEndpoints(t *testing.T) {
 	buildTestService()
@@ -28,6 +38,113 @@ func TestEndpoints(t *testing.T) {
 	t.Run("GET /v1/meme-coin/:id", testGetMemeCoinEndpoint)
 	t.Run("DELETE /v1/meme-coin/:id", testDeleteMemeCoinEndpoint)
 	t.Run("POST /v1/meme-coin/:id/pock", testPockMemeCoinEndpoint)
+	t.Run("POST /v1/meme-coin/batch", testBatchCreateMemeCoinsEndpoint)
+	t.Run("GET /v1/meme-coin", testBatchGetMemeCoinsEndpoint)
+	t.Run("POST /v1/meme-coin/pokes", testBatchPokeMemeCoinsEndpoint)
+	t.Run("Idempotency-Key", testIdempotencyKey)
+	t.Run("GET /v1/meme-coin/export", testExportMemeCoinsEndpoint)
+	t.Run("POST /v1/meme-coin/import", testImportMemeCoinsEndpoint)
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	// Router with Redis reported as down
+	healthService := services.NewHealthService(&mocks.MockDatabaseHealth{}, &mocks.MockRedisStatus{RedisStatus: repositories.RedisStatusDown}, nil)
+	memeCoinService := services.NewMemeCoinService(&mocks.MockMemeCoinRepository{}, &mocks.MockRedisCachedRepository{}, nil, nil)
+	healthRouter := routes.NewRouter(handlers.NewMemeCoinHandler(memeCoinService, nil), routes.RouterConfig{
+		Health: handlers.NewHealthHandler(healthService),
+	})
+
+	// Case 1: liveness
+	liveCaseRecorder := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/health/live", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	healthRouter.ServeHTTP(liveCaseRecorder, req)
+	assert.Equal(t, http.StatusOK, liveCaseRecorder.Code)
+
+	// Case 2: readiness stays ready but reports the degraded state
+	readyCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/health/ready", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	healthRouter.ServeHTTP(readyCaseRecorder, req)
+
+	resJSON := map[string]any{}
+	json.Unmarshal(readyCaseRecorder.Body.Bytes(), &resJSON)
+	assert.Equal(t, http.StatusOK, readyCaseRecorder.Code)
+	assert.Equal(t, services.ReadinessStatusDegraded, resJSON["status"])
+	assert.Equal(t, string(repositories.RedisStatusDown), resJSON["redis"])
+
+	// Case 3: database pool statistics
+	databaseCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/health/database", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	healthRouter.ServeHTTP(databaseCaseRecorder, req)
+
+	resJSON = map[string]any{}
+	json.Unmarshal(databaseCaseRecorder.Body.Bytes(), &resJSON)
+	assert.Equal(t, http.StatusOK, databaseCaseRecorder.Code)
+	assert.Contains(t, resJSON, "acquired_conns")
+	assert.Contains(t, resJSON, "max_conns")
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	// Router with API key authentication enabled
+	apiKeyService := services.NewAPIKeyService(&mocks.MockAPIKeyRepository{})
+	memeCoinService := services.NewMemeCoinService(&mocks.MockMemeCoinRepository{}, &mocks.MockRedisCachedRepository{}, nil, nil)
+	authenticatedRouter := routes.NewRouter(handlers.NewMemeCoinHandler(memeCoinService, nil), routes.RouterConfig{
+		Authentication: middlewares.NewAPIKeyMiddleware(apiKeyService, nil),
+	})
+	createdAPIKey, err := apiKeyService.CreateAPIKey("tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Case 1: write endpoint without a key
+	noKeyCaseRecorder := httptest.NewRecorder()
+	req, err := http.NewRequest("DELETE", "/v1/meme-coin/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticatedRouter.ServeHTTP(noKeyCaseRecorder, req)
+
+	resJSON := map[string]any{}
+	json.Unmarshal(noKeyCaseRecorder.Body.Bytes(), &resJSON)
+	assert.Equal(t, http.StatusUnauthorized, noKeyCaseRecorder.Code)
+	assert.Equal(t, "Unauthorized", resJSON["message"])
+
+	// Case 2: write endpoint with an unknown key
+	wrongKeyCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("DELETE", "/v1/meme-coin/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(middlewares.APIKeyHeader, createdAPIKey.Key+"x")
+	authenticatedRouter.ServeHTTP(wrongKeyCaseRecorder, req)
+	assert.Equal(t, http.StatusUnauthorized, wrongKeyCaseRecorder.Code)
+
+	// Case 3: write endpoint with a valid key
+	validKeyCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("DELETE", "/v1/meme-coin/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(middlewares.APIKeyHeader, createdAPIKey.Key)
+	authenticatedRouter.ServeHTTP(validKeyCaseRecorder, req)
+	assert.Equal(t, http.StatusOK, validKeyCaseRecorder.Code)
+
+	// Case 4: read endpoints stay public
+	publicCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/v1/meme-coin/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticatedRouter.ServeHTTP(publicCaseRecorder, req)
+	assert.Equal(t, http.StatusOK, publicCaseRecorder.Code)
 }
 
 func testCreateMemeCoinEndpoint(t *testing.T) {
@@ -52,14 +169,12 @@ func testCreateMemeCoinEndpoint(t *testing.T) {
 		"name": "name",
 	}
 	requestBodyJSON, _ := json.Marshal(requestBody)
-	timeBefore := time.Now()
 	http.Header.Add(req.Header, "Content-Type", "application/json")
 	req, err = http.NewRequest("POST", "/v1/meme-coin/create", bytes.NewReader(requestBodyJSON))
 	if err != nil {
 		t.Fatal(err)
 	}
 	router.ServeHTTP(noDescriptionInRequestCaseRecorder, req)
-	timeAfter := time.Now()
 	resJSONstr = noDescriptionInRequestCaseRecorder.Body.String()
 	resJSON = map[string]any{}
 	json.Unmarshal([]byte(resJSONstr), &resJSON)
@@ -70,8 +185,7 @@ func testCreateMemeCoinEndpoint(t *testing.T) {
 	assert.Greater(t, int(resJSON["id"].(float64)), 0)
 	assert.Greater(t, int(resJSON["popularity_score"].(float64)), 0)
 	assert.Less(t, int(resJSON["popularity_score"].(float64)), 100)
-	assert.GreaterOrEqual(t, resJSON["created_at"].(string), timeBefore.Format(time.RFC3339Nano))
-	assert.LessOrEqual(t, resJSON["created_at"].(string), timeAfter.Format(time.RFC3339Nano))
+	assert.Equal(t, testClock.Now().Format(time.RFC3339Nano), resJSON["created_at"])
 
 	// Case 3: "name" and "description" are in the request body
 	bothNameAndDescriptionInRequestCaseRecorder := httptest.NewRecorder()
@@ -80,14 +194,13 @@ func testCreateMemeCoinEndpoint(t *testing.T) {
 		"description": "description",
 	}
 	requestBodyJSON, _ = json.Marshal(requestBody)
-	timeBefore = time.Now()
+	testClock.Advance(time.Second)
 	http.Header.Add(req.Header, "Content-Type", "application/json")
 	req, err = http.NewRequest("POST", "/v1/meme-coin/create", bytes.NewReader(requestBodyJSON))
 	if err != nil {
 		t.Fatal(err)
 	}
 	router.ServeHTTP(bothNameAndDescriptionInRequestCaseRecorder, req)
-	timeAfter = time.Now()
 	resJSONstr = bothNameAndDescriptionInRequestCaseRecorder.Body.String()
 	resJSON = map[string]any{}
 	json.Unmarshal([]byte(resJSONstr), &resJSON)
@@ -98,8 +211,7 @@ func testCreateMemeCoinEndpoint(t *testing.T) {
 	assert.Greater(t, int(resJSON["id"].(float64)), 0)
 	assert.Greater(t, int(resJSON["popularity_score"].(float64)), 0)
 	assert.Less(t, int(resJSON["popularity_score"].(float64)), 100)
-	assert.GreaterOrEqual(t, resJSON["created_at"].(string), timeBefore.Format(time.RFC3339Nano))
-	assert.LessOrEqual(t, resJSON["created_at"].(string), timeAfter.Format(time.RFC3339Nano))
+	assert.Equal(t, testClock.Now().Format(time.RFC3339Nano), resJSON["created_at"])
 }
 
 func testUpdateMemeCoinEndpoint(t *testing.T) {
@@ -156,14 +268,12 @@ func testUpdateMemeCoinEndpoint(t *testing.T) {
 		"description": "description updated",
 	}
 	requestBodyJSON, _ := json.Marshal(requestBody)
-	timeBefore := time.Now()
 	http.Header.Add(req.Header, "Content-Type", "application/json")
 	req, err = http.NewRequest("PATCH", updatePath, bytes.NewReader(requestBodyJSON))
 	if err != nil {
 		t.Fatal(err)
 	}
 	router.ServeHTTP(idAndDescriptionInRequestCaseRecorder, req)
-	timeAfter := time.Now()
 	resJSONstr = idAndDescriptionInRequestCaseRecorder.Body.String()
 	resJSON = map[string]any{}
 
@@ -174,8 +284,7 @@ func testUpdateMemeCoinEndpoint(t *testing.T) {
 	assert.Equal(t, int(resJSON["id"].(float64)), memeCoinId)
 	assert.Greater(t, int(resJSON["popularity_score"].(float64)), 0)
 	assert.Less(t, int(resJSON["popularity_score"].(float64)), 100)
-	assert.GreaterOrEqual(t, resJSON["created_at"].(string), timeBefore.Format(time.RFC3339Nano))
-	assert.LessOrEqual(t, resJSON["created_at"].(string), timeAfter.Format(time.RFC3339Nano))
+	assert.Equal(t, testClock.Now().Format(time.RFC3339Nano), resJSON["created_at"])
 }
 
 func testGetMemeCoinEndpoint(t *testing.T) {
@@ -328,16 +437,475 @@ func testPockMemeCoinEndpoint(t *testing.T) {
 	assert.Equal(t, http.StatusNoContent, idInRequestCaseRecorder.Code)
 }
 
+func testBatchCreateMemeCoinsEndpoint(t *testing.T) {
+	// Case 1: "items" is not in the request body
+	noItemsInRequestCaseRecorder := httptest.NewRecorder()
+	req, err := http.NewRequest("POST", "/v1/meme-coin/batch", bytes.NewReader([]byte(`{}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(noItemsInRequestCaseRecorder, req)
+
+	resJSONstr := noItemsInRequestCaseRecorder.Body.String()
+	resJSON := map[string]any{}
+	json.Unmarshal([]byte(resJSONstr), &resJSON)
+	assert.Equal(t, http.StatusBadRequest, noItemsInRequestCaseRecorder.Code)
+	assert.Equal(t, "Invalid request body", resJSON["message"])
+
+	// Case 2: an item has no "name"
+	noNameInItemCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/v1/meme-coin/batch", bytes.NewReader([]byte(`{"items":[{"description":"description"}]}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(noNameInItemCaseRecorder, req)
+
+	resJSONstr = noNameInItemCaseRecorder.Body.String()
+	resJSON = map[string]any{}
+	json.Unmarshal([]byte(resJSONstr), &resJSON)
+	assert.Equal(t, http.StatusBadRequest, noNameInItemCaseRecorder.Code)
+	assert.Equal(t, "Invalid request body", resJSON["message"])
+
+	// Case 3: too many items
+	tooManyItems := make([]map[string]string, services.MaxBatchCreateSize+1)
+	for i := range tooManyItems {
+		tooManyItems[i] = map[string]string{"name": "name" + strconv.Itoa(i)}
+	}
+	requestBodyJSON, _ := json.Marshal(map[string]any{"items": tooManyItems})
+	tooManyItemsCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/v1/meme-coin/batch", bytes.NewReader(requestBodyJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(tooManyItemsCaseRecorder, req)
+
+	resJSONstr = tooManyItemsCaseRecorder.Body.String()
+	resJSON = map[string]any{}
+	json.Unmarshal([]byte(resJSONstr), &resJSON)
+	assert.Equal(t, http.StatusBadRequest, tooManyItemsCaseRecorder.Code)
+	assert.Equal(t, "Too many MemeCoins", resJSON["message"])
+
+	// Case 4: new and existing names
+	requestBodyJSON, _ = json.Marshal(map[string]any{
+		"items": []map[string]string{
+			{"name": "name", "description": "description"},
+			{"name": mocks.ExistingMemeCoinName},
+		},
+	})
+	itemsInRequestCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/v1/meme-coin/batch", bytes.NewReader(requestBodyJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(itemsInRequestCaseRecorder, req)
+
+	var response handlers.BatchCreateMemeCoinsResponse
+	json.Unmarshal(itemsInRequestCaseRecorder.Body.Bytes(), &response)
+	assert.Equal(t, http.StatusOK, itemsInRequestCaseRecorder.Code)
+	assert.Equal(t, 1, response.Created)
+	assert.Equal(t, 1, response.Conflicts)
+	assert.Len(t, response.Results, 2)
+	assert.Equal(t, services.BatchStatusCreated, response.Results[0].Status)
+	assert.Equal(t, "name", response.Results[0].MemeCoin.Name)
+	assert.Equal(t, "description", response.Results[0].MemeCoin.Description)
+	assert.Equal(t, services.BatchStatusConflict, response.Results[1].Status)
+	assert.Nil(t, response.Results[1].MemeCoin)
+}
+
+func testBatchGetMemeCoinsEndpoint(t *testing.T) {
+	// Case 1: "ids" is not in the query
+	noIDsInRequestCaseRecorder := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/v1/meme-coin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(noIDsInRequestCaseRecorder, req)
+
+	resJSONstr := noIDsInRequestCaseRecorder.Body.String()
+	resJSON := map[string]any{}
+	json.Unmarshal([]byte(resJSONstr), &resJSON)
+	assert.Equal(t, http.StatusBadRequest, noIDsInRequestCaseRecorder.Code)
+	assert.Equal(t, "Invalid MemeCoin IDs", resJSON["message"])
+
+	// Case 2: "ids" contains a non numeric id
+	nonNumericIDCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/v1/meme-coin?ids=1,abc", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(nonNumericIDCaseRecorder, req)
+
+	resJSONstr = nonNumericIDCaseRecorder.Body.String()
+	resJSON = map[string]any{}
+	json.Unmarshal([]byte(resJSONstr), &resJSON)
+	assert.Equal(t, http.StatusBadRequest, nonNumericIDCaseRecorder.Code)
+	assert.Equal(t, "Invalid MemeCoin IDs", resJSON["message"])
+	assert.Equal(t, "Wrong ID format", resJSON["error"])
+
+	// Case 3: too many ids
+	tooManyIDs := make([]string, services.MaxBatchGetSize+1)
+	for i := range tooManyIDs {
+		tooManyIDs[i] = strconv.Itoa(i + 1)
+	}
+	tooManyIDsCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/v1/meme-coin?ids="+strings.Join(tooManyIDs, ","), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(tooManyIDsCaseRecorder, req)
+
+	resJSONstr = tooManyIDsCaseRecorder.Body.String()
+	resJSON = map[string]any{}
+	json.Unmarshal([]byte(resJSONstr), &resJSON)
+	assert.Equal(t, http.StatusBadRequest, tooManyIDsCaseRecorder.Code)
+	assert.Equal(t, "Too many MemeCoin IDs", resJSON["message"])
+
+	// Case 4: "ids" is in the query
+	idsInRequestCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/v1/meme-coin?ids=2,1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(idsInRequestCaseRecorder, req)
+
+	var result services.BatchGetMemeCoinsResult
+	json.Unmarshal(idsInRequestCaseRecorder.Body.Bytes(), &result)
+	assert.Equal(t, http.StatusOK, idsInRequestCaseRecorder.Code)
+	assert.Len(t, result.MemeCoins, 2)
+	assert.Equal(t, 2, result.MemeCoins[0].Id)
+	assert.Equal(t, 1, result.MemeCoins[1].Id)
+	assert.Equal(t, "FakeCoin", result.MemeCoins[0].Name)
+	assert.Empty(t, result.NotFound)
+}
+
+func testBatchPokeMemeCoinsEndpoint(t *testing.T) {
+	// Case 1: "pokes" is not in the request body
+	noPokesInRequestCaseRecorder := httptest.NewRecorder()
+	req, err := http.NewRequest("POST", "/v1/meme-coin/pokes", bytes.NewReader([]byte(`{}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(noPokesInRequestCaseRecorder, req)
+
+	resJSONstr := noPokesInRequestCaseRecorder.Body.String()
+	resJSON := map[string]any{}
+	json.Unmarshal([]byte(resJSONstr), &resJSON)
+	assert.Equal(t, http.StatusBadRequest, noPokesInRequestCaseRecorder.Code)
+	assert.Equal(t, "Invalid request body", resJSON["message"])
+
+	// Case 2: poke count is out of range
+	invalidCountCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/v1/meme-coin/pokes", bytes.NewReader([]byte(`{"pokes":{"1":0}}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(invalidCountCaseRecorder, req)
+
+	resJSONstr = invalidCountCaseRecorder.Body.String()
+	resJSON = map[string]any{}
+	json.Unmarshal([]byte(resJSONstr), &resJSON)
+	assert.Equal(t, http.StatusBadRequest, invalidCountCaseRecorder.Code)
+	assert.Equal(t, "Invalid request body", resJSON["message"])
+
+	// Case 3: "pokes" is in the request body
+	pokesInRequestCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/v1/meme-coin/pokes", bytes.NewReader([]byte(`{"pokes":{"2":3,"1":1}}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(pokesInRequestCaseRecorder, req)
+
+	var response handlers.BatchPokeMemeCoinsResponse
+	json.Unmarshal(pokesInRequestCaseRecorder.Body.Bytes(), &response)
+	assert.Equal(t, http.StatusOK, pokesInRequestCaseRecorder.Code)
+	assert.Equal(t, []services.BatchPokeMemeCoinResult{
+		{Id: 1, Count: 1, Status: services.BatchStatusPoked},
+		{Id: 2, Count: 3, Status: services.BatchStatusPoked},
+	}, response.Results)
+}
+
+func testIdempotencyKey(t *testing.T) {
+	createRequestBodyJSON, _ := json.Marshal(map[string]string{
+		"name":        "idempotent",
+		"description": "description",
+	})
+
+	// Case 1: the first request is handled and the duplicate is replayed
+	firstRequestCaseRecorder := httptest.NewRecorder()
+	req, err := http.NewRequest("POST", "/v1/meme-coin/create", bytes.NewReader(createRequestBodyJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(middlewares.IdempotencyKeyHeader, "create-key")
+	router.ServeHTTP(firstRequestCaseRecorder, req)
+
+	assert.Equal(t, http.StatusOK, firstRequestCaseRecorder.Code)
+	assert.Equal(t, "", firstRequestCaseRecorder.Header().Get(middlewares.IdempotentReplayedHeader))
+
+	duplicatedRequestCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/v1/meme-coin/create", bytes.NewReader(createRequestBodyJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(middlewares.IdempotencyKeyHeader, "create-key")
+	router.ServeHTTP(duplicatedRequestCaseRecorder, req)
+
+	assert.Equal(t, http.StatusOK, duplicatedRequestCaseRecorder.Code)
+	assert.Equal(t, "true", duplicatedRequestCaseRecorder.Header().Get(middlewares.IdempotentReplayedHeader))
+	assert.Equal(t, firstRequestCaseRecorder.Body.String(), duplicatedRequestCaseRecorder.Body.String())
+
+	// Case 2: the key is reused with a different payload
+	differentPayloadCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/v1/meme-coin/create", bytes.NewReader([]byte(`{"name":"another"}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(middlewares.IdempotencyKeyHeader, "create-key")
+	router.ServeHTTP(differentPayloadCaseRecorder, req)
+
+	resJSON := map[string]any{}
+	json.Unmarshal(differentPayloadCaseRecorder.Body.Bytes(), &resJSON)
+	assert.Equal(t, http.StatusUnprocessableEntity, differentPayloadCaseRecorder.Code)
+	assert.Equal(t, "Idempotency-Key reused", resJSON["message"])
+
+	// Case 3: the request with the same key is still in progress
+	record, _ := mockIdempotencyRepository.Find(middlewares.AnonymousIdempotencyScope + ":create-key")
+	mockIdempotencyRepository.Save(middlewares.AnonymousIdempotencyScope+":in-progress-key", repositories.IdempotencyRecord{
+		Fingerprint: record.Fingerprint,
+	}, time.Minute)
+	inProgressCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/v1/meme-coin/create", bytes.NewReader(createRequestBodyJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(middlewares.IdempotencyKeyHeader, "in-progress-key")
+	router.ServeHTTP(inProgressCaseRecorder, req)
+
+	resJSON = map[string]any{}
+	json.Unmarshal(inProgressCaseRecorder.Body.Bytes(), &resJSON)
+	assert.Equal(t, http.StatusConflict, inProgressCaseRecorder.Code)
+	assert.Equal(t, "Request in progress", resJSON["message"])
+
+	// Case 4: concurrent duplicates only poke once
+	const concurrency = 20
+	recorders := make([]*httptest.ResponseRecorder, concurrency)
+	var wg sync.WaitGroup
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(recorder *httptest.ResponseRecorder) {
+			defer wg.Done()
+			req, _ := http.NewRequest("POST", "/v1/meme-coin/1/poke", nil)
+			req.Header.Set(middlewares.IdempotencyKeyHeader, "poke-key")
+			router.ServeHTTP(recorder, req)
+		}(recorders[i])
+	}
+	wg.Wait()
+
+	handled := 0
+	for _, recorder := range recorders {
+		switch {
+		case recorder.Code == http.StatusNoContent && recorder.Header().Get(middlewares.IdempotentReplayedHeader) == "":
+			handled++
+		case recorder.Code == http.StatusNoContent:
+			assert.Equal(t, "true", recorder.Header().Get(middlewares.IdempotentReplayedHeader))
+		default:
+			assert.Equal(t, http.StatusConflict, recorder.Code)
+		}
+	}
+	assert.Equal(t, 1, handled)
+
+	// Case 5: requests with a key are refused while the store is unavailable, the others are still handled
+	mockIdempotencyRepository.ReserveErr = errors.New("connection refused")
+	defer func() { mockIdempotencyRepository.ReserveErr = nil }()
+	unavailableStoreCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/v1/meme-coin/1/poke", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(middlewares.IdempotencyKeyHeader, "unavailable-key")
+	router.ServeHTTP(unavailableStoreCaseRecorder, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, unavailableStoreCaseRecorder.Code)
+	assert.Equal(t, "5", unavailableStoreCaseRecorder.Header().Get("Retry-After"))
+
+	withoutKeyCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/v1/meme-coin/1/poke", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(withoutKeyCaseRecorder, req)
+
+	assert.Equal(t, http.StatusNoContent, withoutKeyCaseRecorder.Code)
+
+	// Case 6: bodies too large to fingerprint are refused
+	mockIdempotencyRepository.ReserveErr = nil
+	tooLargeCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/v1/meme-coin/pokes", bytes.NewReader(make([]byte, middlewares.MaxIdempotentBodySize+1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(middlewares.IdempotencyKeyHeader, "too-large-key")
+	router.ServeHTTP(tooLargeCaseRecorder, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, tooLargeCaseRecorder.Code)
+}
+
+func TestIdempotencyKeyScope(t *testing.T) {
+	apiKeyService := services.NewAPIKeyService(&mocks.MockAPIKeyRepository{})
+	memeCoinService := services.NewMemeCoinService(&mocks.MockMemeCoinRepository{}, &mocks.MockRedisCachedRepository{}, nil, nil)
+	scopedRouter := routes.NewRouter(handlers.NewMemeCoinHandler(memeCoinService, nil), routes.RouterConfig{
+		Authentication: middlewares.NewAPIKeyMiddleware(apiKeyService, nil),
+		Idempotency:    middlewares.NewIdempotencyMiddleware(&mocks.MockIdempotencyRepository{}, middlewares.IdempotencyConfig{}),
+	})
+	firstAPIKey, err := apiKeyService.CreateAPIKey("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondAPIKey, err := apiKeyService.CreateAPIKey("second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(apiKey string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/meme-coin/create", strings.NewReader(`{"name":"scoped"}`))
+		req.Header.Set(middlewares.APIKeyHeader, apiKey)
+		req.Header.Set(middlewares.IdempotencyKeyHeader, "shared-key")
+		scopedRouter.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: the same key is replayed for the client that used it
+	assert.Equal(t, http.StatusOK, create(firstAPIKey.Key).Code)
+	w := create(firstAPIKey.Key)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get(middlewares.IdempotentReplayedHeader))
+
+	// Case 2: another client using the same key gets its own response
+	w = create(secondAPIKey.Key)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "", w.Header().Get(middlewares.IdempotentReplayedHeader))
+}
+
+func testExportMemeCoinsEndpoint(t *testing.T) {
+	// Case 1: "format" is not supported
+	invalidFormatCaseRecorder := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/v1/meme-coin/export?format=xml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(invalidFormatCaseRecorder, req)
+
+	resJSON := map[string]any{}
+	json.Unmarshal(invalidFormatCaseRecorder.Body.Bytes(), &resJSON)
+	assert.Equal(t, http.StatusBadRequest, invalidFormatCaseRecorder.Code)
+	assert.Equal(t, "Invalid format", resJSON["message"])
+
+	// Case 2: CSV by default
+	csvCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/v1/meme-coin/export", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(csvCaseRecorder, req)
+
+	assert.Equal(t, http.StatusOK, csvCaseRecorder.Code)
+	assert.Equal(t, "text/csv", csvCaseRecorder.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=meme_coins.csv", csvCaseRecorder.Header().Get("Content-Disposition"))
+	lines := strings.Split(strings.TrimSpace(csvCaseRecorder.Body.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.Equal(t, "id,name,description,created_at,popularity_score", lines[0])
+
+	// Case 3: NDJSON
+	ndjsonCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/v1/meme-coin/export?format=ndjson", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(ndjsonCaseRecorder, req)
+
+	assert.Equal(t, http.StatusOK, ndjsonCaseRecorder.Code)
+	assert.Equal(t, "application/x-ndjson", ndjsonCaseRecorder.Header().Get("Content-Type"))
+	lines = strings.Split(strings.TrimSpace(ndjsonCaseRecorder.Body.String()), "\n")
+	assert.Len(t, lines, 3)
+}
+
+func testImportMemeCoinsEndpoint(t *testing.T) {
+	// Case 1: "format" is missing and the Content-Type is unknown
+	noFormatCaseRecorder := httptest.NewRecorder()
+	req, err := http.NewRequest("POST", "/v1/meme-coin/import", strings.NewReader("name\ndoge\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(noFormatCaseRecorder, req)
+
+	resJSON := map[string]any{}
+	json.Unmarshal(noFormatCaseRecorder.Body.Bytes(), &resJSON)
+	assert.Equal(t, http.StatusBadRequest, noFormatCaseRecorder.Code)
+	assert.Equal(t, "Invalid format", resJSON["message"])
+
+	// Case 2: CSV without a "name" column
+	malformedCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/v1/meme-coin/import?format=csv", strings.NewReader("description\nfoo\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(malformedCaseRecorder, req)
+
+	resJSON = map[string]any{}
+	json.Unmarshal(malformedCaseRecorder.Body.Bytes(), &resJSON)
+	assert.Equal(t, http.StatusBadRequest, malformedCaseRecorder.Code)
+	assert.Equal(t, "Invalid import file", resJSON["message"])
+
+	// Case 3: a CSV row that cannot be parsed is a 400, not a recovered panic, with the report of the rows committed before it
+	unparsableCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/v1/meme-coin/import?format=csv", strings.NewReader("name\nshiba\n\"doge\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.ServeHTTP(unparsableCaseRecorder, req)
+
+	var importFailedResponse handlers.ImportFailedResponse
+	json.Unmarshal(unparsableCaseRecorder.Body.Bytes(), &importFailedResponse)
+	assert.Equal(t, http.StatusBadRequest, unparsableCaseRecorder.Code)
+	assert.Equal(t, "Invalid import file", importFailedResponse.Message)
+	assert.Equal(t, 1, importFailedResponse.Report.Created)
+
+	// Case 4: NDJSON detected from the Content-Type
+	ndjsonCaseRecorder := httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/v1/meme-coin/import", strings.NewReader(`{"name":"doge"}`+"\n"+`{"name":""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	router.ServeHTTP(ndjsonCaseRecorder, req)
+
+	var report services.ImportReport
+	json.Unmarshal(ndjsonCaseRecorder.Body.Bytes(), &report)
+	assert.Equal(t, http.StatusOK, ndjsonCaseRecorder.Code)
+	assert.Equal(t, 2, report.Total)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, []services.ImportRowError{{Line: 2, Error: "name is required"}}, report.Errors)
+}
+
 func buildTestService() {
 	// Mock repositories
-	mockMemeCoinRepository := &mocks.MockMemeCoinRepository{}
+	testClock = clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	mockMemeCoinRepository := &mocks.MockMemeCoinRepository{Clock: testClock}
 	mockRedisCachedRepository := &mocks.MockRedisCachedRepository{}
 
-	memeCoinService := services.NewMemeCoinService(mockMemeCoinRepository, mockRedisCachedRepository)
-	memeCoinHandler := handlers.NewMemeCoinHandler(memeCoinService)
+	memeCoinService := services.NewMemeCoinService(mockMemeCoinRepository, mockRedisCachedRepository, nil, nil)
+	memeCoinHandler := handlers.NewMemeCoinHandler(memeCoinService, nil)
+
+	// Mock middlewares
+	mockIdempotencyRepository = &mocks.MockIdempotencyRepository{}
+	idempotencyMiddleware := middlewares.NewIdempotencyMiddleware(mockIdempotencyRepository, middlewares.IdempotencyConfig{})
 
 	// Setup routes
-	router = routes.NewRouter(memeCoinHandler)
+	router = routes.NewRouter(memeCoinHandler, routes.RouterConfig{
+		Idempotency: idempotencyMiddleware,
+	})
 
 	// Set Gin to test mode
 	gin.SetMode(gin.TestMode)
@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/pkg/client"
+	"portto-assignment/pkg/clock"
+	"portto-assignment/pkg/memecointest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemeCoinTestRepositories(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("MemeCoinRepository", func(t *testing.T) {
+		fake := clock.NewFake(memecointest.FixtureTime)
+		repo := memecointest.NewMemeCoinRepository(fake)
+
+		// Case 1: names are unique and missing coins are nil, as with PostgreSQL
+		doge, err := repo.CreateOne(ctx, "Doge", "Wow")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, doge.Id)
+		assert.Equal(t, memecointest.FixtureTime, doge.CreatedAt)
+		conflict, err := repo.CreateOne(ctx, "Doge", "Again")
+		assert.NoError(t, err)
+		assert.Nil(t, conflict)
+		missing, err := repo.UpdateOne(ctx, 2, "Nothing")
+		assert.NoError(t, err)
+		assert.Nil(t, missing)
+
+		// Case 2: batches skip the taken names, including the ones taken earlier in the batch
+		created, err := repo.CreateMany(ctx, []repositories.NewMemeCoin{{Name: "Doge"}, {Name: "Pepe"}, {Name: "Pepe"}, {Name: "Shiba"}})
+		assert.NoError(t, err)
+		assert.Len(t, created, 2)
+		found, err := repo.FindMany(ctx, []int{3, 1, 3, 9})
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 3}, []int{found[0].Id, found[1].Id})
+
+		// Case 3: IDs of deleted coins are not reused and their names are free again
+		deleted, err := repo.DeleteOne(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "Doge", deleted.Name)
+		fake.Advance(time.Hour)
+		doge, _ = repo.CreateOne(ctx, "Doge", "Wow")
+		assert.Equal(t, 4, doge.Id)
+		assert.Equal(t, memecointest.FixtureTime.Add(time.Hour), doge.CreatedAt)
+
+		// Case 4: upserts keep the stored score when none is given
+		score := 7
+		upserted, err := repo.UpsertMany(ctx, []repositories.UpsertMemeCoin{{Name: "Pepe", Description: "Frog", PopularityScore: &score}, {Name: "Bonk"}})
+		assert.NoError(t, err)
+		assert.False(t, upserted[0].Inserted)
+		assert.True(t, upserted[1].Inserted)
+		upserted, _ = repo.UpsertMany(ctx, []repositories.UpsertMemeCoin{{Name: "Pepe", Description: "Still a frog"}})
+		assert.Equal(t, 7, upserted[0].PopularityScore)
+
+		// Case 5: the catalogue is streamed in ID order
+		ids := []int{}
+		assert.NoError(t, repo.StreamAll(ctx, func(memeCoin repositories.MemeCoin) error {
+			ids = append(ids, memeCoin.Id)
+			return nil
+		}))
+		assert.Equal(t, []int{2, 3, 4, 5}, ids)
+	})
+
+	t.Run("Concurrency", func(t *testing.T) {
+		repo := memecointest.NewMemeCoinRepository(nil)
+		redis := memecointest.NewRedisRepository()
+		memeCoin, _ := repo.CreateOne(ctx, "Doge", "")
+
+		// Case 1: concurrent writes are neither lost nor given the same ID
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				repo.CreateOne(ctx, fmt.Sprintf("Coin %d", i), "")
+				repo.IncrementPopularityScore(ctx, memeCoin.Id, 1)
+				redis.IncrBy(ctx, "meme:popularity_score:1", 2)
+			}()
+		}
+		wg.Wait()
+		assert.Len(t, repo.All(), 51)
+		memeCoin, _ = repo.FindOne(ctx, memeCoin.Id)
+		assert.Equal(t, 50, memeCoin.PopularityScore)
+		assert.Equal(t, map[string]int{"meme:popularity_score:1": 100}, redis.Values())
+	})
+
+	t.Run("RedisRepository", func(t *testing.T) {
+		redis := memecointest.NewRedisRepository()
+
+		// Case 1: increments create missing keys, deleted keys no longer exist
+		assert.NoError(t, redis.IncrBy(ctx, "a", 3))
+		value, found, err := redis.Get(ctx, "a")
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, 3, value)
+		assert.NoError(t, redis.Delete(ctx, "a"))
+		existsMap, err := redis.ExistsMany(ctx, []string{"a", "b"})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]bool{"a": false, "b": false}, existsMap)
+	})
+
+	t.Run("IdempotencyRepository", func(t *testing.T) {
+		fake := clock.NewFake(memecointest.FixtureTime)
+		repo := memecointest.NewIdempotencyRepository(fake)
+
+		// Case 1: a key is owned by one caller until its TTL passes
+		_, reserved, err := repo.Reserve("key", "fingerprint", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, reserved)
+		fake.Advance(59 * time.Second)
+		record, reserved, _ := repo.Reserve("key", "other", time.Minute)
+		assert.False(t, reserved)
+		assert.Equal(t, "fingerprint", record.Fingerprint)
+		fake.Advance(time.Second)
+		_, reserved, _ = repo.Reserve("key", "other", time.Minute)
+		assert.True(t, reserved)
+	})
+}
+
+func TestMemeCoinTestServer(t *testing.T) {
+	ctx := context.Background()
+	server := memecointest.NewServer(t, memecointest.ServerConfig{})
+
+	// Case 1: fixtures are served as seeded
+	seeded := server.Seed(t,
+		memecointest.NewMemeCoinFixture("Doge").WithDescription("Wow").WithPopularityScore(42),
+		memecointest.NewMemeCoinFixture("Pepe").WithCreatedAt(memecointest.FixtureTime.Add(time.Hour)),
+	)
+	memeCoin, err := server.Client.GetMemeCoin(ctx, seeded[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, seeded[0], *memeCoin)
+	assert.Equal(t, 42, memeCoin.PopularityScore)
+	memeCoin, _ = server.Client.GetMemeCoin(ctx, seeded[1].Id)
+	assert.True(t, memecointest.FixtureTime.Add(time.Hour).Equal(memeCoin.CreatedAt))
+
+	// Case 2: requests go through the real service and router
+	assert.NoError(t, server.Client.PokeMemeCoin(ctx, seeded[0].Id))
+	memeCoin, _ = server.Client.GetMemeCoin(ctx, seeded[0].Id)
+	assert.Equal(t, 43, memeCoin.PopularityScore)
+	_, err = server.Client.CreateMemeCoin(ctx, "Doge", "")
+	var apiError *client.APIError
+	if assert.True(t, errors.As(err, &apiError)) {
+		assert.Equal(t, http.StatusConflict, apiError.StatusCode)
+	}
+
+	// Case 3: a name seeded twice fails the seed
+	_, err = server.MemeCoins.Seed(memecointest.NewMemeCoinFixtures(2)...)
+	assert.NoError(t, err)
+	_, err = server.MemeCoins.Seed(memecointest.NewMemeCoinFixture("Coin 2"))
+	assert.Error(t, err)
+	assert.Len(t, server.MemeCoins.All(), 4)
+}
@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"portto-assignment/database/migrations"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mock.Close()
+
+	appliedAt := time.Now()
+	columns := []string{"version", "applied_at"}
+
+	// Case 1: the lock is taken before checking what is applied, the pending migrations are committed together
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WithArgs(pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, applied_at FROM schema_migrations")).
+		WillReturnRows(pgxmock.NewRows(columns).AddRow("0001_create_meme_coins", appliedAt).AddRow("0002_create_api_keys", appliedAt))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE")).WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations (version) VALUES ($1)")).WithArgs("0003_create_tags").WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	versions, err := migrations.Migrate(mock)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0003_create_tags"}, versions)
+
+	// Case 2: an instance that waited on the lock finds every migration applied
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WithArgs(pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, applied_at FROM schema_migrations")).
+		WillReturnRows(pgxmock.NewRows(columns).AddRow("0001_create_meme_coins", appliedAt).AddRow("0002_create_api_keys", appliedAt).AddRow("0003_create_tags", appliedAt))
+	mock.ExpectCommit()
+	versions, err = migrations.Migrate(mock)
+	assert.NoError(t, err)
+	assert.Empty(t, versions)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
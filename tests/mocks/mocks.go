@@ -1,20 +1,37 @@
 package mocks
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/rand"
 	"portto-assignment/internal/repositories"
+	"portto-assignment/pkg/clock"
+	"sort"
+	"sync"
 	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ExistingMemeCoinName is treated as a name that is already taken
+const ExistingMemeCoinName = "ExistingCoin"
+
+// MockPopularityScore is the score stored in Redis for every existing meme coin
+const MockPopularityScore = 42
+
 type MockMemeCoinRepository struct {
+	// Clock stamps created_at, the real clock when nil
+	Clock clock.Clock
 }
 
 type MockRedisCachedRepository struct {
+	// Leaderboards follow the score writes when set, as the tag leaderboards of RedisCachedRepository do
+	Leaderboards *MockTagLeaderboardRepository
 }
 
-func (m *MockMemeCoinRepository) FindOne(id int) (*repositories.MemeCoin, error) {
+func (m *MockMemeCoinRepository) FindOne(ctx context.Context, id int) (*repositories.MemeCoin, error) {
 	if id == 0 {
 		return nil, errors.New("invalid ID")
 	}
@@ -25,7 +42,7 @@ func (m *MockMemeCoinRepository) FindOne(id int) (*repositories.MemeCoin, error)
 	return &fakeMemeCoin, nil
 }
 
-func (m *MockMemeCoinRepository) CreateOne(name string, description string) (*repositories.MemeCoin, error) {
+func (m *MockMemeCoinRepository) CreateOne(ctx context.Context, name string, description string) (*repositories.MemeCoin, error) {
 	fakeMemeCoin := m.getFakeMemeCoin()
 	fakeMemeCoin.Name = name
 	fakeMemeCoin.Description = description
@@ -33,7 +50,7 @@ func (m *MockMemeCoinRepository) CreateOne(name string, description string) (*re
 	return &fakeMemeCoin, nil
 }
 
-func (m *MockMemeCoinRepository) UpdateOne(id int, description string) (*repositories.MemeCoin, error) {
+func (m *MockMemeCoinRepository) UpdateOne(ctx context.Context, id int, description string) (*repositories.MemeCoin, error) {
 	if id == 0 {
 		return nil, errors.New("invalid ID")
 	}
@@ -45,7 +62,7 @@ func (m *MockMemeCoinRepository) UpdateOne(id int, description string) (*reposit
 	return &fakeMemeCoin, nil
 }
 
-func (m *MockMemeCoinRepository) DeleteOne(id int) (*repositories.MemeCoin, error) {
+func (m *MockMemeCoinRepository) DeleteOne(ctx context.Context, id int) (*repositories.MemeCoin, error) {
 	if id == 0 {
 		return nil, errors.New("invalid ID")
 	}
@@ -56,6 +73,92 @@ func (m *MockMemeCoinRepository) DeleteOne(id int) (*repositories.MemeCoin, erro
 	return &fakeMemeCoin, nil
 }
 
+func (m *MockMemeCoinRepository) FindMany(ctx context.Context, ids []int) ([]repositories.MemeCoin, error) {
+	fakeMemeCoins := []repositories.MemeCoin{}
+	for _, id := range ids {
+		if id == 0 {
+			continue
+		}
+
+		fakeMemeCoin := m.getFakeMemeCoin()
+		fakeMemeCoin.Id = id
+		fakeMemeCoins = append(fakeMemeCoins, fakeMemeCoin)
+	}
+
+	return fakeMemeCoins, nil
+}
+
+func (m *MockMemeCoinRepository) CreateMany(ctx context.Context, newMemeCoins []repositories.NewMemeCoin) ([]repositories.MemeCoin, error) {
+	fakeMemeCoins := []repositories.MemeCoin{}
+	for _, newMemeCoin := range newMemeCoins {
+		if newMemeCoin.Name == ExistingMemeCoinName {
+			continue
+		}
+
+		fakeMemeCoin := m.getFakeMemeCoin()
+		fakeMemeCoin.Name = newMemeCoin.Name
+		fakeMemeCoin.Description = newMemeCoin.Description
+		fakeMemeCoins = append(fakeMemeCoins, fakeMemeCoin)
+	}
+
+	return fakeMemeCoins, nil
+}
+
+func (m *MockMemeCoinRepository) StreamAll(ctx context.Context, fn func(memeCoin repositories.MemeCoin) error) error {
+	for id := 1; id <= 3; id++ {
+		fakeMemeCoin := m.getFakeMemeCoin()
+		fakeMemeCoin.Id = id
+		err := fn(fakeMemeCoin)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *MockMemeCoinRepository) UpsertMany(ctx context.Context, upsertMemeCoins []repositories.UpsertMemeCoin) ([]repositories.UpsertedMemeCoin, error) {
+	fakeMemeCoins := []repositories.UpsertedMemeCoin{}
+	for _, upsertMemeCoin := range upsertMemeCoins {
+		fakeMemeCoin := m.getFakeMemeCoin()
+		fakeMemeCoin.Name = upsertMemeCoin.Name
+		fakeMemeCoin.Description = upsertMemeCoin.Description
+		if upsertMemeCoin.PopularityScore != nil {
+			fakeMemeCoin.PopularityScore = *upsertMemeCoin.PopularityScore
+		}
+		fakeMemeCoins = append(fakeMemeCoins, repositories.UpsertedMemeCoin{
+			MemeCoin: fakeMemeCoin,
+			Inserted: upsertMemeCoin.Name != ExistingMemeCoinName,
+		})
+	}
+
+	return fakeMemeCoins, nil
+}
+
+func (m *MockMemeCoinRepository) UpdatePopularityScore(ctx context.Context, id int, popularityScore int) (*repositories.MemeCoin, error) {
+	if id == 0 {
+		return nil, nil
+	}
+
+	fakeMemeCoin := m.getFakeMemeCoin()
+	fakeMemeCoin.Id = id
+	fakeMemeCoin.PopularityScore = popularityScore
+
+	return &fakeMemeCoin, nil
+}
+
+func (m *MockMemeCoinRepository) IncrementPopularityScore(ctx context.Context, id int, increment int) (*repositories.MemeCoin, error) {
+	if id == 0 {
+		return nil, nil
+	}
+
+	fakeMemeCoin := m.getFakeMemeCoin()
+	fakeMemeCoin.Id = id
+	fakeMemeCoin.PopularityScore += increment
+
+	return &fakeMemeCoin, nil
+}
+
 func (m *MockMemeCoinRepository) PokeOne(id int) error {
 	if id == 0 {
 		return errors.New("invalid ID")
@@ -69,36 +172,664 @@ func (m *MockMemeCoinRepository) getFakeMemeCoin() repositories.MemeCoin {
 		Id:              rand.Intn(9999) + 1,
 		Name:            "FakeCoin",
 		Description:     "A fake meme coin",
-		CreatedAt:       time.Now(),
+		CreatedAt:       m.now(),
 		PopularityScore: rand.Intn(99) + 1,
 	}
 }
 
-func (m *MockRedisCachedRepository) IncrBy(key string, increment int) error {
+func (m *MockMemeCoinRepository) now() time.Time {
+	if m.Clock == nil {
+		return time.Now()
+	}
+	return m.Clock.Now()
+}
+
+func (m *MockRedisCachedRepository) IncrBy(ctx context.Context, key string, increment int) error {
+	return m.IncrByMany(ctx, map[string]int{key: increment})
+}
+
+func (m *MockRedisCachedRepository) Get(ctx context.Context, key string) (int, bool, error) {
 	if key == fmt.Sprintf("meme:popularity_score:%d", 0) {
-		return fmt.Errorf("key %s does not exist", key)
+		return 0, false, nil
 	}
-	return nil
+	return MockPopularityScore, true, nil
 }
 
-func (m *MockRedisCachedRepository) Set(key string, value int) error {
+func (m *MockRedisCachedRepository) Set(ctx context.Context, key string, value int) error {
+	return m.SetMany(ctx, map[string]int{key: value})
+}
+
+func (m *MockRedisCachedRepository) Delete(ctx context.Context, key string) error {
 	if key == fmt.Sprintf("meme:popularity_score:%d", 0) {
 		return fmt.Errorf("key %s does not exist", key)
 	}
+	for id := range m.leaderboardValues(map[string]int{key: 0}) {
+		m.Leaderboards.RemoveMemeCoin(ctx, id)
+	}
 	return nil
 }
 
-func (m *MockRedisCachedRepository) Delete(key string) error {
+func (m *MockRedisCachedRepository) Exists(ctx context.Context, key string) (bool, error) {
 	if key == fmt.Sprintf("meme:popularity_score:%d", 0) {
-		return fmt.Errorf("key %s does not exist", key)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (m *MockRedisCachedRepository) SetMany(ctx context.Context, values map[string]int) error {
+	if _, ok := values[fmt.Sprintf("meme:popularity_score:%d", 0)]; ok {
+		return fmt.Errorf("key %s does not exist", fmt.Sprintf("meme:popularity_score:%d", 0))
+	}
+	for id, value := range m.leaderboardValues(values) {
+		m.Leaderboards.SetScore(ctx, id, value)
+	}
+	return nil
+}
+
+func (m *MockRedisCachedRepository) IncrByMany(ctx context.Context, increments map[string]int) error {
+	if _, ok := increments[fmt.Sprintf("meme:popularity_score:%d", 0)]; ok {
+		return fmt.Errorf("key %s does not exist", fmt.Sprintf("meme:popularity_score:%d", 0))
+	}
+	if leaderboardIncrements := m.leaderboardValues(increments); len(leaderboardIncrements) > 0 {
+		m.Leaderboards.IncrByMany(ctx, leaderboardIncrements)
 	}
 	return nil
 }
 
-func (m *MockRedisCachedRepository) Exists(key string) (bool, error) {
+// leaderboardValues maps the values of popularity score keys to their coin ids, nil without leaderboards
+func (m *MockRedisCachedRepository) leaderboardValues(values map[string]int) map[int]int {
+	if m.Leaderboards == nil {
+		return nil
+	}
+	ids := map[int]int{}
+	for key, value := range values {
+		id, err := repositories.DefaultKeyBuilder().ParsePopularityScore(key)
+		if err == nil {
+			ids[id] = value
+		}
+	}
+	return ids
+}
+
+func (m *MockRedisCachedRepository) AdjustBy(ctx context.Context, key string, delta int) (int, error) {
 	if key == fmt.Sprintf("meme:popularity_score:%d", 0) {
+		return 0, fmt.Errorf("key %s does not exist", key)
+	}
+	if MockPopularityScore+delta < 0 {
+		return 0, repositories.ErrNegativePopularityScore
+	}
+	return MockPopularityScore + delta, nil
+}
+
+func (m *MockRedisCachedRepository) ExistsMany(ctx context.Context, keys []string) (map[string]bool, error) {
+	existsMap := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		existsMap[key] = key != fmt.Sprintf("meme:popularity_score:%d", 0)
+	}
+
+	return existsMap, nil
+}
+
+type MockIdempotencyRepository struct {
+	mutex   sync.Mutex
+	records map[string]repositories.IdempotencyRecord
+	// ReserveErr is returned by Reserve when set, as if Redis were down
+	ReserveErr error
+}
+
+func (m *MockIdempotencyRepository) Reserve(key string, fingerprint string, ttl time.Duration) (*repositories.IdempotencyRecord, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.ReserveErr != nil {
+		return nil, false, m.ReserveErr
+	}
+	if m.records == nil {
+		m.records = map[string]repositories.IdempotencyRecord{}
+	}
+	if record, ok := m.records[key]; ok {
+		return &record, false, nil
+	}
+	m.records[key] = repositories.IdempotencyRecord{Fingerprint: fingerprint}
+
+	return nil, true, nil
+}
+
+func (m *MockIdempotencyRepository) Find(key string) (*repositories.IdempotencyRecord, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	record, ok := m.records[key]
+	if !ok {
+		return nil, nil
+	}
+
+	return &record, nil
+}
+
+func (m *MockIdempotencyRepository) Save(key string, record repositories.IdempotencyRecord, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.records == nil {
+		m.records = map[string]repositories.IdempotencyRecord{}
+	}
+	m.records[key] = record
+
+	return nil
+}
+
+func (m *MockIdempotencyRepository) Release(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.records, key)
+
+	return nil
+}
+
+type MockAPIKeyRepository struct {
+	mutex   sync.Mutex
+	apiKeys []repositories.APIKey
+	hashes  []string
+}
+
+func (m *MockAPIKeyRepository) CreateOne(name string, prefix string, keyHash string) (*repositories.APIKey, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	apiKey := repositories.APIKey{
+		Id:        len(m.apiKeys) + 1,
+		Name:      name,
+		Prefix:    prefix,
+		CreatedAt: time.Now(),
+	}
+	m.apiKeys = append(m.apiKeys, apiKey)
+	m.hashes = append(m.hashes, keyHash)
+
+	return &apiKey, nil
+}
+
+func (m *MockAPIKeyRepository) FindActiveByHash(keyHash string) (*repositories.APIKey, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, hash := range m.hashes {
+		if hash == keyHash && m.apiKeys[i].RevokedAt == nil {
+			apiKey := m.apiKeys[i]
+			return &apiKey, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (m *MockAPIKeyRepository) FindAll() ([]repositories.APIKey, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([]repositories.APIKey{}, m.apiKeys...), nil
+}
+
+func (m *MockAPIKeyRepository) RevokeOne(id int) (*repositories.APIKey, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if id <= 0 || id > len(m.apiKeys) || m.apiKeys[id-1].RevokedAt != nil {
+		return nil, nil
+	}
+	revokedAt := time.Now()
+	m.apiKeys[id-1].RevokedAt = &revokedAt
+	apiKey := m.apiKeys[id-1]
+
+	return &apiKey, nil
+}
+
+type MockRedisStatus struct {
+	RedisStatus repositories.RedisStatus
+}
+
+func (m *MockRedisStatus) Status() repositories.RedisStatus {
+	return m.RedisStatus
+}
+
+type MockDatabaseHealth struct {
+	Err error
+}
+
+func (m *MockDatabaseHealth) Ping(ctx context.Context) error {
+	return m.Err
+}
+
+// Stat returns nil because pool statistics cannot be built outside pgxpool
+func (m *MockDatabaseHealth) Stat() *pgxpool.Stat {
+	return nil
+}
+
+// MockMemoryRedisRepository keeps popularity scores in memory and counts the calls reaching it
+type MockMemoryRedisRepository struct {
+	mutex  sync.Mutex
+	values map[string]int
+	// Err fails every call when set
+	Err error
+	// FailKeys fails the increments of these keys, the others are applied
+	FailKeys map[string]bool
+
+	IncrByManyCalls int
+	ExistsManyCalls int
+}
+
+func (m *MockMemoryRedisRepository) IncrBy(ctx context.Context, key string, increment int) error {
+	return m.IncrByMany(ctx, map[string]int{key: increment})
+}
+
+func (m *MockMemoryRedisRepository) Get(ctx context.Context, key string) (int, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.Err != nil {
+		return 0, false, m.Err
+	}
+	value, found := m.values[key]
+	return value, found, nil
+}
+
+func (m *MockMemoryRedisRepository) Set(ctx context.Context, key string, value int) error {
+	return m.SetMany(ctx, map[string]int{key: value})
+}
+
+func (m *MockMemoryRedisRepository) Delete(ctx context.Context, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.Err != nil {
+		return m.Err
+	}
+	delete(m.values, key)
+	return nil
+}
+
+func (m *MockMemoryRedisRepository) Exists(ctx context.Context, key string) (bool, error) {
+	existsMap, err := m.ExistsMany(ctx, []string{key})
+	return existsMap[key], err
+}
+
+func (m *MockMemoryRedisRepository) SetMany(ctx context.Context, values map[string]int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.Err != nil {
+		return m.Err
+	}
+	if m.values == nil {
+		m.values = make(map[string]int)
+	}
+	for key, value := range values {
+		m.values[key] = value
+	}
+	return nil
+}
+
+func (m *MockMemoryRedisRepository) IncrByMany(ctx context.Context, increments map[string]int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.IncrByManyCalls++
+	if m.Err != nil {
+		return m.Err
+	}
+	if m.values == nil {
+		m.values = make(map[string]int)
+	}
+	failed := map[string]int{}
+	for key, increment := range increments {
+		if m.FailKeys[key] {
+			failed[key] = increment
+			continue
+		}
+		m.values[key] += increment
+	}
+	if len(failed) > 0 {
+		return &repositories.IncrementError{Failed: failed, Err: errors.New("connection reset")}
+	}
+	return nil
+}
+
+func (m *MockMemoryRedisRepository) AdjustBy(ctx context.Context, key string, delta int) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.Err != nil {
+		return 0, m.Err
+	}
+	if m.values[key]+delta < 0 {
+		return 0, repositories.ErrNegativePopularityScore
+	}
+	if m.values == nil {
+		m.values = make(map[string]int)
+	}
+	m.values[key] += delta
+	return m.values[key], nil
+}
+
+func (m *MockMemoryRedisRepository) ExistsMany(ctx context.Context, keys []string) (map[string]bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.ExistsManyCalls++
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	existsMap := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		_, existsMap[key] = m.values[key]
+	}
+	return existsMap, nil
+}
+
+// Calls returns how many increment and existence batches reached the repository
+func (m *MockMemoryRedisRepository) Calls() (int, int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.IncrByManyCalls, m.ExistsManyCalls
+}
+
+// MockTagRepository keeps tags and categories in memory, every meme coin of MockMemeCoinRepository can be tagged
+type MockTagRepository struct {
+	mutex      sync.Mutex
+	tags       []repositories.Tag
+	tagged     map[int]map[string]bool
+	categories []repositories.Category
+}
+
+func (m *MockTagRepository) AddOne(ctx context.Context, memeCoinId int, name string) (*repositories.Tag, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	tag := m.findTag(name)
+	if tag == nil {
+		m.tags = append(m.tags, repositories.Tag{Id: len(m.tags) + 1, Name: name, CreatedAt: time.Now()})
+		tag = &m.tags[len(m.tags)-1]
+	}
+	if m.tagged == nil {
+		m.tagged = map[int]map[string]bool{}
+	}
+	if m.tagged[memeCoinId] == nil {
+		m.tagged[memeCoinId] = map[string]bool{}
+	}
+	m.tagged[memeCoinId][name] = true
+
+	addedTag := *tag
+	return &addedTag, nil
+}
+
+func (m *MockTagRepository) RemoveOne(ctx context.Context, memeCoinId int, name string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.tagged[memeCoinId][name] {
 		return false, nil
 	}
+	delete(m.tagged[memeCoinId], name)
 
 	return true, nil
 }
+
+func (m *MockTagRepository) FindByMemeCoin(ctx context.Context, memeCoinId int) ([]repositories.Tag, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	tags := []repositories.Tag{}
+	for _, tag := range m.tags {
+		if m.tagged[memeCoinId][tag.Name] {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+
+	return tags, nil
+}
+
+func (m *MockTagRepository) CountAll(ctx context.Context, category string) ([]repositories.TagCount, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	tagCounts := []repositories.TagCount{}
+	for _, tag := range m.tags {
+		if category != "" && (tag.Category == nil || *tag.Category != category) {
+			continue
+		}
+		tagCount := repositories.TagCount{Tag: tag}
+		for _, tags := range m.tagged {
+			if tags[tag.Name] {
+				tagCount.Count++
+			}
+		}
+		tagCounts = append(tagCounts, tagCount)
+	}
+	sort.Slice(tagCounts, func(i, j int) bool {
+		if tagCounts[i].Count != tagCounts[j].Count {
+			return tagCounts[i].Count > tagCounts[j].Count
+		}
+		return tagCounts[i].Name < tagCounts[j].Name
+	})
+
+	return tagCounts, nil
+}
+
+func (m *MockTagRepository) FindMemeCoins(ctx context.Context, name string, afterId int, limit int) ([]repositories.MemeCoin, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ids := []int{}
+	for id, tags := range m.tagged {
+		if tags[name] && id > afterId {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	return (&MockMemeCoinRepository{}).FindMany(ctx, ids)
+}
+
+func (m *MockTagRepository) StreamMemeCoinTags(ctx context.Context, fn func(memeCoinTag repositories.MemeCoinTag) error) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for id, tags := range m.tagged {
+		for tag := range tags {
+			err := fn(repositories.MemeCoinTag{MemeCoinId: id, Tag: tag, PopularityScore: MockPopularityScore})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *MockTagRepository) SetCategory(ctx context.Context, name string, category string) (*repositories.Tag, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	tag := m.findTag(name)
+	if tag == nil {
+		return nil, nil
+	}
+	if category == "" {
+		tag.Category = nil
+	} else if m.findCategory(category) >= 0 {
+		tag.Category = &category
+	} else {
+		return nil, nil
+	}
+
+	updatedTag := *tag
+	return &updatedTag, nil
+}
+
+func (m *MockTagRepository) CreateCategory(ctx context.Context, name string, description string) (*repositories.Category, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.findCategory(name) >= 0 {
+		return nil, nil
+	}
+	category := repositories.Category{Id: len(m.categories) + 1, Name: name, Description: description, CreatedAt: time.Now()}
+	m.categories = append(m.categories, category)
+
+	return &category, nil
+}
+
+func (m *MockTagRepository) FindCategories(ctx context.Context) ([]repositories.Category, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([]repositories.Category{}, m.categories...), nil
+}
+
+func (m *MockTagRepository) DeleteCategory(ctx context.Context, name string) (*repositories.Category, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	i := m.findCategory(name)
+	if i < 0 {
+		return nil, nil
+	}
+	category := m.categories[i]
+	m.categories = append(m.categories[:i], m.categories[i+1:]...)
+	for j := range m.tags {
+		if m.tags[j].Category != nil && *m.tags[j].Category == name {
+			m.tags[j].Category = nil
+		}
+	}
+
+	return &category, nil
+}
+
+func (m *MockTagRepository) findTag(name string) *repositories.Tag {
+	for i := range m.tags {
+		if m.tags[i].Name == name {
+			return &m.tags[i]
+		}
+	}
+
+	return nil
+}
+
+func (m *MockTagRepository) findCategory(name string) int {
+	for i, category := range m.categories {
+		if category.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// MockTagLeaderboardRepository keeps the tag leaderboards in memory
+type MockTagLeaderboardRepository struct {
+	mutex  sync.Mutex
+	tags   map[int]map[string]bool
+	boards map[string]map[int]int
+}
+
+func (m *MockTagLeaderboardRepository) Add(ctx context.Context, tag string, id int, popularityScore int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.tags == nil {
+		m.tags = map[int]map[string]bool{}
+		m.boards = map[string]map[int]int{}
+	}
+	if m.tags[id] == nil {
+		m.tags[id] = map[string]bool{}
+	}
+	if m.boards[tag] == nil {
+		m.boards[tag] = map[int]int{}
+	}
+	m.tags[id][tag] = true
+	m.boards[tag][id] = popularityScore
+
+	return nil
+}
+
+func (m *MockTagLeaderboardRepository) Remove(ctx context.Context, tag string, id int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.tags[id], tag)
+	delete(m.boards[tag], id)
+
+	return nil
+}
+
+// RemoveMemeCoin, IncrByMany and SetScore are the leaderboard writes of MockRedisCachedRepository
+func (m *MockTagLeaderboardRepository) RemoveMemeCoin(ctx context.Context, id int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for tag := range m.tags[id] {
+		delete(m.boards[tag], id)
+	}
+	delete(m.tags, id)
+
+	return nil
+}
+
+func (m *MockTagLeaderboardRepository) IncrByMany(ctx context.Context, increments map[int]int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for id, increment := range increments {
+		for tag := range m.tags[id] {
+			m.boards[tag][id] += increment
+		}
+	}
+
+	return nil
+}
+
+func (m *MockTagLeaderboardRepository) SetScore(ctx context.Context, id int, popularityScore int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for tag := range m.tags[id] {
+		m.boards[tag][id] = popularityScore
+	}
+
+	return nil
+}
+
+func (m *MockTagLeaderboardRepository) Top(ctx context.Context, tag string, limit int) ([]repositories.LeaderboardEntry, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entries := []repositories.LeaderboardEntry{}
+	for id, popularityScore := range m.boards[tag] {
+		entries = append(entries, repositories.LeaderboardEntry{Id: id, PopularityScore: popularityScore})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].PopularityScore != entries[j].PopularityScore {
+			return entries[i].PopularityScore > entries[j].PopularityScore
+		}
+		return entries[i].Id > entries[j].Id
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
+
+// MockAuthentication lets every request through, it stands for a configured authentication so that the admin routes are registered
+type MockAuthentication struct{}
+
+func (MockAuthentication) Handle(context *gin.Context) {
+	context.Next()
+}
@@ -0,0 +1,220 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	docs "portto-assignment/api"
+	"portto-assignment/internal/handlers"
+	"portto-assignment/internal/middlewares"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/internal/routes"
+	"portto-assignment/internal/services"
+	"portto-assignment/tests/mocks"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// loadOpenAPI returns the embedded OpenAPI document, it must be valid for the middleware to be built
+func loadOpenAPI(t *testing.T) *openapi3.T {
+	spec, err := docs.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return spec
+}
+
+// assertResponseMatchesOpenAPI checks the status, headers and body of a response against the operation of its request
+func assertResponseMatchesOpenAPI(t *testing.T, spec *openapi3.T, req *http.Request, w *httptest.ResponseRecorder) {
+	t.Helper()
+	router, err := gorillamux.NewRouter(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route, pathParams, err := router.FindRoute(req)
+	if !assert.NoError(t, err, "%s %s is not in the OpenAPI document", req.Method, req.URL.Path) {
+		return
+	}
+
+	err = openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: pathParams,
+			Route:      route,
+		},
+		Status: w.Code,
+		Header: w.Header(),
+		Body:   io.NopCloser(bytes.NewReader(w.Body.Bytes())),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+		},
+	})
+	assert.NoError(t, err, "%s %s answered %d", req.Method, req.URL.Path, w.Code)
+}
+
+// newDocumentedRouter registers every route, with the optional middlewares a request can be rejected by
+func newDocumentedRouter(spec *openapi3.T) *gin.Engine {
+	memeCoinService := services.NewMemeCoinService(&mocks.MockMemeCoinRepository{}, &mocks.MockRedisCachedRepository{}, nil, nil)
+	healthService := services.NewHealthService(&mocks.MockDatabaseHealth{}, &mocks.MockRedisStatus{RedisStatus: repositories.RedisStatusUp}, nil)
+	validation, err := middlewares.NewOpenAPIValidationMiddleware(spec)
+	if err != nil {
+		panic(err)
+	}
+
+	tagService := services.NewTagService(&mocks.MockTagRepository{}, &mocks.MockMemeCoinRepository{}, &mocks.MockRedisCachedRepository{}, &mocks.MockTagLeaderboardRepository{}, nil, nil)
+
+	return routes.NewRouter(handlers.NewMemeCoinHandler(memeCoinService, nil), routes.RouterConfig{
+		OpenAPIValidation: validation,
+		Idempotency:       middlewares.NewIdempotencyMiddleware(&mocks.MockIdempotencyRepository{}, middlewares.IdempotencyConfig{}),
+		Authentication:    mocks.MockAuthentication{},
+		ReadYourWrites:    middlewares.NewReadYourWritesMiddleware(),
+		Health:            handlers.NewHealthHandler(healthService),
+		LogLevel:          handlers.NewLogLevelHandler(&slog.LevelVar{}),
+		Tags:              handlers.NewTagHandler(tagService, nil),
+	})
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	spec := loadOpenAPI(t)
+	router := newDocumentedRouter(spec)
+
+	// Case 1: every registered route is documented, ":id" and "*file" are both path parameters
+	parameter := regexp.MustCompile(`[:*](\w+)`)
+	for _, route := range router.Routes() {
+		path := parameter.ReplaceAllString(route.Path, "{$1}")
+		pathItem := spec.Paths.Find(path)
+		if assert.NotNil(t, pathItem, "%s is not in the OpenAPI document", path) {
+			assert.NotNil(t, pathItem.GetOperation(route.Method), "%s %s is not in the OpenAPI document", route.Method, path)
+		}
+	}
+
+	// Case 2: every documented operation is registered
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		registered[route.Method+" "+parameter.ReplaceAllString(route.Path, "{$1}")] = true
+	}
+	for path, pathItem := range spec.Paths.Map() {
+		for method := range pathItem.Operations() {
+			assert.True(t, registered[method+" "+path], "%s %s is documented but not registered", method, path)
+		}
+	}
+}
+
+func TestOpenAPIValidationMiddleware(t *testing.T) {
+	router := newDocumentedRouter(loadOpenAPI(t))
+	send := func(method string, path string, contentType string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: valid requests reach the handlers
+	w := send("POST", "/v1/meme-coin/create", "application/json", `{"name": "Doge", "description": "Wow"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"Doge"`)
+
+	// Case 2: path parameters, query parameters and JSON bodies are checked
+	for _, invalid := range []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"GET", "/v1/meme-coin/abc", ""},
+		{"GET", "/v1/meme-coin/0", ""},
+		{"GET", "/v1/meme-coin", ""},
+		{"GET", "/v1/meme-coin/export?format=xml", ""},
+		{"POST", "/v1/meme-coin/create", `{"description": "No name"}`},
+		{"POST", "/v1/meme-coin/batch", `{"items": []}`},
+		{"POST", "/v1/meme-coin/pokes", `{"pokes": {"1": 0}}`},
+		{"PATCH", "/v1/meme-coin/1", `{"description": 1}`},
+		{"GET", "/v1/tags/dogs/leaderboard?limit=0", ""},
+		{"POST", "/admin/categories", `{"description": "No name"}`},
+	} {
+		w = send(invalid.method, invalid.path, "application/json", invalid.body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "%s %s", invalid.method, invalid.path)
+		assert.Contains(t, w.Body.String(), `"message":"Invalid request"`, "%s %s", invalid.method, invalid.path)
+	}
+
+	// Case 3: literal paths are not taken for an ID
+	w = send("GET", "/v1/meme-coin/export?format=ndjson", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Case 4: import files are left to the handler
+	w = send("POST", "/v1/meme-coin/import", "text/csv", "name,description\nDoge,Wow\n")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Case 5: unknown routes are answered by the router
+	w = send("GET", "/v1/unknown", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	spec := loadOpenAPI(t)
+	router := newDocumentedRouter(spec)
+
+	// Case 1: success and error responses of every operation match the document
+	for _, request := range []struct {
+		method      string
+		path        string
+		contentType string
+		body        string
+	}{
+		{"GET", "/v1/meme-coin?ids=1,2,0", "", ""},
+		{"GET", "/v1/meme-coin/1", "", ""},
+		{"GET", "/v1/meme-coin/abc", "", ""},
+		{"PATCH", "/v1/meme-coin/1", "application/json", `{"description": "Wow"}`},
+		{"DELETE", "/v1/meme-coin/1", "", ""},
+		{"POST", "/v1/meme-coin/1/poke", "", ""},
+		{"POST", "/v1/meme-coin/pokes", "application/json", `{"pokes": {"1": 2}}`},
+		{"POST", "/v1/meme-coin/create", "application/json", `{"name": "Doge"}`},
+		{"POST", "/v1/meme-coin/create", "application/json", `{"name": "` + mocks.ExistingMemeCoinName + `"}`},
+		{"POST", "/v1/meme-coin/batch", "application/json", `{"items": [{"name": "Doge"}, {"name": "` + mocks.ExistingMemeCoinName + `"}]}`},
+		{"GET", "/v1/meme-coin/export", "", ""},
+		{"POST", "/v1/meme-coin/import", "application/x-ndjson", "{\"name\": \"Doge\"}\n{}\n"},
+		{"POST", "/v1/meme-coin/import", "text/csv", "name\nDoge\n\"Pepe\n"},
+		{"POST", "/v1/meme-coin/import?format=xml", "", ""},
+		{"GET", "/v1/openapi.yaml", "", ""},
+		{"GET", "/health/live", "", ""},
+		{"GET", "/health/ready", "", ""},
+		{"GET", "/health/database", "", ""},
+		{"GET", "/admin/log-level", "", ""},
+		{"PUT", "/admin/log-level", "application/json", `{"level": "loud"}`},
+		{"PUT", "/v1/meme-coin/1/tags/dogs", "", ""},
+		{"PUT", "/v1/meme-coin/1/tags/Not%20a%20tag", "", ""},
+		{"GET", "/v1/meme-coin/1/tags", "", ""},
+		{"GET", "/v1/tags", "", ""},
+		{"GET", "/v1/tags/dogs/meme-coins?limit=1", "", ""},
+		{"GET", "/v1/tags/dogs/leaderboard", "", ""},
+		{"POST", "/admin/categories", "application/json", `{"name": "animals"}`},
+		{"POST", "/admin/categories", "application/json", `{"name": "animals"}`},
+		{"GET", "/admin/categories", "", ""},
+		{"PUT", "/admin/tags/dogs/category", "application/json", `{"category": "animals"}`},
+		{"PUT", "/admin/tags/cats/category", "application/json", `{"category": "animals"}`},
+		{"DELETE", "/admin/categories/animals", "", ""},
+		{"DELETE", "/v1/meme-coin/1/tags/dogs", "", ""},
+		{"DELETE", "/v1/meme-coin/1/tags/dogs", "", ""},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(request.method, request.path, strings.NewReader(request.body))
+		if request.contentType != "" {
+			req.Header.Set("Content-Type", request.contentType)
+		}
+		router.ServeHTTP(w, req)
+		assertResponseMatchesOpenAPI(t, spec, req, w)
+	}
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"portto-assignment/config"
+	"portto-assignment/internal/repositories"
+	"regexp"
+	"testing"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenRedisClient(t *testing.T) {
+	// Case 1: a single node by default
+	client, err := config.OpenRedisClient(config.RedisConfig{URL: "redis://localhost:6379/0"})
+	assert.NoError(t, err)
+	assert.IsType(t, &redis.Client{}, client)
+	client.Close()
+
+	// Case 2: sentinel mode needs the master name and the sentinels
+	_, err = config.OpenRedisClient(config.RedisConfig{Mode: config.RedisModeSentinel, SentinelMaster: "mymaster"})
+	assert.Error(t, err)
+
+	client, err = config.OpenRedisClient(config.RedisConfig{
+		Mode:           config.RedisModeSentinel,
+		URL:            "redis://:secret@ignored:6379/2",
+		SentinelMaster: "mymaster",
+		SentinelAddrs:  []string{"sentinel-1:26379", "sentinel-2:26379"},
+	})
+	assert.NoError(t, err)
+	assert.IsType(t, &redis.Client{}, client)
+	client.Close()
+
+	// Case 3: cluster mode needs at least one node
+	_, err = config.OpenRedisClient(config.RedisConfig{Mode: config.RedisModeCluster})
+	assert.Error(t, err)
+
+	client, err = config.OpenRedisClient(config.RedisConfig{Mode: config.RedisModeCluster, ClusterAddrs: []string{"node-1:6379", "node-2:6379"}})
+	assert.NoError(t, err)
+	assert.IsType(t, &redis.ClusterClient{}, client)
+	client.Close()
+
+	// Case 4: unknown mode
+	_, err = config.OpenRedisClient(config.RedisConfig{Mode: "replicated"})
+	assert.Error(t, err)
+}
+
+func TestRedisClusterSync(t *testing.T) {
+	dbmock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbmock.Close()
+
+	mockClusterClient, redismock := redismock.NewClusterMock()
+	defer mockClusterClient.Close()
+
+	t.Run("CrossSlot", func(t *testing.T) {
+		redisCachedRepository := repositories.NewRedisCachedRepository(dbmock, mockClusterClient, repositories.RepositoryConfig{
+			NeedToSync: false,
+		})
+
+		// Case 1: keys in different slots are read with one MGET per slot
+		redismock.ExpectMGet("meme:popularity_score:1").SetVal([]interface{}{"4"})
+		redismock.ExpectMGet("meme:popularity_score:2").SetVal([]interface{}{"9"})
+		dbmock.ExpectExec(regexp.QuoteMeta("FROM unnest($1::int[], $2::int[])")).
+			WithArgs([]int{1, 2}, []int{4, 9}).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+		err := redisCachedRepository.SyncKeys([]string{"meme:popularity_score:2", "meme:popularity_score:1"})
+		assert.NoError(t, err)
+
+		assert.NoError(t, redismock.ExpectationsWereMet())
+		assert.NoError(t, dbmock.ExpectationsWereMet())
+	})
+
+	t.Run("HashTags", func(t *testing.T) {
+		keys, err := repositories.NewKeyBuilder(repositories.KeyBuilderConfig{Environment: "prod", HashTags: true})
+		assert.NoError(t, err)
+		redisCachedRepository := repositories.NewRedisCachedRepository(dbmock, mockClusterClient, repositories.RepositoryConfig{
+			NeedToSync: false,
+			Keys:       keys,
+		})
+
+		// Case 1: keys sharing the hash tag are read with a single MGET
+		redismock.ExpectMGet("{prod:meme}:popularity_score:1", "{prod:meme}:popularity_score:2").SetVal([]interface{}{"4", nil})
+		dbmock.ExpectExec(regexp.QuoteMeta("FROM unnest($1::int[], $2::int[])")).
+			WithArgs([]int{1}, []int{4}).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		err = redisCachedRepository.SyncKeys([]string{keys.PopularityScore(1), keys.PopularityScore(2)})
+		assert.NoError(t, err)
+
+		assert.NoError(t, redismock.ExpectationsWereMet())
+		assert.NoError(t, dbmock.ExpectationsWereMet())
+	})
+}
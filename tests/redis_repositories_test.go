@@ -1,25 +1,43 @@
 package tests
 
 import (
+	"context"
+	"errors"
 	"portto-assignment/internal/repositories"
+	"portto-assignment/pkg/clock"
+	"regexp"
 	"testing"
+	"time"
 
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-redis/redismock/v9"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
 )
 
+// compareAndSetScript is the script the database policy replaces mismatched scores with
+const compareAndSetScript = `if redis.call('GET', KEYS[1]) == ARGV[1] then redis.call('SET', KEYS[1], ARGV[2]) return 1 end return 0`
+
+// markSyncedScript is the script the sync worker clears the marks of written increments with
+const markSyncedScript = `if redis.call('HINCRBY', KEYS[1], ARGV[1], -tonumber(ARGV[2])) <= 0 then redis.call('HDEL', KEYS[1], ARGV[1]) end return 0`
+
+// unsyncedKey is the hash of the increments not written to the database yet
+const unsyncedKey = "meme:unsynced_scores"
+
+// adjustScript is the script AdjustBy increments scores with unless they would go negative
+const adjustScript = `if tonumber(redis.call('GET', KEYS[1]) or '0') + tonumber(ARGV[1]) < 0 then return false end return redis.call('INCRBY', KEYS[1], ARGV[1])`
+
 type RedisCachedRepositoryTest struct {
-	dbmock                sqlmock.Sqlmock
+	dbmock                pgxmock.PgxPoolIface
 	redismock             redismock.ClientMock
 	redisCachedRepository *repositories.RedisCachedRepository
 }
 
 func TestRedisCachedRepository(t *testing.T) {
-	mockDB, dbmock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	dbmock, err := pgxmock.NewPool()
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer mockDB.Close()
+	defer dbmock.Close()
 
 	mockRedisClient, redismock := redismock.NewClientMock()
 	defer mockRedisClient.Close()
@@ -27,7 +45,7 @@ func TestRedisCachedRepository(t *testing.T) {
 	redisCachedRepositoryTest := RedisCachedRepositoryTest{
 		dbmock:    dbmock,
 		redismock: redismock,
-		redisCachedRepository: repositories.NewRedisCachedRepository(mockDB, mockRedisClient, repositories.RepositoryConfig{
+		redisCachedRepository: repositories.NewRedisCachedRepository(dbmock, mockRedisClient, repositories.RepositoryConfig{
 			SyncBatchSize: repositories.DefaultSyncBatchSize,
 			SyncInterval:  repositories.DefaultSyncInterval,
 			NeedToSync:    false,
@@ -38,23 +56,59 @@ func TestRedisCachedRepository(t *testing.T) {
 	t.Run("TestIncr", redisCachedRepositoryTest.testIncrBy)
 	t.Run("TestDelete", redisCachedRepositoryTest.testDelete)
 	t.Run("TestExists", redisCachedRepositoryTest.testExists)
+	t.Run("TestSetMany", redisCachedRepositoryTest.testSetMany)
+	t.Run("TestIncrByMany", redisCachedRepositoryTest.testIncrByMany)
+	t.Run("TestAdjustBy", redisCachedRepositoryTest.testAdjustBy)
+	t.Run("TestExistsMany", redisCachedRepositoryTest.testExistsMany)
+	t.Run("TestGet", redisCachedRepositoryTest.testGet)
+	t.Run("TestSyncKeys", redisCachedRepositoryTest.testSyncKeys)
+	t.Run("TestReconcile", redisCachedRepositoryTest.testReconcile)
+
+	// One worker and small pages keep the warm-up commands in a predictable order
+	redisCachedRepositoryTest.redisCachedRepository = repositories.NewRedisCachedRepository(dbmock, mockRedisClient, repositories.RepositoryConfig{
+		NeedToSync:     false,
+		WarmUpPageSize: 2,
+		WarmUpWorkers:  1,
+	})
+	t.Run("TestWarmUp", redisCachedRepositoryTest.testWarmUp)
 }
 
 func (r *RedisCachedRepositoryTest) testIncrBy(t *testing.T) {
 	key := "test_key"
+	r.redismock.ExpectHIncrBy(unsyncedKey, key, 1).SetVal(1)
 	r.redismock.ExpectIncrBy(key, 1).SetVal(1)
 
-	err := r.redisCachedRepository.IncrBy("test_key", 1)
+	err := r.redisCachedRepository.IncrBy(context.Background(), "test_key", 1)
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
+func (r *RedisCachedRepositoryTest) testAdjustBy(t *testing.T) {
+	key := "test_key"
+
+	// Case 1: the result of the increment is returned
+	r.redismock.ExpectHIncrBy(unsyncedKey, key, 1).SetVal(1)
+	r.redismock.ExpectEval(adjustScript, []string{key}, -2).SetVal(int64(3))
+	value, err := r.redisCachedRepository.AdjustBy(context.Background(), key, -2)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, value)
+
+	// Case 2: the script leaves the score alone when it would go negative
+	r.redismock.ExpectHIncrBy(unsyncedKey, key, 1).SetVal(2)
+	r.redismock.ExpectEval(adjustScript, []string{key}, -4).RedisNil()
+	r.redismock.ExpectEval(markSyncedScript, []string{unsyncedKey}, key, 1).SetVal(int64(0))
+	_, err = r.redisCachedRepository.AdjustBy(context.Background(), key, -4)
+	assert.ErrorIs(t, err, repositories.ErrNegativePopularityScore)
+
+	assert.NoError(t, r.redismock.ExpectationsWereMet())
+}
+
 func (r *RedisCachedRepositoryTest) testSet(t *testing.T) {
 	key := "test_key"
 	r.redismock.ExpectSet(key, 0, 0).SetVal("OK")
 
-	err := r.redisCachedRepository.Set("test_key", 0)
+	err := r.redisCachedRepository.Set(context.Background(), "test_key", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -64,7 +118,7 @@ func (r *RedisCachedRepositoryTest) testDelete(t *testing.T) {
 	key := "test_key"
 	r.redismock.ExpectDel(key).SetVal(1)
 
-	err := r.redisCachedRepository.Delete("test_key")
+	err := r.redisCachedRepository.Delete(context.Background(), "test_key")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -74,7 +128,7 @@ func (r *RedisCachedRepositoryTest) testExists(t *testing.T) {
 	key := "test_key"
 	r.redismock.ExpectExists(key).SetVal(1)
 
-	exists, err := r.redisCachedRepository.Exists(key)
+	exists, err := r.redisCachedRepository.Exists(context.Background(), key)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -83,3 +137,343 @@ func (r *RedisCachedRepositoryTest) testExists(t *testing.T) {
 		t.Fatal("Key should exist")
 	}
 }
+
+func (r *RedisCachedRepositoryTest) testSetMany(t *testing.T) {
+	key := "test_key"
+	r.redismock.ExpectSet(key, 5, 0).SetVal("OK")
+
+	err := r.redisCachedRepository.SetMany(context.Background(), map[string]int{key: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.redismock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (r *RedisCachedRepositoryTest) testIncrByMany(t *testing.T) {
+	key := "test_key"
+	r.redismock.ExpectHIncrBy(unsyncedKey, key, 1).SetVal(1)
+	r.redismock.ExpectIncrBy(key, 3).SetVal(3)
+
+	err := r.redisCachedRepository.IncrByMany(context.Background(), map[string]int{key: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.redismock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIncrByManyPartialFailure(t *testing.T) {
+	mockRedisClient, redismock := redismock.NewClientMock()
+	defer mockRedisClient.Close()
+	redisCachedRepository := repositories.NewRedisCachedRepository(nil, mockRedisClient, repositories.RepositoryConfig{
+		NeedToSync: false,
+	})
+
+	// Case 1: the failed increment is returned and takes back its mark, the applied one keeps it for the sync worker
+	redismock.ExpectHIncrBy(unsyncedKey, "meme:popularity_score:1", 1).SetVal(1)
+	redismock.ExpectIncrBy("meme:popularity_score:1", 2).SetVal(2)
+	redismock.ExpectHIncrBy(unsyncedKey, "meme:popularity_score:2", 1).SetVal(1)
+	redismock.ExpectIncrBy("meme:popularity_score:2", 3).SetErr(errors.New("OOM command not allowed"))
+	redismock.ExpectEval(markSyncedScript, []string{unsyncedKey}, "meme:popularity_score:2", 1).SetVal(int64(0))
+	err := redisCachedRepository.IncrByMany(context.Background(), map[string]int{"meme:popularity_score:1": 2, "meme:popularity_score:2": 3})
+
+	var incrementErr *repositories.IncrementError
+	assert.ErrorAs(t, err, &incrementErr)
+	assert.Equal(t, map[string]int{"meme:popularity_score:2": 3}, incrementErr.Failed)
+	assert.NoError(t, redismock.ExpectationsWereMet())
+}
+
+func (r *RedisCachedRepositoryTest) testExistsMany(t *testing.T) {
+	keys := []string{"test_key", "missing_key"}
+	r.redismock.ExpectExists(keys[0]).SetVal(1)
+	r.redismock.ExpectExists(keys[1]).SetVal(0)
+
+	existsMap, err := r.redisCachedRepository.ExistsMany(context.Background(), keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !existsMap["test_key"] {
+		t.Fatal("Key should exist")
+	}
+	if existsMap["missing_key"] {
+		t.Fatal("Key should not exist")
+	}
+}
+
+func (r *RedisCachedRepositoryTest) testGet(t *testing.T) {
+	r.redismock.ExpectGet("test_key").SetVal("7")
+	r.redismock.ExpectGet("missing_key").RedisNil()
+
+	value, found, err := r.redisCachedRepository.Get(context.Background(), "test_key")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 7, value)
+
+	value, found, err = r.redisCachedRepository.Get(context.Background(), "missing_key")
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, 0, value)
+}
+
+func (r *RedisCachedRepositoryTest) testSyncKeys(t *testing.T) {
+	keys := []string{"meme:popularity_score:3", "meme:popularity_score:4", "meme:popularity_score:5"}
+
+	// Case 1: one MGET and one UPDATE for the whole batch, deleted keys are skipped
+	r.redismock.ExpectMGet(keys...).SetVal([]interface{}{"12", nil, "7"})
+	r.dbmock.ExpectExec(regexp.QuoteMeta("FROM unnest($1::int[], $2::int[])")).
+		WithArgs([]int{3, 5}, []int{12, 7}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+	err := r.redisCachedRepository.SyncKeys([]string{keys[2], keys[0], keys[1]})
+	assert.NoError(t, err)
+
+	// Case 2: the batch fails as a whole
+	r.redismock.ExpectMGet(keys[0]).SetVal([]interface{}{"12"})
+	r.dbmock.ExpectExec(regexp.QuoteMeta("FROM unnest($1::int[], $2::int[])")).
+		WithArgs([]int{3}, []int{12}).
+		WillReturnError(errors.New("connection reset"))
+	err = r.redisCachedRepository.SyncKeys(keys[:1])
+	assert.Error(t, err)
+
+	// Case 3: Redis fails before anything is written
+	r.redismock.ExpectMGet(keys[0]).SetErr(errors.New("connection refused"))
+	err = r.redisCachedRepository.SyncKeys(keys[:1])
+	assert.Error(t, err)
+
+	assert.NoError(t, r.dbmock.ExpectationsWereMet())
+	assert.NoError(t, r.redismock.ExpectationsWereMet())
+}
+
+func (r *RedisCachedRepositoryTest) expectReconcileScan() {
+	keys := []string{"meme:popularity_score:1", "meme:popularity_score:2", "meme:popularity_score:9", "meme:popularity_score:abc"}
+	r.redismock.ExpectScan(0, "meme:popularity_score:*", repositories.DefaultSyncBatchSize).SetVal(keys, 0)
+	r.redismock.ExpectGet(keys[0]).SetVal("5")
+	r.redismock.ExpectGet(keys[1]).SetVal("7")
+	r.redismock.ExpectGet(keys[2]).SetVal("3")
+	r.redismock.ExpectGet(keys[3]).SetVal("not a number")
+
+	r.dbmock.ExpectQuery(regexp.QuoteMeta("SELECT id, popularity_score FROM meme_coins ORDER BY id")).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "popularity_score"}).
+			AddRow(1, 5).
+			AddRow(2, 4).
+			AddRow(3, 8))
+}
+
+func (r *RedisCachedRepositoryTest) testReconcile(t *testing.T) {
+	// Case 1: unknown policy
+	report, err := r.redisCachedRepository.Reconcile("unknown")
+	assert.Error(t, err)
+	assert.Nil(t, report)
+
+	// Case 2: report only
+	r.expectReconcileScan()
+	report, err = r.redisCachedRepository.Reconcile(repositories.ReconcilePolicyReport)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.ScannedKeys)
+	assert.Equal(t, 3, report.ScannedRows)
+	assert.Equal(t, []string{"meme:popularity_score:9", "meme:popularity_score:abc"}, report.OrphanKeys)
+	assert.Equal(t, []repositories.StoredScore{{Id: 3, Stored: 8}}, report.MissingKeys)
+	assert.Equal(t, []repositories.ScoreMismatch{{Id: 2, Cached: 7, Stored: 4}}, report.Mismatches)
+	assert.Equal(t, 0, report.Repaired)
+
+	// Case 3: the database wins
+	r.expectReconcileScan()
+	r.redismock.ExpectHMGet(unsyncedKey, "meme:popularity_score:2").SetVal([]interface{}{nil})
+	r.redismock.ExpectDel("meme:popularity_score:9").SetVal(1)
+	r.redismock.ExpectDel("meme:popularity_score:abc").SetVal(1)
+	r.redismock.ExpectSetNX("meme:popularity_score:3", 8, 0).SetVal(true)
+	r.redismock.ExpectEval(compareAndSetScript, []string{"meme:popularity_score:2"}, 7, 4).SetVal(int64(1))
+	report, err = r.redisCachedRepository.Reconcile(repositories.ReconcilePolicyDatabase)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.Repaired)
+	assert.Equal(t, 0, report.Skipped)
+
+	// Case 4: Redis wins, mismatched scores are written to the database
+	r.expectReconcileScan()
+	r.redismock.ExpectDel("meme:popularity_score:9").SetVal(1)
+	r.redismock.ExpectDel("meme:popularity_score:abc").SetVal(1)
+	r.redismock.ExpectSetNX("meme:popularity_score:3", 8, 0).SetVal(true)
+	r.redismock.ExpectMGet("meme:popularity_score:2").SetVal([]interface{}{"7"})
+	r.dbmock.ExpectExec(regexp.QuoteMeta("FROM unnest($1::int[], $2::int[])")).
+		WithArgs([]int{2}, []int{7}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	report, err = r.redisCachedRepository.Reconcile(repositories.ReconcilePolicyRedis)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.Repaired)
+
+	// Case 5: a poke landing between the scan and the repair is kept, the score is no longer the scanned one
+	r.expectReconcileScan()
+	r.redismock.ExpectHMGet(unsyncedKey, "meme:popularity_score:2").SetVal([]interface{}{nil})
+	r.redismock.ExpectDel("meme:popularity_score:9").SetVal(1)
+	r.redismock.ExpectDel("meme:popularity_score:abc").SetVal(1)
+	r.redismock.ExpectSetNX("meme:popularity_score:3", 8, 0).SetVal(true)
+	r.redismock.ExpectEval(compareAndSetScript, []string{"meme:popularity_score:2"}, 7, 4).SetVal(int64(0))
+	report, err = r.redisCachedRepository.Reconcile(repositories.ReconcilePolicyDatabase)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Repaired)
+	assert.Equal(t, 1, report.Skipped)
+
+	// Case 6: a poke not synced yet is left to the sync worker, whichever process made it
+	r.expectReconcileScan()
+	r.redismock.ExpectHMGet(unsyncedKey, "meme:popularity_score:2").SetVal([]interface{}{"1"})
+	r.redismock.ExpectDel("meme:popularity_score:9").SetVal(1)
+	r.redismock.ExpectDel("meme:popularity_score:abc").SetVal(1)
+	r.redismock.ExpectSetNX("meme:popularity_score:3", 8, 0).SetVal(true)
+	report, err = r.redisCachedRepository.Reconcile(repositories.ReconcilePolicyDatabase)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Repaired)
+	assert.Equal(t, 1, report.Skipped)
+
+	assert.NoError(t, r.dbmock.ExpectationsWereMet())
+	assert.NoError(t, r.redismock.ExpectationsWereMet())
+}
+
+func (r *RedisCachedRepositoryTest) expectWarmUpPages() {
+	sqlStatement := regexp.QuoteMeta("SELECT id, popularity_score FROM meme_coins WHERE id > $1 ORDER BY id LIMIT $2")
+	r.dbmock.ExpectQuery(sqlStatement).
+		WithArgs(0, 2).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "popularity_score"}).AddRow(1, 10).AddRow(2, 20))
+	r.dbmock.ExpectQuery(sqlStatement).
+		WithArgs(2, 2).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "popularity_score"}).AddRow(3, 30))
+}
+
+func (r *RedisCachedRepositoryTest) testWarmUp(t *testing.T) {
+	// Case 1: keys already in Redis are not overwritten
+	r.expectWarmUpPages()
+	r.redismock.ExpectSetNX("meme:popularity_score:1", 10, 0).SetVal(true)
+	r.redismock.ExpectSetNX("meme:popularity_score:2", 20, 0).SetVal(false)
+	r.redismock.ExpectSetNX("meme:popularity_score:3", 30, 0).SetVal(true)
+
+	report, err := r.redisCachedRepository.WarmUp()
+	assert.NoError(t, err)
+	assert.Equal(t, repositories.WarmUpReport{Rows: 3, Loaded: 2, Skipped: 1}, *report)
+
+	// Case 2: a failed page doesn't stop the next ones
+	r.expectWarmUpPages()
+	r.redismock.ExpectSetNX("meme:popularity_score:1", 10, 0).SetErr(errors.New("connection reset"))
+	r.redismock.ExpectSetNX("meme:popularity_score:3", 30, 0).SetVal(true)
+
+	report, err = r.redisCachedRepository.WarmUp()
+	assert.Error(t, err)
+	assert.Equal(t, repositories.WarmUpReport{Rows: 3, Loaded: 1, FailedPages: 1}, *report)
+
+	// Case 3: the database fails
+	r.dbmock.ExpectQuery(regexp.QuoteMeta("FROM meme_coins WHERE id > $1")).
+		WithArgs(0, 2).
+		WillReturnError(errors.New("connection refused"))
+
+	report, err = r.redisCachedRepository.WarmUp()
+	assert.Error(t, err)
+	assert.Equal(t, 0, report.Rows)
+
+	assert.NoError(t, r.dbmock.ExpectationsWereMet())
+	assert.NoError(t, r.redismock.ExpectationsWereMet())
+}
+
+func TestWarmUpWithRedisDown(t *testing.T) {
+	dbmock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbmock.Close()
+
+	mockRedisClient, redismock := redismock.NewClientMock()
+	defer mockRedisClient.Close()
+
+	// Case 1: the warm-up is left to the recovery of the fallback, the database isn't read at boot
+	redismock.ExpectPing().SetErr(errors.New("connection refused"))
+	repositories.NewRedisCachedRepository(dbmock, mockRedisClient, repositories.RepositoryConfig{
+		SyncInterval: time.Minute,
+		NeedToSync:   true,
+		Clock:        clock.NewFake(time.Now()),
+	})
+
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+	assert.NoError(t, redismock.ExpectationsWereMet())
+}
+
+func TestPopularityScoreSyncWorker(t *testing.T) {
+	dbmock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbmock.Close()
+
+	mockRedisClient, redismock := redismock.NewClientMock()
+	defer mockRedisClient.Close()
+
+	// The warm-up finds an empty database
+	redismock.ExpectPing().SetVal("PONG")
+	dbmock.ExpectQuery(regexp.QuoteMeta("SELECT id, popularity_score FROM meme_coins WHERE id > $1 ORDER BY id LIMIT $2")).
+		WithArgs(0, repositories.DefaultWarmUpPageSize).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "popularity_score"}))
+	// Case 1: the keys a stopped process left marked are synced on the first tick
+	redismock.ExpectHGetAll(unsyncedKey).SetVal(map[string]string{"meme:popularity_score:2": "3"})
+	redismock.ExpectMGet("meme:popularity_score:2").SetVal([]interface{}{"9"})
+	dbmock.ExpectExec(regexp.QuoteMeta("FROM unnest($1::int[], $2::int[])")).
+		WithArgs([]int{2}, []int{9}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	redismock.ExpectEval(markSyncedScript, []string{unsyncedKey}, "meme:popularity_score:2", 3).SetVal(int64(0))
+	fake := clock.NewFake(time.Now())
+	redisCachedRepository := repositories.NewRedisCachedRepository(dbmock, mockRedisClient, repositories.RepositoryConfig{
+		SyncInterval: time.Minute,
+		NeedToSync:   true,
+		Clock:        fake,
+	})
+
+	// Synced is true once every expectation is met, the marks are cleared after the database write
+	synced := func() bool {
+		fake.Advance(time.Minute)
+		return dbmock.ExpectationsWereMet() == nil && redismock.ExpectationsWereMet() == nil
+	}
+	assert.Eventually(t, synced, time.Second, time.Millisecond)
+
+	// Case 2: a poke is written to the database on the next tick, without waiting for a full batch
+	redismock.ExpectHIncrBy(unsyncedKey, "meme:popularity_score:3", 1).SetVal(1)
+	redismock.ExpectIncrBy("meme:popularity_score:3", 1).SetVal(13)
+	redismock.ExpectMGet("meme:popularity_score:3").SetVal([]interface{}{"13"})
+	dbmock.ExpectExec(regexp.QuoteMeta("FROM unnest($1::int[], $2::int[])")).
+		WithArgs([]int{3}, []int{13}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	redismock.ExpectEval(markSyncedScript, []string{unsyncedKey}, "meme:popularity_score:3", 1).SetVal(int64(0))
+	assert.NoError(t, redisCachedRepository.IncrBy(context.Background(), "meme:popularity_score:3", 1))
+
+	// The worker may take the tick before the dirty key, so the clock is advanced until the sync happens
+	assert.Eventually(t, synced, time.Second, time.Millisecond)
+
+	// Case 3: a failed write keeps the batch pending, it is retried once the backoff is over
+	redismock.ExpectHIncrBy(unsyncedKey, "meme:popularity_score:4", 1).SetVal(1)
+	redismock.ExpectIncrBy("meme:popularity_score:4", 1).SetVal(5)
+	redismock.ExpectMGet("meme:popularity_score:4").SetVal([]interface{}{"5"})
+	dbmock.ExpectExec(regexp.QuoteMeta("FROM unnest($1::int[], $2::int[])")).
+		WithArgs([]int{4}, []int{5}).
+		WillReturnError(errors.New("connection refused"))
+	redismock.ExpectMGet("meme:popularity_score:4").SetVal([]interface{}{"5"})
+	dbmock.ExpectExec(regexp.QuoteMeta("FROM unnest($1::int[], $2::int[])")).
+		WithArgs([]int{4}, []int{5}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	redismock.ExpectEval(markSyncedScript, []string{unsyncedKey}, "meme:popularity_score:4", 1).SetVal(int64(0))
+	assert.NoError(t, redisCachedRepository.IncrBy(context.Background(), "meme:popularity_score:4", 1))
+
+	assert.Eventually(t, synced, time.Second, time.Millisecond)
+
+	// Case 4: Close writes the pending keys without waiting for the tick
+	redismock.ExpectHIncrBy(unsyncedKey, "meme:popularity_score:5", 1).SetVal(1)
+	redismock.ExpectIncrBy("meme:popularity_score:5", 1).SetVal(2)
+	redismock.ExpectMGet("meme:popularity_score:5").SetVal([]interface{}{"2"})
+	dbmock.ExpectExec(regexp.QuoteMeta("FROM unnest($1::int[], $2::int[])")).
+		WithArgs([]int{5}, []int{2}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	redismock.ExpectEval(markSyncedScript, []string{unsyncedKey}, "meme:popularity_score:5", 1).SetVal(int64(0))
+	assert.NoError(t, redisCachedRepository.IncrBy(context.Background(), "meme:popularity_score:5", 1))
+	assert.NoError(t, redisCachedRepository.Close())
+
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+	assert.NoError(t, redismock.ExpectationsWereMet())
+}
@@ -1,41 +1,55 @@
 package tests
 
 import (
+	"context"
 	"math/rand"
 	"portto-assignment/internal/repositories"
+	"portto-assignment/pkg/clock"
 	"regexp"
 	"testing"
 	"time"
 
-	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pashagolub/pgxmock/v4"
 	"github.com/stretchr/testify/assert"
 )
 
 type MemeCoinRepositoryTest struct {
-	mockConnectionPool sqlmock.Sqlmock
+	mockConnectionPool pgxmock.PgxPoolIface
 	memeCoinRepository *repositories.MemeCoinRepository
+	// now is the time of the fake clock, new rows are created at it
+	now time.Time
 }
 
 func TestMemeCoinRepository(t *testing.T) {
 	// Mocking the database connection
-	mockDB, mock, err := sqlmock.New()
+	mock, err := pgxmock.NewPool()
 	if err != nil {
 		t.Fatal()
 	}
-	defer mockDB.Close()
+	defer mock.Close()
 
-	// Get the repository
-	memeCoinRepository := repositories.NewMemeCoinRepository(mockDB)
+	// Get the repository, on a fake clock so that created_at is known
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	memeCoinRepository := repositories.NewRoutedMemeCoinRepository(repositories.NewDatabaseRouter(mock, nil, repositories.DatabaseRouterConfig{
+		Clock: clock.NewFake(now),
+	}))
 
 	// Run the tests
 	memeCoinRepositoryTest := MemeCoinRepositoryTest{
 		mockConnectionPool: mock,
 		memeCoinRepository: memeCoinRepository,
+		now:                now,
 	}
 	t.Run("FindOne", memeCoinRepositoryTest.testFindOne)
 	t.Run("CreateOne", memeCoinRepositoryTest.testCreateOne)
 	t.Run("UpdateOne", memeCoinRepositoryTest.testUpdateOne)
 	t.Run("DeleteOne", memeCoinRepositoryTest.testDeleteOne)
+	t.Run("FindMany", memeCoinRepositoryTest.testFindMany)
+	t.Run("CreateMany", memeCoinRepositoryTest.testCreateMany)
+	t.Run("StreamAll", memeCoinRepositoryTest.testStreamAll)
+	t.Run("UpsertMany", memeCoinRepositoryTest.testUpsertMany)
+	t.Run("UpdatePopularityScore", memeCoinRepositoryTest.testUpdatePopularityScore)
+	t.Run("IncrementPopularityScore", memeCoinRepositoryTest.testIncrementPopularityScore)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
@@ -54,10 +68,10 @@ func (repo *MemeCoinRepositoryTest) testFindOne(t *testing.T) {
 	sqlStatement := "SELECT id, name, description, created_at, popularity_score FROM meme_coins WHERE id = $1"
 	repo.mockConnectionPool.ExpectQuery(regexp.QuoteMeta(sqlStatement)).
 		WithArgs(fakeMemeCoin.Id).
-		WillReturnRows(sqlmock.
+		WillReturnRows(pgxmock.
 			NewRows([]string{"id", "name", "description", "created_at", "popularity_score"}).
 			AddRow(fakeMemeCoin.Id, fakeMemeCoin.Name, fakeMemeCoin.Description, fakeMemeCoin.CreatedAt, fakeMemeCoin.PopularityScore))
-	memeCoin, err := repo.memeCoinRepository.FindOne(fakeMemeCoin.Id)
+	memeCoin, err := repo.memeCoinRepository.FindOne(context.Background(), fakeMemeCoin.Id)
 	if err != nil {
 		t.Errorf("FindOne() failed, got error: %v", err)
 	}
@@ -74,18 +88,18 @@ func (repo *MemeCoinRepositoryTest) testCreateOne(t *testing.T) {
 		Id:              rand.Intn(100),
 		Name:            "Test MemeCoin",
 		Description:     "Test MemeCoin Description",
-		CreatedAt:       time.Now(),
+		CreatedAt:       repo.now,
 		PopularityScore: 0,
 	}
 
 	// Mocking the database connection
-	sqlStatement := "INSERT INTO meme_coins (name, description) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING RETURNING id, name, description, created_at, popularity_score"
+	sqlStatement := "INSERT INTO meme_coins (name, description, created_at) VALUES ($1, $2, $3) ON CONFLICT (name) DO NOTHING RETURNING id, name, description, created_at, popularity_score"
 	repo.mockConnectionPool.ExpectQuery(regexp.QuoteMeta(sqlStatement)).
-		WithArgs(fakeMemeCoin.Name, fakeMemeCoin.Description).
-		WillReturnRows(sqlmock.
+		WithArgs(fakeMemeCoin.Name, fakeMemeCoin.Description, repo.now).
+		WillReturnRows(pgxmock.
 			NewRows([]string{"id", "name", "description", "created_at", "popularity_score"}).
 			AddRow(fakeMemeCoin.Id, fakeMemeCoin.Name, fakeMemeCoin.Description, fakeMemeCoin.CreatedAt, fakeMemeCoin.PopularityScore))
-	memeCoin, err := repo.memeCoinRepository.CreateOne(fakeMemeCoin.Name, fakeMemeCoin.Description)
+	memeCoin, err := repo.memeCoinRepository.CreateOne(context.Background(), fakeMemeCoin.Name, fakeMemeCoin.Description)
 	if err != nil {
 		t.Errorf("CreateOne() failed, got error: %v", err)
 	}
@@ -110,10 +124,10 @@ func (repo *MemeCoinRepositoryTest) testUpdateOne(t *testing.T) {
 	sqlStatement := "UPDATE meme_coins SET description = $2 WHERE id = $1 RETURNING id, name, description, created_at, popularity_score"
 	repo.mockConnectionPool.ExpectQuery(regexp.QuoteMeta(sqlStatement)).
 		WithArgs(fakeMemeCoin.Id, fakeMemeCoin.Description).
-		WillReturnRows(sqlmock.
+		WillReturnRows(pgxmock.
 			NewRows([]string{"id", "name", "description", "created_at", "popularity_score"}).
 			AddRow(fakeMemeCoin.Id, fakeMemeCoin.Name, fakeMemeCoin.Description, fakeMemeCoin.CreatedAt, fakeMemeCoin.PopularityScore))
-	memeCoin, err := repo.memeCoinRepository.UpdateOne(fakeMemeCoin.Id, fakeMemeCoin.Description)
+	memeCoin, err := repo.memeCoinRepository.UpdateOne(context.Background(), fakeMemeCoin.Id, fakeMemeCoin.Description)
 	if err != nil {
 		t.Errorf("UpdateOne() failed, got error: %v", err)
 	}
@@ -138,10 +152,10 @@ func (repo *MemeCoinRepositoryTest) testDeleteOne(t *testing.T) {
 	sqlStatement := "DELETE FROM meme_coins WHERE id = $1 RETURNING id, name, description, created_at, popularity_score"
 	repo.mockConnectionPool.ExpectQuery(regexp.QuoteMeta(sqlStatement)).
 		WithArgs(fakeMemeCoin.Id).
-		WillReturnRows(sqlmock.
+		WillReturnRows(pgxmock.
 			NewRows([]string{"id", "name", "description", "created_at", "popularity_score"}).
 			AddRow(fakeMemeCoin.Id, fakeMemeCoin.Name, fakeMemeCoin.Description, fakeMemeCoin.CreatedAt, fakeMemeCoin.PopularityScore))
-	memeCoin, err := repo.memeCoinRepository.DeleteOne(fakeMemeCoin.Id)
+	memeCoin, err := repo.memeCoinRepository.DeleteOne(context.Background(), fakeMemeCoin.Id)
 	if err != nil {
 		t.Errorf("DeleteOne() failed, got error: %v", err)
 	}
@@ -152,3 +166,157 @@ func (repo *MemeCoinRepositoryTest) testDeleteOne(t *testing.T) {
 	assert.Equal(t, fakeMemeCoin.CreatedAt, memeCoin.CreatedAt)
 	assert.Equal(t, fakeMemeCoin.PopularityScore, memeCoin.PopularityScore)
 }
+
+func (repo *MemeCoinRepositoryTest) testFindMany(t *testing.T) {
+	fakeMemeCoins := []repositories.MemeCoin{
+		{Id: 1, Name: "Test MemeCoin 1", Description: "Test MemeCoin Description 1", CreatedAt: time.Now(), PopularityScore: 0},
+		{Id: 2, Name: "Test MemeCoin 2", Description: "Test MemeCoin Description 2", CreatedAt: time.Now(), PopularityScore: 5},
+	}
+	ids := []int{1, 2, 3}
+
+	// Mocking the database connection
+	sqlStatement := "SELECT id, name, description, created_at, popularity_score FROM meme_coins WHERE id = ANY($1) ORDER BY id"
+	rows := pgxmock.NewRows([]string{"id", "name", "description", "created_at", "popularity_score"})
+	for _, fakeMemeCoin := range fakeMemeCoins {
+		rows.AddRow(fakeMemeCoin.Id, fakeMemeCoin.Name, fakeMemeCoin.Description, fakeMemeCoin.CreatedAt, fakeMemeCoin.PopularityScore)
+	}
+	repo.mockConnectionPool.ExpectQuery(regexp.QuoteMeta(sqlStatement)).
+		WithArgs(ids).
+		WillReturnRows(rows)
+	memeCoins, err := repo.memeCoinRepository.FindMany(context.Background(), ids)
+	if err != nil {
+		t.Errorf("FindMany() failed, got error: %v", err)
+	}
+
+	assert.Equal(t, fakeMemeCoins, memeCoins)
+}
+
+func (repo *MemeCoinRepositoryTest) testCreateMany(t *testing.T) {
+	fakeMemeCoin := repositories.MemeCoin{
+		Id:              rand.Intn(100),
+		Name:            "Test MemeCoin",
+		Description:     "Test MemeCoin Description",
+		CreatedAt:       repo.now,
+		PopularityScore: 0,
+	}
+	newMemeCoins := []repositories.NewMemeCoin{
+		{Name: fakeMemeCoin.Name, Description: fakeMemeCoin.Description},
+		{Name: "Existing MemeCoin", Description: "Existing MemeCoin Description"},
+	}
+
+	// Mocking the database connection, the existing name is skipped by ON CONFLICT
+	sqlStatement := "INSERT INTO meme_coins (name, description, created_at) SELECT name, description, $3 FROM unnest($1::text[], $2::text[]) AS input(name, description) ON CONFLICT (name) DO NOTHING RETURNING id, name, description, created_at, popularity_score"
+	repo.mockConnectionPool.ExpectQuery(regexp.QuoteMeta(sqlStatement)).
+		WithArgs([]string{fakeMemeCoin.Name, "Existing MemeCoin"}, []string{fakeMemeCoin.Description, "Existing MemeCoin Description"}, repo.now).
+		WillReturnRows(pgxmock.
+			NewRows([]string{"id", "name", "description", "created_at", "popularity_score"}).
+			AddRow(fakeMemeCoin.Id, fakeMemeCoin.Name, fakeMemeCoin.Description, fakeMemeCoin.CreatedAt, fakeMemeCoin.PopularityScore))
+	memeCoins, err := repo.memeCoinRepository.CreateMany(context.Background(), newMemeCoins)
+	if err != nil {
+		t.Errorf("CreateMany() failed, got error: %v", err)
+	}
+
+	assert.Equal(t, []repositories.MemeCoin{fakeMemeCoin}, memeCoins)
+}
+
+func (repo *MemeCoinRepositoryTest) testStreamAll(t *testing.T) {
+	fakeMemeCoins := []repositories.MemeCoin{
+		{Id: 1, Name: "Test MemeCoin 1", Description: "Test MemeCoin Description 1", CreatedAt: time.Now(), PopularityScore: 0},
+		{Id: 2, Name: "Test MemeCoin 2", Description: "Test MemeCoin Description 2", CreatedAt: time.Now(), PopularityScore: 5},
+	}
+
+	// Mocking the database connection
+	sqlStatement := "SELECT id, name, description, created_at, popularity_score FROM meme_coins ORDER BY id"
+	rows := pgxmock.NewRows([]string{"id", "name", "description", "created_at", "popularity_score"})
+	for _, fakeMemeCoin := range fakeMemeCoins {
+		rows.AddRow(fakeMemeCoin.Id, fakeMemeCoin.Name, fakeMemeCoin.Description, fakeMemeCoin.CreatedAt, fakeMemeCoin.PopularityScore)
+	}
+	repo.mockConnectionPool.ExpectQuery(regexp.QuoteMeta(sqlStatement)).WillReturnRows(rows)
+
+	memeCoins := []repositories.MemeCoin{}
+	err := repo.memeCoinRepository.StreamAll(context.Background(), func(memeCoin repositories.MemeCoin) error {
+		memeCoins = append(memeCoins, memeCoin)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("StreamAll() failed, got error: %v", err)
+	}
+
+	assert.Equal(t, fakeMemeCoins, memeCoins)
+}
+
+func (repo *MemeCoinRepositoryTest) testUpsertMany(t *testing.T) {
+	popularityScore := 10
+	upsertMemeCoins := []repositories.UpsertMemeCoin{
+		{Name: "New MemeCoin", Description: "New MemeCoin Description", PopularityScore: &popularityScore},
+		{Name: "Existing MemeCoin", Description: "Existing MemeCoin Description"},
+	}
+	createdAt := repo.now
+
+	// Mocking the database connection
+	sqlStatement := "WITH input AS ( SELECT * FROM unnest($1::text[], $2::text[], $3::int[]) AS input(name, description, popularity_score) ) " +
+		"INSERT INTO meme_coins AS meme_coin (name, description, popularity_score, created_at) SELECT name, description, COALESCE(popularity_score, 0), $4 FROM input " +
+		"ON CONFLICT (name) DO UPDATE SET description = EXCLUDED.description, " +
+		"popularity_score = COALESCE((SELECT input.popularity_score FROM input WHERE input.name = EXCLUDED.name), meme_coin.popularity_score) " +
+		"RETURNING id, name, description, created_at, popularity_score, (xmax = 0) AS inserted"
+	repo.mockConnectionPool.ExpectQuery(regexp.QuoteMeta(sqlStatement)).
+		WithArgs([]string{"New MemeCoin", "Existing MemeCoin"}, []string{"New MemeCoin Description", "Existing MemeCoin Description"}, []*int{&popularityScore, nil}, repo.now).
+		WillReturnRows(pgxmock.
+			NewRows([]string{"id", "name", "description", "created_at", "popularity_score", "inserted"}).
+			AddRow(1, "New MemeCoin", "New MemeCoin Description", createdAt, 10, true).
+			AddRow(2, "Existing MemeCoin", "Existing MemeCoin Description", createdAt, 7, false))
+	upsertedMemeCoins, err := repo.memeCoinRepository.UpsertMany(context.Background(), upsertMemeCoins)
+	if err != nil {
+		t.Errorf("UpsertMany() failed, got error: %v", err)
+	}
+
+	assert.Equal(t, []repositories.UpsertedMemeCoin{
+		{MemeCoin: repositories.MemeCoin{Id: 1, Name: "New MemeCoin", Description: "New MemeCoin Description", CreatedAt: createdAt, PopularityScore: 10}, Inserted: true},
+		{MemeCoin: repositories.MemeCoin{Id: 2, Name: "Existing MemeCoin", Description: "Existing MemeCoin Description", CreatedAt: createdAt, PopularityScore: 7}, Inserted: false},
+	}, upsertedMemeCoins)
+}
+
+func (repo *MemeCoinRepositoryTest) testUpdatePopularityScore(t *testing.T) {
+	fakeMemeCoin := repositories.MemeCoin{
+		Id:              rand.Intn(100),
+		Name:            "Test MemeCoin",
+		Description:     "Test MemeCoin Description",
+		CreatedAt:       time.Now(),
+		PopularityScore: 42,
+	}
+
+	sqlStatement := "UPDATE meme_coins SET popularity_score = $2 WHERE id = $1 RETURNING id, name, description, created_at, popularity_score"
+	repo.mockConnectionPool.ExpectQuery(regexp.QuoteMeta(sqlStatement)).
+		WithArgs(fakeMemeCoin.Id, fakeMemeCoin.PopularityScore).
+		WillReturnRows(pgxmock.
+			NewRows([]string{"id", "name", "description", "created_at", "popularity_score"}).
+			AddRow(fakeMemeCoin.Id, fakeMemeCoin.Name, fakeMemeCoin.Description, fakeMemeCoin.CreatedAt, fakeMemeCoin.PopularityScore))
+	memeCoin, err := repo.memeCoinRepository.UpdatePopularityScore(context.Background(), fakeMemeCoin.Id, fakeMemeCoin.PopularityScore)
+	if err != nil {
+		t.Errorf("UpdatePopularityScore() failed, got error: %v", err)
+	}
+	assert.Equal(t, fakeMemeCoin, *memeCoin)
+}
+
+func (repo *MemeCoinRepositoryTest) testIncrementPopularityScore(t *testing.T) {
+	sqlStatement := "UPDATE meme_coins SET popularity_score = popularity_score + $2 WHERE id = $1"
+
+	// Case 1: meme coin does not exist
+	repo.mockConnectionPool.ExpectQuery(regexp.QuoteMeta(sqlStatement)).
+		WithArgs(0, 1).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "description", "created_at", "popularity_score"}))
+	memeCoin, err := repo.memeCoinRepository.IncrementPopularityScore(context.Background(), 0, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, memeCoin)
+
+	// Case 2: meme coin exists
+	createdAt := time.Now()
+	repo.mockConnectionPool.ExpectQuery(regexp.QuoteMeta(sqlStatement)).
+		WithArgs(1, 1).
+		WillReturnRows(pgxmock.
+			NewRows([]string{"id", "name", "description", "created_at", "popularity_score"}).
+			AddRow(1, "Test MemeCoin", "Test MemeCoin Description", createdAt, 6))
+	memeCoin, err = repo.memeCoinRepository.IncrementPopularityScore(context.Background(), 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, memeCoin.PopularityScore)
+}
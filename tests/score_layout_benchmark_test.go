@@ -0,0 +1,176 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"portto-assignment/internal/repositories"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The layout benchmarks need a Redis server, for example:
+//
+//	BENCHMARK_REDIS_URL=redis://... go test ./tests -run '^$' -bench ScoreLayout
+//
+// Scores are written to ids far above the real ones and removed afterwards.
+const scoreLayoutBenchmarkFirstId = 900_000_000
+
+var scoreLayoutBenchmarkSizes = []int{10_000, 100_000}
+
+var scoreLayouts = []repositories.ScoreLayout{repositories.ScoreLayoutString, repositories.ScoreLayoutHash}
+
+// BenchmarkScoreLayoutMemory reports the Redis memory used per score by each layout
+func BenchmarkScoreLayoutMemory(b *testing.B) {
+	for _, layout := range scoreLayouts {
+		for _, size := range scoreLayoutBenchmarkSizes {
+			b.Run(fmt.Sprintf("layout=%s/scores=%d", layout, size), func(b *testing.B) {
+				redisClient := setupScoreLayoutBenchmark(b)
+				redisCachedRepository := newScoreLayoutBenchmarkRepository(redisClient, layout)
+
+				var bytesPerScore float64
+				for i := 0; i < b.N; i++ {
+					before := usedMemory(b, redisClient)
+					setScoreLayoutBenchmarkScores(b, redisCachedRepository, size)
+					bytesPerScore = float64(usedMemory(b, redisClient)-before) / float64(size)
+
+					b.StopTimer()
+					deleteScoreLayoutBenchmarkScores(b, redisClient)
+					b.StartTimer()
+				}
+				b.ReportMetric(bytesPerScore, "bytes/score")
+			})
+		}
+	}
+}
+
+// BenchmarkScoreLayoutGet measures reading one score through the repository
+func BenchmarkScoreLayoutGet(b *testing.B) {
+	for _, layout := range scoreLayouts {
+		b.Run(fmt.Sprintf("layout=%s", layout), func(b *testing.B) {
+			redisClient := setupScoreLayoutBenchmark(b)
+			redisCachedRepository := newScoreLayoutBenchmarkRepository(redisClient, layout)
+			size := scoreLayoutBenchmarkSizes[0]
+			setScoreLayoutBenchmarkScores(b, redisCachedRepository, size)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := "meme:popularity_score:" + strconv.Itoa(scoreLayoutBenchmarkFirstId+i%size)
+				if _, _, err := redisCachedRepository.Get(context.Background(), key); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkScoreLayoutIncrBy measures the increment command each layout issues for a poke
+func BenchmarkScoreLayoutIncrBy(b *testing.B) {
+	for _, layout := range scoreLayouts {
+		b.Run(fmt.Sprintf("layout=%s", layout), func(b *testing.B) {
+			redisClient := setupScoreLayoutBenchmark(b)
+			size := scoreLayoutBenchmarkSizes[0]
+			setScoreLayoutBenchmarkScores(b, newScoreLayoutBenchmarkRepository(redisClient, layout), size)
+
+			ctx := context.Background()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				id := scoreLayoutBenchmarkFirstId + i%size
+				var err error
+				if layout == repositories.ScoreLayoutHash {
+					err = redisClient.HIncrBy(ctx, "meme:ps:"+strconv.Itoa(id/repositories.DefaultScoreBucketSize), strconv.Itoa(id), 1).Err()
+				} else {
+					err = redisClient.IncrBy(ctx, "meme:popularity_score:"+strconv.Itoa(id), 1).Err()
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func setupScoreLayoutBenchmark(b *testing.B) *redis.Client {
+	redisURL := os.Getenv("BENCHMARK_REDIS_URL")
+	if redisURL == "" {
+		b.Skip("BENCHMARK_REDIS_URL is required")
+	}
+
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		b.Fatal(err)
+	}
+	redisClient := redis.NewClient(opts)
+	b.Cleanup(func() {
+		deleteScoreLayoutBenchmarkScores(b, redisClient)
+		redisClient.Close()
+	})
+
+	return redisClient
+}
+
+func newScoreLayoutBenchmarkRepository(redisClient *redis.Client, layout repositories.ScoreLayout) *repositories.RedisCachedRepository {
+	return repositories.NewRedisCachedRepository(nil, redisClient, repositories.RepositoryConfig{
+		NeedToSync:  false,
+		ScoreLayout: layout,
+	})
+}
+
+func setScoreLayoutBenchmarkScores(b *testing.B, redisCachedRepository *repositories.RedisCachedRepository, size int) {
+	const batchSize = 1000
+	for start := 0; start < size; start += batchSize {
+		values := make(map[string]int, batchSize)
+		for id := start; id < start+batchSize && id < size; id++ {
+			values["meme:popularity_score:"+strconv.Itoa(scoreLayoutBenchmarkFirstId+id)] = id
+		}
+		if err := redisCachedRepository.SetMany(context.Background(), values); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func deleteScoreLayoutBenchmarkScores(b *testing.B, redisClient *redis.Client) {
+	ctx := context.Background()
+	// Ids 900000000 to 900099999 and their buckets 900000 to 900099, nothing else
+	for _, pattern := range []string{"meme:popularity_score:9000?????", "meme:ps:9000??"} {
+		iter := redisClient.Scan(ctx, 0, pattern, 1000).Iterator()
+		keys := []string{}
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+			if len(keys) == 1000 {
+				redisClient.Del(ctx, keys...)
+				keys = keys[:0]
+			}
+		}
+		if err := iter.Err(); err != nil {
+			b.Fatal(err)
+		}
+		if len(keys) > 0 {
+			redisClient.Del(ctx, keys...)
+		}
+	}
+}
+
+// usedMemory is the used_memory field of INFO memory
+func usedMemory(b *testing.B, redisClient *redis.Client) int64 {
+	info, err := redisClient.Info(context.Background(), "memory").Result()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, line := range strings.Split(info, "\r\n") {
+		value, found := strings.CutPrefix(line, "used_memory:")
+		if found {
+			usedMemory, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				b.Fatal(err)
+			}
+			return usedMemory
+		}
+	}
+	b.Fatal("used_memory is missing from INFO memory")
+
+	return 0
+}
@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"context"
+	"portto-assignment/internal/repositories"
+	"regexp"
+	"testing"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScoreLayout(t *testing.T) {
+	// Case 1: the string layout is the default
+	layout, err := repositories.ParseScoreLayout("")
+	assert.NoError(t, err)
+	assert.Equal(t, repositories.ScoreLayoutString, layout)
+
+	// Case 2: known layouts
+	layout, err = repositories.ParseScoreLayout("hash")
+	assert.NoError(t, err)
+	assert.Equal(t, repositories.ScoreLayoutHash, layout)
+
+	// Case 3: unknown layout
+	_, err = repositories.ParseScoreLayout("zset")
+	assert.Error(t, err)
+}
+
+func TestHashScoreLayout(t *testing.T) {
+	dbmock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbmock.Close()
+
+	mockRedisClient, redismock := redismock.NewClientMock()
+	defer mockRedisClient.Close()
+
+	redisCachedRepository := repositories.NewRedisCachedRepository(dbmock, mockRedisClient, repositories.RepositoryConfig{
+		NeedToSync:     false,
+		WarmUpPageSize: 2,
+		WarmUpWorkers:  1,
+		ScoreLayout:    repositories.ScoreLayoutHash,
+	})
+
+	t.Run("Commands", func(t *testing.T) {
+		// Case 1: scores are fields of the bucket of 1000 meme coins they belong to
+		redismock.ExpectHIncrBy("meme:unsynced_scores", "meme:popularity_score:1234", 1).SetVal(1)
+		redismock.ExpectHIncrBy("meme:ps:1", "1234", 2).SetVal(2)
+		assert.NoError(t, redisCachedRepository.IncrBy(context.Background(), "meme:popularity_score:1234", 2))
+
+		redismock.ExpectHGet("meme:ps:1", "1234").SetVal("2")
+		value, found, err := redisCachedRepository.Get(context.Background(), "meme:popularity_score:1234")
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, 2, value)
+
+		redismock.ExpectHGet("meme:ps:0", "7").RedisNil()
+		_, found, err = redisCachedRepository.Get(context.Background(), "meme:popularity_score:7")
+		assert.NoError(t, err)
+		assert.False(t, found)
+
+		redismock.ExpectHExists("meme:ps:0", "7").SetVal(false)
+		exists, err := redisCachedRepository.Exists(context.Background(), "meme:popularity_score:7")
+		assert.NoError(t, err)
+		assert.False(t, exists)
+
+		redismock.ExpectHSet("meme:ps:0", "7", 5).SetVal(1)
+		assert.NoError(t, redisCachedRepository.Set(context.Background(), "meme:popularity_score:7", 5))
+
+		redismock.ExpectHDel("meme:ps:0", "7").SetVal(1)
+		assert.NoError(t, redisCachedRepository.Delete(context.Background(), "meme:popularity_score:7"))
+
+		// Case 2: keys without a meme coin id can't be placed in a bucket
+		err = redisCachedRepository.Set(context.Background(), "test_key", 1)
+		assert.Error(t, err)
+
+		assert.NoError(t, redismock.ExpectationsWereMet())
+	})
+
+	t.Run("Sync", func(t *testing.T) {
+		// Case 1: one HMGET per bucket and one UPDATE for the batch
+		redismock.ExpectHMGet("meme:ps:0", "3", "5").SetVal([]interface{}{"12", nil})
+		dbmock.ExpectExec(regexp.QuoteMeta("FROM unnest($1::int[], $2::int[])")).
+			WithArgs([]int{3}, []int{12}).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		err := redisCachedRepository.SyncKeys([]string{"meme:popularity_score:5", "meme:popularity_score:3"})
+		assert.NoError(t, err)
+
+		assert.NoError(t, dbmock.ExpectationsWereMet())
+		assert.NoError(t, redismock.ExpectationsWereMet())
+	})
+
+	t.Run("Reconcile", func(t *testing.T) {
+		// Case 1: buckets are scanned field by field
+		redismock.ExpectScan(0, "meme:ps:*", repositories.DefaultSyncBatchSize).SetVal([]string{"meme:ps:0"}, 0)
+		redismock.ExpectHGetAll("meme:ps:0").SetVal(map[string]string{"1": "5", "9": "3"})
+		dbmock.ExpectQuery(regexp.QuoteMeta("SELECT id, popularity_score FROM meme_coins ORDER BY id")).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "popularity_score"}).AddRow(1, 5).AddRow(2, 4))
+		redismock.ExpectHDel("meme:ps:0", "9").SetVal(1)
+		redismock.ExpectHSetNX("meme:ps:0", "2", 4).SetVal(true)
+
+		report, err := redisCachedRepository.Reconcile(repositories.ReconcilePolicyDatabase)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, report.ScannedKeys)
+		assert.Equal(t, []string{"meme:popularity_score:9"}, report.OrphanKeys)
+		assert.Equal(t, []repositories.StoredScore{{Id: 2, Stored: 4}}, report.MissingKeys)
+		assert.Equal(t, 2, report.Repaired)
+
+		assert.NoError(t, dbmock.ExpectationsWereMet())
+		assert.NoError(t, redismock.ExpectationsWereMet())
+	})
+
+	t.Run("WarmUp", func(t *testing.T) {
+		// Case 1: fields already in Redis are not overwritten
+		dbmock.ExpectQuery(regexp.QuoteMeta("FROM meme_coins WHERE id > $1")).
+			WithArgs(0, 2).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "popularity_score"}).AddRow(1, 10))
+		redismock.ExpectHSetNX("meme:ps:0", "1", 10).SetVal(true)
+
+		report, err := redisCachedRepository.WarmUp()
+		assert.NoError(t, err)
+		assert.Equal(t, repositories.WarmUpReport{Rows: 1, Loaded: 1}, *report)
+
+		assert.NoError(t, dbmock.ExpectationsWereMet())
+		assert.NoError(t, redismock.ExpectationsWereMet())
+	})
+
+	t.Run("Migration", func(t *testing.T) {
+		// Case 1: string keys are moved into their bucket, invalid keys are left alone
+		redismock.ExpectScan(0, "meme:popularity_score:*", repositories.DefaultSyncBatchSize).
+			SetVal([]string{"meme:popularity_score:1001", "meme:popularity_score:abc"}, 0)
+		redismock.ExpectGet("meme:popularity_score:1001").SetVal("8")
+		redismock.ExpectGet("meme:popularity_score:abc").SetVal("1")
+		redismock.ExpectHSet("meme:ps:1", "1001", 8).SetVal(1)
+		redismock.ExpectDel("meme:popularity_score:1001").SetVal(1)
+
+		report, err := redisCachedRepository.MigrateScoreLayout(repositories.ScoreLayoutString)
+		assert.NoError(t, err)
+		assert.Equal(t, repositories.ScoreLayoutMigrationReport{
+			From:    repositories.ScoreLayoutString,
+			To:      repositories.ScoreLayoutHash,
+			Moved:   1,
+			Skipped: 1,
+		}, *report)
+
+		// Case 2: nothing to move within the same layout
+		report, err = redisCachedRepository.MigrateScoreLayout(repositories.ScoreLayoutHash)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, report.Moved)
+
+		// Case 3: unknown layout
+		_, err = redisCachedRepository.MigrateScoreLayout("zset")
+		assert.Error(t, err)
+
+		assert.NoError(t, redismock.ExpectationsWereMet())
+	})
+}
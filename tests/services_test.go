@@ -1,10 +1,17 @@
 package tests
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
+	"portto-assignment/internal/repositories"
 	"portto-assignment/internal/services"
+	"portto-assignment/pkg/clock"
 	"portto-assignment/tests/mocks"
 
 	"github.com/stretchr/testify/assert"
@@ -12,28 +19,73 @@ import (
 
 var memeCoinService *services.MemeCoinService
 
+// memeCoinServiceClock stamps the meme coins of the mock repository of memeCoinService
+var memeCoinServiceClock *clock.Fake
+
 func TestMemeCoinService(t *testing.T) {
 	// Mock the repository
-	mockMemeCoinRepository := &mocks.MockMemeCoinRepository{}
+	memeCoinServiceClock = clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	mockMemeCoinRepository := &mocks.MockMemeCoinRepository{Clock: memeCoinServiceClock}
 	mockRedisCachedRepository := &mocks.MockRedisCachedRepository{}
 
-	memeCoinService = services.NewMemeCoinService(mockMemeCoinRepository, mockRedisCachedRepository)
+	memeCoinService = services.NewMemeCoinService(mockMemeCoinRepository, mockRedisCachedRepository, nil, nil)
 
 	t.Run("CreateMemeCoin", testCreateMemeCoin)
 	t.Run("GetMemeCoin", testGetMemeCoin)
 	t.Run("UpdateMemeCoin", testUpdateMemeCoin)
 	t.Run("DeleteMemeCoin", testDeleteMemeCoin)
 	t.Run("PokeMemeCoin", testPokeMemeCoin)
+	t.Run("CreateMemeCoins", testCreateMemeCoins)
+	t.Run("GetMemeCoins", testGetMemeCoins)
+	t.Run("PokeMemeCoins", testPokeMemeCoins)
+	t.Run("ExportMemeCoins", testExportMemeCoins)
+	t.Run("ImportMemeCoins", testImportMemeCoins)
+	t.Run("GetPopularityScore", testGetPopularityScore)
+	t.Run("SetPopularityScore", testSetPopularityScore)
+	t.Run("AdjustPopularityScore", testAdjustPopularityScore)
+}
+
+func TestAPIKeyService(t *testing.T) {
+	apiKeyService := services.NewAPIKeyService(&mocks.MockAPIKeyRepository{})
+
+	// Test case 1: name is empty
+	createdAPIKey, err := apiKeyService.CreateAPIKey("")
+	assert.Error(t, err)
+	assert.Nil(t, createdAPIKey)
+
+	// Test case 2: the created key authenticates until it is revoked
+	createdAPIKey, err = apiKeyService.CreateAPIKey("importer")
+	assert.NoError(t, err)
+	assert.Equal(t, "importer", createdAPIKey.Name)
+	assert.True(t, strings.HasPrefix(createdAPIKey.Key, createdAPIKey.Prefix))
+
+	apiKey, err := apiKeyService.Authenticate(createdAPIKey.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, createdAPIKey.Id, apiKey.Id)
+
+	apiKey, err = apiKeyService.Authenticate(createdAPIKey.Key + "x")
+	assert.NoError(t, err)
+	assert.Nil(t, apiKey)
+
+	revokedAPIKey, err := apiKeyService.RevokeAPIKey(createdAPIKey.Id)
+	assert.NoError(t, err)
+	assert.NotNil(t, revokedAPIKey.RevokedAt)
+
+	apiKey, err = apiKeyService.Authenticate(createdAPIKey.Key)
+	assert.NoError(t, err)
+	assert.Nil(t, apiKey)
+
+	apiKeys, err := apiKeyService.ListAPIKeys()
+	assert.NoError(t, err)
+	assert.Len(t, apiKeys, 1)
 }
 
 func testCreateMemeCoin(t *testing.T) {
 	// Test case 1: name is not empty
-	timeBeforeExecute := time.Now()
-	memeCoin, err := memeCoinService.CreateMemeCoin(services.CreateMemeCoinInput{
+	memeCoin, err := memeCoinService.CreateMemeCoin(context.Background(), services.CreateMemeCoinInput{
 		Name:        "name",
 		Description: "description",
 	})
-	timeAfterExecute := time.Now()
 
 	assert.NoError(t, err)
 	assert.NotNil(t, memeCoin)
@@ -41,45 +93,41 @@ func testCreateMemeCoin(t *testing.T) {
 	assert.Less(t, memeCoin.PopularityScore, 100)
 	assert.Equal(t, "name", memeCoin.Name)
 	assert.Equal(t, "description", memeCoin.Description)
-	assert.GreaterOrEqual(t, memeCoin.CreatedAt.UnixNano(), timeBeforeExecute.UnixNano())
-	assert.LessOrEqual(t, memeCoin.CreatedAt.UnixNano(), timeAfterExecute.UnixNano())
+	assert.Equal(t, memeCoinServiceClock.Now(), memeCoin.CreatedAt)
 	assert.Greater(t, memeCoin.PopularityScore, 0)
 	assert.Less(t, memeCoin.PopularityScore, 100)
 }
 
 func testGetMemeCoin(t *testing.T) {
 	// Test case 1: id is invalid (id = 0 => invalid)
-	memeCoin, err := memeCoinService.GetMemeCoin(0)
+	memeCoin, err := memeCoinService.GetMemeCoin(context.Background(), 0)
 	assert.Error(t, err)
 	assert.Nil(t, memeCoin)
 
 	// Test case 2: id is valid
-	timeBeforeExecute := time.Now()
-	memeCoin, err = memeCoinService.GetMemeCoin(1)
-	timeAfterExecute := time.Now()
+	memeCoin, err = memeCoinService.GetMemeCoin(context.Background(), 1)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, memeCoin)
 	assert.Greater(t, memeCoin.Id, 0)
 	assert.Less(t, memeCoin.PopularityScore, 100)
+	// The popularity score is overlaid from Redis
+	assert.Equal(t, mocks.MockPopularityScore, memeCoin.PopularityScore)
 	assert.Equal(t, "FakeCoin", memeCoin.Name)
 	assert.Equal(t, "A fake meme coin", memeCoin.Description)
-	assert.GreaterOrEqual(t, memeCoin.CreatedAt.UnixNano(), timeBeforeExecute.UnixNano())
-	assert.LessOrEqual(t, memeCoin.CreatedAt.UnixNano(), timeAfterExecute.UnixNano())
+	assert.Equal(t, memeCoinServiceClock.Now(), memeCoin.CreatedAt)
 	assert.Greater(t, memeCoin.PopularityScore, 0)
 	assert.Less(t, memeCoin.PopularityScore, 100)
 }
 
 func testUpdateMemeCoin(t *testing.T) {
 	// Test case 1: id is invalid (id = 0 => invalid)
-	memeCoin, err := memeCoinService.UpdateMemeCoin(0, "new description")
+	memeCoin, err := memeCoinService.UpdateMemeCoin(context.Background(), 0, "new description")
 	assert.Error(t, err)
 	assert.Nil(t, memeCoin)
 
 	// Test case 2: id is valid
-	timeBeforeExecute := time.Now()
-	memeCoin, err = memeCoinService.UpdateMemeCoin(1, "new description")
-	timeAfterExecute := time.Now()
+	memeCoin, err = memeCoinService.UpdateMemeCoin(context.Background(), 1, "new description")
 
 	assert.NoError(t, err)
 	assert.NotNil(t, memeCoin)
@@ -87,22 +135,19 @@ func testUpdateMemeCoin(t *testing.T) {
 	assert.Less(t, memeCoin.PopularityScore, 100)
 	assert.Equal(t, "FakeCoin", memeCoin.Name)
 	assert.Equal(t, "new description", memeCoin.Description)
-	assert.GreaterOrEqual(t, memeCoin.CreatedAt.UnixNano(), timeBeforeExecute.UnixNano())
-	assert.LessOrEqual(t, memeCoin.CreatedAt.UnixNano(), timeAfterExecute.UnixNano())
+	assert.Equal(t, memeCoinServiceClock.Now(), memeCoin.CreatedAt)
 	assert.Greater(t, memeCoin.PopularityScore, 0)
 	assert.Less(t, memeCoin.PopularityScore, 100)
 }
 
 func testDeleteMemeCoin(t *testing.T) {
 	// Test case 1: id is invalid (id = 0 => invalid)
-	memeCoin, err := memeCoinService.DeleteMemeCoin(0)
+	memeCoin, err := memeCoinService.DeleteMemeCoin(context.Background(), 0)
 	assert.Error(t, err)
 	assert.Nil(t, memeCoin)
 
 	// Test case 2: id is valid
-	timeBeforeExecute := time.Now()
-	memeCoin, err = memeCoinService.DeleteMemeCoin(1)
-	timeAfterExecute := time.Now()
+	memeCoin, err = memeCoinService.DeleteMemeCoin(context.Background(), 1)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, memeCoin)
@@ -110,18 +155,222 @@ func testDeleteMemeCoin(t *testing.T) {
 	assert.Less(t, memeCoin.PopularityScore, 100)
 	assert.Equal(t, "FakeCoin", memeCoin.Name)
 	assert.Equal(t, "A fake meme coin", memeCoin.Description)
-	assert.GreaterOrEqual(t, memeCoin.CreatedAt.UnixNano(), timeBeforeExecute.UnixNano())
-	assert.LessOrEqual(t, memeCoin.CreatedAt.UnixNano(), timeAfterExecute.UnixNano())
+	assert.Equal(t, memeCoinServiceClock.Now(), memeCoin.CreatedAt)
 	assert.Greater(t, memeCoin.PopularityScore, 0)
 	assert.Less(t, memeCoin.PopularityScore, 100)
 }
 
 func testPokeMemeCoin(t *testing.T) {
 	// Test case 1: id is invalid (id = 0 => invalid)
-	err := memeCoinService.PokeMemeCoin(0)
+	err := memeCoinService.PokeMemeCoin(context.Background(), 0)
+	assert.Error(t, err)
+
+	// Test case 2: id is valid
+	err = memeCoinService.PokeMemeCoin(context.Background(), 1)
+	assert.NoError(t, err)
+}
+
+func testCreateMemeCoins(t *testing.T) {
+	// Test case 1: batch is too large
+	tooManyInputs := make([]services.CreateMemeCoinInput, services.MaxBatchCreateSize+1)
+	results, err := memeCoinService.CreateMemeCoins(context.Background(), tooManyInputs)
+	assert.Error(t, err)
+	assert.Nil(t, results)
+
+	// Test case 2: new names, a name that already exists and a duplicated name
+	results, err = memeCoinService.CreateMemeCoins(context.Background(), []services.CreateMemeCoinInput{
+		{Name: "first", Description: "description"},
+		{Name: mocks.ExistingMemeCoinName, Description: "description"},
+		{Name: "first", Description: "another description"},
+		{Name: "second"},
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 4)
+	for i, result := range results {
+		assert.Equal(t, i, result.Index)
+	}
+	assert.Equal(t, services.BatchStatusCreated, results[0].Status)
+	assert.Equal(t, "first", results[0].MemeCoin.Name)
+	assert.Equal(t, "description", results[0].MemeCoin.Description)
+	assert.Equal(t, services.BatchStatusConflict, results[1].Status)
+	assert.Nil(t, results[1].MemeCoin)
+	assert.NotEmpty(t, results[1].Error)
+	assert.Equal(t, services.BatchStatusConflict, results[2].Status)
+	assert.Nil(t, results[2].MemeCoin)
+	assert.NotEmpty(t, results[2].Error)
+	assert.Equal(t, services.BatchStatusCreated, results[3].Status)
+	assert.Equal(t, "second", results[3].MemeCoin.Name)
+}
+
+func testGetMemeCoins(t *testing.T) {
+	// Test case 1: batch is too large
+	tooManyIds := make([]int, services.MaxBatchGetSize+1)
+	result, err := memeCoinService.GetMemeCoins(context.Background(), tooManyIds)
+	assert.Error(t, err)
+	assert.Nil(t, result)
+
+	// Test case 2: existing, missing (id = 0 => missing) and duplicated ids
+	result, err = memeCoinService.GetMemeCoins(context.Background(), []int{3, 0, 1, 3})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0}, result.NotFound)
+	ids := []int{}
+	for _, memeCoin := range result.MemeCoins {
+		ids = append(ids, memeCoin.Id)
+	}
+	assert.Equal(t, []int{3, 1}, ids)
+}
+
+func testPokeMemeCoins(t *testing.T) {
+	// Test case 1: poke count is out of range
+	results, err := memeCoinService.PokeMemeCoins(context.Background(), map[int]int{1: services.MaxPokeCount + 1})
+	assert.Error(t, err)
+	assert.Nil(t, results)
+
+	// Test case 2: existing and missing (id = 0 => missing) meme coins
+	results, err = memeCoinService.PokeMemeCoins(context.Background(), map[int]int{2: 5, 0: 1, 1: 3})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []services.BatchPokeMemeCoinResult{
+		{Id: 0, Count: 1, Status: services.BatchStatusNotFound},
+		{Id: 1, Count: 3, Status: services.BatchStatusPoked},
+		{Id: 2, Count: 5, Status: services.BatchStatusPoked},
+	}, results)
+}
+
+func testExportMemeCoins(t *testing.T) {
+	// Test case 1: unsupported format
+	err := memeCoinService.ExportMemeCoins(context.Background(), &bytes.Buffer{}, "xml")
+	assert.ErrorIs(t, err, services.ErrUnsupportedFormat)
+
+	// Test case 2: CSV
+	csvOutput := &bytes.Buffer{}
+	err = memeCoinService.ExportMemeCoins(context.Background(), csvOutput, services.FormatCSV)
+	assert.NoError(t, err)
+
+	records, err := csv.NewReader(csvOutput).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 4)
+	assert.Equal(t, []string{"id", "name", "description", "created_at", "popularity_score"}, records[0])
+	assert.Equal(t, "1", records[1][0])
+	assert.Equal(t, "FakeCoin", records[1][1])
+
+	// Test case 3: NDJSON
+	ndjsonOutput := &bytes.Buffer{}
+	err = memeCoinService.ExportMemeCoins(context.Background(), ndjsonOutput, services.FormatNDJSON)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(ndjsonOutput.String()), "\n")
+	assert.Len(t, lines, 3)
+	var memeCoin map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(lines[2]), &memeCoin))
+	assert.Equal(t, float64(3), memeCoin["id"])
+	assert.Equal(t, "FakeCoin", memeCoin["name"])
+}
+
+func testImportMemeCoins(t *testing.T) {
+	// Test case 1: CSV without a "name" column
+	report, err := memeCoinService.ImportMemeCoins(context.Background(), strings.NewReader("description\nfoo\n"), services.FormatCSV)
+	assert.ErrorIs(t, err, services.ErrMalformedImport)
+	assert.Equal(t, 0, report.Total)
+
+	// Test case 2: a bare quote in the first field is reported with its line instead of panicking, the rows before it are committed
+	report, err = memeCoinService.ImportMemeCoins(context.Background(), strings.NewReader("name,description\ndoge,wow\nbad\"coin,wow\n"), services.FormatCSV)
+	assert.ErrorIs(t, err, services.ErrMalformedImport)
+	assert.ErrorContains(t, err, "line 3")
+	assert.Equal(t, 1, report.Total)
+	assert.Equal(t, 1, report.Created)
+
+	// Test case 3: CSV with valid and invalid rows
+	csvInput := strings.Join([]string{
+		"id,name,description,created_at,popularity_score",
+		"1,doge,such wow,2024-01-01T00:00:00Z,10",
+		"2,,no name,2024-01-01T00:00:00Z,1",
+		"3,pepe,,2024-01-01T00:00:00Z,abc",
+		"4," + mocks.ExistingMemeCoinName + ",updated,2024-01-01T00:00:00Z,",
+		"5,cat,negative,2024-01-01T00:00:00Z,-1",
+		"6,too,many,fields,1,2",
+		"7,doge,again,2024-01-01T00:00:00Z,20",
+	}, "\n")
+	report, err = memeCoinService.ImportMemeCoins(context.Background(), strings.NewReader(csvInput), services.FormatCSV)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, report.Total)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, 4, report.Failed)
+	assert.Equal(t, []services.ImportRowError{
+		{Line: 3, Error: "name is required"},
+		{Line: 4, Name: "pepe", Error: "popularity_score is not an integer"},
+		{Line: 6, Name: "cat", Error: "popularity_score must not be negative"},
+		{Line: 7, Error: "wrong number of fields"},
+	}, report.Errors)
+
+	// Test case 4: NDJSON with valid and invalid rows
+	ndjsonInput := strings.Join([]string{
+		`{"name":"doge","description":"such wow","popularity_score":10}`,
+		``,
+		`{"description":"no name"}`,
+		`not json`,
+		`{"name":"` + mocks.ExistingMemeCoinName + `"}`,
+	}, "\n")
+	report, err = memeCoinService.ImportMemeCoins(context.Background(), strings.NewReader(ndjsonInput), services.FormatNDJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.Total)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, 3, report.Errors[0].Line)
+	assert.Equal(t, "name is required", report.Errors[0].Error)
+	assert.Equal(t, 4, report.Errors[1].Line)
+}
+
+func testGetPopularityScore(t *testing.T) {
+	// Test case 1: id is invalid (id = 0 => invalid)
+	popularityScore, err := memeCoinService.GetPopularityScore(context.Background(), 0)
 	assert.Error(t, err)
+	assert.Nil(t, popularityScore)
 
 	// Test case 2: id is valid
-	err = memeCoinService.PokeMemeCoin(1)
+	popularityScore, err = memeCoinService.GetPopularityScore(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, popularityScore.Id)
+	assert.Equal(t, mocks.MockPopularityScore, *popularityScore.Cached)
+	assert.Greater(t, popularityScore.Stored, 0)
+}
+
+func testSetPopularityScore(t *testing.T) {
+	// Test case 1: score is negative
+	popularityScore, err := memeCoinService.SetPopularityScore(context.Background(), 1, -1)
+	assert.Error(t, err)
+	assert.Nil(t, popularityScore)
+
+	// Test case 2: meme coin does not exist (id = 0 => missing)
+	popularityScore, err = memeCoinService.SetPopularityScore(context.Background(), 0, 10)
+	assert.NoError(t, err)
+	assert.Nil(t, popularityScore)
+
+	// Test case 3: id is valid
+	popularityScore, err = memeCoinService.SetPopularityScore(context.Background(), 1, 10)
 	assert.NoError(t, err)
+	assert.Equal(t, 10, *popularityScore.Cached)
+	assert.Equal(t, 10, popularityScore.Stored)
+}
+
+func testAdjustPopularityScore(t *testing.T) {
+	// Test case 1: meme coin does not exist (id = 0 => missing)
+	popularityScore, err := memeCoinService.AdjustPopularityScore(context.Background(), 0, 1)
+	assert.Error(t, err)
+	assert.Nil(t, popularityScore)
+
+	// Test case 2: id is valid, the result of the increment is written through
+	popularityScore, err = memeCoinService.AdjustPopularityScore(context.Background(), 1, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, mocks.MockPopularityScore+5, *popularityScore.Cached)
+	assert.Equal(t, mocks.MockPopularityScore+5, popularityScore.Stored)
+
+	// Test case 3: the score can't be taken below zero
+	popularityScore, err = memeCoinService.AdjustPopularityScore(context.Background(), 1, -mocks.MockPopularityScore-1)
+	assert.ErrorIs(t, err, repositories.ErrNegativePopularityScore)
+	assert.Nil(t, popularityScore)
 }
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"context"
+	"path/filepath"
+	"portto-assignment/config"
+	"portto-assignment/database/migrations"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/pkg/clock"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteMemeCoinRepository(t *testing.T) {
+	ctx := context.Background()
+	db, err := config.OpenSQLiteDatabase(config.StorageConfig{SQLitePath: filepath.Join(t.TempDir(), "memecoin.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Migrations are applied once
+	versions, err := migrations.MigrateSQLite(db)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0001_create_meme_coins"}, versions)
+	versions, err = migrations.MigrateSQLite(db)
+	assert.NoError(t, err)
+	assert.Empty(t, versions)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 123456789, time.UTC)
+	repo := repositories.NewSQLiteMemeCoinRepository(db, repositories.SQLiteConfig{
+		StreamPageSize: 2,
+		Clock:          clock.NewFake(now),
+	})
+
+	t.Run("Single", func(t *testing.T) {
+		// Case 1: created_at comes from the clock with the precision of PostgreSQL, a taken name returns nil
+		doge, err := repo.CreateOne(ctx, "Doge", "Wow")
+		assert.NoError(t, err)
+		assert.Equal(t, repositories.MemeCoin{Id: 1, Name: "Doge", Description: "Wow", CreatedAt: now.Truncate(time.Microsecond)}, *doge)
+		conflict, err := repo.CreateOne(ctx, "Doge", "Again")
+		assert.NoError(t, err)
+		assert.Nil(t, conflict)
+
+		// Case 2: updates return the changed row, missing rows are nil
+		doge, err = repo.UpdateOne(ctx, 1, "Such wow")
+		assert.NoError(t, err)
+		assert.Equal(t, "Such wow", doge.Description)
+		doge, err = repo.IncrementPopularityScore(ctx, 1, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, doge.PopularityScore)
+		missing, err := repo.UpdatePopularityScore(ctx, 99, 1)
+		assert.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
+	t.Run("Batches", func(t *testing.T) {
+		// Case 1: taken names are skipped, including the ones taken earlier in the batch
+		created, err := repo.CreateMany(ctx, []repositories.NewMemeCoin{{Name: "Doge"}, {Name: "Pepe"}, {Name: "Pepe"}, {Name: "Shiba"}})
+		assert.NoError(t, err)
+		assert.Len(t, created, 2)
+
+		// Case 2: lookups are deduplicated and ordered by id
+		shiba := created[1]
+		found, err := repo.FindMany(ctx, []int{shiba.Id, 1, shiba.Id, 99})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Doge", "Shiba"}, []string{found[0].Name, found[1].Name})
+
+		// Case 3: upserts keep the stored score when none is given
+		score := 7
+		upserted, err := repo.UpsertMany(ctx, []repositories.UpsertMemeCoin{{Name: "Pepe", Description: "Frog", PopularityScore: &score}, {Name: "Bonk"}})
+		assert.NoError(t, err)
+		assert.False(t, upserted[0].Inserted)
+		assert.True(t, upserted[1].Inserted)
+		bonk := upserted[1].MemeCoin
+		upserted, _ = repo.UpsertMany(ctx, []repositories.UpsertMemeCoin{{Name: "Pepe", Description: "Still a frog"}})
+		assert.Equal(t, 7, upserted[0].PopularityScore)
+
+		// Case 4: the catalogue is streamed in id order across pages
+		names := []string{}
+		assert.NoError(t, repo.StreamAll(ctx, func(memeCoin repositories.MemeCoin) error {
+			names = append(names, memeCoin.Name)
+			return nil
+		}))
+		assert.Equal(t, []string{"Doge", "Pepe", "Shiba", "Bonk"}, names)
+
+		// Case 5: deleted ids are not reused
+		deleted, err := repo.DeleteOne(ctx, bonk.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, "Bonk", deleted.Name)
+		recreated, _ := repo.CreateOne(ctx, "Bonk", "")
+		assert.Greater(t, recreated.Id, bonk.Id)
+	})
+
+	t.Run("MemoryRedisRepository", func(t *testing.T) {
+		keys := repositories.DefaultKeyBuilder()
+		redis := repositories.NewMemoryRedisRepository(repositories.MemoryRedisConfig{Repo: repo, Keys: keys})
+
+		// Case 1: warm-up loads every stored score
+		loaded, err := redis.WarmUp(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, loaded)
+		score, found, _ := redis.Get(ctx, keys.PopularityScore(1))
+		assert.True(t, found)
+		assert.Equal(t, 3, score)
+
+		// Case 2: increments and sets are written through to the database
+		memeCoins, _ := repo.FindMany(ctx, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+		doge, pepe, shiba := memeCoins[0], memeCoins[1], memeCoins[2]
+		assert.NoError(t, redis.IncrByMany(ctx, map[string]int{keys.PopularityScore(doge.Id): 2, keys.PopularityScore(pepe.Id): 1}))
+		assert.NoError(t, redis.Set(ctx, keys.PopularityScore(shiba.Id), 10))
+		memeCoins, _ = repo.FindMany(ctx, []int{doge.Id, pepe.Id, shiba.Id})
+		assert.Equal(t, []int{5, 8, 10}, []int{memeCoins[0].PopularityScore, memeCoins[1].PopularityScore, memeCoins[2].PopularityScore})
+
+		// Case 3: deleted keys no longer exist, keys that aren't scores only live in memory
+		assert.NoError(t, redis.Delete(ctx, keys.PopularityScore(shiba.Id)))
+		assert.NoError(t, redis.IncrBy(ctx, "other", 1))
+		existsMap, _ := redis.ExistsMany(ctx, []string{keys.PopularityScore(shiba.Id), "other"})
+		assert.Equal(t, map[string]bool{keys.PopularityScore(shiba.Id): false, "other": true}, existsMap)
+	})
+}
+
+func TestMemoryIdempotencyRepository(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	repo := repositories.NewMemoryIdempotencyRepository(fake)
+
+	// Case 1: a key is owned by one caller until its TTL passes
+	_, reserved, err := repo.Reserve("key", "fingerprint", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+	record, reserved, _ := repo.Reserve("key", "other", time.Minute)
+	assert.False(t, reserved)
+	assert.Equal(t, "fingerprint", record.Fingerprint)
+	fake.Advance(time.Minute)
+	record, _ = repo.Find("key")
+	assert.Nil(t, record)
+	_, reserved, _ = repo.Reserve("key", "other", time.Minute)
+	assert.True(t, reserved)
+
+	// Case 2: a saved response replaces the reservation, a released key is free again
+	assert.NoError(t, repo.Save("key", repositories.IdempotencyRecord{Fingerprint: "other", StatusCode: 200}, time.Hour))
+	record, _ = repo.Find("key")
+	assert.Equal(t, 200, record.StatusCode)
+	assert.NoError(t, repo.Release("key"))
+	_, reserved, _ = repo.Reserve("key", "fingerprint", time.Minute)
+	assert.True(t, reserved)
+}
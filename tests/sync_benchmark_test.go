@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"portto-assignment/internal/repositories"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+// The sync benchmarks need a migrated database and a Redis server, for example:
+//
+//	BENCHMARK_DATABASE_URL=postgres://... BENCHMARK_REDIS_URL=redis://... go test ./tests -run '^$' -bench Sync
+var syncBenchmarkSizes = []int{100, 1000, 5000}
+
+// BenchmarkSyncKeys measures the MGET and single UPDATE sync path
+func BenchmarkSyncKeys(b *testing.B) {
+	for _, size := range syncBenchmarkSizes {
+		b.Run(fmt.Sprintf("keys=%d", size), func(b *testing.B) {
+			db, redisClient, _, keys := setupSyncBenchmark(b, size)
+			redisCachedRepository := repositories.NewRedisCachedRepository(db, redisClient, repositories.RepositoryConfig{
+				NeedToSync: false,
+			})
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := redisCachedRepository.SyncKeys(keys); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(size*b.N)/b.Elapsed().Seconds(), "keys/s")
+		})
+	}
+}
+
+// BenchmarkSyncKeysPerRow is the previous sync path, one GET and one UPDATE per key in a transaction, kept as the baseline
+func BenchmarkSyncKeysPerRow(b *testing.B) {
+	for _, size := range syncBenchmarkSizes {
+		b.Run(fmt.Sprintf("keys=%d", size), func(b *testing.B) {
+			db, redisClient, ids, keys := setupSyncBenchmark(b, size)
+
+			ctx := context.Background()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tx, err := db.Begin(ctx)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for j, key := range keys {
+					score, err := redisClient.Get(ctx, key).Int()
+					if err != nil {
+						b.Fatal(err)
+					}
+					_, err = tx.Exec(ctx, "UPDATE meme_coins SET popularity_score = $2 WHERE id = $1", ids[j], score)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+				if err := tx.Commit(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(size*b.N)/b.Elapsed().Seconds(), "keys/s")
+		})
+	}
+}
+
+// setupSyncBenchmark creates size meme coins with a popularity score key each, and removes them when the benchmark ends
+func setupSyncBenchmark(b *testing.B, size int) (*pgxpool.Pool, *redis.Client, []int, []string) {
+	databaseURL := os.Getenv("BENCHMARK_DATABASE_URL")
+	redisURL := os.Getenv("BENCHMARK_REDIS_URL")
+	if databaseURL == "" || redisURL == "" {
+		b.Skip("BENCHMARK_DATABASE_URL and BENCHMARK_REDIS_URL are required")
+	}
+
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(db.Close)
+
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		b.Fatal(err)
+	}
+	redisClient := redis.NewClient(opts)
+	b.Cleanup(func() { redisClient.Close() })
+
+	const namePrefix = "sync-benchmark-"
+	_, err = db.Exec(ctx, `
+		INSERT INTO meme_coins (name, description)
+		SELECT $1 || i, 'Sync benchmark' FROM generate_series(1, $2::int) AS i
+		ON CONFLICT (name) DO NOTHING`, namePrefix, size)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	rows, err := db.Query(ctx, "SELECT id FROM meme_coins WHERE name LIKE $1 || '%' ORDER BY id LIMIT $2", namePrefix, size)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0, size)
+	keys := make([]string, 0, size)
+	pipe := redisClient.Pipeline()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			b.Fatal(err)
+		}
+		key := fmt.Sprintf("meme:popularity_score:%d", id)
+		pipe.Set(ctx, key, id%1000, 0)
+		ids = append(ids, id)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		b.Fatal(err)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Cleanup(func() {
+		redisClient.Del(ctx, keys...)
+		db.Exec(ctx, "DELETE FROM meme_coins WHERE name LIKE $1 || '%'", namePrefix)
+	})
+
+	return db, redisClient, ids, keys
+}
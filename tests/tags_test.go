@@ -0,0 +1,323 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"portto-assignment/internal/handlers"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/internal/routes"
+	"portto-assignment/internal/services"
+	"portto-assignment/tests/mocks"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagRepository(t *testing.T) {
+	ctx := context.Background()
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mock.Close()
+
+	tagRepository := repositories.NewTagRepository(mock)
+	columns := []string{"id", "name", "category", "created_at"}
+	createdAt := time.Now()
+	animals := "animals"
+
+	// Case 1: the tag comes back with the name of its category
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO meme_coin_tags (meme_coin_id, tag_id)")).
+		WithArgs(1, "dogs").
+		WillReturnRows(pgxmock.NewRows(columns).AddRow(1, "dogs", &animals, createdAt))
+	tag, err := tagRepository.AddOne(ctx, 1, "dogs")
+	assert.NoError(t, err)
+	assert.Equal(t, repositories.Tag{Id: 1, Name: "dogs", Category: &animals, CreatedAt: createdAt}, *tag)
+
+	// Case 2: removing a tag the coin doesn't have
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM meme_coin_tags")).
+		WithArgs(1, "cats").
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	removed, err := tagRepository.RemoveOne(ctx, 1, "cats")
+	assert.NoError(t, err)
+	assert.False(t, removed)
+
+	// Case 3: counts are filtered by category
+	mock.ExpectQuery(regexp.QuoteMeta("COUNT(meme_coin_tags.meme_coin_id)")).
+		WithArgs("animals").
+		WillReturnRows(pgxmock.NewRows(append(columns, "count")).AddRow(1, "dogs", &animals, createdAt, 3))
+	tagCounts, err := tagRepository.CountAll(ctx, "animals")
+	assert.NoError(t, err)
+	assert.Equal(t, []repositories.TagCount{{Tag: *tag, Count: 3}}, tagCounts)
+
+	// Case 4: filing a tag under a missing category
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE tags")).
+		WithArgs("dogs", "plants").
+		WillReturnRows(pgxmock.NewRows(columns))
+	tag, err = tagRepository.SetCategory(ctx, "dogs", "plants")
+	assert.NoError(t, err)
+	assert.Nil(t, tag)
+
+	// Case 5: a taken category name
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO categories (name, description)")).
+		WithArgs("animals", "").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "description", "created_at"}))
+	category, err := tagRepository.CreateCategory(ctx, "animals", "")
+	assert.NoError(t, err)
+	assert.Nil(t, category)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTagLeaderboardRepository(t *testing.T) {
+	ctx := context.Background()
+	mockRedisClient, mock := redismock.NewClientMock()
+	defer mockRedisClient.Close()
+
+	leaderboards := repositories.NewTagLeaderboardRepository(mockRedisClient, repositories.TagLeaderboardConfig{})
+
+	// Case 1: a tagged coin joins the leaderboard with its score
+	mock.ExpectSAdd("meme:meme_coin_tags:1", "dogs").SetVal(1)
+	mock.ExpectZAdd("meme:tag_leaderboard:dogs", redis.Z{Score: 42, Member: "1"}).SetVal(1)
+	assert.NoError(t, leaderboards.Add(ctx, "dogs", 1, 42))
+
+	// Case 2: the highest scores come first
+	mock.ExpectZRevRangeWithScores("meme:tag_leaderboard:dogs", 0, 1).SetVal([]redis.Z{{Score: 45, Member: "1"}, {Score: 7, Member: "4"}})
+	entries, err := leaderboards.Top(ctx, "dogs", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []repositories.LeaderboardEntry{{Id: 1, PopularityScore: 45}, {Id: 4, PopularityScore: 7}}, entries)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTagLeaderboardScoreWrites(t *testing.T) {
+	ctx := context.Background()
+	dbmock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbmock.Close()
+	mockRedisClient, mock := redismock.NewClientMock()
+	defer mockRedisClient.Close()
+	// Scores are written in map order, the leaderboards in id order
+	mock.MatchExpectationsInOrder(false)
+
+	redisCachedRepository := repositories.NewRedisCachedRepository(dbmock, mockRedisClient, repositories.RepositoryConfig{
+		NeedToSync:      false,
+		TagLeaderboards: repositories.NewTagLeaderboardRepository(mockRedisClient, repositories.TagLeaderboardConfig{}),
+	})
+
+	// Case 1: the tags are read in the pipeline of the pokes, which reach the leaderboards of every tag of the coin
+	mock.ExpectHIncrBy(unsyncedKey, "meme:popularity_score:1", 1).SetVal(1)
+	mock.ExpectHIncrBy(unsyncedKey, "meme:popularity_score:2", 1).SetVal(1)
+	mock.ExpectIncrBy("meme:popularity_score:1", 3).SetVal(45)
+	mock.ExpectIncrBy("meme:popularity_score:2", 1).SetVal(8)
+	mock.ExpectSMembers("meme:meme_coin_tags:1").SetVal([]string{"dogs", "cute"})
+	mock.ExpectSMembers("meme:meme_coin_tags:2").SetVal([]string{})
+	mock.ExpectZIncrBy("meme:tag_leaderboard:cute", 3, "1").SetVal(3)
+	mock.ExpectZIncrBy("meme:tag_leaderboard:dogs", 3, "1").SetVal(45)
+	assert.NoError(t, redisCachedRepository.IncrByMany(ctx, map[string]int{"meme:popularity_score:1": 3, "meme:popularity_score:2": 1}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Case 2: a failed leaderboard write is only logged, retrying the pokes would count them twice
+	mock.ExpectHIncrBy(unsyncedKey, "meme:popularity_score:1", 1).SetVal(2)
+	mock.ExpectIncrBy("meme:popularity_score:1", 1).SetVal(46)
+	mock.ExpectSMembers("meme:meme_coin_tags:1").SetVal([]string{"dogs"})
+	mock.ExpectZIncrBy("meme:tag_leaderboard:dogs", 1, "1").SetErr(errors.New("redis: connection reset"))
+	assert.NoError(t, redisCachedRepository.IncrBy(ctx, "meme:popularity_score:1", 1))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Case 3: a failed score write leaves the leaderboards alone and takes back its mark
+	mock.ExpectHIncrBy(unsyncedKey, "meme:popularity_score:1", 1).SetVal(3)
+	mock.ExpectIncrBy("meme:popularity_score:1", 1).SetErr(errors.New("redis: connection reset"))
+	mock.ExpectEval(markSyncedScript, []string{unsyncedKey}, "meme:popularity_score:1", 1).SetVal(int64(0))
+	assert.Error(t, redisCachedRepository.IncrBy(ctx, "meme:popularity_score:1", 1))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Case 4: set scores overwrite the ones on the leaderboards
+	mock.ExpectSet("meme:popularity_score:1", 7, 0).SetVal("OK")
+	mock.ExpectSMembers("meme:meme_coin_tags:1").SetVal([]string{"dogs"})
+	mock.ExpectZAdd("meme:tag_leaderboard:dogs", redis.Z{Score: 7, Member: "1"}).SetVal(0)
+	assert.NoError(t, redisCachedRepository.Set(ctx, "meme:popularity_score:1", 7))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Case 5: a deleted coin leaves every leaderboard
+	mock.ExpectDel("meme:popularity_score:1").SetVal(1)
+	mock.ExpectSMembers("meme:meme_coin_tags:1").SetVal([]string{"dogs", "cute"})
+	mock.ExpectZRem("meme:tag_leaderboard:cute", "1").SetVal(1)
+	mock.ExpectZRem("meme:tag_leaderboard:dogs", "1").SetVal(1)
+	mock.ExpectDel("meme:meme_coin_tags:1").SetVal(1)
+	assert.NoError(t, redisCachedRepository.Delete(ctx, "meme:popularity_score:1"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTagLeaderboardWarmUp(t *testing.T) {
+	ctx := context.Background()
+	dbmock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbmock.Close()
+	mockRedisClient, mock := redismock.NewClientMock()
+	defer mockRedisClient.Close()
+
+	leaderboards := repositories.NewTagLeaderboardRepository(mockRedisClient, repositories.TagLeaderboardConfig{})
+	redisCachedRepository := repositories.NewRedisCachedRepository(dbmock, mockRedisClient, repositories.RepositoryConfig{
+		NeedToSync:      false,
+		TagLeaderboards: leaderboards,
+	})
+	tagRepository := repositories.NewTagRepository(dbmock)
+	streamSql := regexp.QuoteMeta("SELECT meme_coin_tags.meme_coin_id, tags.name, meme_coins.popularity_score")
+
+	// Case 1: the leaderboards are built aside with the scores in Redis and renamed over the live ones, stale keys are deleted
+	mock.ExpectScan(0, "meme:tag_leaderboard_rebuild:*", int64(repositories.DefaultWarmUpPageSize)).SetVal([]string{"meme:tag_leaderboard_rebuild:dogs"}, 0)
+	mock.ExpectScan(0, "meme:meme_coin_tags_rebuild:*", int64(repositories.DefaultWarmUpPageSize)).SetVal([]string{}, 0)
+	mock.ExpectDel("meme:tag_leaderboard_rebuild:dogs").SetVal(1)
+	dbmock.ExpectQuery(streamSql).WillReturnRows(
+		pgxmock.NewRows([]string{"meme_coin_id", "name", "popularity_score"}).
+			AddRow(1, "dogs", 40).
+			AddRow(1, "cute", 40).
+			AddRow(2, "dogs", 5),
+	)
+	mock.ExpectMGet("meme:popularity_score:1", "meme:popularity_score:1", "meme:popularity_score:2").SetVal([]interface{}{"45", "45", nil})
+	mock.ExpectSAdd("meme:meme_coin_tags_rebuild:1", "dogs").SetVal(1)
+	mock.ExpectZAdd("meme:tag_leaderboard_rebuild:dogs", redis.Z{Score: 45, Member: "1"}).SetVal(1)
+	mock.ExpectSAdd("meme:meme_coin_tags_rebuild:1", "cute").SetVal(1)
+	mock.ExpectZAdd("meme:tag_leaderboard_rebuild:cute", redis.Z{Score: 45, Member: "1"}).SetVal(1)
+	mock.ExpectSAdd("meme:meme_coin_tags_rebuild:2", "dogs").SetVal(1)
+	mock.ExpectZAdd("meme:tag_leaderboard_rebuild:dogs", redis.Z{Score: 5, Member: "2"}).SetVal(1)
+	mock.ExpectScan(0, "meme:tag_leaderboard:*", int64(repositories.DefaultWarmUpPageSize)).SetVal([]string{"meme:tag_leaderboard:dogs", "meme:tag_leaderboard:cats"}, 0)
+	mock.ExpectScan(0, "meme:meme_coin_tags:*", int64(repositories.DefaultWarmUpPageSize)).SetVal([]string{"meme:meme_coin_tags:1", "meme:meme_coin_tags:3"}, 0)
+	mock.ExpectRename("meme:tag_leaderboard_rebuild:cute", "meme:tag_leaderboard:cute").SetVal("OK")
+	mock.ExpectRename("meme:tag_leaderboard_rebuild:dogs", "meme:tag_leaderboard:dogs").SetVal("OK")
+	mock.ExpectRename("meme:meme_coin_tags_rebuild:1", "meme:meme_coin_tags:1").SetVal("OK")
+	mock.ExpectRename("meme:meme_coin_tags_rebuild:2", "meme:meme_coin_tags:2").SetVal("OK")
+	mock.ExpectDel("meme:meme_coin_tags:3").SetVal(1)
+	mock.ExpectDel("meme:tag_leaderboard:cats").SetVal(1)
+	loaded, err := leaderboards.WarmUp(ctx, tagRepository, redisCachedRepository)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, loaded)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// Case 2: a failed database read leaves the live keys alone
+	mock.ExpectScan(0, "meme:tag_leaderboard_rebuild:*", int64(repositories.DefaultWarmUpPageSize)).SetVal([]string{}, 0)
+	mock.ExpectScan(0, "meme:meme_coin_tags_rebuild:*", int64(repositories.DefaultWarmUpPageSize)).SetVal([]string{}, 0)
+	dbmock.ExpectQuery(streamSql).WillReturnError(errors.New("connection refused"))
+	_, err = leaderboards.WarmUp(ctx, tagRepository, redisCachedRepository)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestTagRoutes(t *testing.T) {
+	leaderboards := &mocks.MockTagLeaderboardRepository{}
+	memeCoinService := services.NewMemeCoinService(&mocks.MockMemeCoinRepository{}, &mocks.MockRedisCachedRepository{Leaderboards: leaderboards}, nil, nil)
+	tagService := services.NewTagService(&mocks.MockTagRepository{}, &mocks.MockMemeCoinRepository{}, &mocks.MockRedisCachedRepository{}, leaderboards, nil, nil)
+	router := routes.NewRouter(handlers.NewMemeCoinHandler(memeCoinService, nil), routes.RouterConfig{
+		Authentication: mocks.MockAuthentication{},
+		Tags:           handlers.NewTagHandler(tagService, nil),
+	})
+	send := func(method string, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: tags are lowercased, invalid names are refused
+	w := send("PUT", "/v1/meme-coin/1/tags/Dogs", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"dogs"`)
+	w = send("PUT", "/v1/meme-coin/1/tags/dogs%20and%20cats", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	send("PUT", "/v1/meme-coin/2/tags/dogs", "")
+	send("PUT", "/v1/meme-coin/3/tags/dogs", "")
+	send("PUT", "/v1/meme-coin/3/tags/cats", "")
+
+	// Case 2: tags are counted, most used first
+	w = send("GET", "/v1/tags", "")
+	var listTagsResponse handlers.ListTagsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listTagsResponse))
+	assert.Equal(t, []string{"dogs", "cats"}, []string{listTagsResponse.Tags[0].Name, listTagsResponse.Tags[1].Name})
+	assert.Equal(t, []int{3, 1}, []int{listTagsResponse.Tags[0].Count, listTagsResponse.Tags[1].Count})
+
+	// Case 3: coins are listed by tag page by page
+	w = send("GET", "/v1/tags/dogs/meme-coins?limit=2", "")
+	var page services.MemeCoinsByTagPage
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.MemeCoins, 2)
+	assert.Equal(t, 2, *page.NextAfter)
+	w = send("GET", "/v1/tags/dogs/meme-coins?limit=2&after=2", "")
+	page = services.MemeCoinsByTagPage{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 3, page.MemeCoins[0].Id)
+	assert.Nil(t, page.NextAfter)
+
+	// Case 4: pokes move the coin up the leaderboards of its tags only
+	send("POST", "/v1/meme-coin/2/poke", "")
+	send("POST", "/v1/meme-coin/pokes", `{"pokes": {"3": 2}}`)
+	w = send("GET", "/v1/tags/dogs/leaderboard?limit=2", "")
+	var leaderboard handlers.TagLeaderboardResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &leaderboard))
+	assert.Equal(t, []int{3, 2}, []int{leaderboard.Entries[0].Id, leaderboard.Entries[1].Id})
+	assert.Equal(t, []int{mocks.MockPopularityScore + 2, mocks.MockPopularityScore + 1}, []int{leaderboard.Entries[0].PopularityScore, leaderboard.Entries[1].PopularityScore})
+	assert.Equal(t, []int{1, 2}, []int{leaderboard.Entries[0].Rank, leaderboard.Entries[1].Rank})
+
+	// Case 5: removed tags and deleted coins leave the leaderboard
+	w = send("DELETE", "/v1/meme-coin/3/tags/dogs", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = send("DELETE", "/v1/meme-coin/3/tags/dogs", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	send("DELETE", "/v1/meme-coin/2", "")
+	w = send("GET", "/v1/tags/dogs/leaderboard", "")
+	leaderboard = handlers.TagLeaderboardResponse{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &leaderboard))
+	assert.Len(t, leaderboard.Entries, 1)
+	assert.Equal(t, 1, leaderboard.Entries[0].Id)
+
+	// Case 6: tags are filtered by the category they are filed under
+	w = send("POST", "/admin/categories", `{"name": "animals", "description": "Coins named after animals"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send("POST", "/admin/categories", `{"name": "animals"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = send("PUT", "/admin/tags/dogs/category", `{"category": "animals"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"category":"animals"`)
+	w = send("PUT", "/admin/tags/dogs/category", `{"category": "plants"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send("GET", "/v1/tags?category=animals", "")
+	listTagsResponse = handlers.ListTagsResponse{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listTagsResponse))
+	assert.Len(t, listTagsResponse.Tags, 1)
+	assert.Equal(t, "dogs", listTagsResponse.Tags[0].Name)
+
+	// Case 7: deleting a category leaves its tags without one
+	w = send("DELETE", "/admin/categories/animals", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send("GET", "/v1/meme-coin/1/tags", "")
+	assert.Contains(t, w.Body.String(), `"category":null`)
+
+	// Case 8: the category routes are not served without authentication
+	unauthenticatedRouter := routes.NewRouter(handlers.NewMemeCoinHandler(memeCoinService, nil), routes.RouterConfig{
+		Tags: handlers.NewTagHandler(tagService, nil),
+	})
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/admin/categories", strings.NewReader(`{"name": "plants"}`))
+	unauthenticatedRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/tags", nil)
+	unauthenticatedRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
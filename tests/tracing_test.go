@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"portto-assignment/internal/handlers"
+	"portto-assignment/internal/logging"
+	"portto-assignment/internal/middlewares"
+	"portto-assignment/internal/repositories"
+	"portto-assignment/internal/routes"
+	"portto-assignment/internal/services"
+	"portto-assignment/internal/tracing"
+	"portto-assignment/tests/mocks"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans registers a tracer provider keeping the ended spans in memory until the test ends
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	return recorder
+}
+
+// findSpan returns the ended span with the given name
+func findSpan(spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+
+	return nil
+}
+
+func TestTracer(t *testing.T) {
+	t.Run("ParseExporter", func(t *testing.T) {
+		// Case 1: no exporter by default
+		exporter, err := tracing.ParseExporter("")
+		assert.NoError(t, err)
+		assert.Equal(t, tracing.ExporterNone, exporter)
+
+		// Case 2: names are case insensitive
+		exporter, err = tracing.ParseExporter("OTLP")
+		assert.NoError(t, err)
+		assert.Equal(t, tracing.ExporterOTLP, exporter)
+
+		// Case 3: unknown names
+		_, err = tracing.ParseExporter("jaeger")
+		assert.Error(t, err)
+	})
+
+	t.Run("FileExporter", func(t *testing.T) {
+		previousProvider := otel.GetTracerProvider()
+		defer otel.SetTracerProvider(previousProvider)
+
+		// Case 1: the file exporter needs a path
+		_, err := tracing.NewTracerProvider(context.Background(), tracing.TracerConfig{Exporter: tracing.ExporterFile})
+		assert.Error(t, err)
+
+		// Case 2: spans are written to the file once flushed
+		path := t.TempDir() + "/spans.json"
+		provider, err := tracing.NewTracerProvider(context.Background(), tracing.TracerConfig{
+			Exporter: tracing.ExporterFile,
+			FilePath: path,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, span := tracing.Tracer().Start(context.Background(), "Exported")
+		span.End()
+		assert.NoError(t, provider.Shutdown(context.Background()))
+		assert.FileExists(t, path)
+	})
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := recordSpans(t)
+	memeCoinService := services.NewMemeCoinService(&mocks.MockMemeCoinRepository{}, &mocks.MockRedisCachedRepository{}, nil, nil)
+	router := routes.NewRouter(handlers.NewMemeCoinHandler(memeCoinService, nil), routes.RouterConfig{
+		Tracing:   middlewares.NewTracingMiddleware(tracing.DefaultServiceName),
+		RequestID: middlewares.NewRequestIDMiddleware(),
+	})
+
+	// Case 1: the server span continues the trace of the traceparent header
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/meme-coin/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(middlewares.RequestIDHeader, "traced-1")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	spans := recorder.Ended()
+	serverSpan := findSpan(spans, "/v1/meme-coin/:id")
+	if assert.NotNil(t, serverSpan) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", serverSpan.SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", serverSpan.Parent().SpanID().String())
+		assert.Equal(t, trace.SpanKindServer, serverSpan.SpanKind())
+		assert.Contains(t, serverSpan.Attributes(), attribute.String("http.request_id", "traced-1"))
+	}
+
+	// Case 2: the service method is a child of the server span
+	serviceSpan := findSpan(spans, "MemeCoinService.GetMemeCoin")
+	if assert.NotNil(t, serviceSpan) && serverSpan != nil {
+		assert.Equal(t, serverSpan.SpanContext().SpanID(), serviceSpan.Parent().SpanID())
+		assert.Equal(t, serverSpan.SpanContext().TraceID(), serviceSpan.SpanContext().TraceID())
+	}
+
+	// Case 3: a request without traceparent starts a new trace
+	recorder.Reset()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/meme-coin/1", nil)
+	router.ServeHTTP(w, req)
+	serverSpan = findSpan(recorder.Ended(), "/v1/meme-coin/:id")
+	if assert.NotNil(t, serverSpan) {
+		assert.False(t, serverSpan.Parent().IsValid())
+	}
+}
+
+func TestQueryTracer(t *testing.T) {
+	recorder := recordSpans(t)
+	queryTracer := tracing.NewQueryTracer()
+
+	// Case 1: the span is named after the SQL command and carries the statement
+	ctx := queryTracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "\n\t\tselect id FROM meme_coins WHERE id = $1"})
+	queryTracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1")})
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "postgres SELECT", spans[0].Name())
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("db.statement", "select id FROM meme_coins WHERE id = $1"))
+
+	// Case 2: failed statements set the error status
+	recorder.Reset()
+	ctx = queryTracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "UPDATE meme_coins SET description = $2 WHERE id = $1"})
+	queryTracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("connection reset")})
+	spans = recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "postgres UPDATE", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+}
+
+func TestLoggerTraceContext(t *testing.T) {
+	recordSpans(t)
+	var output bytes.Buffer
+	logger := logging.NewLogger(logging.LoggerConfig{Output: &output})
+
+	// Case 1: records written inside a span carry its trace and span ids
+	ctx, span := tracing.Tracer().Start(context.Background(), "Logged")
+	logger.InfoContext(ctx, "Hello")
+	span.End()
+	lines := logLines(t, &output)
+	assert.Len(t, lines, 1)
+	assert.Equal(t, span.SpanContext().TraceID().String(), lines[0][logging.TraceIDAttribute])
+	assert.Equal(t, span.SpanContext().SpanID().String(), lines[0][logging.SpanIDAttribute])
+
+	// Case 2: records outside of a span have none
+	logger.Info("Hello")
+	lines = logLines(t, &output)
+	assert.Len(t, lines, 1)
+	assert.NotContains(t, lines[0], logging.TraceIDAttribute)
+}
+
+func TestBufferedFlushSpan(t *testing.T) {
+	recorder := recordSpans(t)
+	bufferedRepository := repositories.NewBufferedRedisRepository(&mocks.MockMemoryRedisRepository{}, repositories.BufferConfig{
+		FlushInterval: time.Hour,
+	})
+	defer bufferedRepository.Close()
+
+	// Case 1: the flush is a trace of its own, linked to the requests whose pokes it carries
+	ctx, pokeSpan := tracing.Tracer().Start(context.Background(), "Poke")
+	assert.NoError(t, bufferedRepository.IncrBy(ctx, "meme:popularity_score:1", 1))
+	pokeSpan.End()
+	assert.NoError(t, bufferedRepository.Flush())
+
+	flushSpan := findSpan(recorder.Ended(), "BufferedRedisRepository.Flush")
+	if assert.NotNil(t, flushSpan) {
+		assert.False(t, flushSpan.Parent().IsValid())
+		assert.NotEqual(t, pokeSpan.SpanContext().TraceID(), flushSpan.SpanContext().TraceID())
+		assert.Len(t, flushSpan.Links(), 1)
+		assert.Equal(t, pokeSpan.SpanContext(), flushSpan.Links()[0].SpanContext)
+	}
+}